@@ -5,14 +5,17 @@ import (
 	"os"
 	"strings"
 
+	"mychain/core"
 	"mychain/node"
 )
 
 func main() {
-	// 简单解析命令行参数：--port 和 --peers
+	// 简单解析命令行参数：--port、--peers、--network、--chainspec、--ledger 和 --signer-key
 	args := os.Args[1:]
 	var port string
 	var peers []string
+	var network, chainspec, signerKey string
+	var ledger core.LedgerMode
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -26,17 +29,46 @@ func main() {
 				peers = strings.Split(args[i+1], ",")
 				i++
 			}
+		case "--network":
+			if i+1 < len(args) {
+				network = args[i+1]
+				i++
+			}
+		case "--chainspec":
+			if i+1 < len(args) {
+				chainspec = args[i+1]
+				i++
+			}
+		case "--signer-key":
+			if i+1 < len(args) {
+				signerKey = args[i+1]
+				i++
+			}
+		case "--ledger":
+			if i+1 < len(args) {
+				mode, err := core.ParseLedgerMode(args[i+1])
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				ledger = mode
+				i++
+			}
 		}
 	}
 
 	if port == "" {
-		fmt.Println("用法: go run ./cmd/node --port 8001 [--peers http://localhost:8002,http://localhost:8003]")
+		fmt.Println("用法: go run ./cmd/node --port 8001 [--peers http://localhost:8002,http://localhost:8003] [--network mainnet|testnet|regtest | --chainspec <文件>] [--ledger account|utxo] [--signer-key <PoA / PoS / BFT 签名者私钥>]")
 		return
 	}
 
 	cfg := node.Config{
-		Port:  port,
-		Peers: peers,
+		Port:      port,
+		Peers:     peers,
+		Network:   network,
+		ChainSpec: chainspec,
+		Ledger:    ledger,
+		SignerKey: signerKey,
 	}
 
 	n, err := node.NewNode(cfg)
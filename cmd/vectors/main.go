@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"mychain/core"
+	"mychain/core/bytecode"
+	"mychain/core/script"
+	"mychain/core/vm"
+	"mychain/utils"
+)
+
+// 生成区块头 / 交易规范二进制编码的测试向量（docs/vectors.json），
+// 供其他语言的客户端核对自己的编码与哈希实现：
+//
+//	go run ./cmd/vectors > docs/vectors.json
+
+type headerVector struct {
+	Name     string            `json:"name"`
+	Header   *core.BlockHeader `json:"header"`
+	Encoding string            `json:"encoding"` // hex
+	Hash     string            `json:"hash"`     // hex，SHA256(header)，即 encoding 去掉末尾的 seal
+}
+
+type txVector struct {
+	Name     string           `json:"name"`
+	Tx       core.Transaction `json:"tx"`
+	Body     string           `json:"body"`     // hex，参与哈希和签名的 txBody
+	Encoding string           `json:"encoding"` // hex，txBody + 公钥 + 签名 + 多签 / 脚本
+	Hash     string           `json:"hash"`     // hex，SHA256(body)
+}
+
+func main() {
+	ts := time.Unix(1700000000, 123456789).UTC()
+
+	genesis := core.DefaultParams.GenesisBlock()
+	other := &core.BlockHeader{
+		Version:      core.BlockVersion,
+		Height:       1,
+		PreviousHash: genesis.Header.Hash,
+		MerkleRoot:   utils.Sha256([]byte("merkle")),
+		StateRoot:    utils.Sha256([]byte("state")),
+		Timestamp:    time.Unix(1700000010, 0).UTC(),
+		Bits:         0x1e7fffff,
+		Nonce:        42,
+	}
+	// PoA 区块头：带签名者公钥和签名（这里用示意字节，不是真实签名）
+	poa := &core.BlockHeader{
+		Version:      core.BlockVersion,
+		Height:       1,
+		PreviousHash: genesis.Header.Hash,
+		MerkleRoot:   utils.Sha256([]byte("merkle")),
+		StateRoot:    utils.Sha256([]byte("state")),
+		Timestamp:    time.Unix(1700000010, 0).UTC(),
+		Bits:         2,
+		Signer:       []byte{0x04, 0x05, 0x06},
+		Seal:         []byte{0x30, 0x07, 0x08},
+	}
+	headers := []struct {
+		name   string
+		header *core.BlockHeader
+	}{
+		{"genesis", genesis.Header},
+		{"header after genesis", other},
+		{"poa header", poa},
+	}
+
+	multisig := &core.MultiSig{
+		Threshold: 2,
+		PubKeys:   [][]byte{{0x04, 0x01}, {0x04, 0x02}, {0x04, 0x03}},
+		Sigs:      [][]byte{{0x30, 0x01}, nil, {0x30, 0x03}},
+	}
+
+	// 哈希锁：解锁脚本给出原像 "secret"
+	redeem := bytecode.PushData(utils.Sha256([]byte("secret")))
+	redeem = append([]byte{script.OP_SHA256}, redeem...)
+	redeem = append(redeem, script.OP_EQUAL)
+	hashLock := &core.ScriptSpend{Redeem: redeem, Unlock: bytecode.PushData([]byte("secret"))}
+
+	// 计数器合约的部署与调用
+	counter, _ := vm.Assemble("OP_DROP 'count' OP_DUP OP_SLOAD 1 OP_ADD OP_SSTORE OP_STOP")
+	incArgs := bytecode.PushData([]byte("inc"))
+
+	txs := []struct {
+		name string
+		tx   core.Transaction
+	}{
+		{"account transfer", core.Transaction{
+			From: "alice", To: "bob", Value: 30, Fee: 2, Nonce: 7, Timestamp: ts,
+		}},
+		{"account coinbase", core.Transaction{
+			From: core.CoinbaseFrom, To: "miner", Value: 52, Nonce: 1, Timestamp: ts,
+		}},
+		{"utxo transfer", core.Transaction{
+			From:      "alice",
+			Fee:       1,
+			Timestamp: ts,
+			Inputs:    []core.TxInput{{TxHash: utils.Sha256([]byte("prev")), Index: 1}},
+			Outputs:   []core.TxOutput{{To: "bob", Value: 20}, {To: "alice", Value: 29}},
+		}},
+		{"signed transfer (dummy pubkey / sig bytes)", core.Transaction{
+			From: "alice", To: "bob", Value: 1, Nonce: 0, Timestamp: ts,
+			PubKey: []byte{0x04, 0x01, 0x02, 0x03},
+			Sig:    []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02},
+		}},
+		{"2-of-3 multisig transfer (dummy pubkey / sig bytes)", core.Transaction{
+			From: multisig.Address(), To: "bob", Value: 5, Nonce: 1, Timestamp: ts,
+			MultiSig: multisig,
+		}},
+		{"hash-lock script spend, locked until height 100", core.Transaction{
+			From: core.ScriptAddress(redeem), To: "bob", Value: 3, Nonce: 0, Timestamp: ts, LockTime: 100,
+			Script: hashLock,
+		}},
+		{"contract deploy", core.Transaction{
+			From: "alice", Fee: 1, Nonce: 8, Timestamp: ts,
+			Kind: core.TxDeploy, Payload: counter, GasLimit: 1000,
+		}},
+		{"contract call", core.Transaction{
+			From: "bob", To: core.ContractAddress("alice", 8), Value: 2, Fee: 1, Nonce: 0, Timestamp: ts,
+			Kind: core.TxCall, Payload: incArgs, GasLimit: 500,
+		}},
+		{"token issue", core.Transaction{
+			From: "alice", Fee: 1, Nonce: 9, Timestamp: ts,
+			Kind: core.TxIssue, Token: &core.TokenSpec{Symbol: "GOLD", Decimals: 2, Supply: 1000000},
+		}},
+		{"token transfer", core.Transaction{
+			From: "alice", To: "bob", Value: 2500, Fee: 1, Nonce: 10, Timestamp: ts,
+			Asset: core.AssetID("alice", 9),
+		}},
+		{"notarization", core.Transaction{
+			From: "alice", To: "alice", Fee: 1, Nonce: 11, Timestamp: ts,
+			Data: core.NotaryData(utils.Sha256([]byte("hello, notary"))),
+		}},
+		{"stake", core.Transaction{
+			From: "alice", To: "alice", Value: 100, Fee: 1, Nonce: 12, Timestamp: ts,
+			Kind: core.TxStake,
+		}},
+		{"unstake", core.Transaction{
+			From: "alice", Value: 40, Fee: 1, Nonce: 13, Timestamp: ts,
+			Kind: core.TxUnstake,
+		}},
+	}
+
+	out := struct {
+		Version      byte           `json:"version"`
+		Headers      []headerVector `json:"headers"`
+		Transactions []txVector     `json:"transactions"`
+	}{Version: core.EncodingVersion}
+
+	for _, c := range headers {
+		h := c.header
+		enc, _ := h.MarshalBinary()
+		var decoded core.BlockHeader
+		decoded.UnmarshalBinary(enc)
+		h.Hash = decoded.Hash
+		out.Headers = append(out.Headers, headerVector{
+			Name:     c.name,
+			Header:   h,
+			Encoding: utils.ToHex(enc),
+			Hash:     utils.ToHex(h.Hash),
+		})
+	}
+
+	for _, c := range txs {
+		tx := c.tx
+		tx.CalculateHash()
+		enc, _ := tx.MarshalBinary()
+		out.Transactions = append(out.Transactions, txVector{
+			Name:     c.name,
+			Tx:       tx,
+			Body:     utils.ToHex(tx.SigningPayload()),
+			Encoding: utils.ToHex(enc),
+			Hash:     utils.ToHex(tx.Hash),
+		})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, "生成测试向量失败:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// vectorFile 对应 docs/vectors.json 的结构
+type vectorFile struct {
+	Version      byte           `json:"version"`
+	Headers      []headerVector `json:"headers"`
+	Transactions []txVector     `json:"transactions"`
+}
+
+// runMain 运行 main，返回它写到标准输出的内容
+func runMain(t *testing.T) []byte {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = stdout }()
+	main()
+
+	out, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// docs/vectors.json 必须与当前编码实现生成的结果逐字节一致；
+// 有意修改编码后运行 go run ./cmd/vectors > docs/vectors.json 更新
+func TestVectorsUpToDate(t *testing.T) {
+	want, err := os.ReadFile("../../docs/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(runMain(t), want) {
+		t.Fatal("docs/vectors.json is out of date: run go run ./cmd/vectors > docs/vectors.json")
+	}
+}
+
+// 每个向量的编码都能解码回来，并得到同样的哈希
+func TestVectorsDecode(t *testing.T) {
+	var v vectorFile
+	if err := json.Unmarshal(runMain(t), &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, hv := range v.Headers {
+		enc, _ := hex.DecodeString(hv.Encoding)
+		var h core.BlockHeader
+		if err := h.UnmarshalBinary(enc); err != nil {
+			t.Fatalf("%s: %v", hv.Name, err)
+		}
+		if utils.ToHex(h.Hash) != hv.Hash {
+			t.Fatalf("%s: decoded hash %x, want %s", hv.Name, h.Hash, hv.Hash)
+		}
+	}
+	for _, tv := range v.Transactions {
+		enc, _ := hex.DecodeString(tv.Encoding)
+		var tx core.Transaction
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			t.Fatalf("%s: %v", tv.Name, err)
+		}
+		if utils.ToHex(tx.Hash) != tv.Hash {
+			t.Fatalf("%s: decoded hash %x, want %s", tv.Name, tx.Hash, tv.Hash)
+		}
+		if utils.ToHex(tx.SigningPayload()) != tv.Body {
+			t.Fatalf("%s: decoded body does not match", tv.Name)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+
+	"mychain/core"
+	"mychain/core/bytecode"
+	"mychain/core/vm"
+	"mychain/utils"
+)
+
+// 合约流程：
+//  1. 用 contract-deploy 部署合约代码（汇编文件，见 docs/contracts/），得到合约地址
+//  2. 用 contract-call 发送调用交易，修改合约状态；交易上链后用 contract-receipt 查看执行结果
+//  3. 用 contract-query 只读地调用合约（不上链、不花钱），查询合约状态
+
+// 部署合约
+func cmdContractDeploy() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	codePath := flag.String("code", "", "合约代码文件（汇编）")
+	value := flag.Uint("value", 0, "部署时转入合约的金额")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包；默认为 gas 上限所需的最低手续费")
+	gas := flag.Uint64("gas", 0, "gas 上限，默认为部署所需的固定消耗")
+	flag.Parse()
+
+	src, err := os.ReadFile(*codePath)
+	if err != nil {
+		return fmt.Errorf("读取合约代码失败: %w", err)
+	}
+	code, err := vm.Assemble(string(src))
+	if err != nil {
+		return fmt.Errorf("编译合约失败: %w", err)
+	}
+
+	tx := core.Transaction{Kind: core.TxDeploy, Payload: code}
+	if *gas == 0 {
+		*gas = tx.IntrinsicGas()
+	}
+	if err := sendContractTx(*nodeURL, *skPath, &tx, "", uint64(*value), uint32(*fee), *gas); err != nil {
+		return err
+	}
+	fmt.Println("合约地址:", core.ContractAddress(tx.From, tx.Nonce))
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// 发送调用合约的交易
+func cmdContractCall() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	addr := flag.String("addr", "", "合约地址")
+	method := flag.String("method", "", "方法名")
+	args := flag.String("args", "", "方法参数（汇编，只能压入数据），例如 \"'alice' 42\"")
+	value := flag.Uint("value", 0, "转入合约的金额")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包；默认为 gas 上限所需的最低手续费")
+	gas := flag.Uint64("gas", 10000, "gas 上限，执行超出则失败（手续费照付）")
+	flag.Parse()
+
+	if *addr == "" || *method == "" {
+		return fmt.Errorf("必须指定 --addr 合约地址和 --method 方法名")
+	}
+	input, err := vm.Assemble(*args)
+	if err != nil {
+		return fmt.Errorf("编译参数失败: %w", err)
+	}
+	input = append(input, bytecode.PushData([]byte(*method))...)
+
+	tx := core.Transaction{Kind: core.TxCall, Payload: input}
+	if err := sendContractTx(*nodeURL, *skPath, &tx, *addr, uint64(*value), uint32(*fee), *gas); err != nil {
+		return err
+	}
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash), "（上链后用 contract-receipt 查看执行结果）")
+	return nil
+}
+
+// sendContractTx 补全合约交易的发送方、nonce、金额等字段，签名后发送到节点。
+// fee 为 0 时按节点的 gas 价格取 gas 上限所需的最低手续费
+func sendContractTx(nodeURL, skPath string, tx *core.Transaction, to string, value uint64, fee uint32, gas uint64) error {
+	if fee == 0 {
+		gasPrice, err := fetchGasPrice(nodeURL)
+		if err != nil {
+			return fmt.Errorf("查询 gas 价格失败: %w", err)
+		}
+		need := core.GasFee(gasPrice, gas)
+		if need > math.MaxUint32 {
+			return fmt.Errorf("gas 上限 %d 所需的手续费 %d 超出范围", gas, need)
+		}
+		fee = uint32(need)
+	}
+
+	priv, err := loadPrivKey(skPath)
+	if err != nil {
+		return fmt.Errorf("加载私钥失败: %w", err)
+	}
+	pubBytes, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return fmt.Errorf("导出公钥失败: %w", err)
+	}
+
+	base, err := buildTx(nodeURL, utils.PubKeyToAddress(pubBytes), to, value, fee, -1)
+	if err != nil {
+		return err
+	}
+	if base.IsUTXO() {
+		return fmt.Errorf("节点使用 UTXO 模式，不支持合约")
+	}
+	base.Kind, base.Payload, base.GasLimit = tx.Kind, tx.Payload, gas
+	*tx = base
+
+	if err := tx.Sign(priv); err != nil {
+		return fmt.Errorf("签名交易失败: %w", err)
+	}
+	fmt.Println("Gas  :", tx.GasLimit, "手续费:", tx.Fee)
+	return postTx(nodeURL, tx, false)
+}
+
+// 只读调用合约
+func cmdContractQuery() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	addr := flag.String("addr", "", "合约地址")
+	method := flag.String("method", "", "方法名")
+	args := flag.String("args", "", "方法参数（汇编，只能压入数据）")
+	from := flag.String("from", "", "以哪个地址的身份调用（影响 OP_CALLER）")
+	flag.Parse()
+
+	if *addr == "" || *method == "" {
+		return fmt.Errorf("必须指定 --addr 合约地址和 --method 方法名")
+	}
+	q := url.Values{"addr": {*addr}, "method": {*method}, "args": {*args}, "from": {*from}}
+
+	var result struct {
+		Return  string `json:"return"`
+		Num     *int64 `json:"num"`
+		GasUsed uint64 `json:"gasUsed"`
+		Error   string `json:"error"`
+	}
+	if err := getJSON(*nodeURL+"/contract/call?"+q.Encode(), &result); err != nil {
+		return fmt.Errorf("调用合约失败: %w", err)
+	}
+	if result.Error != "" {
+		return fmt.Errorf("合约执行失败（gas %d）: %s", result.GasUsed, result.Error)
+	}
+	fmt.Println("返回值 hex:", result.Return)
+	if result.Num != nil {
+		fmt.Println("返回值数字:", *result.Num)
+	}
+	fmt.Println("消耗 gas  :", result.GasUsed)
+	return nil
+}
+
+// 查看合约交易的执行结果
+func cmdContractReceipt() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	txHash := flag.String("tx", "", "交易哈希（hex）")
+	flag.Parse()
+
+	var r core.Receipt
+	if err := getJSON(*nodeURL+"/contract/receipt?tx="+*txHash, &r); err != nil {
+		return fmt.Errorf("获取收据失败: %w", err)
+	}
+	fmt.Println("区块高度:", r.Height)
+	fmt.Println("合约地址:", r.Contract)
+	fmt.Println("消耗 gas:", r.GasUsed)
+	if r.Return != "" {
+		fmt.Println("返回值  :", r.Return)
+	}
+	if !r.Success {
+		fmt.Println("✘ 执行失败（修改已撤销，转入金额已退回）:", r.Error)
+		return nil
+	}
+	fmt.Println("✔ 执行成功")
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"mychain/core"
@@ -57,6 +58,7 @@ func cmdGen() error {
 	addr := utils.PubKeyToAddress(pubBytes)
 	fmt.Println("生成新的钱包：")
 	fmt.Println("地址 Address :", addr)
+	fmt.Println("公钥 PubKey  :", utils.ToHex(pubBytes), "（创建多签地址时提供给其他人）")
 
 	// 默认保存到当前目录的 wallet_priv.pem
 	path := "wallet_priv.pem"
@@ -75,6 +77,10 @@ func cmdSend() error {
 	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
 	toAddr := flag.String("to", "", "收款方地址（字符串即可）")
 	value := flag.Uint("value", 0, "转账金额 (uint)")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	nonceFlag := flag.Int64("nonce", -1, "交易 nonce，默认自动向节点查询")
+	binaryFlag := flag.Bool("binary", false, "使用规范二进制编码发送交易（默认 JSON）")
+	lockFlag := flag.String("locktime", "", lockTimeUsage)
 
 	flag.Parse()
 
@@ -84,6 +90,10 @@ func cmdSend() error {
 	if *value == 0 {
 		return fmt.Errorf("转账金额必须 > 0")
 	}
+	lockTime, err := parseLockTime(*lockFlag)
+	if err != nil {
+		return err
+	}
 
 	// 1. 加载私钥
 	priv, err := loadPrivKey(*skPath)
@@ -98,13 +108,12 @@ func cmdSend() error {
 	}
 	fromAddr := utils.PubKeyToAddress(pubBytes)
 
-	// 3. 构造交易
-	tx := core.Transaction{
-		From:      fromAddr,
-		To:        *toAddr,
-		Value:     uint32(*value),
-		Timestamp: time.Now(),
+	// 3. 按节点的记账模型构造交易
+	tx, err := buildTx(*nodeURL, fromAddr, *toAddr, uint64(*value), uint32(*fee), *nonceFlag)
+	if err != nil {
+		return err
 	}
+	tx.LockTime = lockTime
 
 	// 4. 用私钥对交易签名（会填充 PubKey、Sig、Hash）
 	if err := tx.Sign(priv); err != nil {
@@ -112,18 +121,97 @@ func cmdSend() error {
 	}
 
 	// 5. 序列化并发送到节点 /newtx
-	jsonBytes, err := jsonMarshalNoEscape(tx)
+	return postTx(*nodeURL, &tx, *binaryFlag)
+}
+
+// buildTx 先问节点用的是哪种记账模型，再构造对应格式、尚未签名的交易：
+// UTXO 模式下从 from 的 UTXO 中选取输入并找零；账户模式下 nonce < 0 时向节点查询下一个可用的 nonce
+func buildTx(nodeURL, from, to string, value uint64, fee uint32, nonceFlag int64) (core.Transaction, error) {
+	ledger, err := fetchLedger(nodeURL)
+	if err != nil {
+		return core.Transaction{}, fmt.Errorf("查询节点记账模型失败: %w", err)
+	}
+
+	tx := core.Transaction{
+		From:      from,
+		Fee:       fee,
+		Timestamp: time.Now(),
+	}
+
+	if ledger == core.LedgerUTXO {
+		// UTXO 模式：选取足够的 UTXO 作为输入，多出来的部分找零给自己
+		utxos, err := fetchUTXOs(nodeURL, from)
+		if err != nil {
+			return core.Transaction{}, fmt.Errorf("查询 UTXO 失败: %w", err)
+		}
+		if err := fillUTXOTx(&tx, utxos, to, value); err != nil {
+			return core.Transaction{}, err
+		}
+		return tx, nil
+	}
+
+	// 账户模式：确定 nonce，没有手动指定时，向节点查询下一个可用的 nonce
+	var nonce uint64
+	if nonceFlag >= 0 {
+		nonce = uint64(nonceFlag)
+	} else {
+		nonce, err = fetchPendingNonce(nodeURL, from)
+		if err != nil {
+			return core.Transaction{}, fmt.Errorf("查询 nonce 失败: %w", err)
+		}
+	}
+	tx.To = to
+	tx.Value = uint32(value)
+	tx.Nonce = nonce
+	return tx, nil
+}
+
+// sendAccountTx 构造账户模式的交易，由 fill 填入交易类型相关的字段（代币、质押等），签名后发送到节点
+func sendAccountTx(nodeURL, skPath, to string, value uint64, fee uint32, fill func(*core.Transaction)) (*core.Transaction, error) {
+	priv, err := loadPrivKey(skPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载私钥失败: %w", err)
+	}
+	pubBytes, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("导出公钥失败: %w", err)
+	}
+
+	tx, err := buildTx(nodeURL, utils.PubKeyToAddress(pubBytes), to, value, fee, -1)
+	if err != nil {
+		return nil, err
+	}
+	if tx.IsUTXO() {
+		return nil, fmt.Errorf("节点使用 UTXO 模式，不支持这种交易")
+	}
+	fill(&tx)
+
+	if err := tx.Sign(priv); err != nil {
+		return nil, fmt.Errorf("签名交易失败: %w", err)
+	}
+	return &tx, postTx(nodeURL, &tx, false)
+}
+
+// postTx 把已签名的交易以 JSON（或规范二进制编码）发送到节点 /newtx
+func postTx(nodeURL string, tx *core.Transaction, binary bool) error {
+	contentType := "application/json"
+	var payload []byte
+	var err error
+	if binary {
+		contentType = "application/octet-stream"
+		payload, err = tx.MarshalBinary()
+	} else {
+		payload, err = jsonMarshalNoEscape(tx)
+	}
 	if err != nil {
 		return fmt.Errorf("序列化交易失败: %w", err)
 	}
 
-	url := *nodeURL + "/newtx"
+	url := nodeURL + "/newtx"
 	fmt.Println("发送交易到:", url)
-	fmt.Println("From:", tx.From)
-	fmt.Println("To  :", tx.To)
-	fmt.Println("Value:", tx.Value)
+	printTx(tx)
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonBytes))
+	resp, err := http.Post(url, contentType, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("发送 HTTP 请求失败: %w", err)
 	}
@@ -136,6 +224,322 @@ func cmdSend() error {
 	return nil
 }
 
+// printTx 打印交易的付款内容
+func printTx(tx *core.Transaction) {
+	fmt.Println("From:", tx.From)
+	if tx.IsUTXO() {
+		for _, in := range tx.Inputs {
+			fmt.Println("In  :", core.OutPoint(in.TxHash, in.Index))
+		}
+		for _, out := range tx.Outputs {
+			fmt.Println("Out :", out.To, out.Value)
+		}
+		fmt.Println("Fee  :", tx.Fee)
+	} else {
+		fmt.Println("To  :", tx.To)
+		fmt.Println("Value:", tx.Value)
+		if tx.Asset != "" {
+			fmt.Println("Asset:", tx.Asset)
+		}
+		if doc := tx.NotarizedHash(); doc != nil {
+			fmt.Println("Notary:", utils.ToHex(doc))
+		} else if len(tx.Data) > 0 {
+			fmt.Println("Data :", utils.ToHex(tx.Data))
+		}
+		if tx.Token != nil {
+			fmt.Println("Token:", tx.Token.Symbol, "发行量", formatUnits(tx.Token.Supply, tx.Token.Decimals))
+		}
+		fmt.Println("Fee  :", tx.Fee)
+		fmt.Println("Nonce:", tx.Nonce)
+	}
+	if tx.LockTime >= core.LockTimeThreshold {
+		fmt.Println("Lock :", time.Unix(int64(tx.LockTime), 0).Format(time.RFC3339), "之后才能被打包")
+	} else if tx.LockTime > 0 {
+		fmt.Println("Lock : 区块高度", tx.LockTime, "起才能被打包")
+	}
+}
+
+const lockTimeUsage = "锁定时间：区块高度（< 500000000），或 Unix 秒 / RFC3339 时间（如 2026-01-02T15:04:05Z），到达前交易不会被打包"
+
+// parseLockTime 解析 --locktime：空表示不锁定，整数原样作为区块高度或 Unix 秒，否则按 RFC3339 时间解析
+func parseLockTime(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return v, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析 --locktime %q: 既不是整数也不是 RFC3339 时间", s)
+	}
+	if t.Unix() < core.LockTimeThreshold {
+		return 0, fmt.Errorf("--locktime 时间 %s 太早", s)
+	}
+	return uint64(t.Unix()), nil
+}
+
+// 只用区块头验证一笔交易已经上链（SPV）：
+// 下载并校验区块头链，再用 /proof 返回的 Merkle 路径证明交易属于其中某个区块
+func cmdVerifyTx() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	txHash := flag.String("tx", "", "交易哈希（hex）")
+	toAddr := flag.String("to", "", "可选：检查交易是否付款给该地址")
+	value := flag.Uint("value", 0, "可选：检查付款金额至少为多少")
+	minConf := flag.Int("confirmations", 1, "至少需要的确认数")
+	network := flag.String("network", "", "节点所在网络：mainnet / testnet / regtest（默认 mainnet）")
+	chainspec := flag.String("chainspec", "", "自定义网络的链配置文件，优先于 --network")
+
+	flag.Parse()
+
+	params, err := core.LoadParams(*network, *chainspec)
+	if err != nil {
+		return err
+	}
+
+	if *txHash == "" {
+		return fmt.Errorf("必须指定 --tx 交易哈希")
+	}
+
+	// 1. 下载区块头并按网络参数校验：创世块、哈希链接、时间戳、难度、POW
+	var headers []*core.BlockHeader
+	if err := getJSON(*nodeURL+"/headers", &headers); err != nil {
+		return fmt.Errorf("获取区块头失败: %w", err)
+	}
+	if err := core.VerifyHeaders(params, headers); err != nil {
+		return fmt.Errorf("区块头校验失败: %w", err)
+	}
+	fmt.Println("区块头校验通过，高度:", len(headers)-1)
+
+	// 2. 获取交易证明，并用本地校验过的区块头验证
+	var proof core.TxProof
+	if err := getJSON(*nodeURL+"/proof?tx="+*txHash, &proof); err != nil {
+		return fmt.Errorf("获取交易证明失败: %w", err)
+	}
+	if utils.ToHex(proof.Tx.Hash) != *txHash {
+		return fmt.Errorf("节点返回的交易哈希与请求不一致")
+	}
+	confirmations, err := core.VerifyTxProof(&proof, headers)
+	if err != nil {
+		return fmt.Errorf("交易证明校验失败: %w", err)
+	}
+	fmt.Println("交易已上链：区块高度", proof.Height, "第", proof.Index, "笔，确认数", confirmations)
+
+	// 3. 检查付款内容
+	if *toAddr != "" {
+		paid := paidTo(&proof.Tx, *toAddr)
+		fmt.Println("付款给", *toAddr, "的金额:", paid)
+		if paid == 0 || paid < uint64(*value) {
+			return fmt.Errorf("交易没有向 %s 支付至少 %d", *toAddr, *value)
+		}
+	}
+	if confirmations < *minConf {
+		return fmt.Errorf("确认数 %d 不足 %d", confirmations, *minConf)
+	}
+
+	fmt.Println("✔ 付款验证通过")
+	return nil
+}
+
+// 查询余额并验证：节点返回最新区块头和账户的状态证明，
+// 钱包先校验区块头链，再用其中的 StateRoot 验证余额与 nonce，不需要信任节点
+func cmdBalance() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	addr := flag.String("addr", "", "要查询的地址")
+	network := flag.String("network", "", "节点所在网络：mainnet / testnet / regtest（默认 mainnet）")
+	chainspec := flag.String("chainspec", "", "自定义网络的链配置文件，优先于 --network")
+
+	flag.Parse()
+
+	params, err := core.LoadParams(*network, *chainspec)
+	if err != nil {
+		return err
+	}
+
+	if *addr == "" {
+		return fmt.Errorf("必须指定 --addr 地址")
+	}
+
+	// 1. 先取余额证明，再取区块头，保证证明对应的区块已包含在区块头链中
+	var result struct {
+		Header *core.BlockHeader   `json:"header"`
+		Proof  *core.AccountProof  `json:"proof"`
+		Assets []core.TokenHolding `json:"assets"`
+	}
+	if err := getJSON(*nodeURL+"/balance?proof=1&addr="+*addr, &result); err != nil {
+		return fmt.Errorf("获取余额证明失败: %w", err)
+	}
+	if result.Header == nil || result.Proof == nil {
+		return fmt.Errorf("节点没有返回余额证明")
+	}
+	if result.Proof.Address != *addr {
+		return fmt.Errorf("节点返回的地址与请求不一致")
+	}
+
+	var headers []*core.BlockHeader
+	if err := getJSON(*nodeURL+"/headers", &headers); err != nil {
+		return fmt.Errorf("获取区块头失败: %w", err)
+	}
+	if err := core.VerifyHeaders(params, headers); err != nil {
+		return fmt.Errorf("区块头校验失败: %w", err)
+	}
+
+	// 2. 证明对应的区块头必须在已校验的区块头链中
+	h := result.Header.Height
+	if h >= uint64(len(headers)) || !bytes.Equal(headers[h].Hash, result.Header.Hash) {
+		return fmt.Errorf("证明对应的区块头不在主链中")
+	}
+
+	// 3. 用本地区块头中的状态根验证账户
+	if err := core.VerifyAccountProof(result.Proof, headers[h].StateRoot); err != nil {
+		return fmt.Errorf("余额证明校验失败: %w", err)
+	}
+
+	fmt.Println("地址   :", *addr)
+	fmt.Println("余额   :", result.Proof.Balance)
+	fmt.Println("nonce  :", result.Proof.Nonce)
+	fmt.Println("区块高度:", h)
+	fmt.Println("✔ 余额证明验证通过")
+	for _, a := range result.Assets {
+		fmt.Printf("代币   : %s %s（资产 ID %s，节点返回，未经证明）\n", formatUnits(uint64(a.Balance), a.Decimals), a.Symbol, a.Asset)
+	}
+	return nil
+}
+
+// paidTo 返回交易支付给 addr 的原生币总金额（账户模式看 To/Value，UTXO 模式累加输出）
+func paidTo(tx *core.Transaction, addr string) uint64 {
+	if !tx.IsUTXO() {
+		if tx.To == addr {
+			return tx.NativeValue()
+		}
+		return 0
+	}
+	var sum uint64
+	for _, out := range tx.Outputs {
+		if out.To == addr {
+			sum += uint64(out.Value)
+		}
+	}
+	return sum
+}
+
+// getJSON 发送 GET 请求并把 JSON 响应解析到 v
+func getJSON(url string, v interface{}) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("节点返回状态码 %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchPendingNonce 调用节点的 /nonce 接口，返回地址下一笔交易应使用的 nonce
+func fetchPendingNonce(nodeURL, addr string) (uint64, error) {
+	resp, err := http.Get(nodeURL + "/nonce?addr=" + addr)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("节点返回状态码 %d", resp.StatusCode)
+	}
+
+	var result struct {
+		PendingNonce uint64 `json:"pendingNonce"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.PendingNonce, nil
+}
+
+// fetchLedger 调用节点的 /stats 接口，返回节点使用的记账模型
+func fetchLedger(nodeURL string) (core.LedgerMode, error) {
+	resp, err := http.Get(nodeURL + "/stats")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ledger core.LedgerMode `json:"ledger"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Ledger == "" {
+		return core.LedgerAccount, nil
+	}
+	return result.Ledger, nil
+}
+
+// fetchGasPrice 调用节点的 /stats 接口，查询合约交易的 gas 价格（见 core.GasFee）
+func fetchGasPrice(nodeURL string) (uint64, error) {
+	resp, err := http.Get(nodeURL + "/stats")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		GasPrice uint64 `json:"gasPrice"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.GasPrice, nil
+}
+
+// fetchUTXOs 调用节点的 /utxos 接口，返回地址当前可花费的 UTXO
+func fetchUTXOs(nodeURL, addr string) ([]core.UTXO, error) {
+	resp, err := http.Get(nodeURL + "/utxos?addr=" + addr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("节点返回状态码 %d", resp.StatusCode)
+	}
+
+	var result struct {
+		UTXOs []core.UTXO `json:"utxos"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.UTXOs, nil
+}
+
+// fillUTXOTx 依次选取 UTXO 直到覆盖 金额 + 手续费，
+// 生成给收款方的输出，以及（如有剩余）找零给自己的输出
+func fillUTXOTx(tx *core.Transaction, utxos []core.UTXO, to string, value uint64) error {
+	need := value + uint64(tx.Fee)
+	var in uint64
+	for _, u := range utxos {
+		if in >= need {
+			break
+		}
+		tx.Inputs = append(tx.Inputs, core.TxInput{TxHash: u.TxHash, Index: u.Index})
+		in += uint64(u.Value)
+	}
+	if in < need {
+		return fmt.Errorf("可用 UTXO 不足：共 %d，需要 %d", in, need)
+	}
+
+	tx.Outputs = []core.TxOutput{{To: to, Value: uint32(value)}}
+	if change := in - need; change > 0 {
+		tx.Outputs = append(tx.Outputs, core.TxOutput{To: tx.From, Value: uint32(change)})
+	}
+	return nil
+}
+
 // 为了避免中文等被转义，写一个简单封装
 func jsonMarshalNoEscape(v interface{}) ([]byte, error) {
 	buf := &bytes.Buffer{}
@@ -149,7 +553,29 @@ func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("用法:")
 		fmt.Println("  生成密钥: go run ./cmd/wallet gen")
-		fmt.Println("  发送交易: go run ./cmd/wallet send --to <地址> --value <金额> [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  发送交易: go run ./cmd/wallet send --to <地址> --value <金额> [--fee <手续费>] [--nonce <n>，仅账户模式] [--locktime <高度或时间>] [--binary] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  查询余额: go run ./cmd/wallet balance --addr <地址> [--node http://localhost:8001] [--network <网络> | --chainspec <文件>]")
+		fmt.Println("  查看公钥: go run ./cmd/wallet pubkey [--sk wallet_priv.pem]")
+		fmt.Println("  多签地址: go run ./cmd/wallet multisig-addr --m <门限> --pubkeys <公钥1>,<公钥2>,...")
+		fmt.Println("  多签付款: go run ./cmd/wallet multisig-new --m <门限> --pubkeys <公钥,...> --to <地址> --value <金额> [--fee <手续费>] [--locktime <高度或时间>] [--out multisig_tx.json] [--node http://localhost:8001]")
+		fmt.Println("  多签签名: go run ./cmd/wallet cosign [--tx multisig_tx.json] [--sk wallet_priv.pem]")
+		fmt.Println("  多签发送: go run ./cmd/wallet submit [--tx multisig_tx.json] [--binary] [--node http://localhost:8001]")
+		fmt.Println("  脚本地址: go run ./cmd/wallet script-addr --script \"<赎回脚本汇编>\"")
+		fmt.Println("  脚本付款: go run ./cmd/wallet script-new --script \"<赎回脚本汇编>\" --to <地址> --value <金额> [--fee <手续费>] [--locktime <高度或时间>] [--out script_tx.json] [--node http://localhost:8001]")
+		fmt.Println("  脚本签名: go run ./cmd/wallet script-sign [--tx script_tx.json] [--sk wallet_priv.pem]")
+		fmt.Println("  脚本解锁: go run ./cmd/wallet script-unlock --unlock \"<解锁脚本汇编>\" [--tx script_tx.json]，之后用 submit --tx script_tx.json 发送")
+		fmt.Println("  部署合约: go run ./cmd/wallet contract-deploy --code <合约汇编文件> [--value <金额>] [--fee <手续费>] [--gas <gas 上限>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  调用合约: go run ./cmd/wallet contract-call --addr <合约地址> --method <方法名> [--args \"<参数汇编>\"] [--value <金额>] [--fee <手续费>] [--gas <gas 上限>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  查询合约: go run ./cmd/wallet contract-query --addr <合约地址> --method <方法名> [--args \"<参数汇编>\"] [--from <调用者>] [--node http://localhost:8001]")
+		fmt.Println("  执行结果: go run ./cmd/wallet contract-receipt --tx <交易哈希> [--node http://localhost:8001]")
+		fmt.Println("  发行代币: go run ./cmd/wallet token issue --symbol <符号> --supply <发行量> [--decimals <小数位数>] [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  公证文件: go run ./cmd/wallet notarize <文件> [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  验证公证: go run ./cmd/wallet notary-verify <文件> | --hash <SHA256> [--confirmations <n>] [--node http://localhost:8001] [--network <网络> | --chainspec <文件>]")
+		fmt.Println("  转账代币: go run ./cmd/wallet token send --asset <资产 ID> --to <地址> --amount <数量> [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  质押    : go run ./cmd/wallet stake --amount <金额> [--validator <验证者地址，默认自己>] [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  取消质押: go run ./cmd/wallet unstake --amount <金额> [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem]")
+		fmt.Println("  罚没双签: go run ./cmd/wallet slash [--evidence <证据 hex，默认取节点 /evidence 的第一条>] [--fee <手续费>] [--node http://localhost:8001] [--sk wallet_priv.pem] [--network <网络> | --chainspec <文件>]")
+		fmt.Println("  验证交易: go run ./cmd/wallet verify-tx --tx <交易哈希> [--to <地址> --value <金额>] [--confirmations <n>] [--node http://localhost:8001] [--network <网络> | --chainspec <文件>]")
 		return
 	}
 
@@ -163,9 +589,51 @@ func main() {
 		err = cmdGen()
 	case "send":
 		err = cmdSend()
+	case "verify-tx":
+		err = cmdVerifyTx()
+	case "balance":
+		err = cmdBalance()
+	case "pubkey":
+		err = cmdPubKey()
+	case "multisig-addr":
+		err = cmdMultisigAddr()
+	case "multisig-new":
+		err = cmdMultisigNew()
+	case "cosign":
+		err = cmdCoSign()
+	case "submit":
+		err = cmdSubmit()
+	case "script-addr":
+		err = cmdScriptAddr()
+	case "script-new":
+		err = cmdScriptNew()
+	case "script-sign":
+		err = cmdScriptSign()
+	case "script-unlock":
+		err = cmdScriptUnlock()
+	case "contract-deploy":
+		err = cmdContractDeploy()
+	case "contract-call":
+		err = cmdContractCall()
+	case "contract-query":
+		err = cmdContractQuery()
+	case "contract-receipt":
+		err = cmdContractReceipt()
+	case "token":
+		err = cmdToken()
+	case "notarize":
+		err = cmdNotarize()
+	case "notary-verify":
+		err = cmdNotaryVerify()
+	case "stake":
+		err = cmdStake()
+	case "unstake":
+		err = cmdUnstake()
+	case "slash":
+		err = cmdSlash()
 	default:
 		fmt.Println("未知子命令:", cmd)
-		fmt.Println("支持的子命令: gen, send")
+		fmt.Println("支持的子命令: gen, send, verify-tx, balance, pubkey, multisig-addr, multisig-new, cosign, submit, script-addr, script-new, script-sign, script-unlock, contract-deploy, contract-call, contract-query, contract-receipt, token, notarize, notary-verify, stake, unstake, slash")
 		return
 	}
 
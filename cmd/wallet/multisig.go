@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// 多签流程：
+//  1. 每个成员用 pubkey 打印自己的公钥，汇总后用 multisig-addr 得到多签地址（向该地址转账即可存入资金）
+//  2. 任一成员用 multisig-new 构造未签名的交易文件
+//  3. 文件依次交给各成员，用 cosign 追加自己的签名
+//  4. 签名数达到门限后，用 submit 发送到节点
+
+// 打印私钥对应的公钥与地址
+func cmdPubKey() error {
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	flag.Parse()
+
+	priv, err := loadPrivKey(*skPath)
+	if err != nil {
+		return fmt.Errorf("加载私钥失败: %w", err)
+	}
+	pubBytes, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return fmt.Errorf("导出公钥失败: %w", err)
+	}
+	fmt.Println("地址 Address :", utils.PubKeyToAddress(pubBytes))
+	fmt.Println("公钥 PubKey  :", utils.ToHex(pubBytes))
+	return nil
+}
+
+// parseMultiSig 解析 --m 和逗号分隔的 hex 公钥列表
+func parseMultiSig(m uint, pubkeys string) (*core.MultiSig, error) {
+	if pubkeys == "" {
+		return nil, fmt.Errorf("必须指定 --pubkeys（逗号分隔的 hex 公钥）")
+	}
+	var keys [][]byte
+	for _, s := range strings.Split(pubkeys, ",") {
+		pk, err := hex.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("公钥不是合法的 hex: %w", err)
+		}
+		keys = append(keys, pk)
+	}
+	return core.NewMultiSig(uint32(m), keys)
+}
+
+// 由门限和公钥计算多签地址
+func cmdMultisigAddr() error {
+	m := flag.Uint("m", 2, "至少需要多少个签名")
+	pubkeys := flag.String("pubkeys", "", "全部成员的公钥（hex），用逗号分隔")
+	flag.Parse()
+
+	ms, err := parseMultiSig(*m, *pubkeys)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d-of-%d 多签地址: %s\n", ms.Threshold, len(ms.PubKeys), ms.Address())
+	return nil
+}
+
+// 构造一笔从多签地址付款、尚未签名的交易，保存到文件
+func cmdMultisigNew() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	m := flag.Uint("m", 2, "至少需要多少个签名")
+	pubkeys := flag.String("pubkeys", "", "全部成员的公钥（hex），用逗号分隔")
+	toAddr := flag.String("to", "", "收款方地址")
+	value := flag.Uint("value", 0, "转账金额 (uint)")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	nonceFlag := flag.Int64("nonce", -1, "交易 nonce，默认自动向节点查询")
+	out := flag.String("out", "multisig_tx.json", "未签名交易的保存路径")
+	lockFlag := flag.String("locktime", "", lockTimeUsage)
+	flag.Parse()
+
+	if *toAddr == "" {
+		return fmt.Errorf("必须指定 --to 收款地址")
+	}
+	if *value == 0 {
+		return fmt.Errorf("转账金额必须 > 0")
+	}
+	ms, err := parseMultiSig(*m, *pubkeys)
+	if err != nil {
+		return err
+	}
+	lockTime, err := parseLockTime(*lockFlag)
+	if err != nil {
+		return err
+	}
+
+	tx, err := buildTx(*nodeURL, ms.Address(), *toAddr, uint64(*value), uint32(*fee), *nonceFlag)
+	if err != nil {
+		return err
+	}
+	tx.MultiSig = ms
+	tx.LockTime = lockTime
+	tx.CalculateHash()
+
+	if err := saveTxFile(*out, &tx); err != nil {
+		return err
+	}
+	printTx(&tx)
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	fmt.Printf("未签名交易已保存到 %s，需要 %d 个成员用 cosign 签名\n", *out, ms.Threshold)
+	return nil
+}
+
+// 为多签交易文件追加自己的签名
+func cmdCoSign() error {
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	path := flag.String("tx", "multisig_tx.json", "多签交易文件")
+	flag.Parse()
+
+	priv, err := loadPrivKey(*skPath)
+	if err != nil {
+		return fmt.Errorf("加载私钥失败: %w", err)
+	}
+	tx, err := loadTxFile(*path)
+	if err != nil {
+		return err
+	}
+
+	printTx(tx)
+	if err := tx.CoSign(priv); err != nil {
+		return fmt.Errorf("签名失败: %w", err)
+	}
+	if err := saveTxFile(*path, tx); err != nil {
+		return err
+	}
+	fmt.Printf("已签名，当前签名数 %d / %d\n", tx.MultiSig.SignCount(), tx.MultiSig.Threshold)
+	return nil
+}
+
+// 把签名已足够的多签交易（或已解锁的脚本交易）发送到节点
+func cmdSubmit() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	path := flag.String("tx", "multisig_tx.json", "多签交易文件")
+	binaryFlag := flag.Bool("binary", false, "使用规范二进制编码发送交易（默认 JSON）")
+	flag.Parse()
+
+	tx, err := loadTxFile(*path)
+	if err != nil {
+		return err
+	}
+	if err := core.VerifyTxSignature(tx); err != nil {
+		return fmt.Errorf("交易签名还不完整或脚本未通过: %w", err)
+	}
+	return postTx(*nodeURL, tx, *binaryFlag)
+}
+
+func saveTxFile(path string, tx *core.Transaction) error {
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func loadTxFile(path string) (*core.Transaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tx core.Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("解析交易文件失败: %w", err)
+	}
+	if tx.MultiSig == nil && tx.Script == nil {
+		return nil, fmt.Errorf("%s 不是多签或脚本交易", path)
+	}
+	return &tx, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// 公证流程：
+//  1. 用 notarize <文件> 把文件的 SHA256 写进一笔转给自己、金额为 0 的交易，发送到节点的 /notarize
+//  2. 交易上链后，任何持有该文件的人都可以用 notary-verify <文件> 证明文件在那个区块的时间已经存在
+//     （只下载区块头和 Merkle 证明，不需要信任节点）
+
+// fileArg 取出子命令后面的文件参数：写在最前面时（notarize <文件> --fee 1）先移出 os.Args，
+// 否则交给 --file 解析
+func fileArg() string {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		path := os.Args[1]
+		os.Args = append([]string{os.Args[0]}, os.Args[2:]...)
+		return path
+	}
+	return ""
+}
+
+// hashFile 返回文件内容的 SHA256
+func hashFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	return utils.Sha256(data), nil
+}
+
+// 公证文件
+func cmdNotarize() error {
+	path := fileArg()
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	fileFlag := flag.String("file", path, "要公证的文件")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包（UTXO 模式下至少为 1，用来选取输入）")
+	flag.Parse()
+
+	if *fileFlag == "" {
+		return fmt.Errorf("用法: notarize <文件> [--fee <手续费>]")
+	}
+	doc, err := hashFile(*fileFlag)
+	if err != nil {
+		return err
+	}
+
+	priv, err := loadPrivKey(*skPath)
+	if err != nil {
+		return fmt.Errorf("加载私钥失败: %w", err)
+	}
+	pubBytes, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return fmt.Errorf("导出公钥失败: %w", err)
+	}
+	from := utils.PubKeyToAddress(pubBytes)
+
+	// 转给自己、金额为 0，只花手续费
+	tx, err := buildTx(*nodeURL, from, from, 0, uint32(*fee), -1)
+	if err != nil {
+		return err
+	}
+	tx.Data = core.NotaryData(doc)
+	if err := tx.Sign(priv); err != nil {
+		return fmt.Errorf("签名交易失败: %w", err)
+	}
+
+	payload, err := jsonMarshalNoEscape(&tx)
+	if err != nil {
+		return fmt.Errorf("序列化交易失败: %w", err)
+	}
+	fmt.Println("文件  :", *fileFlag)
+	fmt.Println("SHA256:", utils.ToHex(doc))
+	printTx(&tx)
+
+	resp, err := http.Post(*nodeURL+"/notarize", "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("发送 HTTP 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("节点拒绝公证交易（%d）: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		TxHash string `json:"txHash"`
+	}
+	json.Unmarshal(body, &result)
+	fmt.Println("交易哈希:", result.TxHash, "（上链后用 notary-verify 验证）")
+	return nil
+}
+
+// 验证文件已被公证
+func cmdNotaryVerify() error {
+	path := fileArg()
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	fileFlag := flag.String("file", path, "要验证的文件")
+	hashFlag := flag.String("hash", "", "直接给出文件的 SHA256（hex），代替 --file")
+	minConf := flag.Int("confirmations", 1, "至少需要的确认数")
+	network := flag.String("network", "", "节点所在网络：mainnet / testnet / regtest（默认 mainnet）")
+	chainspec := flag.String("chainspec", "", "自定义网络的链配置文件，优先于 --network")
+	flag.Parse()
+
+	params, err := core.LoadParams(*network, *chainspec)
+	if err != nil {
+		return err
+	}
+
+	var doc []byte
+	switch {
+	case *hashFlag != "":
+		doc, err = hex.DecodeString(*hashFlag)
+	case *fileFlag != "":
+		doc, err = hashFile(*fileFlag)
+	default:
+		return fmt.Errorf("用法: notary-verify <文件> 或 notary-verify --hash <SHA256>")
+	}
+	if err != nil {
+		return err
+	}
+
+	// 1. 下载区块头并按网络参数校验
+	var headers []*core.BlockHeader
+	if err := getJSON(*nodeURL+"/headers", &headers); err != nil {
+		return fmt.Errorf("获取区块头失败: %w", err)
+	}
+	if err := core.VerifyHeaders(params, headers); err != nil {
+		return fmt.Errorf("区块头校验失败: %w", err)
+	}
+
+	// 2. 获取公证证明，用本地校验过的区块头验证
+	var proof core.NotaryProof
+	if err := getJSON(*nodeURL+"/notary/verify?hash="+utils.ToHex(doc), &proof); err != nil {
+		return fmt.Errorf("获取公证证明失败: %w", err)
+	}
+	confirmations, err := core.VerifyNotaryProof(&proof, doc, headers)
+	if err != nil {
+		return fmt.Errorf("公证证明校验失败: %w", err)
+	}
+	header := headers[proof.Height]
+
+	fmt.Println("SHA256  :", utils.ToHex(doc))
+	fmt.Println("公证人  :", proof.Tx.From)
+	fmt.Println("交易哈希:", utils.ToHex(proof.Tx.Hash))
+	fmt.Println("区块高度:", proof.Height, "区块哈希:", utils.ToHex(header.Hash))
+	fmt.Println("区块时间:", header.Timestamp.Format("2006-01-02 15:04:05 MST"), "（文件最晚在此时已经存在）")
+	fmt.Println("确认数  :", confirmations)
+	if confirmations < *minConf {
+		return fmt.Errorf("确认数 %d 不足 %d", confirmations, *minConf)
+	}
+	fmt.Println("✔ 公证验证通过")
+	return nil
+}
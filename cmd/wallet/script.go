@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"mychain/core"
+	"mychain/core/script"
+	"mychain/utils"
+)
+
+// 脚本地址（pay-to-script-hash）流程：
+//  1. 用 script-addr 由赎回脚本（汇编文本）得到脚本地址，向该地址转账即可存入资金
+//  2. 用 script-new 构造从脚本地址付款、尚未解锁的交易文件
+//  3. 需要签名的人用 script-sign 对交易签名，得到签名的 hex
+//  4. 用 script-unlock 填入解锁脚本（签名、哈希原像等），本地先执行一遍脚本
+//  5. 用 submit 发送到节点
+
+// 由赎回脚本计算脚本地址
+func cmdScriptAddr() error {
+	src := flag.String("script", "", "赎回脚本（汇编），例如 \"OP_SHA256 0x<哈希> OP_EQUALVERIFY 0x<公钥> OP_CHECKSIG\"")
+	flag.Parse()
+
+	redeem, err := script.Assemble(*src)
+	if err != nil {
+		return fmt.Errorf("编译脚本失败: %w", err)
+	}
+	fmt.Println("脚本地址:", core.ScriptAddress(redeem))
+	fmt.Println("脚本 hex:", utils.ToHex(redeem))
+	return nil
+}
+
+// 构造一笔从脚本地址付款、尚未解锁的交易，保存到文件
+func cmdScriptNew() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	src := flag.String("script", "", "赎回脚本（汇编）")
+	toAddr := flag.String("to", "", "收款方地址")
+	value := flag.Uint("value", 0, "转账金额 (uint)")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	nonceFlag := flag.Int64("nonce", -1, "交易 nonce，默认自动向节点查询")
+	lockFlag := flag.String("locktime", "", lockTimeUsage)
+	out := flag.String("out", "script_tx.json", "未解锁交易的保存路径")
+	flag.Parse()
+
+	if *toAddr == "" {
+		return fmt.Errorf("必须指定 --to 收款地址")
+	}
+	if *value == 0 {
+		return fmt.Errorf("转账金额必须 > 0")
+	}
+	redeem, err := script.Assemble(*src)
+	if err != nil {
+		return fmt.Errorf("编译脚本失败: %w", err)
+	}
+	lockTime, err := parseLockTime(*lockFlag)
+	if err != nil {
+		return err
+	}
+
+	tx, err := buildTx(*nodeURL, core.ScriptAddress(redeem), *toAddr, uint64(*value), uint32(*fee), *nonceFlag)
+	if err != nil {
+		return err
+	}
+	tx.Script = &core.ScriptSpend{Redeem: redeem}
+	tx.LockTime = lockTime
+	tx.CalculateHash()
+
+	if err := saveTxFile(*out, &tx); err != nil {
+		return err
+	}
+	printTx(&tx)
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	fmt.Printf("未解锁交易已保存到 %s，用 script-sign 签名、script-unlock 填入解锁脚本\n", *out)
+	return nil
+}
+
+// 对脚本交易签名，打印签名的 hex，供解锁脚本使用
+func cmdScriptSign() error {
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	path := flag.String("tx", "script_tx.json", "脚本交易文件")
+	flag.Parse()
+
+	priv, err := loadPrivKey(*skPath)
+	if err != nil {
+		return fmt.Errorf("加载私钥失败: %w", err)
+	}
+	tx, err := loadTxFile(*path)
+	if err != nil {
+		return err
+	}
+
+	printTx(tx)
+	sig, err := utils.SignECDSA(priv, tx.SigningPayload())
+	if err != nil {
+		return fmt.Errorf("签名失败: %w", err)
+	}
+	pub, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println("公钥:", "0x"+utils.ToHex(pub))
+	fmt.Println("签名:", "0x"+utils.ToHex(sig))
+	return nil
+}
+
+// 填入解锁脚本，并在本地执行一遍脚本检查能否通过
+func cmdScriptUnlock() error {
+	path := flag.String("tx", "script_tx.json", "脚本交易文件")
+	src := flag.String("unlock", "", "解锁脚本（汇编，只能压入数据），例如 \"0x<签名> 0x<原像>\"")
+	flag.Parse()
+
+	tx, err := loadTxFile(*path)
+	if err != nil {
+		return err
+	}
+	if tx.Script == nil {
+		return fmt.Errorf("%s 不是脚本交易", *path)
+	}
+	unlock, err := script.Assemble(*src)
+	if err != nil {
+		return fmt.Errorf("编译解锁脚本失败: %w", err)
+	}
+	tx.Script.Unlock = unlock
+	if err := saveTxFile(*path, tx); err != nil {
+		return err
+	}
+
+	if err := core.VerifyTxSignature(tx); err != nil {
+		fmt.Println("⚠ 脚本执行未通过:", err)
+		return nil
+	}
+	fmt.Println("脚本执行通过 ✔，可以用 submit 发送")
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// 质押流程（PoS 网络）：
+//  1. 用 stake 质押原生币，质押达到 minStake 后成为验证者，用 --signer-key 启动节点即可轮流出块
+//  2. 用 unstake 取消质押，金额在 unbondingBlocks 个区块之后回到余额
+//  3. 节点发现双签时 GET /evidence 会列出证据，任何人都可以用 slash 提交罚没交易
+//  4. GET /validators 查看验证者、解锁中的质押、下一个出块者与罚没记录
+
+// 质押
+func cmdStake() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	validator := flag.String("validator", "", "质押给哪个验证者，默认为自己")
+	amount := flag.Uint64("amount", 0, "质押金额")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	flag.Parse()
+
+	if *amount == 0 || *amount > math.MaxUint32 {
+		return fmt.Errorf("必须指定 --amount 质押金额（1 到 %d）", uint32(math.MaxUint32))
+	}
+	to := *validator
+	if to == "" {
+		self, err := walletAddress(*skPath)
+		if err != nil {
+			return err
+		}
+		to = self
+	}
+
+	tx, err := sendAccountTx(*nodeURL, *skPath, to, *amount, uint32(*fee), func(tx *core.Transaction) {
+		tx.Kind = core.TxStake
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("验证者  :", to)
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// 取消质押
+func cmdUnstake() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	amount := flag.Uint64("amount", 0, "取消质押的金额")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	flag.Parse()
+
+	if *amount == 0 || *amount > math.MaxUint32 {
+		return fmt.Errorf("必须指定 --amount 取消质押的金额（1 到 %d）", uint32(math.MaxUint32))
+	}
+	tx, err := sendAccountTx(*nodeURL, *skPath, "", *amount, uint32(*fee), func(tx *core.Transaction) {
+		tx.Kind = core.TxUnstake
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// 提交双签证据，罚没验证者
+func cmdSlash() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	evidenceHex := flag.String("evidence", "", "双签证据（hex），默认提交节点 GET /evidence 列出的第一条")
+	fee := flag.Uint("fee", 0, "手续费，越高越优先被打包")
+	network := flag.String("network", "", "节点所在网络：mainnet / testnet / regtest（默认 mainnet）")
+	chainspec := flag.String("chainspec", "", "自定义网络的链配置文件，优先于 --network")
+	flag.Parse()
+
+	params, err := core.LoadParams(*network, *chainspec)
+	if err != nil {
+		return err
+	}
+
+	if *evidenceHex == "" {
+		var items []struct {
+			Validator string `json:"validator"`
+			Height    uint64 `json:"height"`
+			Evidence  string `json:"evidence"`
+		}
+		if err := getJSON(*nodeURL+"/evidence", &items); err != nil {
+			return fmt.Errorf("查询双签证据失败: %w", err)
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("节点没有发现尚未罚没的双签")
+		}
+		fmt.Println("验证者  :", items[0].Validator, "高度", items[0].Height)
+		*evidenceHex = items[0].Evidence
+	}
+	evidence, err := hex.DecodeString(*evidenceHex)
+	if err != nil {
+		return fmt.Errorf("无法解析证据: %w", err)
+	}
+	var ev core.DoubleSignEvidence
+	if err := ev.UnmarshalBinary(evidence); err != nil {
+		return fmt.Errorf("无法解析证据: %w", err)
+	}
+	if _, err := ev.Verify(params); err != nil {
+		return err
+	}
+
+	tx, err := sendAccountTx(*nodeURL, *skPath, "", 0, uint32(*fee), func(tx *core.Transaction) {
+		tx.Kind = core.TxSlash
+		tx.Payload = evidence
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// walletAddress 返回私钥文件对应的地址
+func walletAddress(skPath string) (string, error) {
+	priv, err := loadPrivKey(skPath)
+	if err != nil {
+		return "", fmt.Errorf("加载私钥失败: %w", err)
+	}
+	pub, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("导出公钥失败: %w", err)
+	}
+	return utils.PubKeyToAddress(pub), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// 代币流程：
+//  1. 用 token issue 发行代币，得到资产 ID，全部发行量归自己
+//  2. 发行交易上链后，用 token send --asset <资产 ID> 转账（手续费用原生币支付）
+//  3. 用 balance 或 GET /balance 查看持有的全部代币
+
+// cmdToken 分派 token 的子命令
+func cmdToken() error {
+	if len(os.Args) < 2 {
+		return fmt.Errorf("用法: token issue|send [参数]")
+	}
+	sub := os.Args[1]
+	os.Args = append([]string{os.Args[0]}, os.Args[2:]...)
+
+	switch sub {
+	case "issue":
+		return cmdTokenIssue()
+	case "send":
+		return cmdTokenSend()
+	default:
+		return fmt.Errorf("未知的 token 子命令 %q，支持 issue, send", sub)
+	}
+}
+
+// 发行代币
+func cmdTokenIssue() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	symbol := flag.String("symbol", "", "代币符号，1~12 个大写字母或数字")
+	decimals := flag.Uint("decimals", 0, "小数位数（0~18）")
+	supply := flag.String("supply", "", "发行总量，可以带小数，例如 1000.5")
+	fee := flag.Uint("fee", 0, "手续费（原生币），越高越优先被打包")
+	flag.Parse()
+
+	if *symbol == "" || *supply == "" {
+		return fmt.Errorf("必须指定 --symbol 代币符号和 --supply 发行总量")
+	}
+	if *decimals > core.MaxTokenDecimals {
+		return fmt.Errorf("小数位数最多 %d", core.MaxTokenDecimals)
+	}
+	amount, err := parseUnits(*supply, uint8(*decimals))
+	if err != nil {
+		return err
+	}
+
+	spec := &core.TokenSpec{Symbol: strings.ToUpper(*symbol), Decimals: uint8(*decimals), Supply: amount}
+	tx, err := sendAccountTx(*nodeURL, *skPath, "", 0, uint32(*fee), func(tx *core.Transaction) {
+		tx.Kind = core.TxIssue
+		tx.Token = spec
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("资产 ID:", core.AssetID(tx.From, tx.Nonce))
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// 转账代币
+func cmdTokenSend() error {
+	nodeURL := flag.String("node", "http://localhost:8001", "节点地址，例如 http://localhost:8001")
+	skPath := flag.String("sk", "wallet_priv.pem", "私钥文件路径")
+	asset := flag.String("asset", "", "资产 ID（token issue 时打印，也可在 GET /tokens 查到）")
+	toAddr := flag.String("to", "", "收款方地址")
+	amountFlag := flag.String("amount", "", "转账数量，可以带小数（按代币的小数位数换算）")
+	fee := flag.Uint("fee", 0, "手续费（原生币），越高越优先被打包")
+	flag.Parse()
+
+	if *asset == "" || *toAddr == "" || *amountFlag == "" {
+		return fmt.Errorf("必须指定 --asset 资产 ID、--to 收款地址和 --amount 数量")
+	}
+
+	var token core.Token
+	if err := getJSON(*nodeURL+"/tokens?id="+*asset, &token); err != nil {
+		return fmt.Errorf("查询代币失败: %w", err)
+	}
+	amount, err := parseUnits(*amountFlag, token.Decimals)
+	if err != nil {
+		return err
+	}
+	if amount == 0 || amount > math.MaxUint32 {
+		return fmt.Errorf("单笔转账数量必须在 1 到 %d 个最小单位之间", uint32(math.MaxUint32))
+	}
+
+	tx, err := sendAccountTx(*nodeURL, *skPath, *toAddr, amount, uint32(*fee), func(tx *core.Transaction) {
+		tx.Asset = *asset
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("代币  :", token.Symbol, formatUnits(amount, token.Decimals))
+	fmt.Println("交易哈希:", utils.ToHex(tx.Hash))
+	return nil
+}
+
+// parseUnits 把带小数的数量（如 "12.5"）按 decimals 位小数换算成最小单位
+func parseUnits(s string, decimals uint8) (uint64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > int(decimals) {
+		return 0, fmt.Errorf("数量 %q 的小数位数超过代币的 %d 位", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析数量 %q: %w", s, err)
+	}
+	return n, nil
+}
+
+// formatUnits 把最小单位的数量按 decimals 位小数显示
+func formatUnits(n uint64, decimals uint8) string {
+	s := strconv.FormatUint(n, 10)
+	if decimals == 0 {
+		return s
+	}
+	d := int(decimals)
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+	return s[:len(s)-d] + "." + s[len(s)-d:]
+}
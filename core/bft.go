@@ -0,0 +1,359 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"mychain/utils"
+)
+
+// 拜占庭容错共识（BFT，参考 Tendermint），用于验证者固定的许可链：
+//   - 链配置 consensus.signers 列出 N 个验证者，最多容忍 f = (N-1)/3 个验证者离线或作恶
+//   - 每个高度分若干轮，第 r 轮的提议者是 signers[(h + r) % N]。每轮分三步：
+//     propose：提议者广播区块提议；
+//     prevote：验证者校验提议后对区块哈希投 prevote（提议无效、超时或与已锁定的区块冲突时投空）；
+//     precommit：同一轮中见到超过 2/3 的 prevote 投给某个区块后锁定它，并对它投 precommit
+//   - 同一轮中超过 2/3 的验证者对某个区块投了 precommit，该区块即被提交：这些 precommit 组成提交证书（CommitCert），
+//     随区块一起保存和广播，没有提交证书的区块不能进入区块树
+//   - 某一步超时、或超过 2/3 的 prevote 投给空时进入下一轮，由下一个提议者重新提议
+//   - 提交的区块是最终的：区块树拒绝与已提交区块冲突的区块，主链不会再被重组
+//
+// 区块头与 PoA 一样带出块者公钥和签名，Bits 固定为 1、nonce 为 0。
+// 一个区块可能在之后的轮次中由别的提议者重新提议，因此区块头的签名者只要求是验证者。
+// 投票与提议的签名内容：
+//   - 投票：SHA256("vote" | u8 类型 | u64 高度 | u32 轮次 | bytes 区块哈希（投空时为空）)
+//   - 提议：SHA256("proposal" | u64 高度 | u32 轮次 | u32 (polRound + 1) | bytes 区块哈希)
+//
+// 轮次的状态机与网络消息在 p2p/bft.go 中。
+
+const (
+	bftWeight uint32 = 1 // BFT 区块的权重
+
+	DefaultRoundTimeout = 3 * time.Second // consensus.roundTimeoutMs 为 0 时每一步的超时
+)
+
+var (
+	ErrBadVote       = errors.New("invalid consensus vote")
+	ErrBadProposal   = errors.New("invalid block proposal")
+	ErrMissingCommit = errors.New("block has no valid commit certificate")
+	ErrFinalized     = errors.New("block conflicts with a finalized block")
+)
+
+// VoteType 是投票的类型
+type VoteType uint8
+
+const (
+	VotePrevote   VoteType = 1
+	VotePrecommit VoteType = 2
+)
+
+func (t VoteType) String() string {
+	switch t {
+	case VotePrevote:
+		return "prevote"
+	case VotePrecommit:
+		return "precommit"
+	}
+	return fmt.Sprintf("vote(%d)", uint8(t))
+}
+
+// Vote 是验证者在某个高度、某一轮中的投票
+type Vote struct {
+	Type      VoteType `json:"type"`
+	Height    uint64   `json:"height"`
+	Round     uint32   `json:"round"`
+	BlockHash []byte   `json:"blockHash,omitempty"` // 为空表示投给空（本轮不提交任何区块）
+	Validator []byte   `json:"validator"`           // 投票者公钥
+	Sig       []byte   `json:"sig"`
+}
+
+// SigningHash 返回投票在网络 network 上的签名内容。与区块签名一样绑定网络编号，
+// 同一把私钥在其他网络上的投票不能在本网络重放
+func (v *Vote) SigningHash(network uint32) []byte {
+	var e encoder
+	e.buf.WriteString("vote")
+	e.u32(network)
+	e.u8(uint8(v.Type))
+	e.u64(v.Height)
+	e.u32(v.Round)
+	e.bytes(v.BlockHash)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// Address 返回投票者地址
+func (v *Vote) Address() string {
+	return utils.PubKeyToAddress(v.Validator)
+}
+
+// Proposal 是提议者在某个高度、某一轮中提出的区块
+type Proposal struct {
+	Height   uint64 `json:"height"`
+	Round    uint32 `json:"round"`
+	POLRound int32  `json:"polRound"` // 重新提议之前某一轮已有超过 2/3 prevote 的区块时为那一轮，新区块为 -1
+	Block    *Block `json:"block"`
+	Proposer []byte `json:"proposer"` // 提议者公钥
+	Sig      []byte `json:"sig"`
+}
+
+// SigningHash 返回提议在网络 network 上的签名内容
+func (p *Proposal) SigningHash(network uint32) []byte {
+	var e encoder
+	e.buf.WriteString("proposal")
+	e.u32(network)
+	e.u64(p.Height)
+	e.u32(p.Round)
+	e.u32(uint32(p.POLRound + 1))
+	if p.Block != nil && p.Block.Header != nil {
+		e.bytes(p.Block.Header.Hash)
+	} else {
+		e.bytes(nil)
+	}
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// CommitCert 是区块的提交证书：同一轮中超过 2/3 验证者对该区块的 precommit
+type CommitCert struct {
+	Height     uint64  `json:"height"`
+	Round      uint32  `json:"round"`
+	BlockHash  []byte  `json:"blockHash"`
+	Precommits []*Vote `json:"precommits"`
+}
+
+// BFT 是拜占庭容错共识引擎。校验区块与投票只需要链配置；参与投票的节点还要用 Authorize 设置验证者私钥
+type BFT struct {
+	Params     *ChainParams
+	validators map[string]int // 验证者地址 → 在提议者轮换顺序中的位置
+
+	signer *blockSigner // 本节点的验证者私钥，未设置时只能校验不能提议和投票
+}
+
+// NewBFT 按链配置中的验证者列表创建 BFT 引擎
+func NewBFT(p *ChainParams) *BFT {
+	b := &BFT{Params: p, validators: make(map[string]int)}
+	for i, addr := range p.Consensus.Signers {
+		b.validators[addr] = i
+	}
+	return b
+}
+
+func (b *BFT) Name() EngineName { return EngineBFT }
+
+// Authorize 设置本节点提议和投票使用的私钥，其地址必须在验证者列表中
+func (b *BFT) Authorize(priv *ecdsa.PrivateKey) error {
+	s, err := newBlockSigner(priv, b.Params.NetworkID)
+	if err != nil {
+		return err
+	}
+	if _, ok := b.validators[s.addr]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotSigner, s.addr)
+	}
+	b.signer = s
+	return nil
+}
+
+// Signer 返回本节点的验证者地址，未授权时为空
+func (b *BFT) Signer() string {
+	if b.signer == nil {
+		return ""
+	}
+	return b.signer.addr
+}
+
+// IsValidator 判断 addr 是否在验证者列表中
+func (b *BFT) IsValidator(addr string) bool {
+	_, ok := b.validators[addr]
+	return ok
+}
+
+// Validators 返回验证者数量
+func (b *BFT) Validators() int {
+	return len(b.validators)
+}
+
+// Quorum 返回提交所需的票数：超过 2/3 的验证者
+func (b *BFT) Quorum() int {
+	return len(b.validators)*2/3 + 1
+}
+
+// Proposer 返回高度 height 第 round 轮的提议者地址
+func (b *BFT) Proposer(height uint64, round uint32) string {
+	signers := b.Params.Consensus.Signers
+	return signers[(height+uint64(round))%uint64(len(signers))]
+}
+
+// Timeout 返回第 round 轮每一步的超时：每一轮比上一轮多一半的基础超时，网络变慢时最终总能在超时内完成一轮
+func (b *BFT) Timeout(round uint32) time.Duration {
+	base := DefaultRoundTimeout
+	if ms := b.Params.Consensus.RoundTimeoutMs; ms > 0 {
+		base = time.Duration(ms) * time.Millisecond
+	}
+	return base + base*time.Duration(round)/2
+}
+
+// Prepare 填写本节点的公钥与出块权重
+func (b *BFT) Prepare(chain HeaderReader, h *BlockHeader) error {
+	if b.signer == nil {
+		return ErrNotSigner
+	}
+	h.Signer = b.signer.pub
+	h.Bits = bftWeight
+	h.Nonce = 0
+	return nil
+}
+
+// Seal 用本节点的验证者私钥对区块哈希签名
+func (b *BFT) Seal(ctx context.Context, blk *Block) error {
+	return b.signer.seal(blk)
+}
+
+// VerifySeal 检查区块哈希、签名者是验证者、签名与出块权重；提交证书由 VerifyCommit 检查
+func (b *BFT) VerifySeal(chain HeaderReader, h *BlockHeader) error {
+	addr, err := verifySignedHeader(b.Params.NetworkID, h)
+	if err != nil {
+		return err
+	}
+	if !b.IsValidator(addr) {
+		return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, addr)
+	}
+	if h.Bits != bftWeight {
+		return fmt.Errorf("%w: got weight %d, want %d", ErrBadDifficulty, h.Bits, bftWeight)
+	}
+	return nil
+}
+
+// Work 返回出块权重：每个区块都是 1
+func (b *BFT) Work(h *BlockHeader) *big.Int {
+	return big.NewInt(int64(h.Bits))
+}
+
+// Reward 按货币政策计算区块奖励
+func (b *BFT) Reward(height uint64) uint64 {
+	return b.Params.Monetary.Subsidy(height)
+}
+
+// SignVote 用本节点的验证者私钥签名投票，填写投票者公钥
+func (b *BFT) SignVote(v *Vote) error {
+	if b.signer == nil {
+		return ErrNotSigner
+	}
+	v.Validator = b.signer.pub
+	sig, err := utils.SignECDSA(b.signer.key, v.SigningHash(b.Params.NetworkID))
+	if err != nil {
+		return err
+	}
+	v.Sig = sig
+	return nil
+}
+
+// VerifyVote 检查投票的类型、签名，以及投票者是验证者，返回投票者地址
+func (b *BFT) VerifyVote(v *Vote) (string, error) {
+	if v.Type != VotePrevote && v.Type != VotePrecommit {
+		return "", fmt.Errorf("%w: unknown type %d", ErrBadVote, v.Type)
+	}
+	if len(v.Validator) == 0 || !utils.VerifyECDSA(v.Validator, v.SigningHash(b.Params.NetworkID), v.Sig) {
+		return "", fmt.Errorf("%w: bad signature", ErrBadVote)
+	}
+	addr := v.Address()
+	if !b.IsValidator(addr) {
+		return "", fmt.Errorf("%w: %s is not a validator", ErrBadVote, addr)
+	}
+	return addr, nil
+}
+
+// SignProposal 用本节点的验证者私钥签名提议，填写提议者公钥
+func (b *BFT) SignProposal(p *Proposal) error {
+	if b.signer == nil {
+		return ErrNotSigner
+	}
+	p.Proposer = b.signer.pub
+	sig, err := utils.SignECDSA(b.signer.key, p.SigningHash(b.Params.NetworkID))
+	if err != nil {
+		return err
+	}
+	p.Sig = sig
+	return nil
+}
+
+// VerifyProposal 检查提议的签名、提议者是这一轮轮到的验证者，以及区块高度与封装；
+// 区块的交易与状态根由调用方在父区块的状态上检查
+func (b *BFT) VerifyProposal(p *Proposal) error {
+	if p.Block == nil || p.Block.Header == nil {
+		return fmt.Errorf("%w: no block", ErrBadProposal)
+	}
+	if p.POLRound < -1 || p.POLRound >= int32(p.Round) {
+		return fmt.Errorf("%w: polRound %d in round %d", ErrBadProposal, p.POLRound, p.Round)
+	}
+	if len(p.Proposer) == 0 || !utils.VerifyECDSA(p.Proposer, p.SigningHash(b.Params.NetworkID), p.Sig) {
+		return fmt.Errorf("%w: bad signature", ErrBadProposal)
+	}
+	if got, want := utils.PubKeyToAddress(p.Proposer), b.Proposer(p.Height, p.Round); got != want {
+		return fmt.Errorf("%w: proposer %s, want %s", ErrBadProposal, got, want)
+	}
+	if p.Block.Header.Height != p.Height {
+		return fmt.Errorf("%w: block height %d in proposal for %d", ErrBadProposal, p.Block.Header.Height, p.Height)
+	}
+	return b.VerifySeal(nil, p.Block.Header)
+}
+
+// VerifyCommit 检查区块带有有效的提交证书：同一轮中超过 2/3 的不同验证者对该区块投了 precommit
+func (b *BFT) VerifyCommit(blk *Block) error {
+	c := blk.Commit
+	if c == nil {
+		return ErrMissingCommit
+	}
+	if c.Height != blk.Header.Height || !bytes.Equal(c.BlockHash, blk.Header.Hash) {
+		return fmt.Errorf("%w: certificate is for another block", ErrMissingCommit)
+	}
+	seen := make(map[string]bool)
+	for i, v := range c.Precommits {
+		if v == nil || v.Type != VotePrecommit || v.Height != c.Height || v.Round != c.Round || !bytes.Equal(v.BlockHash, c.BlockHash) {
+			return fmt.Errorf("%w: vote %d is not a precommit for this block", ErrMissingCommit, i)
+		}
+		addr, err := b.VerifyVote(v)
+		if err != nil {
+			return fmt.Errorf("%w: vote %d: %v", ErrMissingCommit, i, err)
+		}
+		if seen[addr] {
+			return fmt.Errorf("%w: duplicate vote from %s", ErrMissingCommit, addr)
+		}
+		seen[addr] = true
+	}
+	if len(seen) < b.Quorum() {
+		return fmt.Errorf("%w: %d precommits, need %d", ErrMissingCommit, len(seen), b.Quorum())
+	}
+	return nil
+}
+
+// NewCommitCert 用同一轮中对同一区块的 precommit 组成提交证书（按投票者地址排序，调用方保证票数足够）
+func NewCommitCert(height uint64, round uint32, hash []byte, precommits []*Vote) *CommitCert {
+	votes := append([]*Vote(nil), precommits...)
+	sort.Slice(votes, func(i, j int) bool { return votes[i].Address() < votes[j].Address() })
+	return &CommitCert{Height: height, Round: round, BlockHash: hash, Precommits: votes}
+}
+
+// checkCommit 在 BFT 链上检查区块的提交证书，以及它不与已提交的区块冲突；其他共识引擎不检查
+func (bc *Blockchain) checkCommit(b *Block, height int) error {
+	engine, ok := bc.Engine.(*BFT)
+	if !ok {
+		return nil
+	}
+	if final := bc.FinalizedHeight(); uint64(height) <= final {
+		return fmt.Errorf("%w: height %d, finalized %d", ErrFinalized, height, final)
+	}
+	return engine.VerifyCommit(b)
+}
+
+// FinalizedHeight 返回已经最终确定、不会再被重组的主链高度：
+// BFT 链上每个区块都带有提交证书，整条主链都是最终的；其他共识引擎只有创世块是最终的
+func (bc *Blockchain) FinalizedHeight() uint64 {
+	if _, ok := bc.Engine.(*BFT); ok {
+		return uint64(bc.tip.height)
+	}
+	return 0
+}
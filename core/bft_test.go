@@ -0,0 +1,342 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+
+	"mychain/utils"
+)
+
+// bftNetwork 返回一个有 n 个验证者的 BFT 网络（其余参数与回归测试网络相同），以及每个验证者授权好的区块链
+func bftNetwork(t *testing.T, n int) (*ChainParams, []*Blockchain) {
+	t.Helper()
+	p := loadRegtest(t)
+	keys := make([]*ecdsa.PrivateKey, n)
+	p.Consensus = ConsensusSpec{Engine: EngineBFT, RoundTimeoutMs: 100}
+	for i := range keys {
+		priv, pub, err := utils.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = priv
+		p.Consensus.Signers = append(p.Consensus.Signers, utils.PubKeyToAddress(pub))
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make([]*Blockchain, n)
+	for i, key := range keys {
+		nodes[i] = NewBlockchain(p)
+		if err := nodes[i].Engine.(*BFT).Authorize(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p, nodes
+}
+
+// proposeBFT 由 bc 的验证者在 round 轮为下一个高度构造、签名区块，并签名提议
+func proposeBFT(t *testing.T, bc *Blockchain, round uint32) *Proposal {
+	t.Helper()
+	engine := bc.Engine.(*BFT)
+	height := bc.NextHeight()
+	coinbase := bc.Params.NewCoinbase(height, engine.Signer(), uint32(bc.Params.Monetary.Subsidy(height)))
+	block, err := bc.NewBlockTemplate([]Transaction{coinbase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Seal(context.Background(), &block); err != nil {
+		t.Fatal(err)
+	}
+	p := &Proposal{Height: block.Header.Height, Round: round, POLRound: -1, Block: &block}
+	if err := engine.SignProposal(p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// signVote 由 bc 的验证者签名一张投票
+func signVote(t *testing.T, bc *Blockchain, typ VoteType, height uint64, round uint32, hash []byte) *Vote {
+	t.Helper()
+	v := &Vote{Type: typ, Height: height, Round: round, BlockHash: hash}
+	if err := bc.Engine.(*BFT).SignVote(v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// runBFTRound 让 online 中的验证者走完下一个高度的第 round 轮：提议者提议，各验证者检查提议并投 prevote，
+// 见到超过 2/3 的 prevote 后投 precommit，precommit 凑够票数时组成提交证书，返回带证书的区块（未凑够时为 nil）
+func runBFTRound(t *testing.T, online []*Blockchain, round uint32) *Block {
+	t.Helper()
+	engine := online[0].Engine.(*BFT)
+	height := online[0].NextHeight()
+
+	var proposal *Proposal
+	for _, bc := range online {
+		if bc.Engine.(*BFT).Signer() == engine.Proposer(height, round) {
+			proposal = proposeBFT(t, bc, round)
+		}
+	}
+
+	// propose → prevote：提议者离线时所有人投空
+	var prevotes []*Vote
+	for _, bc := range online {
+		var hash []byte
+		if proposal != nil {
+			if err := bc.Engine.(*BFT).VerifyProposal(proposal); err != nil {
+				t.Fatalf("verify proposal: %v", err)
+			}
+			if err := ValidateBlock(proposal.Block, bc.NextBlockContext()); err != nil {
+				t.Fatalf("validate proposed block: %v", err)
+			}
+			hash = proposal.Block.Header.Hash
+		}
+		prevotes = append(prevotes, signVote(t, bc, VotePrevote, height, round, hash))
+	}
+
+	// prevote → precommit：只对得到超过 2/3 prevote 的区块投 precommit
+	var hash []byte
+	if proposal != nil && countVotes(t, engine, prevotes, proposal.Block.Header.Hash) >= engine.Quorum() {
+		hash = proposal.Block.Header.Hash
+	}
+	var precommits []*Vote
+	for _, bc := range online {
+		precommits = append(precommits, signVote(t, bc, VotePrecommit, height, round, hash))
+	}
+	if hash == nil || countVotes(t, engine, precommits, hash) < engine.Quorum() {
+		return nil
+	}
+
+	committed := *proposal.Block
+	committed.Commit = NewCommitCert(height, round, hash, precommits)
+	return &committed
+}
+
+// countVotes 检查每张投票的签名，返回投给 hash 的票数
+func countVotes(t *testing.T, engine *BFT, votes []*Vote, hash []byte) int {
+	t.Helper()
+	n := 0
+	for _, v := range votes {
+		if _, err := engine.VerifyVote(v); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(v.BlockHash, hash) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBFTValidatorsCommitRounds(t *testing.T) {
+	_, nodes := bftNetwork(t, 4)
+
+	// 四个验证者都在线：每个高度都在第 0 轮提交，所有节点接入同一个区块
+	for h := uint64(1); h <= 3; h++ {
+		block := runBFTRound(t, nodes, 0)
+		if block == nil {
+			t.Fatalf("height %d: round 0 did not commit", h)
+		}
+		for i, bc := range nodes {
+			if _, err := bc.ProcessBlock(*block); err != nil {
+				t.Fatalf("height %d: node %d: %v", h, i, err)
+			}
+			if bc.FinalizedHeight() != h {
+				t.Fatalf("height %d: node %d finalized %d", h, i, bc.FinalizedHeight())
+			}
+		}
+	}
+
+	// 下一个高度第 0 轮的提议者离线：这一轮所有人投空，剩下三个验证者（正好 2/3 以上）在第 1 轮提交
+	engine := nodes[0].Engine.(*BFT)
+	height := nodes[0].NextHeight()
+	var online []*Blockchain
+	for _, bc := range nodes {
+		if bc.Engine.(*BFT).Signer() != engine.Proposer(height, 0) {
+			online = append(online, bc)
+		}
+	}
+	if block := runBFTRound(t, online, 0); block != nil {
+		t.Fatal("round 0 committed without its proposer")
+	}
+	block := runBFTRound(t, online, 1)
+	if block == nil {
+		t.Fatal("round 1 did not commit with 3 of 4 validators")
+	}
+	if block.Commit.Round != 1 || len(block.Commit.Precommits) != 3 {
+		t.Fatalf("commit in round %d with %d precommits", block.Commit.Round, len(block.Commit.Precommits))
+	}
+	for i, bc := range nodes {
+		if _, err := bc.ProcessBlock(*block); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+
+	// 只有两个验证者在线时凑不够票数，不会提交
+	if block := runBFTRound(t, online[:2], 0); block != nil {
+		t.Fatal("2 of 4 validators committed a block")
+	}
+}
+
+func TestBFTVerifyCommit(t *testing.T) {
+	_, nodes := bftNetwork(t, 4)
+	engine := nodes[0].Engine.(*BFT)
+	block := runBFTRound(t, nodes, 0)
+	if block == nil {
+		t.Fatal("round 0 did not commit")
+	}
+	if err := engine.VerifyCommit(block); err != nil {
+		t.Fatalf("valid commit: %v", err)
+	}
+
+	height, hash := block.Header.Height, block.Header.Hash
+	precommit := func(i int, round uint32, hash []byte) *Vote {
+		return signVote(t, nodes[i], VotePrecommit, height, round, hash)
+	}
+	_, others := bftNetwork(t, 1) // 另一个网络的验证者
+
+	tests := []struct {
+		name  string
+		votes []*Vote
+		cert  func(c *CommitCert)
+	}{
+		{"two of four", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash)}, nil},
+		{"duplicate voter", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(1, 0, hash)}, nil},
+		{"vote from another round", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 1, hash)}, nil},
+		{"vote for another block", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 0, []byte("other"))}, nil},
+		{"nil vote", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 0, nil)}, nil},
+		{"prevote", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), signVote(t, nodes[2], VotePrevote, height, 0, hash)}, nil},
+		{"non-validator", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), signVote(t, others[0], VotePrecommit, height, 0, hash)}, nil},
+		{"vote signed on another network", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), func() *Vote {
+			v := &Vote{Type: VotePrecommit, Height: height, Round: 0, BlockHash: hash}
+			if err := otherNetwork(t, nodes[2]).SignVote(v); err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}()}, nil},
+		{"forged signature", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 0, hash)}, func(c *CommitCert) {
+			c.Precommits[2].Sig = c.Precommits[0].Sig
+		}},
+		{"certificate for another block", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 0, hash)}, func(c *CommitCert) {
+			c.BlockHash = []byte("other")
+		}},
+		{"certificate for another height", []*Vote{precommit(0, 0, hash), precommit(1, 0, hash), precommit(2, 0, hash)}, func(c *CommitCert) {
+			c.Height++
+		}},
+	}
+	for _, tt := range tests {
+		b := *block
+		b.Commit = &CommitCert{Height: height, Round: 0, BlockHash: hash, Precommits: tt.votes}
+		if tt.cert != nil {
+			tt.cert(b.Commit)
+		}
+		if err := engine.VerifyCommit(&b); !errors.Is(err, ErrMissingCommit) {
+			t.Errorf("%s: err = %v, want ErrMissingCommit", tt.name, err)
+		}
+	}
+
+	b := *block
+	b.Commit = nil
+	if err := engine.VerifyCommit(&b); !errors.Is(err, ErrMissingCommit) {
+		t.Fatalf("no certificate: err = %v, want ErrMissingCommit", err)
+	}
+}
+
+// otherNetwork 返回一个与 bc 的链配置只有网络编号不同的 BFT 引擎，用 bc 的验证者私钥授权
+func otherNetwork(t *testing.T, bc *Blockchain) *BFT {
+	t.Helper()
+	p := *bc.Params
+	p.NetworkID++
+	engine := NewBFT(&p)
+	if err := engine.Authorize(bc.Engine.(*BFT).signer.key); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestBFTSignaturesBindNetwork(t *testing.T) {
+	_, nodes := bftNetwork(t, 3)
+	engine := nodes[0].Engine.(*BFT)
+	height := nodes[0].NextHeight()
+
+	// 同一个验证者、同样的内容：本网络签的投票有效，另一个网络签的无效
+	v := signVote(t, nodes[1], VotePrevote, height, 0, []byte("block"))
+	if _, err := engine.VerifyVote(v); err != nil {
+		t.Fatalf("vote signed on this network: %v", err)
+	}
+	replayed := *v
+	if err := otherNetwork(t, nodes[1]).SignVote(&replayed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.VerifyVote(&replayed); !errors.Is(err, ErrBadVote) {
+		t.Fatalf("vote signed on another network: err = %v, want ErrBadVote", err)
+	}
+
+	var proposer *Blockchain
+	for _, bc := range nodes {
+		if bc.Engine.(*BFT).Signer() == engine.Proposer(height, 0) {
+			proposer = bc
+		}
+	}
+	p := proposeBFT(t, proposer, 0)
+	if err := engine.VerifyProposal(p); err != nil {
+		t.Fatalf("proposal signed on this network: %v", err)
+	}
+	replayedProposal := *p
+	if err := otherNetwork(t, proposer).SignProposal(&replayedProposal); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.VerifyProposal(&replayedProposal); !errors.Is(err, ErrBadProposal) {
+		t.Fatalf("proposal signed on another network: err = %v, want ErrBadProposal", err)
+	}
+}
+
+func TestBFTCheckCommit(t *testing.T) {
+	p, nodes := bftNetwork(t, 4)
+	bc := nodes[0]
+
+	// 没有提交证书的区块不能进入区块树
+	block := runBFTRound(t, nodes, 0)
+	bare := *block
+	bare.Commit = nil
+	if _, err := bc.ProcessBlock(bare); !errors.Is(err, ErrMissingCommit) {
+		t.Fatalf("block without commit: err = %v, want ErrMissingCommit", err)
+	}
+	for _, n := range nodes {
+		if _, err := n.ProcessBlock(*block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 与已提交区块冲突的区块：即使带着（作恶的验证者签出的）有效提交证书，也会被拒绝
+	engine := bc.Engine.(*BFT)
+	forkNodes := make([]*Blockchain, len(nodes))
+	for i, n := range nodes {
+		forkNodes[i] = NewBlockchain(p)
+		forkNodes[i].Engine = n.Engine
+	}
+	conflict := runBFTRound(t, forkNodes, 1)
+	if conflict == nil || bytes.Equal(conflict.Header.Hash, block.Header.Hash) {
+		t.Fatal("could not build a conflicting block")
+	}
+	if err := engine.VerifyCommit(conflict); err != nil {
+		t.Fatalf("conflicting block should carry a valid certificate: %v", err)
+	}
+	if err := bc.checkCommit(conflict, 1); !errors.Is(err, ErrFinalized) {
+		t.Fatalf("checkCommit below the finalized height: err = %v, want ErrFinalized", err)
+	}
+	if _, err := bc.ProcessBlock(*conflict); !errors.Is(err, ErrFinalized) {
+		t.Fatalf("conflicting block: err = %v, want ErrFinalized", err)
+	}
+	if !bytes.Equal(bc.LatestBlock().Header.Hash, block.Header.Hash) {
+		t.Fatal("the finalized block was replaced")
+	}
+
+	// 下一个高度的区块照常检查证书
+	if err := bc.checkCommit(&bare, 2); !errors.Is(err, ErrMissingCommit) {
+		t.Fatalf("checkCommit above the finalized height: err = %v, want ErrMissingCommit", err)
+	}
+}
@@ -2,43 +2,67 @@ package core
 
 import "time"
 
+// BlockVersion 当前区块格式版本，规则变化时递增，收到的区块必须使用该版本
+const BlockVersion uint32 = 1
+
 // 区块头
 type BlockHeader struct {
+	Version      uint32    `json:"version"` // 区块格式版本
+	Height       uint64    `json:"height"`  // 区块高度（创世块为 0）
 	PreviousHash []byte    `json:"previousHash"`
 	MerkleRoot   []byte    `json:"merkleRoot"`
+	StateRoot    []byte    `json:"stateRoot"` // 执行完本块交易后的状态根
 	Timestamp    time.Time `json:"timestamp"`
+	Bits         uint32    `json:"bits"`             // compact 格式的难度目标（PoA 中为出块权重，见 poa.go）
+	Signer       []byte    `json:"signer,omitempty"` // PoA：出块签名者的公钥，参与区块哈希
 	Hash         []byte    `json:"hash"`
 	Nonce        uint32    `json:"nonce"`
+	Seal         []byte    `json:"seal,omitempty"` // PoA：签名者对区块哈希的签名，不参与区块哈希
 }
 
 // 区块
 type Block struct {
 	Header *BlockHeader  `json:"header"`
 	Txs    []Transaction `json:"txs"`
+	Commit *CommitCert   `json:"commit,omitempty"` // BFT：超过 2/3 验证者的 precommit（见 bft.go），不参与区块哈希
 }
 
-// 创建创世块：所有节点必须生成完全相同的创世块
-func NewGenesisBlock() Block {
-	// 固定的时间戳（随便选一个常量）
-	const genesisTime int64 = 1700000000 // 比如 2023-11 的某个时间
+// GenesisBlock 按链配置创建创世块：同一网络的所有节点必须生成完全相同的创世块。
+// 创世分配作为 From 为空的交易放在创世块中，状态根为执行完这些交易后的状态
+func (p *ChainParams) GenesisBlock() Block {
+	txs := p.genesisTxs()
+	var merkle []byte // 没有创世分配时与旧版本一样为空
+	if len(txs) > 0 {
+		merkle = CalculateMerkleRoot(txs)
+	}
 
-	header := &BlockHeader{
+	st := NewState()
+	genesis := Block{Header: &BlockHeader{Height: 0}, Txs: txs}
+	executeBlock(p, st, &genesis) // 创世分配只是给地址加钱，不会失败
+
+	genesis.Header = &BlockHeader{
+		Version:      BlockVersion,
+		Height:       0,
 		PreviousHash: nil,
-		MerkleRoot:   nil,
-		Timestamp:    time.Unix(genesisTime, 0), // ✅ 固定时间
+		MerkleRoot:   merkle,
+		StateRoot:    st.Root(),
+		Timestamp:    time.Unix(p.Genesis.Timestamp, 0), // ✅ 固定时间
+		Bits:         p.InitialBits,
 	}
 
-	block := Block{
-		Header: header,
-		Txs:    []Transaction{},
+	// POW 是确定性的：同样的 header 会得到同样的 Hash 和 Nonce；
+	// 其他共识下创世块没有签名者，直接计算哈希
+	if p.Consensus.Engine == EnginePoW {
+		genesis.Mine()
+	} else {
+		genesis.Header.Hash = headerHash(genesis.Header)
 	}
 
-	// POW 是确定性的：同样的 header 会得到同样的 Hash 和 Nonce
-	block.Mine()
-
-	return block
+	return genesis
 }
 
+// Mine 在当前协程中按 nonce 从 0 开始顺序搜索，结果是确定的，用于创世块；
+// 普通区块使用并行、可取消的 Miner
 func (b *Block) Mine() {
 	pow := NewPow(b)
 	hash, nonce := pow.Run()
@@ -47,7 +71,9 @@ func (b *Block) Mine() {
 	b.Header.Nonce = nonce
 }
 
-func NewBlock(prevHash []byte, txs []Transaction) Block {
+// NewBlock 在 parent 之后按时间戳 ts 打包交易，返回尚未封装的区块（Hash 为空），
+// stateRoot 为执行完 txs 之后的状态根（由调用方试执行得到）；难度等共识字段由共识引擎的 Prepare 填写
+func NewBlock(parent *BlockHeader, ts time.Time, stateRoot []byte, txs []Transaction) Block {
 	// 先为每个交易计算 hash
 	for i := range txs {
 		txs[i].CalculateHash()
@@ -56,17 +82,16 @@ func NewBlock(prevHash []byte, txs []Transaction) Block {
 	merkle := CalculateMerkleRoot(txs)
 
 	header := &BlockHeader{
-		PreviousHash: prevHash,
+		Version:      BlockVersion,
+		Height:       parent.Height + 1,
+		PreviousHash: parent.Hash,
 		MerkleRoot:   merkle,
-		Timestamp:    time.Now(),
+		StateRoot:    stateRoot,
+		Timestamp:    ts.Truncate(time.Second), // 编码中只保留到秒
 	}
 
-	block := Block{
+	return Block{
 		Header: header,
 		Txs:    txs,
 	}
-
-	block.Mine() // 开始 POW
-
-	return block
 }
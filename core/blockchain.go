@@ -1,23 +1,126 @@
 package core
 
-import "bytes"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
 
-// Blockchain 就是一串 Block
+	"mychain/utils"
+)
+
+var (
+	ErrKnownBlock      = errors.New("block already known")
+	ErrUnknownParent   = errors.New("parent block unknown")
+	ErrInvalidAncestor = errors.New("block builds on an invalid block")
+)
+
+// blockNode 是区块树中的一个节点
+type blockNode struct {
+	block  *Block
+	parent *blockNode
+	height int
+	work   *big.Int // 从创世块到本区块的累计工作量
+	undo   Undo     // 本区块在主链上时，用于把它断开的回滚日志
+
+	validators []Validator // PoS：本区块执行完之后的验证者集合，区块执行过之后才有（见 pos.go）
+
+	issued, burned uint64 // 本区块执行完之后的累计发行量与销毁量，区块执行过之后才有（见 monetary.go）
+}
+
+// ancestor 返回本节点在指定高度上的祖先
+func (n *blockNode) ancestor(height int) *blockNode {
+	if height < 0 || height > n.height {
+		return nil
+	}
+	for n != nil && n.height > height {
+		n = n.parent
+	}
+	return n
+}
+
+// Blockchain 保存所有已知区块组成的树，Blocks 是其中累计工作量最大的那条主链
 type Blockchain struct {
-	Blocks   []Block          `json:"blocks"`
-	Balances map[string]int64 `json:"-"`
+	Blocks []Block       `json:"blocks"` // 当前主链（Blocks[0] 为创世块）
+	State  *State        `json:"-"`      // 主链最新区块执行完之后的状态
+	Params *ChainParams  `json:"-"`      // 链参数（记账模型等）
+	Clock  *NetworkClock `json:"-"`      // 网络调整时间，用于出块时间戳和拒绝时间戳太超前的区块
+	Engine Consensus     `json:"-"`      // 共识引擎（按链配置选择 POW / PoA / PoS），负责封装、校验封装与分叉选择
+
+	// 已校验过签名的交易，交易池与区块校验共用：交易入池时验过，区块到达时不再重复校验
+	SigCache *SigCache `json:"-"`
+
+	index   map[string]*blockNode // 区块哈希（hex）→ 节点，包括分叉链上的区块
+	tip     *blockNode            // 主链最新区块
+	invalid map[string]bool       // 已确认非法的区块，避免重复校验
+}
+
+// ChainUpdate 描述一次区块处理对主链造成的变化
+type ChainUpdate struct {
+	Connected    []Block // 新接入主链的区块（按高度升序）
+	Disconnected []Block // 因重组从主链断开的区块（按高度升序）
+}
+
+// Reorged 表示这次更新是否发生了链重组
+func (u *ChainUpdate) Reorged() bool {
+	return len(u.Disconnected) > 0
 }
 
 // 新建一个只包含创世块的区块链
-func NewBlockchain() *Blockchain {
-	genesis := NewGenesisBlock()
+func NewBlockchain(params *ChainParams) *Blockchain {
+	genesis := params.GenesisBlock()
 	bc := &Blockchain{
-		Blocks: []Block{genesis},
+		State:   NewState(),
+		Params:  params,
+		Clock:   NewNetworkClock(),
+		Engine:  NewEngine(params),
+		index:   make(map[string]*blockNode),
+		invalid: make(map[string]bool),
+
+		SigCache: NewSigCache(DefaultSigCacheSize),
+	}
+
+	node := &blockNode{
+		block:  &genesis,
+		height: 0,
+		work:   bc.Engine.Work(genesis.Header),
 	}
-	bc.RebuildBalances() // 基于区块重新计算一次余额表（创世块一般没有交易）
+	node.undo, _ = ApplyBlock(params, bc.State, node.block) // 创世块一般没有交易
+	bc.recordValidators(node)
+	bc.recordSupply(node)
+	bc.index[utils.ToHex(genesis.Header.Hash)] = node
+	bc.tip = node
+	bc.Blocks = []Block{genesis}
 	return bc
 }
 
+// NewBlockchainFromBlocks 用一条完整的链（例如从文件或邻居处得到）构造区块链，
+// 创世块必须与本地一致，之后每个区块都要通过完整校验
+func NewBlockchainFromBlocks(blocks []Block, params *ChainParams) (*Blockchain, error) {
+	if len(blocks) == 0 {
+		return nil, errors.New("empty chain")
+	}
+	bc := NewBlockchain(params)
+	if blocks[0].Header == nil || !bytes.Equal(blocks[0].Header.Hash, bc.GenesisHash()) {
+		return nil, ErrGenesisMismatch
+	}
+
+	PreverifyBlocks(blocks[1:], bc.SigCache)
+	for i := 1; i < len(blocks); i++ {
+		if err := bc.AppendBlock(blocks[i]); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+	}
+	return bc, nil
+}
+
+// GenesisHash 返回本链创世块的哈希
+func (bc *Blockchain) GenesisHash() []byte {
+	return bc.Blocks[0].Header.Hash
+}
+
 // 获取最新区块
 func (bc *Blockchain) LatestBlock() *Block {
 	if len(bc.Blocks) == 0 {
@@ -26,134 +129,319 @@ func (bc *Blockchain) LatestBlock() *Block {
 	return &bc.Blocks[len(bc.Blocks)-1]
 }
 
-// AddBlock 使用给定的交易创建一个新区块并追加到链上
-func (bc *Blockchain) AddBlock(txs []Transaction) Block {
-	prev := bc.LatestBlock()
-	var prevHash []byte
-	if prev != nil {
-		prevHash = prev.Header.Hash
+// HasBlock 判断某个哈希的区块是否已在区块树中（主链或分叉）
+func (bc *Blockchain) HasBlock(hash []byte) bool {
+	_, ok := bc.index[utils.ToHex(hash)]
+	return ok
+}
+
+// GetBlock 按哈希查找区块（主链或分叉链），找不到返回 nil
+func (bc *Blockchain) GetBlock(hash []byte) *Block {
+	if n, ok := bc.index[utils.ToHex(hash)]; ok {
+		return n.block
 	}
+	return nil
+}
 
-	newBlock := NewBlock(prevHash, txs)
-	bc.Blocks = append(bc.Blocks, newBlock)
-	return newBlock
+// AddBlock 使用给定的交易在主链末尾封装一个新区块（POW 挖矿 / PoA、PoS 签名）并接入主链，封装期间会阻塞调用方
+func (bc *Blockchain) AddBlock(txs []Transaction) (Block, error) {
+	newBlock, err := bc.NewBlockTemplate(txs)
+	if err != nil {
+		return Block{}, err
+	}
+	if err := bc.Engine.Seal(context.Background(), &newBlock); err != nil {
+		return Block{}, err
+	}
+	if _, err := bc.ProcessBlock(newBlock); err != nil {
+		return Block{}, err
+	}
+	return newBlock, nil
 }
 
-// IsValid 检查整条链是否合法
-// 1. 每个块的 PreviousHash 是否等于前一个块的 Hash
-// 2. 每个块是否通过 POW 验证
-func (bc *Blockchain) IsValid() bool {
-	if len(bc.Blocks) == 0 {
-		return true
+// NewBlockTemplate 用给定的交易构造一个接在主链末尾、尚未封装的区块（已填好状态根和共识字段）
+func (bc *Blockchain) NewBlockTemplate(txs []Transaction) (Block, error) {
+	root, err := bc.nextStateRoot(txs)
+	if err != nil {
+		return Block{}, err
 	}
+	b := NewBlock(bc.tip.block.Header, bc.nextTimestamp(), root, txs)
+	if err := bc.Engine.Prepare(bc.tip, b.Header); err != nil {
+		return Block{}, err
+	}
+	if err := checkProposer(bc.Params, bc.tip.validators, b.Header); err != nil {
+		return Block{}, err
+	}
+	return b, nil
+}
 
-	for i := 1; i < len(bc.Blocks); i++ {
-		prev := &bc.Blocks[i-1]
-		curr := &bc.Blocks[i]
+// nextTimestamp 返回下一个区块使用的时间戳：网络调整时间，但至少比过去中位时间晚 1 秒
+func (bc *Blockchain) nextTimestamp() time.Time {
+	ts := bc.Clock.Now().Truncate(time.Second) // 编码中只保留到秒
+	if mtp := medianTimePast(bc.tip); !ts.After(mtp) {
+		ts = mtp.Add(time.Second)
+	}
+	return ts
+}
 
-		// 1. 链式结构验证
-		if !bytes.Equal(curr.Header.PreviousHash, prev.Header.Hash) {
-			return false
-		}
+// nextStateRoot 在当前状态上试执行下一个区块的 txs，返回执行后的状态根（状态随后恢复原样）
+func (bc *Blockchain) nextStateRoot(txs []Transaction) ([]byte, error) {
+	for i := range txs {
+		txs[i].CalculateHash()
+	}
+	mark := bc.State.Snapshot()
+	defer bc.State.RevertToSnapshot(mark)
+	next := &Block{Header: &BlockHeader{Height: bc.NextHeight()}, Txs: txs}
+	if err := executeBlock(bc.Params, bc.State, next); err != nil {
+		return nil, err
+	}
+	return bc.State.Root(), nil
+}
 
-		// 2. POW 验证
-		pow := NewPow(curr)
-		if !pow.Validate() {
-			return false
+// NextHeight 返回下一个区块的高度
+func (bc *Blockchain) NextHeight() uint64 {
+	return bc.tip.block.Header.Height + 1
+}
+
+// SpendableBalance 返回地址在下一个区块中可以花费的余额（不含尚未成熟的 coinbase）
+func (bc *Blockchain) SpendableBalance(addr string) int64 {
+	if bc.Params.Ledger == LedgerUTXO {
+		var sum int64
+		for _, u := range bc.State.UTXOsOf(addr) {
+			if bc.Params.Monetary.Mature(u, bc.NextHeight()) {
+				sum += int64(u.Value)
+			}
 		}
+		return sum
 	}
 
-	return true
+	mark := bc.State.Snapshot()
+	defer bc.State.RevertToSnapshot(mark)
+	bc.State.beginBlock(bc.NextHeight())
+	return bc.State.Spendable(addr)
 }
 
-// ReplaceIfLonger 在 newBlocks 更长且合法时，用它替换当前链。
-// 返回值表示是否发生了替换。
-func (bc *Blockchain) ReplaceIfLonger(newBlocks []Block) bool {
-	// 1. 长度不够，直接拒绝
-	if len(newBlocks) <= len(bc.Blocks) {
-		return false
+// NextBlockContext 返回在当前最新区块之后追加区块时使用的校验上下文
+func (bc *Blockchain) NextBlockContext() *BlockContext {
+	return &BlockContext{
+		Params: bc.Params,
+		Prev:   bc.tip.block,
+		Chain:  bc.tip,
+		Engine: bc.Engine,
+		State:  bc.State,
+
+		MedianTime: medianTimePast(bc.tip),
+		Now:        bc.Clock.Now(),
+		SigCache:   bc.SigCache,
 	}
+}
 
-	// 2. 校验新链是否有效
-	if !isValidChain(newBlocks) {
-		return false
+// AppendBlock 要求区块必须直接接在当前主链末尾
+func (bc *Blockchain) AppendBlock(b Block) error {
+	if b.Header == nil {
+		return ErrNoHeader
 	}
+	if !bytes.Equal(b.Header.PreviousHash, bc.tip.block.Header.Hash) {
+		return ErrPrevHashMismatch
+	}
+	_, err := bc.ProcessBlock(b)
+	return err
+}
 
-	// 3. 替换本地链
-	bc.Blocks = newBlocks
-	return true
+// ProcessBlock 把一个区块加入区块树，并按累计工作量选择主链：
+//   - 父区块未知：返回 ErrUnknownParent
+//   - 先做与状态无关的 CheckBlock，通过后记入区块树
+//   - PoS 链上出块者必须是按父区块执行完之后的质押选出的验证者（见 pos.go）
+//   - BFT 链上区块还必须带有效的提交证书，且不能与已提交（最终）的区块冲突（见 bft.go）
+//   - 若它所在分支的累计工作量超过当前主链，则切换主链（必要时重组）
+//
+// 返回的 ChainUpdate 描述主链的变化；区块只进入分叉时两个列表都为空。
+func (bc *Blockchain) ProcessBlock(b Block) (*ChainUpdate, error) {
+	if b.Header == nil {
+		return nil, ErrNoHeader
+	}
+	key := utils.ToHex(b.Header.Hash)
+	if _, ok := bc.index[key]; ok {
+		return nil, ErrKnownBlock
+	}
+	if bc.invalid[key] {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAncestor, key)
+	}
+
+	parentKey := utils.ToHex(b.Header.PreviousHash)
+	if bc.invalid[parentKey] {
+		bc.invalid[key] = true
+		return nil, ErrInvalidAncestor
+	}
+	parent, ok := bc.index[parentKey]
+	if !ok {
+		return nil, ErrUnknownParent
+	}
+
+	ctx := &BlockContext{
+		Params:     bc.Params,
+		Prev:       parent.block,
+		Chain:      parent,
+		Engine:     bc.Engine,
+		MedianTime: medianTimePast(parent),
+		Now:        bc.Clock.Now(),
+		SigCache:   bc.SigCache,
+	}
+	if err := CheckBlock(&b, ctx); err != nil {
+		return nil, err
+	}
+	if err := bc.checkCommit(&b, parent.height+1); err != nil {
+		return nil, err
+	}
+	if err := bc.checkStakedProposer(&b, parent); err != nil {
+		return nil, err
+	}
+
+	node := &blockNode{
+		block:  &b,
+		parent: parent,
+		height: parent.height + 1,
+		work:   new(big.Int).Add(parent.work, bc.Engine.Work(b.Header)),
+	}
+	bc.index[key] = node
+
+	// 累计工作量相同的情况下保留先收到的链
+	if node.work.Cmp(bc.tip.work) <= 0 {
+		return &ChainUpdate{}, nil
+	}
+	return bc.reorganize(node)
 }
 
-// isValidChain 用于在不修改当前 bc 的前提下，验证一条区块链是否有效
-// 除了检查 prevHash / POW 以外，还会模拟一份 state，防止明显的余额为负情况。
-func isValidChain(blocks []Block) bool {
-	if len(blocks) == 0 {
-		return false
+// reorganize 把主链切换到以 newTip 结尾的分支：
+// 先从旧主链末尾逐个断开到分叉点（用 Undo 恢复状态），再按顺序接入新分支。
+// 新分支中任何一个区块执行失败，都会回滚到原来的主链。
+func (bc *Blockchain) reorganize(newTip *blockNode) (*ChainUpdate, error) {
+	fork := findFork(bc.tip, newTip)
+
+	// 1. 断开旧主链上分叉点之后的区块（从最新往回）
+	var detached []*blockNode
+	for n := bc.tip; n != fork; n = n.parent {
+		n.undo.Revert()
+		n.undo = nil
+		detached = append(detached, n)
 	}
 
-	state := make(map[string]int64)
+	// 2. 依次接入新分支（从分叉点往后）
+	attach := make([]*blockNode, newTip.height-fork.height)
+	for n := newTip; n != fork; n = n.parent {
+		attach[n.height-fork.height-1] = n
+	}
 
-	for i := 0; i < len(blocks); i++ {
-		cur := blocks[i]
+	for i, n := range attach {
+		undo, err := ApplyBlock(bc.Params, bc.State, n.block)
+		if err != nil {
+			bc.markInvalid(n)
 
-		// 1. 前后区块的前驱 Hash 必须一致
-		if i > 0 {
-			prev := blocks[i-1]
-			if !bytes.Equal(cur.Header.PreviousHash, prev.Header.Hash) {
-				return false
+			// 撤销已接入的新区块，重新接回旧主链
+			for j := i - 1; j >= 0; j-- {
+				attach[j].undo.Revert()
+				attach[j].undo = nil
 			}
+			for j := len(detached) - 1; j >= 0; j-- {
+				detached[j].undo, _ = ApplyBlock(bc.Params, bc.State, detached[j].block)
+			}
+			return nil, fmt.Errorf("block %s: %w", utils.ToHex(n.block.Header.Hash), err)
 		}
+		n.undo = undo
+		bc.recordValidators(n)
+		bc.recordSupply(n)
+	}
 
-		// 2. POW 验证
-		pow := NewPow(&cur)
-		if !pow.Validate() {
-			return false
-		}
+	// 3. 更新主链
+	bc.tip = newTip
+	bc.Blocks = bc.Blocks[:fork.height+1]
+	update := &ChainUpdate{}
+	for _, n := range attach {
+		bc.Blocks = append(bc.Blocks, *n.block)
+		update.Connected = append(update.Connected, *n.block)
+	}
+	for i := len(detached) - 1; i >= 0; i-- {
+		update.Disconnected = append(update.Disconnected, *detached[i].block)
+	}
+	return update, nil
+}
 
-		// 3. 用一个临时 state 模拟余额变化，发现余额 < 0 直接判不合法
-		for _, tx := range cur.Txs {
-			amount := int64(tx.Value)
+// findFork 返回两个节点的最近公共祖先
+func findFork(a, b *blockNode) *blockNode {
+	if a.height > b.height {
+		a = a.ancestor(b.height)
+	} else {
+		b = b.ancestor(a.height)
+	}
+	for a != b {
+		a = a.parent
+		b = b.parent
+	}
+	return a
+}
 
-			if tx.From != "" && tx.From != "COINBASE" {
-				if state[tx.From] < amount {
-					// 说明在这条链的执行过程中，这个账户曾经出现过“余额不足”
-					return false
-				}
-				state[tx.From] -= amount
-			}
-			if tx.To != "" {
-				state[tx.To] += amount
-			}
+// markInvalid 把 n 及其所有后代从区块树中移除并标记为非法
+func (bc *Blockchain) markInvalid(n *blockNode) {
+	for key, other := range bc.index {
+		if other.height >= n.height && other.ancestor(n.height) == n {
+			delete(bc.index, key)
+			bc.invalid[key] = true
 		}
 	}
-	return true
 }
 
-// RebuildBalances 从头扫描整条链，重建账户余额表。
-// 约定：
-//   - 普通交易：From 账户减去 Value，To 账户加上 Value
-//   - 挖矿奖励：From == "COINBASE"，只给 To 加钱，不扣任何人
-func (bc *Blockchain) RebuildBalances() {
-	bc.Balances = make(map[string]int64)
+// IsValid 检查整条主链是否合法（从创世块开始重新校验一遍）
+func (bc *Blockchain) IsValid() bool {
+	return ValidateChain(bc.Blocks, bc.Params) == nil
+}
+
+// ImportChain 把邻居发来的整条链逐个交给 ProcessBlock，
+// 主链是否切换由累计工作量决定，而不是链的长度。
+// 返回所有区块处理过程中主链变化的汇总（中途出错时也会返回已发生的变化）。
+func (bc *Blockchain) ImportChain(blocks []Block) (*ChainUpdate, error) {
+	update := &ChainUpdate{}
+	if len(blocks) == 0 {
+		return update, errors.New("empty chain")
+	}
+	if blocks[0].Header == nil || !bytes.Equal(blocks[0].Header.Hash, bc.GenesisHash()) {
+		return update, ErrGenesisMismatch
+	}
 
-	for _, block := range bc.Blocks {
-		for _, tx := range block.Txs {
-			amount := int64(tx.Value)
+	// 先并行校验所有新区块的签名，之后逐个处理时直接命中缓存
+	var fresh []Block
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].Header != nil && !bc.HasBlock(blocks[i].Header.Hash) {
+			fresh = append(fresh, blocks[i])
+		}
+	}
+	PreverifyBlocks(fresh, bc.SigCache)
 
-			if tx.From != "" && tx.From != "COINBASE" {
-				bc.Balances[tx.From] -= amount
-			}
-			if tx.To != "" {
-				bc.Balances[tx.To] += amount
-			}
+	for i := 1; i < len(blocks); i++ {
+		u, err := bc.ProcessBlock(blocks[i])
+		if errors.Is(err, ErrKnownBlock) {
+			continue
 		}
+		if err != nil {
+			return update, fmt.Errorf("block %d: %w", i, err)
+		}
+		update.Disconnected = append(update.Disconnected, u.Disconnected...)
+		update.Connected = append(update.Connected, u.Connected...)
 	}
+	return update, nil
+}
+
+// ValidateChain 在不修改当前链的前提下，验证一条区块链是否有效：
+// 创世块必须与本地一致，之后每个区块都要通过 ValidateBlock
+func ValidateChain(blocks []Block, params *ChainParams) error {
+	_, err := NewBlockchainFromBlocks(blocks, params)
+	return err
+}
+
+// TotalWork 返回主链的累计工作量
+func (bc *Blockchain) TotalWork() *big.Int {
+	return new(big.Int).Set(bc.tip.work)
 }
 
 // GetBalance 返回某个地址当前在链上的余额（不包含 mempool 未确认交易的影响）
 func (bc *Blockchain) GetBalance(addr string) int64 {
-	if bc.Balances == nil {
-		bc.RebuildBalances()
-	}
-	return bc.Balances[addr]
+	return bc.State.Balance(addr)
 }
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+// loadRegtest 返回回归测试网络的链参数：难度极低，挖矿瞬间完成，coinbase 立即成熟
+func loadRegtest(t *testing.T) *ChainParams {
+	t.Helper()
+	p, err := LoadNetwork("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// mineBlocks 在 bc 的主链末尾依次挖 n 个只含 coinbase 的区块，奖励给 payee
+func mineBlocks(t *testing.T, bc *Blockchain, payee string, n int) []Block {
+	t.Helper()
+	var blocks []Block
+	for i := 0; i < n; i++ {
+		height := bc.NextHeight()
+		coinbase := bc.Params.NewCoinbase(height, payee, uint32(bc.Params.Monetary.Subsidy(height)))
+		b, err := bc.AddBlock([]Transaction{coinbase})
+		if err != nil {
+			t.Fatalf("mine block %d: %v", len(bc.Blocks), err)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// processAll 把 blocks 依次交给 bc.ProcessBlock，返回最后一次的主链变化
+func processAll(t *testing.T, bc *Blockchain, blocks []Block) *ChainUpdate {
+	t.Helper()
+	var update *ChainUpdate
+	for _, b := range blocks {
+		u, err := bc.ProcessBlock(b)
+		if err != nil {
+			t.Fatalf("process block %x: %v", b.Header.Hash, err)
+		}
+		update = u
+	}
+	return update
+}
+
+func TestReorgSwitchesToHeavierBranch(t *testing.T) {
+	p := loadRegtest(t)
+	reward := int64(p.Monetary.Subsidy(1))
+
+	// 两条从同一创世块分出的链：a 挖 2 块给 alice，b 挖 3 块给 bob
+	a := mineBlocks(t, NewBlockchain(p), "alice", 2)
+	b := mineBlocks(t, NewBlockchain(p), "bob", 3)
+
+	bc := NewBlockchain(p)
+	genesisBalances := maps.Clone(bc.State.Balances)
+	processAll(t, bc, a)
+	if got := bc.GetBalance("alice"); got != 2*reward {
+		t.Fatalf("alice balance = %d, want %d", got, 2*reward)
+	}
+
+	// 分叉链的前两块累计工作量不超过主链，只进入区块树
+	if u := processAll(t, bc, b[:2]); len(u.Connected) != 0 || len(u.Disconnected) != 0 {
+		t.Fatalf("equal-work fork changed the main chain: %+v", u)
+	}
+	if !bytes.Equal(bc.LatestBlock().Header.Hash, a[1].Header.Hash) {
+		t.Fatal("tip moved to a fork with equal work")
+	}
+
+	// 第三块让分叉链更重：断开 a 的两块，接入 b 的三块
+	u := processAll(t, bc, b[2:])
+	if len(u.Disconnected) != 2 || len(u.Connected) != 3 {
+		t.Fatalf("reorg disconnected %d, connected %d; want 2 and 3", len(u.Disconnected), len(u.Connected))
+	}
+	if !u.Reorged() {
+		t.Fatal("Reorged() = false after switching branches")
+	}
+	if got := bc.GetBalance("alice"); got != 0 {
+		t.Fatalf("alice balance after reorg = %d, want 0", got)
+	}
+	if got := bc.GetBalance("bob"); got != 3*reward {
+		t.Fatalf("bob balance after reorg = %d, want %d", got, 3*reward)
+	}
+	if len(bc.Blocks) != 4 || !bytes.Equal(bc.Blocks[3].Header.Hash, b[2].Header.Hash) {
+		t.Fatalf("main chain has %d blocks, tip %x", len(bc.Blocks), bc.LatestBlock().Header.Hash)
+	}
+
+	// 撤销全部区块后状态回到创世块
+	for i := len(bc.Blocks) - 1; i > 0; i-- {
+		bc.index[utils.ToHex(bc.Blocks[i].Header.Hash)].undo.Revert()
+	}
+	if !maps.Equal(bc.State.Balances, genesisBalances) {
+		t.Fatalf("balances after undoing every block = %v, want %v", bc.State.Balances, genesisBalances)
+	}
+}
+
+func TestReorgRollsBackOnInvalidBlock(t *testing.T) {
+	p := loadRegtest(t)
+	a := mineBlocks(t, NewBlockchain(p), "alice", 1)
+
+	// 分叉链第 2 块花了 mallory 没有的钱：签名正确，只有在接入主链、真正执行时才会被发现
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork := NewBlockchain(p)
+	b := mineBlocks(t, fork, "bob", 1)
+	spend := Transaction{From: utils.PubKeyToAddress(pub), To: "bob", Value: 1, Timestamp: time.Now()}
+	if err := spend.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	coinbase := fork.Params.NewCoinbase(2, "bob", uint32(fork.Params.Monetary.Subsidy(2)))
+	bad := NewBlock(b[0].Header, b[0].Header.Timestamp.Add(time.Second), nil, []Transaction{coinbase, spend})
+	if err := fork.Engine.Prepare(fork.tip, bad.Header); err != nil {
+		t.Fatal(err)
+	}
+	bad.Mine()
+
+	bc := NewBlockchain(p)
+	processAll(t, bc, a)
+	before := maps.Clone(bc.State.Balances)
+	processAll(t, bc, b)
+	if _, err := bc.ProcessBlock(bad); !errors.Is(err, ErrOverspend) {
+		t.Fatalf("block spending a missing balance: err = %v, want ErrOverspend", err)
+	}
+
+	if !bytes.Equal(bc.LatestBlock().Header.Hash, a[0].Header.Hash) {
+		t.Fatal("main chain did not roll back to the original tip")
+	}
+	if !maps.Equal(bc.State.Balances, before) {
+		t.Fatalf("balances after failed reorg = %v, want %v", bc.State.Balances, before)
+	}
+	if got := bc.GetBalance("bob"); got != 0 {
+		t.Fatalf("bob balance after failed reorg = %d, want 0", got)
+	}
+}
@@ -0,0 +1,99 @@
+package bytecode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 字节码的文本形式（汇编）：以空白分隔的记号，# 之后到行尾为注释，
+//   - OP_XXX：操作码，如 OP_DUP、OP_CHECKSIG、OP_2
+//   - 0x<hex>：压入一段数据，如公钥、签名、哈希（"0x" 压入空串）
+//   - 'text'：压入一段文本（不能含空白），常用作合约的方法名和存储键
+//   - 十进制整数：压入数字，如 2、-1、1700000000
+//   - name:：定义标签，同时生成一条跳转目标指令（只有支持跳转的指令集可用）
+//   - @name：压入标签的偏移，配合跳转指令使用
+//
+// 例如哈希锁 + 签名：OP_SHA256 0x<哈希> OP_EQUALVERIFY 0x<公钥> OP_CHECKSIG
+
+// Assemble 把汇编文本编译成字节码，并检查结果能被 Parse 解析
+func (s *InstructionSet) Assemble(src string) ([]byte, error) {
+	var toks []string
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		toks = append(toks, strings.Fields(line)...)
+	}
+
+	// 两遍：第一遍确定标签的偏移（标签引用固定占 3 字节），第二遍生成代码
+	labels := make(map[string]int)
+	var out []byte
+	for pass := 0; pass < 2; pass++ {
+		out = out[:0]
+		for _, tok := range toks {
+			switch {
+			case s.jumpDest != 0 && strings.HasSuffix(tok, ":") && len(tok) > 1:
+				name := tok[:len(tok)-1]
+				if _, ok := labels[name]; ok && pass == 0 {
+					return nil, fmt.Errorf("%w: duplicate label %q", ErrBadAssembly, name)
+				}
+				labels[name] = len(out)
+				out = append(out, s.jumpDest)
+			case s.jumpDest != 0 && strings.HasPrefix(tok, "@"):
+				off, ok := labels[tok[1:]]
+				if !ok && pass == 1 {
+					return nil, fmt.Errorf("%w: undefined label %q", ErrBadAssembly, tok[1:])
+				}
+				out = append(out, pushLabel(off)...)
+			case strings.HasPrefix(tok, "OP_"):
+				op, ok := s.byName[tok]
+				if !ok || op == OP_PUSHDATA1 || op == OP_PUSHDATA2 {
+					return nil, fmt.Errorf("%w: %s", ErrUnknownOpcode, tok)
+				}
+				out = append(out, op)
+			case strings.HasPrefix(tok, "0x"):
+				data, err := hex.DecodeString(tok[2:])
+				if err != nil {
+					return nil, fmt.Errorf("%w: bad hex %q", ErrBadAssembly, tok)
+				}
+				out = append(out, PushData(data)...)
+			case len(tok) >= 2 && strings.HasPrefix(tok, "'") && strings.HasSuffix(tok, "'"):
+				out = append(out, PushData([]byte(tok[1:len(tok)-1]))...)
+			default:
+				n, err := strconv.ParseInt(tok, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: unknown token %q", ErrBadAssembly, tok)
+				}
+				out = append(out, PushNum(n)...)
+			}
+		}
+	}
+	if _, err := s.Parse(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pushLabel 用固定的 2 字节数字压入标签偏移，这样第一遍就能算出所有标签的位置
+func pushLabel(off int) []byte {
+	return []byte{2, byte(off), byte(off >> 8)}
+}
+
+// Disassemble 把字节码还原成汇编文本（标签显示为跳转目标指令，标签引用显示为数字数据）
+func (s *InstructionSet) Disassemble(code []byte) (string, error) {
+	ins, err := s.Parse(code)
+	if err != nil {
+		return "", err
+	}
+	toks := make([]string, len(ins))
+	for i, in := range ins {
+		if in.Op > OP_0 && in.Op <= OP_PUSHDATA2 {
+			toks[i] = "0x" + hex.EncodeToString(in.Data)
+		} else {
+			toks[i] = s.Name(in.Op)
+		}
+	}
+	return strings.Join(toks, " "), nil
+}
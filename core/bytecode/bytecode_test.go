@@ -0,0 +1,198 @@
+package bytecode
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+const (
+	opNop  byte = 0x61
+	opDest byte = 0x62
+)
+
+// 测试用的指令集：压栈之外只有 OP_NOP 和作为标签的 OP_DEST
+var testSet = NewInstructionSet(map[byte]string{opNop: "OP_NOP", opDest: "OP_DEST"}, opDest)
+
+func TestNumEncoding(t *testing.T) {
+	tests := []struct {
+		n   int64
+		enc []byte
+	}{
+		{0, nil},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{255, []byte{0xff, 0x00}},
+		{-255, []byte{0xff, 0x80}},
+		{256, []byte{0x00, 0x01}},
+		{math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{-math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := EncodeNum(tt.n); !bytes.Equal(got, tt.enc) {
+			t.Errorf("EncodeNum(%d) = %x, want %x", tt.n, got, tt.enc)
+		}
+		if got, err := DecodeNum(tt.enc); err != nil || got != tt.n {
+			t.Errorf("DecodeNum(%x) = %d, %v, want %d", tt.enc, got, err, tt.n)
+		}
+	}
+
+	// 非最短编码同样可以解码，“负 0”是 0
+	for enc, want := range map[string]int64{"\x01\x00": 1, "\x00\x80": 0, "\x05\x00\x80": -5} {
+		if got, err := DecodeNum([]byte(enc)); err != nil || got != want {
+			t.Errorf("DecodeNum(%x) = %d, %v, want %d", enc, got, err, want)
+		}
+	}
+	if _, err := DecodeNum(make([]byte, MaxNumSize+1)); !errors.Is(err, ErrNumOverflow) {
+		t.Errorf("%d-byte number: err = %v, want ErrNumOverflow", MaxNumSize+1, err)
+	}
+}
+
+func TestAsBool(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want bool
+	}{
+		{nil, false},
+		{[]byte{0}, false},
+		{[]byte{0, 0, 0}, false},
+		{[]byte{0x80}, false},
+		{[]byte{0, 0x80}, false},
+		{[]byte{0x80, 0}, true},
+		{[]byte{0, 1}, true},
+		{[]byte{1}, true},
+	}
+	for _, tt := range tests {
+		if got := AsBool(tt.b); got != tt.want {
+			t.Errorf("AsBool(%x) = %v, want %v", tt.b, got, tt.want)
+		}
+	}
+	if AsBool(FromBool(false)) || !AsBool(FromBool(true)) {
+		t.Error("FromBool does not round-trip")
+	}
+}
+
+func TestPushEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		push   []byte
+		prefix []byte
+	}{
+		{"empty", PushData(nil), []byte{OP_0}},
+		{"direct", PushData(make([]byte, 75)), []byte{75}},
+		{"pushdata1 lower bound", PushData(make([]byte, 76)), []byte{OP_PUSHDATA1, 76}},
+		{"pushdata1 upper bound", PushData(make([]byte, 255)), []byte{OP_PUSHDATA1, 255}},
+		{"pushdata2", PushData(make([]byte, 256)), []byte{OP_PUSHDATA2, 0x01, 0x00}},
+		{"zero", PushNum(0), []byte{OP_0}},
+		{"minus one", PushNum(-1), []byte{OP_1NEGATE}},
+		{"small number", PushNum(16), []byte{OP_16}},
+		{"large number", PushNum(17), []byte{1, 17}},
+		{"negative number", PushNum(-2), []byte{1, 0x82}},
+	}
+	for _, tt := range tests {
+		if !bytes.HasPrefix(tt.push, tt.prefix) {
+			t.Errorf("%s: push %x, want prefix %x", tt.name, tt.push[:min(len(tt.push), 3)], tt.prefix)
+			continue
+		}
+		// 每种压栈指令都解析成一条指令，压入的值与原数据一致
+		ins, err := testSet.Parse(tt.push)
+		if err != nil || len(ins) != 1 || !ins[0].IsPush() {
+			t.Errorf("%s: parse %v, err %v", tt.name, ins, err)
+		}
+	}
+	for _, n := range []int64{-1, 0, 1, 16, 17, -1000, math.MaxInt64} {
+		ins, err := testSet.Parse(PushNum(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := DecodeNum(ins[0].PushValue()); err != nil || got != n {
+			t.Errorf("PushNum(%d) pushes %d, %v", n, got, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		want error
+	}{
+		{"truncated pushdata1", []byte{OP_PUSHDATA1}, ErrBadBytecode},
+		{"truncated pushdata2", []byte{OP_PUSHDATA2, 0x01}, ErrBadBytecode},
+		{"push past end", []byte{3, 1, 2}, ErrBadBytecode},
+		{"pushdata1 past end", []byte{OP_PUSHDATA1, 2, 1}, ErrBadBytecode},
+		{"element too large", append([]byte{OP_PUSHDATA2, 0x02, 0x09}, make([]byte, MaxElementSize+1)...), ErrElementTooLarge},
+		{"unknown opcode", []byte{opNop, 0xfe}, ErrUnknownOpcode},
+		{"opcode of another set", []byte{0x63}, ErrUnknownOpcode},
+	}
+	for _, tt := range tests {
+		if _, err := testSet.Parse(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	code := append(append([]byte{opNop}, PushData([]byte("ab"))...), OP_1NEGATE)
+	ins, err := testSet.Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ins) != 3 || ins[1].Offset != 1 || string(ins[1].Data) != "ab" || ins[2].Offset != 4 {
+		t.Fatalf("instructions %+v", ins)
+	}
+	if testSet.IsPushOnly(code) || !testSet.IsPushOnly(code[1:]) || testSet.IsPushOnly([]byte{OP_PUSHDATA1}) {
+		t.Fatal("IsPushOnly")
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	code, err := testSet.Assemble(`
+		# 注释与换行都是空白
+		@end OP_NOP 0x 0xabcd 'text' -1 0 16 17
+		end: OP_NOP`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 标签引用固定占 3 字节，标签 end 位于偏移 18
+	want := []byte{2, 18, 0, opNop, OP_0, 2, 0xab, 0xcd, 4, 't', 'e', 'x', 't', OP_1NEGATE, OP_0, OP_16, 1, 17, opDest, opNop}
+	if !bytes.Equal(code, want) {
+		t.Fatalf("assembled %x, want %x", code, want)
+	}
+
+	text, err := testSet.Disassemble(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "0x1200 OP_NOP OP_0 0xabcd 0x74657874 OP_1NEGATE OP_0 OP_16 0x11 OP_DEST OP_NOP" {
+		t.Fatalf("disassembled %q", text)
+	}
+	again, err := testSet.Assemble(text)
+	if err != nil || !bytes.Equal(again, code) {
+		t.Fatalf("disassembly does not round-trip: %x, %v", again, err)
+	}
+
+	noLabels := NewInstructionSet(map[byte]string{opNop: "OP_NOP"}, 0)
+	bad := []struct {
+		name string
+		set  *InstructionSet
+		src  string
+		want error
+	}{
+		{"duplicate label", testSet, "a: a:", ErrBadAssembly},
+		{"undefined label", testSet, "@nowhere", ErrBadAssembly},
+		{"labels not supported", noLabels, "a: OP_NOP", ErrBadAssembly},
+		{"bad hex", testSet, "0xabc", ErrBadAssembly},
+		{"unknown token", testSet, "hello", ErrBadAssembly},
+		{"unknown opcode", testSet, "OP_FOO", ErrUnknownOpcode},
+		{"raw pushdata", testSet, "OP_PUSHDATA2", ErrUnknownOpcode},
+		{"element too large", testSet, "0x" + string(bytes.Repeat([]byte("00"), MaxElementSize+1)), ErrElementTooLarge},
+	}
+	for _, tt := range bad {
+		if _, err := tt.set.Assemble(tt.src); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
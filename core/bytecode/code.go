@@ -0,0 +1,185 @@
+// Package bytecode 是脚本（core/script）与合约（core/vm）共用的字节码基础：
+// 栈上数字的编码、数据压栈指令、把字节码解析成指令序列，以及汇编 / 反汇编。
+// 两种虚拟机各自定义压栈之外的操作码，用 NewInstructionSet 得到自己的指令集。
+package bytecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 压栈操作码，两种虚拟机相同，取值与比特币脚本一致
+const (
+	OP_0         byte = 0x00 // 压入空字节串（即 false / 0）
+	OP_PUSHDATA1 byte = 0x4c // 后跟 u8 长度 + 数据
+	OP_PUSHDATA2 byte = 0x4d // 后跟 u16 长度（大端序）+ 数据
+	OP_1NEGATE   byte = 0x4f // 压入 -1
+	OP_1         byte = 0x51 // OP_1 ~ OP_16 压入 1 ~ 16
+	OP_16        byte = 0x60
+)
+
+// 字节码限制
+const (
+	MaxElementSize = 520 // 单个栈元素最多多少字节
+	MaxNumSize     = 8   // 作为数字使用的栈元素最多多少字节
+)
+
+var (
+	ErrBadBytecode     = errors.New("malformed bytecode")
+	ErrUnknownOpcode   = errors.New("unknown opcode")
+	ErrElementTooLarge = errors.New("stack element too large")
+	ErrNumOverflow     = errors.New("number out of range")
+	ErrBadAssembly     = errors.New("invalid assembly")
+)
+
+// Instruction 是解析后的一条指令：所在的字节偏移、操作码，以及压栈指令携带的数据
+type Instruction struct {
+	Offset int
+	Op     byte
+	Data   []byte
+}
+
+// IsPush 判断指令是否只是压入数据（OP_0、直接压栈、PUSHDATA、OP_1NEGATE、OP_1 ~ OP_16）
+func (in Instruction) IsPush() bool {
+	return in.Op <= OP_PUSHDATA2 || in.Op == OP_1NEGATE || (in.Op >= OP_1 && in.Op <= OP_16)
+}
+
+// PushValue 返回压栈指令压入的栈元素（OP_1NEGATE、OP_1 ~ OP_16 压入对应的数字）
+func (in Instruction) PushValue() []byte {
+	switch {
+	case in.Op == OP_1NEGATE:
+		return EncodeNum(-1)
+	case in.Op >= OP_1 && in.Op <= OP_16:
+		return EncodeNum(int64(in.Op - OP_1 + 1))
+	}
+	return in.Data
+}
+
+// InstructionSet 是一种虚拟机的指令集：操作码 → 名称，不在表中的操作码（除数据压栈外）都是非法的
+type InstructionSet struct {
+	names    map[byte]string
+	byName   map[string]byte
+	jumpDest byte // 汇编标签生成的操作码，0 表示不支持标签
+}
+
+// NewInstructionSet 用虚拟机自己的操作码（ops：操作码 → 名称）加上共用的压栈操作码组成指令集。
+// jumpDest 不为 0 时汇编支持标签（见 Assemble）
+func NewInstructionSet(ops map[byte]string, jumpDest byte) *InstructionSet {
+	s := &InstructionSet{
+		names: map[byte]string{
+			OP_0: "OP_0", OP_PUSHDATA1: "OP_PUSHDATA1", OP_PUSHDATA2: "OP_PUSHDATA2", OP_1NEGATE: "OP_1NEGATE",
+		},
+		byName:   make(map[string]byte),
+		jumpDest: jumpDest,
+	}
+	for n := byte(1); n <= 16; n++ {
+		s.names[OP_1+n-1] = fmt.Sprintf("OP_%d", n)
+	}
+	for op, name := range ops {
+		s.names[op] = name
+	}
+	for op, name := range s.names {
+		s.byName[name] = op
+	}
+	return s
+}
+
+// Name 返回操作码的名称，用于错误信息和反汇编
+func (s *InstructionSet) Name(op byte) string {
+	if name, ok := s.names[op]; ok {
+		return name
+	}
+	if op > OP_0 && op < OP_PUSHDATA1 {
+		return fmt.Sprintf("PUSH(%d)", op)
+	}
+	return fmt.Sprintf("0x%02x", op)
+}
+
+// Parse 把字节码解析成指令序列，长度越界、数据过长或未知操作码都视为非法。
+// 执行前先完整解析一遍，未被执行的分支中的错误同样会被发现。总长度的上限由各虚拟机检查
+func (s *InstructionSet) Parse(code []byte) ([]Instruction, error) {
+	var ins []Instruction
+	for i := 0; i < len(code); {
+		in := Instruction{Offset: i, Op: code[i]}
+		i++
+
+		n := -1
+		switch {
+		case in.Op > OP_0 && in.Op < OP_PUSHDATA1:
+			n = int(in.Op)
+		case in.Op == OP_PUSHDATA1:
+			if i+1 > len(code) {
+				return nil, fmt.Errorf("%w: truncated OP_PUSHDATA1", ErrBadBytecode)
+			}
+			n = int(code[i])
+			i++
+		case in.Op == OP_PUSHDATA2:
+			if i+2 > len(code) {
+				return nil, fmt.Errorf("%w: truncated OP_PUSHDATA2", ErrBadBytecode)
+			}
+			n = int(binary.BigEndian.Uint16(code[i:]))
+			i += 2
+		default:
+			if _, ok := s.names[in.Op]; !ok {
+				return nil, fmt.Errorf("%w: 0x%02x at offset %d", ErrUnknownOpcode, in.Op, in.Offset)
+			}
+		}
+		if n >= 0 {
+			if n > MaxElementSize {
+				return nil, fmt.Errorf("%w: push of %d bytes", ErrElementTooLarge, n)
+			}
+			if i+n > len(code) {
+				return nil, fmt.Errorf("%w: push of %d bytes past end of code", ErrBadBytecode, n)
+			}
+			in.Data = code[i : i+n]
+			i += n
+		}
+		ins = append(ins, in)
+	}
+	return ins, nil
+}
+
+// IsPushOnly 判断字节码是否只包含压栈指令（脚本的解锁部分与合约调用参数必须如此）
+func (s *InstructionSet) IsPushOnly(code []byte) bool {
+	ins, err := s.Parse(code)
+	if err != nil {
+		return false
+	}
+	for _, in := range ins {
+		if !in.IsPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// PushData 返回压入 data 的最短指令
+func PushData(data []byte) []byte {
+	n := len(data)
+	switch {
+	case n == 0:
+		return []byte{OP_0}
+	case n < int(OP_PUSHDATA1):
+		return append([]byte{byte(n)}, data...)
+	case n <= 0xff:
+		return append([]byte{OP_PUSHDATA1, byte(n)}, data...)
+	default:
+		b := []byte{OP_PUSHDATA2, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return append(b, data...)
+	}
+}
+
+// PushNum 返回压入数字 n 的最短指令
+func PushNum(n int64) []byte {
+	switch {
+	case n == 0:
+		return []byte{OP_0}
+	case n == -1:
+		return []byte{OP_1NEGATE}
+	case n >= 1 && n <= 16:
+		return []byte{OP_1 + byte(n-1)}
+	}
+	return PushData(EncodeNum(n))
+}
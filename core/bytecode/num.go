@@ -0,0 +1,74 @@
+package bytecode
+
+import "fmt"
+
+// 栈上的数字与比特币相同，用小端序的“符号 + 绝对值”表示：
+// 最高字节的最高位是符号位，0 编码为空字节串。作为数字使用的元素最多 MaxNumSize 字节。
+// 合约存储中的数字也用这种格式，查询结果可以用 DecodeNum 还原。
+
+// EncodeNum 把 n 编码成最短的栈元素
+func EncodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	neg := n < 0
+	abs := uint64(n)
+	if neg {
+		abs = uint64(-n)
+	}
+
+	var b []byte
+	for abs > 0 {
+		b = append(b, byte(abs))
+		abs >>= 8
+	}
+	// 最高字节的最高位已被占用时，额外补一个字节存放符号
+	if b[len(b)-1]&0x80 != 0 {
+		if neg {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0x00)
+		}
+	} else if neg {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+// DecodeNum 把栈元素解析为数字
+func DecodeNum(b []byte) (int64, error) {
+	if len(b) > MaxNumSize {
+		return 0, fmt.Errorf("%w: %d bytes (max %d)", ErrNumOverflow, len(b), MaxNumSize)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var abs uint64
+	for i, v := range b {
+		abs |= uint64(v) << (8 * i)
+	}
+	sign := uint64(0x80) << (8 * (len(b) - 1))
+	if abs&sign != 0 {
+		return -int64(abs &^ sign), nil
+	}
+	return int64(abs), nil
+}
+
+// AsBool 把栈元素当作布尔值：空串、全 0 以及“负 0”为 false，其余为 true
+func AsBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			// 最后一个字节只有符号位时仍是 0
+			return !(i == len(b)-1 && v == 0x80)
+		}
+	}
+	return false
+}
+
+// FromBool 把布尔值编码为栈元素：true 为 1，false 为空串
+func FromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
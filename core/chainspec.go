@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 内置网络的链配置文件，编译进程序中
+//
+//go:embed chainspecs/*.json
+var chainspecFS embed.FS
+
+// Networks 返回内置的网络名称
+func Networks() []string {
+	entries, _ := chainspecFS.ReadDir("chainspecs")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseChainSpec 解析 JSON 链配置，不认识的字段视为错误（防止拼写错误被悄悄忽略）
+func ParseChainSpec(data []byte) (*ChainParams, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var p ChainParams
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadChainSpec, err)
+	}
+	if p.Consensus.Engine == "" {
+		p.Consensus.Engine = EnginePoW
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadNetwork 加载内置网络 mainnet / testnet / regtest 的链参数
+func LoadNetwork(name string) (*ChainParams, error) {
+	data, err := chainspecFS.ReadFile("chainspecs/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown network %q (want one of %s)", name, strings.Join(Networks(), ", "))
+	}
+	return ParseChainSpec(data)
+}
+
+// LoadChainSpec 从文件加载自定义网络的链参数
+func LoadChainSpec(path string) (*ChainParams, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseChainSpec(data)
+}
+
+// LoadParams 按命令行参数选择链参数：给了 specPath 就读取该文件，否则加载内置网络 network（默认 mainnet）
+func LoadParams(network, specPath string) (*ChainParams, error) {
+	if specPath != "" {
+		return LoadChainSpec(specPath)
+	}
+	if network == "" {
+		network = DefaultParams.Name
+	}
+	return LoadNetwork(network)
+}
+
+func mustLoadNetwork(name string) ChainParams {
+	p, err := LoadNetwork(name)
+	if err != nil {
+		panic(err)
+	}
+	return *p
+}
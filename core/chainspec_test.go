@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinNetworksLoad(t *testing.T) {
+	seen := make(map[uint32]string)
+	genesis := make(map[string]string)
+	for _, name := range Networks() {
+		p, err := LoadNetwork(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if p.Name != name {
+			t.Fatalf("%s: spec is named %q", name, p.Name)
+		}
+		if other, ok := seen[p.NetworkID]; ok {
+			t.Fatalf("%s and %s share network id %d", name, other, p.NetworkID)
+		}
+		seen[p.NetworkID] = name
+
+		hash := string(p.GenesisBlock().Header.Hash)
+		if other, ok := genesis[hash]; ok {
+			t.Fatalf("%s and %s share a genesis block", name, other)
+		}
+		genesis[hash] = name
+	}
+	if _, err := LoadNetwork("nosuchnet"); err == nil {
+		t.Fatal("unknown network loaded")
+	}
+}
+
+// docs/ 下的示例链配置都必须能被解析并通过校验
+func TestExampleChainSpecsLoad(t *testing.T) {
+	paths, err := filepath.Glob("../docs/chainspec*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no example chain specs found")
+	}
+	for _, path := range paths {
+		p, err := LoadChainSpec(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		// 创世分配直接记入创世状态，并计入发行量
+		bc := NewBlockchain(p)
+		if bc.Engine.Name() != p.Consensus.Engine {
+			t.Fatalf("%s: engine %q, spec says %q", path, bc.Engine.Name(), p.Consensus.Engine)
+		}
+		var total uint64
+		for addr, value := range p.Genesis.Alloc {
+			if got := bc.GetBalance(addr); got != int64(value) {
+				t.Fatalf("%s: genesis balance of %s = %d, want %d", path, addr, got, value)
+			}
+			total += value
+		}
+		// PoS 的创世质押同样计入发行量
+		for addr, stake := range p.Consensus.Stakes {
+			if got := bc.State.Stake(addr); got != stake {
+				t.Fatalf("%s: genesis stake of %s = %d, want %d", path, addr, got, stake)
+			}
+			total += stake
+		}
+		s, err := bc.Supply(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Issued != total {
+			t.Fatalf("%s: genesis supply = %d, want %d", path, s.Issued, total)
+		}
+		if bytes.Equal(bc.GenesisHash(), DefaultParams.GenesisBlock().Header.Hash) {
+			t.Fatalf("%s: genesis equals mainnet genesis", path)
+		}
+	}
+}
+
+func TestParseChainSpecRejectsBadSpecs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"not json", `{`},
+		{"unknown field", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"difficulty":1}`},
+		{"missing name", `{"ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1}`},
+		{"bad ledger", `{"name":"x","ledger":"cash","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1}`},
+		{"bits easier than the limit", `{"name":"x","ledger":"account","initialBits":553713663,"targetBlockSeconds":1,"maxTxPerBlock":1}`},
+		{"zero bits", `{"name":"x","ledger":"account","initialBits":0,"targetBlockSeconds":1,"maxTxPerBlock":1}`},
+		{"zero block time", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":0,"maxTxPerBlock":1}`},
+		{"no transactions per block", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":0}`},
+		{"zero alloc", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"genesis":{"alloc":{"a":0}}}`},
+		{"alloc too large", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"genesis":{"alloc":{"a":4294967296}}}`},
+		{"unknown engine", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"consensus":{"engine":"pos"}}`},
+		{"poa without signers", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"consensus":{"engine":"poa"}}`},
+		{"duplicate signer", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"consensus":{"engine":"poa","signers":["a","a"]}}`},
+		{"reward too large", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"monetary":{"initialReward":4294967296}}`},
+		{"pow with signers", `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1,"consensus":{"engine":"pow","signers":["a"]}}`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseChainSpec([]byte(tt.spec)); !errors.Is(err, ErrBadChainSpec) {
+			t.Errorf("%s: err = %v, want ErrBadChainSpec", tt.name, err)
+		}
+	}
+
+	ok := `{"name":"x","ledger":"account","initialBits":520159231,"targetBlockSeconds":1,"maxTxPerBlock":1}`
+	if _, err := ParseChainSpec([]byte(ok)); err != nil {
+		t.Fatalf("minimal spec: %v", err)
+	}
+}
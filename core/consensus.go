@@ -0,0 +1,262 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"mychain/utils"
+)
+
+var (
+	ErrBadSeal   = errors.New("invalid block seal")
+	ErrNotSigner = errors.New("node is not an authorized signer")
+)
+
+// EngineName 是链配置中共识引擎的名称
+type EngineName string
+
+const (
+	// EnginePoW 工作量证明：矿工搜索 nonce，累计工作量最大的链为主链
+	EnginePoW EngineName = "pow"
+
+	// EnginePoA 权威证明：链配置中的一组签名者轮流对区块签名（见 poa.go）
+	EnginePoA EngineName = "poa"
+
+	// EnginePoS 权益证明：按质押加权选出每个高度的出块者，由它对区块签名（见 pos.go）
+	EnginePoS EngineName = "pos"
+
+	// EngineBFT 拜占庭容错：固定的一组验证者分轮投票，每个区块提交后即为最终（见 bft.go）
+	EngineBFT EngineName = "bft"
+)
+
+// ConsensusSpec 是链配置中的共识部分
+type ConsensusSpec struct {
+	Engine  EngineName `json:"engine"`            // pow / poa / pos / bft
+	Signers []string   `json:"signers,omitempty"` // PoA：按顺序轮流出块的签名者地址；BFT：验证者地址，按顺序轮流提议
+
+	// BFT：每一步（propose / prevote / precommit）的基础超时（毫秒），0 表示 DefaultRoundTimeout
+	RoundTimeoutMs uint64 `json:"roundTimeoutMs,omitempty"`
+
+	// PoS（见 stake.go）
+	Stakes          map[string]uint64 `json:"stakes,omitempty"`          // 创世时各验证者的质押
+	MinStake        uint64            `json:"minStake,omitempty"`        // 质押不少于它的地址才是验证者
+	UnbondingBlocks uint64            `json:"unbondingBlocks,omitempty"` // 取消质押的金额锁定多少个区块后回到余额
+	SlashPercent    uint64            `json:"slashPercent,omitempty"`    // 双签时销毁质押（含解锁中的部分）的百分比
+}
+
+func (c *ConsensusSpec) validate() error {
+	if c.Engine != EnginePoA && c.Engine != EngineBFT && len(c.Signers) > 0 {
+		return fmt.Errorf("%w: signers are only used by %q and %q", ErrBadChainSpec, EnginePoA, EngineBFT)
+	}
+	if c.Engine != EngineBFT && c.RoundTimeoutMs != 0 {
+		return fmt.Errorf("%w: roundTimeoutMs is only used by %q", ErrBadChainSpec, EngineBFT)
+	}
+	if c.Engine != EnginePoS && (len(c.Stakes) > 0 || c.MinStake != 0 || c.UnbondingBlocks != 0 || c.SlashPercent != 0) {
+		return fmt.Errorf("%w: staking parameters are only used by %q", ErrBadChainSpec, EnginePoS)
+	}
+	switch c.Engine {
+	case EnginePoW:
+	case EnginePoS:
+		return c.validateStaking()
+	case EnginePoA, EngineBFT:
+		if len(c.Signers) == 0 {
+			return fmt.Errorf("%w: %q needs at least one signer", ErrBadChainSpec, c.Engine)
+		}
+		seen := make(map[string]bool)
+		for _, addr := range c.Signers {
+			if addr == "" || seen[addr] {
+				return fmt.Errorf("%w: bad or duplicate signer %q", ErrBadChainSpec, addr)
+			}
+			seen[addr] = true
+		}
+	default:
+		return fmt.Errorf("%w: unknown consensus engine %q (want %q, %q, %q or %q)", ErrBadChainSpec, c.Engine, EnginePoW, EnginePoA, EnginePoS, EngineBFT)
+	}
+	return nil
+}
+
+// HeaderReader 按高度读取一条链上的区块头，校验区块时代表它的父区块及全部祖先，
+// 高度超出范围时返回 nil
+type HeaderReader interface {
+	HeaderAt(height uint64) *BlockHeader
+}
+
+// HeaderAt 返回本节点及其祖先中高度为 height 的区块头
+func (n *blockNode) HeaderAt(height uint64) *BlockHeader {
+	a := n.ancestor(int(height))
+	if a == nil {
+		return nil
+	}
+	return a.block.Header
+}
+
+// headerList 是从创世块开始、按高度排列的区块头（轻节点下载的区块头链）
+type headerList []*BlockHeader
+
+func (l headerList) HeaderAt(height uint64) *BlockHeader {
+	if height >= uint64(len(l)) {
+		return nil
+	}
+	return l[height]
+}
+
+// Consensus 是共识引擎：决定谁能出块、区块如何封装、以及分叉时选哪条链。
+// 区块的其余规则（交易、状态根、时间戳等）与共识引擎无关，仍由 CheckBlock / ApplyBlock 检查。
+type Consensus interface {
+	// Name 返回引擎名称
+	Name() EngineName
+
+	// Prepare 填写新区块头中由共识决定的字段（POW 的难度，PoA / PoS / BFT 的签名者与出块权重），
+	// chain 为父区块及其祖先
+	Prepare(chain HeaderReader, h *BlockHeader) error
+
+	// Seal 封装区块并填好 Hash：POW 搜索 nonce，PoA / PoS / BFT 用本节点的签名者私钥签名。ctx 取消时停止
+	Seal(ctx context.Context, b *Block) error
+
+	// VerifySeal 校验区块头的共识字段与封装。chain 为 nil 时（例如父区块未知的孤块）
+	// 只做不依赖父区块的检查
+	VerifySeal(chain HeaderReader, h *BlockHeader) error
+
+	// Work 返回区块在分叉选择中的权重，累计权重最大的链为主链
+	Work(h *BlockHeader) *big.Int
+
+	// Reward 返回高度 height 的区块奖励（不含手续费）
+	Reward(height uint64) uint64
+}
+
+// SignerEngine 是由签名者对区块签名的共识引擎（PoA、PoS、BFT）：
+// 校验区块只需要链配置，出块的节点还要用 Authorize 设置自己的私钥
+type SignerEngine interface {
+	Consensus
+
+	// Authorize 设置本节点出块使用的私钥
+	Authorize(priv *ecdsa.PrivateKey) error
+
+	// Signer 返回本节点的签名者地址，未设置私钥时为空
+	Signer() string
+}
+
+// NewEngine 按链配置创建共识引擎
+func NewEngine(p *ChainParams) Consensus {
+	switch p.Consensus.Engine {
+	case EnginePoA:
+		return NewProofOfAuthority(p)
+	case EnginePoS:
+		return NewProofOfStake(p)
+	case EngineBFT:
+		return NewBFT(p)
+	}
+	return &ProofOfWork{Params: p, Miner: NewMiner(0)}
+}
+
+// headerHash 返回区块头的哈希：SHA256(header)，不包含 Seal
+func headerHash(h *BlockHeader) []byte {
+	return utils.Sha256(encodeHeader(h, h.Nonce))
+}
+
+// blockSigner 保存签名出块的节点私钥，PoA、PoS 与 BFT 共用
+type blockSigner struct {
+	key     *ecdsa.PrivateKey
+	pub     []byte
+	addr    string
+	network uint32 // 链配置中的 networkId，写进签名内容
+}
+
+func newBlockSigner(priv *ecdsa.PrivateKey, network uint32) (*blockSigner, error) {
+	pub, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &blockSigner{key: priv, pub: pub, addr: utils.PubKeyToAddress(pub), network: network}, nil
+}
+
+// sealHash 返回区块签名的内容：SHA256("seal" | u32 networkId | bytes 区块哈希)。
+// 签名绑定网络编号，同一把私钥在另一个网络（例如测试网）签的区块头在本网络无效，也不能作为双签证据
+func sealHash(network uint32, hash []byte) []byte {
+	var e encoder
+	e.buf.WriteString("seal")
+	e.u32(network)
+	e.bytes(hash)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// seal 计算区块哈希并签名，区块头必须已由 Prepare 填入本节点的公钥
+func (s *blockSigner) seal(b *Block) error {
+	if s == nil {
+		return ErrNotSigner
+	}
+	if !bytes.Equal(b.Header.Signer, s.pub) {
+		return fmt.Errorf("%w: header prepared for another signer", ErrBadSeal)
+	}
+	b.Header.Hash = headerHash(b.Header)
+	sig, err := utils.SignECDSA(s.key, sealHash(s.network, b.Header.Hash))
+	if err != nil {
+		return err
+	}
+	b.Header.Seal = sig
+	return nil
+}
+
+// verifySignedHeader 检查签名出块的区块头：nonce 为 0、哈希与内容一致、Seal 是 Signer 在网络 network 上
+// 对哈希的有效签名（见 sealHash），返回签名者地址
+func verifySignedHeader(network uint32, h *BlockHeader) (string, error) {
+	if h.Nonce != 0 {
+		return "", fmt.Errorf("%w: nonce must be 0", ErrBadSeal)
+	}
+	if !bytes.Equal(h.Hash, headerHash(h)) {
+		return "", fmt.Errorf("%w: header hash mismatch", ErrBadSeal)
+	}
+	if len(h.Signer) == 0 || !utils.VerifyECDSA(h.Signer, sealHash(network, h.Hash), h.Seal) {
+		return "", fmt.Errorf("%w: bad signature", ErrBadSeal)
+	}
+	return utils.PubKeyToAddress(h.Signer), nil
+}
+
+// ProofOfWork 是工作量证明引擎：难度按 difficulty.go 的规则调整，区块哈希必须不大于难度目标
+type ProofOfWork struct {
+	Params *ChainParams
+	Miner  *Miner // 并行挖矿器
+}
+
+func (w *ProofOfWork) Name() EngineName { return EnginePoW }
+
+// Prepare 填写按难度调整规则必须使用的难度
+func (w *ProofOfWork) Prepare(chain HeaderReader, h *BlockHeader) error {
+	h.Bits = powBits(w.Params, chain, h.Height)
+	return nil
+}
+
+// Seal 挖矿：搜索满足难度的 nonce
+func (w *ProofOfWork) Seal(ctx context.Context, b *Block) error {
+	return w.Miner.Mine(ctx, b)
+}
+
+// VerifySeal 检查难度符合调整规则，且 POW 有效。POW 区块不带签名者与签名
+func (w *ProofOfWork) VerifySeal(chain HeaderReader, h *BlockHeader) error {
+	if len(h.Signer) > 0 || len(h.Seal) > 0 {
+		return fmt.Errorf("%w: proof-of-work block carries a signer", ErrBadSeal)
+	}
+	if chain != nil {
+		if want := powBits(w.Params, chain, h.Height); h.Bits != want {
+			return fmt.Errorf("%w: got %08x, want %08x", ErrBadDifficulty, h.Bits, want)
+		}
+	}
+	if !NewPow(&Block{Header: h}).Validate() {
+		return ErrBadPow
+	}
+	return nil
+}
+
+// Work 返回难度对应的工作量
+func (w *ProofOfWork) Work(h *BlockHeader) *big.Int {
+	return BlockWork(h.Bits)
+}
+
+// Reward 按货币政策计算区块奖励
+func (w *ProofOfWork) Reward(height uint64) uint64 {
+	return w.Params.Monetary.Subsidy(height)
+}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+
+	"mychain/core/vm"
+	"mychain/utils"
+)
+
+// 合约交易的固定 gas 消耗：每笔合约交易 TxBaseGas，部署时代码每字节另加 DeployGasPerByte
+const (
+	TxBaseGas        = 100
+	DeployGasPerByte = 5
+)
+
+// GasPriceUnit 是链参数 gasPrice 的计价单位：gasPrice 为每 GasPriceUnit 个 gas 的手续费
+const GasPriceUnit = 1000
+
+var (
+	ErrContractsDisabled = errors.New("contracts are not enabled on this chain")
+	ErrBadContractTx     = errors.New("malformed contract transaction")
+	ErrIntrinsicGas      = errors.New("gas limit below intrinsic gas")
+	ErrGasFee            = errors.New("fee does not cover gas limit")
+	ErrBlockGasLimit     = errors.New("block exceeds gas limit")
+	ErrNotContract       = errors.New("address is not a contract")
+	ErrContractExists    = errors.New("contract already exists")
+)
+
+// Receipt 记录一笔合约交易的执行结果。
+// 执行失败（revert、gas 耗尽等）的交易仍然上链：手续费照付、nonce 照常加一，
+// 但本次调用对余额和存储的修改全部撤销，转入合约的金额退回给调用者。
+type Receipt struct {
+	TxHash   string `json:"txHash"`
+	Height   uint64 `json:"height"`
+	Contract string `json:"contract"` // 部署或调用的合约地址
+	Success  bool   `json:"success"`
+	GasUsed  uint64 `json:"gasUsed"`
+	Return   string `json:"return,omitempty"` // OP_RETURN 的返回值（hex）
+	Error    string `json:"error,omitempty"`
+}
+
+// ContractAddress 返回 deployer 用 nonce 部署的合约地址：SHA256("contract" | bytes deployer | u64 nonce)
+func ContractAddress(deployer string, nonce uint64) string {
+	var e encoder
+	e.buf.WriteString("contract")
+	e.string(deployer)
+	e.u64(nonce)
+	return utils.ToHex(utils.Sha256(e.buf.Bytes()))
+}
+
+// IntrinsicGas 返回合约交易在执行代码之前就要消耗的 gas
+func (tx *Transaction) IntrinsicGas() uint64 {
+	gas := uint64(TxBaseGas)
+	if tx.Kind == TxDeploy {
+		gas += DeployGasPerByte * uint64(len(tx.Payload))
+	}
+	return gas
+}
+
+// GasFee 返回按 gasPrice 买下 gasLimit 个 gas 要付的手续费（gasLimit × gasPrice / GasPriceUnit，向上取整）。
+// 合约交易的手续费至少是这个数，否则一笔零手续费的交易就能占满整个区块的 gas
+func GasFee(gasPrice, gasLimit uint64) uint64 {
+	hi, lo := bits.Mul64(gasLimit, gasPrice)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	fee := lo / GasPriceUnit
+	if lo%GasPriceUnit != 0 {
+		fee++
+	}
+	return fee
+}
+
+// checkTxKind 检查交易类型相关的字段：普通转账不能带代码和 gas，代币字段要合法（见 token.go），
+// 质押相关交易要求 PoS（见 stake.go），
+// 合约交易要求链参数开启合约，gas 上限在固有消耗与区块上限之间，手续费付得起 gas 上限，代码能被解析
+func checkTxKind(p *ChainParams, tx *Transaction) error {
+	if err := checkTokenFields(tx); err != nil {
+		return err
+	}
+	switch tx.Kind {
+	case TxTransfer:
+		if len(tx.Payload) != 0 || tx.GasLimit != 0 {
+			return fmt.Errorf("%w: transfer carries payload or gas limit", ErrBadContractTx)
+		}
+		return nil
+	case TxIssue:
+		return checkIssueTx(tx)
+	case TxStake, TxUnstake, TxSlash:
+		return checkStakeTx(p, tx)
+	case TxDeploy, TxCall:
+	default:
+		return fmt.Errorf("%w: unknown kind %d", ErrBadContractTx, tx.Kind)
+	}
+
+	if p.MaxBlockGas == 0 {
+		return ErrContractsDisabled
+	}
+	if tx.IsCoinbase() || tx.From == "" {
+		return fmt.Errorf("%w: contract transaction needs a sender", ErrBadContractTx)
+	}
+	if intrinsic := tx.IntrinsicGas(); tx.GasLimit < intrinsic {
+		return fmt.Errorf("%w: limit %d, intrinsic %d", ErrIntrinsicGas, tx.GasLimit, intrinsic)
+	}
+	if tx.GasLimit > p.MaxBlockGas {
+		return fmt.Errorf("%w: tx gas limit %d, block limit %d", ErrBlockGasLimit, tx.GasLimit, p.MaxBlockGas)
+	}
+	if need := GasFee(p.GasPrice, tx.GasLimit); uint64(tx.Fee) < need {
+		return fmt.Errorf("%w: fee %d, gas limit %d needs %d at gasPrice %d", ErrGasFee, tx.Fee, tx.GasLimit, need, p.GasPrice)
+	}
+
+	if tx.Kind == TxDeploy {
+		if tx.To != "" || len(tx.Payload) == 0 {
+			return fmt.Errorf("%w: deploy needs code and no recipient", ErrBadContractTx)
+		}
+		if _, err := vm.Parse(tx.Payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadContractTx, err)
+		}
+		return nil
+	}
+	if tx.To == "" {
+		return fmt.Errorf("%w: call needs a contract address", ErrBadContractTx)
+	}
+	if len(tx.Payload) > vm.MaxInputSize || !vm.IsPushOnly(tx.Payload) {
+		return fmt.Errorf("%w: call input must be push-only and at most %d bytes", ErrBadContractTx, vm.MaxInputSize)
+	}
+	return nil
+}
+
+// BlockGas 返回一组交易的 gas 上限之和，区块内的总和不能超过 MaxBlockGas
+func BlockGas(txs []Transaction) uint64 {
+	var gas uint64
+	for i := range txs {
+		gas += txs[i].GasLimit
+	}
+	return gas
+}
+
+// applyContractTx 执行合约交易。调用方已经扣除了 Value + Fee 并把 nonce 加一；
+// 执行失败时撤销本次执行的全部修改并退回 Value，结果都记录在收据中
+func applyContractTx(st *State, tx *Transaction) {
+	r := &Receipt{TxHash: utils.ToHex(tx.Hash), Height: st.Height}
+	mark := st.Snapshot()
+	if err := runContractTx(st, tx, r); err != nil {
+		st.RevertToSnapshot(mark)
+		st.AddBalance(tx.From, int64(tx.Value))
+		r.Error = err.Error()
+	} else {
+		r.Success = true
+	}
+	st.setReceipt(r)
+}
+
+func runContractTx(st *State, tx *Transaction, r *Receipt) error {
+	r.GasUsed = tx.IntrinsicGas()
+
+	if tx.Kind == TxDeploy {
+		r.Contract = ContractAddress(tx.From, tx.Nonce)
+		if st.Code[r.Contract] != nil {
+			return fmt.Errorf("%w: %s", ErrContractExists, r.Contract)
+		}
+		st.SetCode(r.Contract, tx.Payload)
+		st.AddBalance(r.Contract, int64(tx.Value))
+		return nil
+	}
+
+	r.Contract = tx.To
+	code := st.Code[tx.To]
+	if code == nil {
+		return fmt.Errorf("%w: %s", ErrNotContract, tx.To)
+	}
+	st.AddBalance(tx.To, int64(tx.Value))
+	host := &contractHost{st: st, caller: tx.From, self: tx.To, value: int64(tx.Value)}
+	res, err := vm.Execute(code, tx.Payload, host, tx.GasLimit-r.GasUsed)
+	r.GasUsed += res.GasUsed
+	r.Return = utils.ToHex(res.Return)
+	return err
+}
+
+// contractHost 把 State 提供给虚拟机，所有修改都经过 State 的日志，失败时可以整体撤销
+type contractHost struct {
+	st     *State
+	caller string
+	self   string
+	value  int64
+}
+
+func (h *contractHost) Caller() string            { return h.caller }
+func (h *contractHost) CallValue() int64          { return h.value }
+func (h *contractHost) Self() string              { return h.self }
+func (h *contractHost) Height() uint64            { return h.st.Height }
+func (h *contractHost) Balance(addr string) int64 { return h.st.Balance(addr) }
+func (h *contractHost) Load(key []byte) []byte    { return h.st.StorageAt(h.self, key) }
+func (h *contractHost) Store(key, value []byte)   { h.st.SetStorage(h.self, key, value) }
+func (h *contractHost) Transfer(to string, amount int64) error {
+	if spendable := h.st.Spendable(h.self); spendable < amount {
+		return fmt.Errorf("%w: contract %s can spend %d, transfers %d", ErrOverspend, h.self, spendable, amount)
+	}
+	h.st.AddBalance(h.self, -amount)
+	h.st.AddBalance(to, amount)
+	return nil
+}
+
+// CallContract 以 from 的身份只读地调用合约（不需要签名，不消耗余额），
+// 在最新状态上执行，结束后撤销全部修改，用于 /contract/call 查询
+func (bc *Blockchain) CallContract(from, addr string, input []byte) (*vm.Result, error) {
+	code := bc.State.Code[addr]
+	if code == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotContract, addr)
+	}
+	mark := bc.State.Snapshot()
+	defer bc.State.RevertToSnapshot(mark)
+
+	host := &contractHost{st: bc.State, caller: from, self: addr}
+	return vm.Execute(code, input, host, bc.Params.MaxBlockGas)
+}
+
+// SetCode 部署合约代码
+func (st *State) SetCode(addr string, code []byte) {
+	st.journal = append(st.journal, func() {
+		delete(st.Code, addr)
+	})
+	st.Code[addr] = code
+	st.touchCode(addr)
+}
+
+// StorageAt 返回合约存储中 key 对应的值（不存在为 nil）
+func (st *State) StorageAt(addr string, key []byte) []byte {
+	return st.Storage[addr][string(key)]
+}
+
+// SetStorage 写入合约存储，value 为空表示删除
+func (st *State) SetStorage(addr string, key, value []byte) {
+	slots, existed := st.Storage[addr]
+	prev, had := slots[string(key)]
+	st.journal = append(st.journal, func() {
+		if !existed {
+			delete(st.Storage, addr)
+		} else if had {
+			slots[string(key)] = prev
+		} else {
+			delete(slots, string(key))
+		}
+	})
+	if !existed {
+		slots = make(map[string][]byte)
+		st.Storage[addr] = slots
+	}
+	if len(value) == 0 {
+		delete(slots, string(key))
+	} else {
+		slots[string(key)] = append([]byte(nil), value...)
+	}
+	st.touchStorage(addr, key)
+}
+
+// StorageEntry 是合约存储中的一项
+type StorageEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// StorageOf 返回合约的全部存储（按键排序）
+func (st *State) StorageOf(addr string) []StorageEntry {
+	var entries []StorageEntry
+	for k, v := range st.Storage[addr] {
+		entries = append(entries, StorageEntry{Key: []byte(k), Value: v})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].Key, entries[j].Key) < 0 })
+	return entries
+}
+
+func (st *State) setReceipt(r *Receipt) {
+	prev, existed := st.Receipts[r.TxHash]
+	st.journal = append(st.journal, func() {
+		if existed {
+			st.Receipts[r.TxHash] = prev
+		} else {
+			delete(st.Receipts, r.TxHash)
+		}
+	})
+	st.Receipts[r.TxHash] = r
+}
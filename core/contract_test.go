@@ -0,0 +1,250 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mychain/core/bytecode"
+	"mychain/core/vm"
+	"mychain/utils"
+)
+
+// assembleContract 编译 docs/contracts 下的示例合约
+func assembleContract(t *testing.T, name string) []byte {
+	t.Helper()
+	src, err := os.ReadFile("../docs/contracts/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := vm.Assemble(string(src))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return code
+}
+
+func contractTx(kind TxKind, from, to string, value, fee uint32, nonce uint64, payload []byte, gas uint64) *Transaction {
+	tx := &Transaction{From: from, To: to, Value: value, Fee: fee, Nonce: nonce, Kind: kind,
+		Payload: payload, GasLimit: gas, Timestamp: time.Unix(1700000000, 0)}
+	tx.CalculateHash()
+	return tx
+}
+
+func TestContractCalls(t *testing.T) {
+	p := loadRegtest(t)
+	st := NewState()
+	st.AddBalance("alice", 1000)
+
+	// apply 执行一笔合约交易，返回它的收据
+	apply := func(tx *Transaction) *Receipt {
+		t.Helper()
+		if err := checkTxKind(p, tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyTx(p, st, tx); err != nil {
+			t.Fatal(err)
+		}
+		return st.Receipts[utils.ToHex(tx.Hash)]
+	}
+	input := func(src string) []byte {
+		b, err := vm.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	deploy := contractTx(TxDeploy, "alice", "", 5, 10, 0, assembleContract(t, "counter.asm"), 10000)
+	r := apply(deploy)
+	counter := ContractAddress("alice", 0)
+	if !r.Success || r.Contract != counter || st.Code[counter] == nil {
+		t.Fatalf("deploy receipt: %+v", r)
+	}
+	if r.GasUsed != deploy.IntrinsicGas() {
+		t.Fatalf("deploy gas used %d, want intrinsic %d", r.GasUsed, deploy.IntrinsicGas())
+	}
+	if st.Balance(counter) != 5 || st.Balance("alice") != 985 {
+		t.Fatalf("balances after deploy: contract %d, alice %d", st.Balance(counter), st.Balance("alice"))
+	}
+
+	if r := apply(contractTx(TxCall, "alice", counter, 4, 10, 1, input("3 'add'"), 10000)); !r.Success {
+		t.Fatalf("add: %s", r.Error)
+	}
+	if got := st.StorageAt(counter, []byte("count")); string(got) != string(bytecode.EncodeNum(3)) {
+		t.Fatalf("count after add 3 = %x", got)
+	}
+	balance, count := st.Balance("alice"), st.StorageAt(counter, []byte("count"))
+
+	// 执行失败（revert、gas 耗尽、调用不存在的合约）：存储与余额的修改全部撤销，
+	// 转入合约的金额退回，手续费照付，nonce 照常加一
+	failures := []struct {
+		name string
+		tx   *Transaction
+		want string
+	}{
+		{"revert", contractTx(TxCall, "alice", counter, 4, 10, 2, input("'bogus'"), 10000), vm.ErrReverted.Error()},
+		{"verify", contractTx(TxCall, "alice", counter, 4, 10, 3, input("0 'add'"), 10000), vm.ErrVerifyFailed.Error()},
+		{"out of gas", contractTx(TxCall, "alice", counter, 4, 1, 4, input("'inc'"), TxBaseGas+20), vm.ErrOutOfGas.Error()},
+		{"not a contract", contractTx(TxCall, "alice", "bob", 4, 10, 5, input("'inc'"), 10000), ErrNotContract.Error()},
+	}
+	for _, tt := range failures {
+		r := apply(tt.tx)
+		balance -= int64(tt.tx.Fee)
+		if r.Success || !strings.Contains(r.Error, tt.want) {
+			t.Fatalf("%s: receipt %+v, want error %q", tt.name, r, tt.want)
+		}
+		if r.GasUsed > tt.tx.GasLimit {
+			t.Fatalf("%s: gas used %d above limit %d", tt.name, r.GasUsed, tt.tx.GasLimit)
+		}
+		if got := st.Balance("alice"); got != balance {
+			t.Fatalf("%s: alice has %d, want %d (only the fee charged)", tt.name, got, balance)
+		}
+		if st.Nonce("alice") != tt.tx.Nonce+1 {
+			t.Fatalf("%s: nonce %d, want %d", tt.name, st.Nonce("alice"), tt.tx.Nonce+1)
+		}
+		if st.Balance(counter) != 9 || st.Balance("bob") != 0 {
+			t.Fatalf("%s: value not refunded: contract %d, bob %d", tt.name, st.Balance(counter), st.Balance("bob"))
+		}
+		if string(st.StorageAt(counter, []byte("count"))) != string(count) {
+			t.Fatalf("%s: storage changed", tt.name)
+		}
+	}
+
+	// 重复部署到同一地址（同一 deployer、同一 nonce）失败
+	st.Nonces["alice"] = 0
+	if r := apply(contractTx(TxDeploy, "alice", "", 0, 10, 0, []byte{vm.OP_STOP}, 10000)); r.Success {
+		t.Fatal("deployed twice to the same address")
+	}
+}
+
+func TestContractTransferOverspend(t *testing.T) {
+	p := loadRegtest(t)
+	st := NewState()
+	st.AddBalance("alice", 100)
+	code, err := vm.Assemble("'bob' 10 OP_TRANSFER OP_STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyTx(p, st, contractTx(TxDeploy, "alice", "", 3, 0, 0, code, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	addr := ContractAddress("alice", 0)
+
+	// 合约余额 3 + 本次转入 5 不够转出 10：整体撤销，转入的 5 退回
+	call := contractTx(TxCall, "alice", addr, 5, 0, 1, nil, 10000)
+	if err := applyTx(p, st, call); err != nil {
+		t.Fatal(err)
+	}
+	if r := st.Receipts[utils.ToHex(call.Hash)]; r.Success || !strings.Contains(r.Error, ErrOverspend.Error()) {
+		t.Fatalf("receipt %+v, want overspend", r)
+	}
+	if st.Balance(addr) != 3 || st.Balance("bob") != 0 || st.Balance("alice") != 97 {
+		t.Fatalf("balances: contract %d, bob %d, alice %d", st.Balance(addr), st.Balance("bob"), st.Balance("alice"))
+	}
+
+	// 转入 7 之后刚好够
+	call = contractTx(TxCall, "alice", addr, 7, 0, 2, nil, 10000)
+	if err := applyTx(p, st, call); err != nil {
+		t.Fatal(err)
+	}
+	if r := st.Receipts[utils.ToHex(call.Hash)]; !r.Success {
+		t.Fatalf("transfer with enough balance: %s", r.Error)
+	}
+	if st.Balance(addr) != 0 || st.Balance("bob") != 10 {
+		t.Fatalf("balances: contract %d, bob %d", st.Balance(addr), st.Balance("bob"))
+	}
+}
+
+func TestCheckTxKind(t *testing.T) {
+	p := loadRegtest(t)
+	code := []byte{vm.OP_STOP}
+	disabled := *p
+	disabled.MaxBlockGas = 0
+
+	tests := []struct {
+		name string
+		p    *ChainParams
+		tx   *Transaction
+		want error
+	}{
+		{"transfer with payload", p, contractTx(TxTransfer, "alice", "bob", 1, 0, 0, []byte{1}, 0), ErrBadContractTx},
+		{"transfer with gas", p, contractTx(TxTransfer, "alice", "bob", 1, 0, 0, nil, 100), ErrBadContractTx},
+		{"unknown kind", p, contractTx(TxKind(9), "alice", "bob", 1, 0, 0, nil, 0), ErrBadContractTx},
+		{"contracts disabled", &disabled, contractTx(TxDeploy, "alice", "", 0, 1, 0, code, 1000), ErrContractsDisabled},
+		{"below intrinsic gas", p, contractTx(TxDeploy, "alice", "", 0, 0, 0, code, TxBaseGas), ErrIntrinsicGas},
+		{"fee does not cover gas limit", p, contractTx(TxCall, "alice", "c", 0, 1, 0, nil, 1001), ErrGasFee},
+		{"above block gas", p, contractTx(TxCall, "alice", "c", 0, 0, 0, nil, p.MaxBlockGas+1), ErrBlockGasLimit},
+		{"deploy with recipient", p, contractTx(TxDeploy, "alice", "bob", 0, 1, 0, code, 1000), ErrBadContractTx},
+		{"deploy without code", p, contractTx(TxDeploy, "alice", "", 0, 1, 0, nil, 1000), ErrBadContractTx},
+		{"deploy malformed code", p, contractTx(TxDeploy, "alice", "", 0, 1, 0, []byte{0xfe}, 1000), ErrBadContractTx},
+		{"call without contract", p, contractTx(TxCall, "alice", "", 0, 1, 0, nil, 1000), ErrBadContractTx},
+		{"call input not push only", p, contractTx(TxCall, "alice", "c", 0, 1, 0, []byte{vm.OP_1, vm.OP_DUP}, 1000), ErrBadContractTx},
+		{"call input with sstore", p, contractTx(TxCall, "alice", "c", 0, 1, 0, []byte{vm.OP_1, vm.OP_1, vm.OP_SSTORE}, 1000), ErrBadContractTx},
+		{"coinbase", p, contractTx(TxCall, CoinbaseFrom, "c", 0, 1, 0, nil, 1000), ErrBadContractTx},
+	}
+	for _, tt := range tests {
+		if err := checkTxKind(tt.p, tt.tx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if err := checkTxKind(p, contractTx(TxCall, "alice", "c", 0, 1, 0, []byte{vm.OP_1, 1, 'x'}, 1000)); err != nil {
+		t.Fatalf("push-only call: %v", err)
+	}
+}
+
+func TestGasFee(t *testing.T) {
+	tests := []struct {
+		price, limit, want uint64
+	}{
+		{1, 0, 0},
+		{1, 1, 1},
+		{1, GasPriceUnit, 1},
+		{1, GasPriceUnit + 1, 2},
+		{3, GasPriceUnit, 3},
+		{0, 1000000, 0},
+		{math.MaxUint64, 1, math.MaxUint64/GasPriceUnit + 1},
+		{1 << 32, 1 << 32, math.MaxUint64}, // 乘积溢出时按付不起处理
+		{math.MaxUint64, 2, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		if got := GasFee(tt.price, tt.limit); got != tt.want {
+			t.Errorf("GasFee(%d, %d) = %d, want %d", tt.price, tt.limit, got, tt.want)
+		}
+	}
+
+	// 开启合约时 gasPrice 必须为正，按 gasPrice 买下整个区块的 gas 的手续费要放得进 32 位
+	for name, price := range map[string]uint64{"zero gas price": 0, "fee above 32 bits": 1 << 40} {
+		p := loadRegtest(t)
+		p.GasPrice = price
+		if err := p.Validate(); !errors.Is(err, ErrBadChainSpec) {
+			t.Errorf("%s: err = %v, want ErrBadChainSpec", name, err)
+		}
+	}
+	p := loadRegtest(t)
+	p.MaxBlockGas, p.GasPrice = 0, 0
+	if err := p.Validate(); err != nil {
+		t.Fatalf("contracts disabled without gas price: %v", err)
+	}
+}
+
+func TestCallContractIsReadOnly(t *testing.T) {
+	bc := NewBlockchain(loadRegtest(t))
+	addr := ContractAddress("alice", 0)
+	bc.State.SetCode(addr, assembleContract(t, "counter.asm"))
+	root := bc.State.Root()
+
+	inc, _ := vm.Assemble("'inc'")
+	if _, err := bc.CallContract("alice", addr, inc); err != nil {
+		t.Fatal(err)
+	}
+	if bc.State.StorageAt(addr, []byte("count")) != nil || string(bc.State.Root()) != string(root) {
+		t.Fatal("CallContract changed the state")
+	}
+	if _, err := bc.CallContract("alice", "nobody", inc); !errors.Is(err, ErrNotContract) {
+		t.Fatalf("call to a non-contract: err = %v, want ErrNotContract", err)
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"math/big"
+	"time"
+)
+
+// 难度调整相关参数。各网络的初始难度、调整间隔和出块间隔见 ChainParams，
+// 例如 mainnet 的 0x1f00ffff 大约等价于“哈希前 2 个字节为 0”
+const (
+	// maxAdjustFactor 单次调整最多放大 / 缩小的倍数，防止难度剧烈波动
+	maxAdjustFactor = 4
+
+	// maxPowLimitBits 任何网络都不能低于的难度（regtest 使用它，约一半的哈希都满足）
+	maxPowLimitBits uint32 = 0x207fffff
+)
+
+// powLimit 所有网络允许的最大目标值，某个网络的最低难度由 ChainParams.InitialBits 决定
+var powLimit = CompactToBig(maxPowLimitBits)
+
+// CompactToBig 把 compact 格式（类似比特币 nBits）的难度转成目标值：
+// 高 8 位为指数 e，低 23 位为尾数 m，目标值 = m * 256^(e-3)
+func CompactToBig(bits uint32) *big.Int {
+	mantissa := bits & 0x007fffff
+	exponent := uint(bits >> 24)
+
+	var target *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		target = big.NewInt(int64(mantissa))
+	} else {
+		target = big.NewInt(int64(mantissa))
+		target.Lsh(target, 8*(exponent-3))
+	}
+
+	// 符号位置 1 表示负数，目标值不允许为负，这里统一视为 0（无效）
+	if bits&0x00800000 != 0 {
+		return big.NewInt(0)
+	}
+	return target
+}
+
+// BigToCompact 是 CompactToBig 的逆运算（会损失低位精度）
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() <= 0 {
+		return 0
+	}
+
+	exponent := uint(len(n.Bytes()))
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(n.Uint64()) << (8 * (3 - exponent))
+	} else {
+		tmp := new(big.Int).Rsh(n, 8*(exponent-3))
+		mantissa = uint32(tmp.Uint64())
+	}
+
+	// 尾数最高位不能占用符号位，否则右移一个字节、指数加一
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	return uint32(exponent<<24) | mantissa
+}
+
+// retarget 根据 first（RetargetInterval 个区块之前）到 parent 的实际出块耗时，
+// 与期望耗时比较后等比例调整 parent 的难度
+func retarget(p *ChainParams, parent, first *BlockHeader) uint32 {
+	actual := parent.Timestamp.Sub(first.Timestamp)
+	// first 到 parent 之间一共 RetargetInterval-1 个出块间隔
+	expected := p.TargetBlockTime() * time.Duration(p.RetargetInterval-1)
+
+	// 限制调整幅度
+	if actual < expected/maxAdjustFactor {
+		actual = expected / maxAdjustFactor
+	}
+	if actual > expected*maxAdjustFactor {
+		actual = expected * maxAdjustFactor
+	}
+
+	// 新目标 = 旧目标 * 实际耗时 / 期望耗时（目标越大难度越低）
+	target := CompactToBig(parent.Bits)
+	target.Mul(target, big.NewInt(int64(actual)))
+	target.Div(target, big.NewInt(int64(expected)))
+
+	if limit := CompactToBig(p.InitialBits); target.Cmp(limit) > 0 {
+		target.Set(limit)
+	}
+	return BigToCompact(target)
+}
+
+// isRetargetHeight 判断高度 height 的区块是否需要重新计算难度
+func (p *ChainParams) isRetargetHeight(height uint64) bool {
+	return p.RetargetInterval > 1 && height%p.RetargetInterval == 0
+}
+
+// powBits 计算高度 height 的区块必须使用的难度，chain 为它的父区块及祖先。
+// 规则：
+//   - 高度不是 RetargetInterval 的整数倍（或该网络不调整难度）时，沿用父区块难度
+//   - 否则取最近 RetargetInterval 个区块的实际耗时，与期望耗时比较后等比例调整
+func powBits(p *ChainParams, chain HeaderReader, height uint64) uint32 {
+	parent := chain.HeaderAt(height - 1)
+	if !p.isRetargetHeight(height) {
+		return parent.Bits
+	}
+	return retarget(p, parent, chain.HeaderAt(height-p.RetargetInterval))
+}
+
+// BlockWork 返回一个难度为 bits 的区块代表的工作量：2^256 / (target + 1)
+func BlockWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	denom := new(big.Int).Add(target, big.NewInt(1))
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denom)
+}
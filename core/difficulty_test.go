@@ -0,0 +1,111 @@
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	tests := []struct {
+		bits   uint32
+		target string // 十六进制
+	}{
+		{0x1f00ffff, "ffff" + strings.Repeat("0", 56)},
+		{0x1d00ffff, "ffff" + strings.Repeat("0", 52)},
+		{0x207fffff, "7fffff" + strings.Repeat("0", 58)},
+		{0x03123456, "123456"},
+		{0x02123400, "1234"},
+		{0x01120000, "12"},
+		{0x05009234, "92340000"},
+	}
+	for _, tt := range tests {
+		target := CompactToBig(tt.bits)
+		if got := target.Text(16); got != tt.target {
+			t.Errorf("CompactToBig(%#x) = %s, want %s", tt.bits, got, tt.target)
+		}
+		if got := BigToCompact(target); got != tt.bits {
+			t.Errorf("BigToCompact(CompactToBig(%#x)) = %#x", tt.bits, got)
+		}
+	}
+
+	// 尾数最高位是符号位：负数目标视为 0，编码时尾数需要右移一个字节
+	if CompactToBig(0x04923456).Sign() != 0 {
+		t.Error("negative compact target should decode to 0")
+	}
+	if got := BigToCompact(big.NewInt(0x80)); got != 0x02008000 {
+		t.Errorf("BigToCompact(0x80) = %#x, want 0x02008000", got)
+	}
+	if got := BigToCompact(big.NewInt(0)); got != 0 {
+		t.Errorf("BigToCompact(0) = %#x, want 0", got)
+	}
+}
+
+// retargetChain 返回高度 0 到 p.RetargetInterval-1 的区块节点（下一个区块正好要调整难度），
+// 每个区块难度都是 bits，第一个到最后一个区块之间共耗时 span
+func retargetChain(p *ChainParams, bits uint32, span time.Duration) []*blockNode {
+	start := time.Unix(1700000000, 0)
+	nodes := make([]*blockNode, p.RetargetInterval)
+	for i := range nodes {
+		ts := start.Add(span * time.Duration(i) / time.Duration(p.RetargetInterval-1))
+		nodes[i] = &blockNode{block: &Block{Header: &BlockHeader{Timestamp: ts, Bits: bits}}, height: i}
+		if i > 0 {
+			nodes[i].parent = nodes[i-1]
+		}
+	}
+	return nodes
+}
+
+// nextRetarget 返回 retargetChain 之后下一个区块的难度
+func nextRetarget(p *ChainParams, bits uint32, span time.Duration) uint32 {
+	nodes := retargetChain(p, bits, span)
+	return powBits(p, nodes[len(nodes)-1], uint64(len(nodes)))
+}
+
+func TestRetargetClamps(t *testing.T) {
+	p := &DefaultParams
+	const bits = 0x1d00ffff // 比最低难度难得多，放大 4 倍也不会碰到最低难度
+	expected := p.TargetBlockTime() * time.Duration(p.RetargetInterval-1)
+	scaled := func(num, den int64) uint32 {
+		target := CompactToBig(bits)
+		target.Mul(target, big.NewInt(num))
+		target.Div(target, big.NewInt(den))
+		return BigToCompact(target)
+	}
+
+	tests := []struct {
+		name string
+		span time.Duration
+		want uint32
+	}{
+		{"on target", expected, bits},
+		{"twice as slow", 2 * expected, scaled(2, 1)},
+		{"half as fast", expected / 2, scaled(1, 2)},
+		{"clamped at 4x", 100 * expected, scaled(4, 1)},
+		{"exactly 4x", 4 * expected, scaled(4, 1)},
+		{"clamped at 1/4", 0, scaled(1, 4)},
+		{"exactly 1/4", expected / 4, scaled(1, 4)},
+	}
+	for _, tt := range tests {
+		if got := nextRetarget(p, bits, tt.span); got != tt.want {
+			t.Errorf("%s: bits %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+
+	// 不在调整高度时沿用父区块难度；变简单时不超过最低难度
+	nodes := retargetChain(p, bits, 0)
+	if got := powBits(p, nodes[len(nodes)-2], uint64(len(nodes)-1)); got != bits {
+		t.Errorf("between retargets: bits %#x, want %#x", got, bits)
+	}
+	if got := nextRetarget(p, p.InitialBits, 100*expected); got != p.InitialBits {
+		t.Errorf("easiest difficulty: bits %#x, want %#x", got, p.InitialBits)
+	}
+
+	// 不调整难度的网络（regtest）一直沿用父区块难度
+	regtest := loadRegtest(t)
+	parent := &blockNode{block: &Block{Header: &BlockHeader{Bits: regtest.InitialBits}}, height: 9}
+	if got := powBits(regtest, parent, 10); got != regtest.InitialBits {
+		t.Errorf("regtest: bits %#x, want %#x", got, regtest.InitialBits)
+	}
+}
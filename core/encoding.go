@@ -0,0 +1,346 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"mychain/utils"
+)
+
+// EncodingVersion 是区块头 / 交易二进制编码格式的版本号，写在每段编码的第一个字节。
+// 布局（所有整数均为大端序）：
+//
+//	bytes  = u32 长度 + 原始字节（字符串同样按 UTF-8 字节处理）
+//	header = u8 版本 | u32 区块版本 | u64 高度 | bytes 前序哈希 | bytes Merkle 根 | bytes 状态根
+//	         | i64 时间戳（Unix 秒） | u32 难度 | bytes 签名者公钥 | u32 nonce
+//	sealedHeader = header | bytes 签名者签名（PoA）
+//	txBody = u8 版本 | bytes From | bytes To | u32 金额 | u32 手续费 | u64 nonce | i64 时间戳（Unix 纳秒）
+//	         | u32 输入个数 + 每个输入（bytes 交易哈希 | u32 序号）
+//	         | u32 输出个数 + 每个输出（bytes 地址 | u32 金额）
+//	         | u64 锁定时间 | u8 交易类型 | bytes 合约代码 / 调用参数 | u64 gas 上限
+//	         | bytes 资产 ID | u8 是否带代币参数 [ bytes 符号 | u8 小数位数 | u64 发行量 ] | bytes 附带数据
+//	tx     = txBody | bytes 公钥 | bytes 签名 | u8 附加类型 witness
+//	witness = 0：无
+//	        | 1：u32 门限 | u32 公钥个数 + 每个（bytes 公钥 | bytes 签名）   多签交易
+//	        | 2：bytes 赎回脚本 | bytes 解锁脚本                            脚本交易
+//
+// 区块哈希 = SHA256(header)（不含签名者签名），交易哈希与签名内容均为 txBody。
+// 详细说明与测试向量见 docs/encoding.md。
+const EncodingVersion byte = 7
+
+var (
+	ErrBadEncoding     = errors.New("malformed binary encoding")
+	ErrEncodingVersion = errors.New("unsupported encoding version")
+)
+
+// encoder 按固定布局写入字段
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) u8(v byte) { e.buf.WriteByte(v) }
+
+func (e *encoder) u32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) u64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) i64(v int64) { e.u64(uint64(v)) }
+
+func (e *encoder) bytes(v []byte) {
+	e.u32(uint32(len(v)))
+	e.buf.Write(v)
+}
+
+func (e *encoder) string(v string) { e.bytes([]byte(v)) }
+
+// decoder 按固定布局读取字段，出错后后续读取都返回零值，最后统一检查 err
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = fmt.Errorf("%w: unexpected end of data", ErrBadEncoding)
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) u8() byte {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) u32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) u64() uint64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) i64() int64 { return int64(d.u64()) }
+
+func (d *decoder) bytes() []byte {
+	n := d.u32()
+	b := d.take(int(n))
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (d *decoder) string() string { return string(d.bytes()) }
+
+// count 读取一个列表长度，并粗略检查剩余数据至少够每个元素 minSize 字节，防止恶意的超大长度
+func (d *decoder) count(minSize int) int {
+	n := d.u32()
+	if d.err == nil && uint64(n)*uint64(minSize) > uint64(len(d.data)) {
+		d.err = fmt.Errorf("%w: list length %d exceeds data", ErrBadEncoding, n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) version() {
+	if v := d.u8(); d.err == nil && v != EncodingVersion {
+		d.err = fmt.Errorf("%w: %d", ErrEncodingVersion, v)
+	}
+}
+
+// finish 检查数据是否恰好读完
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) != 0 {
+		d.err = fmt.Errorf("%w: %d trailing bytes", ErrBadEncoding, len(d.data))
+	}
+	return d.err
+}
+
+// encodeHeader 按 header 布局编码区块头，nonce 单独传入以便挖矿时反复替换
+func encodeHeader(h *BlockHeader, nonce uint32) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.u32(h.Version)
+	e.u64(h.Height)
+	e.bytes(h.PreviousHash)
+	e.bytes(h.MerkleRoot)
+	e.bytes(h.StateRoot)
+	e.i64(h.Timestamp.Unix())
+	e.u32(h.Bits)
+	e.bytes(h.Signer)
+	e.u32(nonce)
+	return e.buf.Bytes()
+}
+
+// MarshalBinary 返回区块头的规范二进制编码 sealedHeader（不含 Hash，Hash 由 header 部分计算得到）
+func (h *BlockHeader) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.buf.Write(encodeHeader(h, h.Nonce))
+	e.bytes(h.Seal)
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary 解码区块头，并重新计算 Hash
+func (h *BlockHeader) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	d.version()
+	version := d.u32()
+	height := d.u64()
+	prev := d.bytes()
+	merkle := d.bytes()
+	stateRoot := d.bytes()
+	ts := d.i64()
+	bits := d.u32()
+	signer := d.bytes()
+	nonce := d.u32()
+	headerLen := len(data) - len(d.data)
+	seal := d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*h = BlockHeader{
+		Version:      version,
+		Height:       height,
+		PreviousHash: prev,
+		MerkleRoot:   merkle,
+		StateRoot:    stateRoot,
+		Timestamp:    time.Unix(ts, 0),
+		Bits:         bits,
+		Signer:       signer,
+		Nonce:        nonce,
+		Seal:         seal,
+		Hash:         utils.Sha256(data[:headerLen]),
+	}
+	return nil
+}
+
+// encodeBody 按 txBody 布局编码交易中参与哈希和签名的字段
+func (tx *Transaction) encodeBody(e *encoder) {
+	e.u8(EncodingVersion)
+	e.string(tx.From)
+	e.string(tx.To)
+	e.u32(tx.Value)
+	e.u32(tx.Fee)
+	e.u64(tx.Nonce)
+	e.i64(tx.Timestamp.UnixNano())
+	e.u32(uint32(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		e.bytes(in.TxHash)
+		e.u32(in.Index)
+	}
+	e.u32(uint32(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		e.string(out.To)
+		e.u32(out.Value)
+	}
+	e.u64(tx.LockTime)
+	e.u8(byte(tx.Kind))
+	e.bytes(tx.Payload)
+	e.u64(tx.GasLimit)
+	e.string(tx.Asset)
+	if tx.Token == nil {
+		e.u8(0)
+	} else {
+		e.u8(1)
+		e.string(tx.Token.Symbol)
+		e.u8(tx.Token.Decimals)
+		e.u64(tx.Token.Supply)
+	}
+	e.bytes(tx.Data)
+}
+
+// 交易附加部分的类型
+const (
+	witnessNone     byte = 0
+	witnessMultiSig byte = 1
+	witnessScript   byte = 2
+)
+
+// MarshalBinary 返回交易的规范二进制编码（txBody + 公钥 + 签名 + 多签 / 脚本，Hash 由 txBody 计算得到）
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	var e encoder
+	tx.encodeBody(&e)
+	e.bytes(tx.PubKey)
+	e.bytes(tx.Sig)
+	switch {
+	case tx.MultiSig != nil && tx.Script != nil:
+		return nil, fmt.Errorf("%w: both multisig and script set", ErrBadEncoding)
+	case tx.MultiSig != nil:
+		ms := tx.MultiSig
+		if len(ms.Sigs) != len(ms.PubKeys) {
+			return nil, fmt.Errorf("%w: %d sigs for %d keys", ErrBadMultisig, len(ms.Sigs), len(ms.PubKeys))
+		}
+		e.u8(witnessMultiSig)
+		e.u32(ms.Threshold)
+		e.u32(uint32(len(ms.PubKeys)))
+		for i := range ms.PubKeys {
+			e.bytes(ms.PubKeys[i])
+			e.bytes(ms.Sigs[i])
+		}
+	case tx.Script != nil:
+		e.u8(witnessScript)
+		e.bytes(tx.Script.Redeem)
+		e.bytes(tx.Script.Unlock)
+	default:
+		e.u8(witnessNone)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary 解码交易，并重新计算 Hash
+func (tx *Transaction) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	d.version()
+
+	var t Transaction
+	t.From = d.string()
+	t.To = d.string()
+	t.Value = d.u32()
+	t.Fee = d.u32()
+	t.Nonce = d.u64()
+	t.Timestamp = time.Unix(0, d.i64())
+	if n := d.count(8); n > 0 {
+		t.Inputs = make([]TxInput, n)
+		for i := range t.Inputs {
+			t.Inputs[i] = TxInput{TxHash: d.bytes(), Index: d.u32()}
+		}
+	}
+	if n := d.count(8); n > 0 {
+		t.Outputs = make([]TxOutput, n)
+		for i := range t.Outputs {
+			t.Outputs[i] = TxOutput{To: d.string(), Value: d.u32()}
+		}
+	}
+	t.LockTime = d.u64()
+	t.Kind = TxKind(d.u8())
+	t.Payload = d.bytes()
+	t.GasLimit = d.u64()
+	t.Asset = d.string()
+	switch flag := d.u8(); flag {
+	case 0:
+	case 1:
+		t.Token = &TokenSpec{Symbol: d.string(), Decimals: d.u8(), Supply: d.u64()}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: bad token flag %d", ErrBadEncoding, flag)
+		}
+	}
+	t.Data = d.bytes()
+	t.PubKey = d.bytes()
+	t.Sig = d.bytes()
+	switch kind := d.u8(); kind {
+	case witnessNone:
+	case witnessMultiSig:
+		ms := &MultiSig{Threshold: d.u32()}
+		n := d.count(8)
+		ms.PubKeys = make([][]byte, n)
+		ms.Sigs = make([][]byte, n)
+		for i := 0; i < n; i++ {
+			ms.PubKeys[i] = d.bytes()
+			ms.Sigs[i] = d.bytes()
+		}
+		t.MultiSig = ms
+	case witnessScript:
+		t.Script = &ScriptSpend{Redeem: d.bytes(), Unlock: d.bytes()}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: unknown witness type %d", ErrBadEncoding, kind)
+		}
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	t.CalculateHash()
+	*tx = t
+	return nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LockTimeThreshold 区分锁定时间的两种含义（与比特币相同）：
+// 小于它表示区块高度，大于等于它表示 Unix 时间戳（秒）
+const LockTimeThreshold = 500000000
+
+var ErrTxLocked = errors.New("transaction is not final (locktime not reached)")
+
+// IsFinal 判断交易能否被打包进高度为 height 的区块：
+//   - LockTime 为 0：不锁定
+//   - LockTime < LockTimeThreshold：区块高度 >= LockTime 后才能打包
+//   - 否则：父区块的过去中位时间 mtp >= LockTime 后才能打包。
+//     使用过去中位时间而不是区块自身的时间戳，矿工无法通过把时间戳写超前来提前解锁
+func (tx *Transaction) IsFinal(height uint64, mtp time.Time) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+	if tx.LockTime < LockTimeThreshold {
+		return height >= tx.LockTime
+	}
+	return mtp.Unix() >= int64(tx.LockTime)
+}
+
+// checkFinal 与 IsFinal 相同，不满足时返回说明锁定条件的错误
+func (tx *Transaction) checkFinal(height uint64, mtp time.Time) error {
+	if tx.IsFinal(height, mtp) {
+		return nil
+	}
+	if tx.LockTime < LockTimeThreshold {
+		return fmt.Errorf("%w: locked until height %d, block height %d", ErrTxLocked, tx.LockTime, height)
+	}
+	return fmt.Errorf("%w: locked until %s, median time past %s", ErrTxLocked,
+		time.Unix(int64(tx.LockTime), 0).Format(time.RFC3339), mtp.Format(time.RFC3339))
+}
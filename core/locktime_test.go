@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+func TestIsFinal(t *testing.T) {
+	mtp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		lockTime uint64
+		height   uint64
+		want     bool
+	}{
+		{"not locked", 0, 1, true},
+		{"height reached", 10, 10, true},
+		{"height not reached", 10, 9, false},
+		{"largest height lock", LockTimeThreshold - 1, LockTimeThreshold - 2, false},
+		{"largest height lock reached", LockTimeThreshold - 1, LockTimeThreshold - 1, true},
+		// 从 LockTimeThreshold 开始按时间解释，与区块高度无关
+		{"smallest time lock", LockTimeThreshold, 1 << 40, true},
+		{"time reached", uint64(mtp.Unix()), 1, true},
+		{"time not reached", uint64(mtp.Unix()) + 1, 1 << 40, false},
+	}
+	for _, tt := range tests {
+		tx := Transaction{LockTime: tt.lockTime}
+		if got := tx.IsFinal(tt.height, mtp); got != tt.want {
+			t.Errorf("%s: IsFinal(%d) = %v, want %v", tt.name, tt.height, got, tt.want)
+		}
+		if err := tx.checkFinal(tt.height, mtp); (err == nil) != tt.want || (err != nil && !errors.Is(err, ErrTxLocked)) {
+			t.Errorf("%s: checkFinal = %v", tt.name, err)
+		}
+	}
+}
+
+func TestTimeLockUsesMedianTimePast(t *testing.T) {
+	p := loadRegtest(t)
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := utils.PubKeyToAddress(pub)
+
+	bc := NewBlockchain(p)
+	mineBlocks(t, bc, alice, 1)
+	ctx := bc.NextBlockContext()
+
+	// 锁定到过去中位时间之后 1 小时：区块自己的时间戳写到锁定时间之后也不能提前解锁
+	lockTime := ctx.MedianTime.Add(time.Hour)
+	tx := Transaction{From: alice, To: "bob", Value: 1, LockTime: uint64(lockTime.Unix()), Timestamp: time.Now()}
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	coinbase := p.NewCoinbase(2, "miner", uint32(p.Monetary.Subsidy(2)))
+	b := NewBlock(ctx.Prev.Header, lockTime.Add(time.Second), nil, []Transaction{coinbase, tx})
+	if err := ctx.Engine.Prepare(ctx.Chain, b.Header); err != nil {
+		t.Fatal(err)
+	}
+	b.Mine()
+
+	ctx.Now = lockTime // 让这个时间戳不算太超前
+	if err := CheckBlock(&b, ctx); !errors.Is(err, ErrTxLocked) {
+		t.Fatalf("err = %v, want ErrTxLocked", err)
+	}
+	ctx.MedianTime = lockTime
+	if err := CheckBlock(&b, ctx); err != nil {
+		t.Fatalf("once the median time past reaches the locktime: %v", err)
+	}
+}
+
+func TestMempoolHoldsLockedTransactions(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 100)
+	st.AddBalance("carol", 100)
+	mp := NewMempool(&DefaultParams)
+
+	// alice 的 nonce 0 锁定到高度 5，她的 nonce 1 也只能等着；carol 的交易不受影响
+	locked := transferTx("alice", 0, 1)
+	locked.LockTime = 5
+	for _, tx := range []Transaction{locked, transferTx("alice", 1, 1), transferTx("carol", 0, 1)} {
+		if _, err := mp.Add(tx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mp.LockedCount(st, time.Time{}); got != 1 {
+		t.Fatalf("LockedCount = %d, want 1", got)
+	}
+	txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32)
+	if len(txs) != 1 || txs[0].From != "carol" {
+		t.Fatalf("Pending = %+v, want only carol's transaction", txs)
+	}
+
+	// 下一个区块高度为 5 时解锁
+	st.Height = 4
+	if got := mp.LockedCount(st, time.Time{}); got != 0 {
+		t.Fatalf("LockedCount at height 4 = %d, want 0", got)
+	}
+	if txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32); len(txs) != 3 {
+		t.Fatalf("Pending at height 4 returned %d transactions, want 3", len(txs))
+	}
+}
@@ -0,0 +1,385 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mychain/utils"
+)
+
+// 交易池参数
+const (
+	// MaxMempoolSize 交易池最多容纳的交易数
+	MaxMempoolSize = 1000
+
+	// MaxQueuedPerAccount 每个账户最多能提前提交多少个 nonce（含可执行的）
+	MaxQueuedPerAccount = 16
+)
+
+var (
+	ErrTxKnown      = errors.New("transaction already in mempool")
+	ErrNonceTooLow  = errors.New("nonce too low")
+	ErrNonceTooHigh = errors.New("nonce too far in the future")
+	ErrNonceTaken   = errors.New("another transaction with the same nonce is pending")
+	ErrMempoolFull  = errors.New("mempool is full")
+)
+
+// poolTx 是交易池中的一笔交易，seq 记录到达顺序，size 为序列化后的字节数
+type poolTx struct {
+	tx   Transaction
+	seq  uint64
+	size int
+}
+
+// betterThan 判断 p 是否应该比 o 优先打包：手续费率（Fee / size）高者优先，相同则先到先得
+func (p *poolTx) betterThan(o *poolTx) bool {
+	// 交叉相乘比较 p.Fee/p.size 与 o.Fee/o.size，避免浮点误差
+	a := uint64(p.tx.Fee) * uint64(o.size)
+	b := uint64(o.tx.Fee) * uint64(p.size)
+	if a != b {
+		return a > b
+	}
+	return p.seq < o.seq
+}
+
+// Mempool 按账户和 nonce 组织待打包交易：
+//   - pending：从账户当前 nonce 开始连续的交易，可以直接打包
+//   - queued：nonce 之间有空缺的“未来交易”，等前面的交易到齐后自动变成 pending
+//
+// UTXO 模式下交易没有 nonce，每笔交易各自独立，只按 spent 记录防止池内双花。
+//
+// Mempool 本身不加锁，由调用方（P2PServer）负责并发保护。
+type Mempool struct {
+	params   *ChainParams
+	accounts map[string]map[uint64]*poolTx // From → nonce → 交易
+	byHash   map[string]*poolTx
+	spent    map[string]string // UTXO 模式：OutPoint → 花费它的池内交易哈希
+	seq      uint64
+}
+
+// NewMempool 创建一个空交易池
+func NewMempool(params *ChainParams) *Mempool {
+	return &Mempool{
+		params:   params,
+		accounts: make(map[string]map[uint64]*poolTx),
+		byHash:   make(map[string]*poolTx),
+		spent:    make(map[string]string),
+	}
+}
+
+// Len 返回交易池中的交易总数（pending + queued）
+func (mp *Mempool) Len() int {
+	return len(mp.byHash)
+}
+
+// Add 在链上状态 st 的基础上检查并加入一笔已验签的普通交易，
+// 返回值表示它是否可以立即打包（false 表示进入 queued 等待前面的 nonce）
+func (mp *Mempool) Add(tx Transaction, st *State) (bool, error) {
+	tx.CalculateHash()
+	key := utils.ToHex(tx.Hash)
+	if _, ok := mp.byHash[key]; ok {
+		return false, ErrTxKnown
+	}
+	if mp.params.Ledger == LedgerUTXO {
+		return true, mp.addUTXO(tx, key, st)
+	}
+
+	next := st.Nonce(tx.From)
+	if tx.Nonce < next {
+		return false, fmt.Errorf("%w: got %d, account nonce is %d", ErrNonceTooLow, tx.Nonce, next)
+	}
+	if tx.Nonce >= next+MaxQueuedPerAccount {
+		return false, fmt.Errorf("%w: got %d, account nonce is %d", ErrNonceTooHigh, tx.Nonce, next)
+	}
+
+	txs := mp.accounts[tx.From]
+	if _, ok := txs[tx.Nonce]; ok {
+		return false, fmt.Errorf("%w: nonce %d", ErrNonceTaken, tx.Nonce)
+	}
+	if mp.Len() >= MaxMempoolSize {
+		return false, ErrMempoolFull
+	}
+
+	// 余额检查：该账户 nonce 更小的池内交易 + 本笔（金额 + 手续费），总额不能超过已确认余额，
+	// 也不能动用在下一个区块时仍未成熟的 coinbase；代币转账另外检查同一代币的余额，质押相关交易另外检查质押与罚没记录
+	spent := int64(tx.NativeValue()) + int64(tx.Fee)
+	tokenSpent := int64(tx.Value)
+	for n, p := range txs {
+		if n < tx.Nonce {
+			spent += int64(p.tx.NativeValue()) + int64(p.tx.Fee)
+			if tx.Asset != "" && p.tx.Asset == tx.Asset {
+				tokenSpent += int64(p.tx.Value)
+			}
+		}
+	}
+	if tx.Asset != "" {
+		if st.Tokens[tx.Asset] == nil {
+			return false, fmt.Errorf("%w: %s", ErrUnknownToken, tx.Asset)
+		}
+		if have := st.TokenBalance(tx.Asset, tx.From); tokenSpent > have {
+			return false, fmt.Errorf("%w: account %s has %d of %s, pending spends %d",
+				ErrTokenOverdraw, tx.From, have, tx.Asset, tokenSpent)
+		}
+	}
+	if err := checkStakingState(mp.params, st, &tx); err != nil {
+		return false, err
+	}
+	if balance := st.Balance(tx.From); spent > balance {
+		return false, fmt.Errorf("%w: account %s has %d, pending spends %d",
+			ErrOverspend, tx.From, balance, spent)
+	}
+	mark := st.Snapshot()
+	st.beginBlock(st.Height + 1)
+	spendable := st.Spendable(tx.From)
+	st.RevertToSnapshot(mark)
+	if spent > spendable {
+		return false, fmt.Errorf("%w: account %s can spend %d, pending spends %d",
+			ErrImmatureSpend, tx.From, spendable, spent)
+	}
+
+	if txs == nil {
+		txs = make(map[uint64]*poolTx)
+		mp.accounts[tx.From] = txs
+	}
+	mp.seq++
+	p := &poolTx{tx: tx, seq: mp.seq, size: tx.Size()}
+	txs[tx.Nonce] = p
+	mp.byHash[key] = p
+
+	return tx.Nonce < mp.PendingNonce(tx.From, st), nil
+}
+
+// addUTXO 加入一笔 UTXO 交易：输入必须是已确认且未被池内其他交易花费的输出
+func (mp *Mempool) addUTXO(tx Transaction, key string, st *State) error {
+	if mp.Len() >= MaxMempoolSize {
+		return ErrMempoolFull
+	}
+
+	var in uint64
+	for _, input := range tx.Inputs {
+		op := OutPoint(input.TxHash, input.Index)
+		if other, ok := mp.spent[op]; ok {
+			return fmt.Errorf("%w: %s (by %s)", ErrMempoolDouble, op, other)
+		}
+		u, ok := st.UTXOs[op]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMissingInput, op)
+		}
+		if u.To != tx.From {
+			return fmt.Errorf("%w: %s belongs to %s", ErrInputOwner, op, u.To)
+		}
+		if !mp.params.Monetary.Mature(u, st.Height+1) {
+			return fmt.Errorf("%w: %s mined at height %d", ErrImmatureSpend, op, u.Height)
+		}
+		in += uint64(u.Value)
+	}
+	if in != tx.OutputValue()+uint64(tx.Fee) {
+		return fmt.Errorf("%w: in %d, out %d, fee %d", ErrFeeMismatch, in, tx.OutputValue(), tx.Fee)
+	}
+
+	mp.seq++
+	mp.byHash[key] = &poolTx{tx: tx, seq: mp.seq, size: tx.Size()}
+	for _, input := range tx.Inputs {
+		mp.spent[OutPoint(input.TxHash, input.Index)] = key
+	}
+	return nil
+}
+
+// IsSpent 判断某个输出是否已被交易池中的交易花费（UTXO 模式）
+func (mp *Mempool) IsSpent(outPoint string) bool {
+	_, ok := mp.spent[outPoint]
+	return ok
+}
+
+// PendingNonce 返回账户下一笔交易应使用的 nonce：
+// 链上 nonce 之后，交易池中连续 nonce 的下一个
+func (mp *Mempool) PendingNonce(addr string, st *State) uint64 {
+	next := st.Nonce(addr)
+	txs := mp.accounts[addr]
+	for {
+		if _, ok := txs[next]; !ok {
+			return next
+		}
+		next++
+	}
+}
+
+// Pending 选出最多 max 笔可以按顺序打包的交易：
+// 同一账户内严格按 nonce 递增，不同账户之间每次挑选队首手续费率最高的交易（相同则先到先打包）。
+// 选择时会在 st 上试执行，执行失败的交易连同该账户 nonce 更大的交易一起移出交易池
+// （其他账户的交易只会给它转入，不会让它变得可以执行）；试执行结束后 st 恢复原样。
+// 在下一个区块中尚未解锁的交易（mtp 为主链末端的过去中位时间）留在池中，
+// 同一账户 nonce 更大的交易也要等它解锁后才能打包。
+// 合约交易的 gas 上限之和不超过 MaxBlockGas，手续费之和不超过 maxFees（coinbase 金额 = 区块奖励 + 手续费，
+// 要放得进 32 位），放不下的交易连同其后续交易留到下一个区块。
+// UTXO 模式下每笔交易单独成一个队列。
+func (mp *Mempool) Pending(st *State, max int, mtp time.Time, maxFees uint64) []Transaction {
+	queues := mp.queues(st)
+
+	// 在下一个区块的高度上试执行（会解锁到期的 coinbase）
+	mark := st.Snapshot()
+	defer st.RevertToSnapshot(mark)
+	st.beginBlock(st.Height + 1)
+
+	gasLeft := mp.params.MaxBlockGas
+	var result []Transaction
+	for len(result) < max && len(queues) > 0 {
+		// 取队首手续费率最高的账户
+		best := 0
+		for i := range queues {
+			if queues[i][0].betterThan(queues[best][0]) {
+				best = i
+			}
+		}
+
+		tx := queues[best][0].tx
+		if !tx.IsFinal(st.Height, mtp) || tx.GasLimit > gasLeft || uint64(tx.Fee) > maxFees {
+			queues = append(queues[:best], queues[best+1:]...)
+			continue
+		}
+		if err := applyTx(mp.params, st, &tx); err != nil {
+			mp.evict(queues[best][0])
+			queues = append(queues[:best], queues[best+1:]...)
+			continue
+		}
+		gasLeft -= tx.GasLimit
+		maxFees -= uint64(tx.Fee)
+		result = append(result, tx)
+
+		queues[best] = queues[best][1:]
+		if len(queues[best]) == 0 {
+			queues = append(queues[:best], queues[best+1:]...)
+		}
+	}
+	return result
+}
+
+// Reset 在主链变化后调用：移除 nonce 已经小于链上 nonce 的交易（已上链或已失效），
+// UTXO 模式下移除输入已不在 UTXO 集合中的交易；
+// 再在新的主链状态上试执行每个可以打包的队列，执行失败的交易连同该账户 nonce 更大的交易一起移除
+func (mp *Mempool) Reset(st *State) {
+	if mp.params.Ledger == LedgerUTXO {
+		for key, p := range mp.byHash {
+			for _, input := range p.tx.Inputs {
+				if _, ok := st.UTXOs[OutPoint(input.TxHash, input.Index)]; !ok {
+					mp.removeUTXO(key, p)
+					break
+				}
+			}
+		}
+	}
+	for addr, txs := range mp.accounts {
+		next := st.Nonce(addr)
+		for n, p := range txs {
+			if n < next {
+				delete(txs, n)
+				delete(mp.byHash, utils.ToHex(p.tx.Hash))
+			}
+		}
+		if len(txs) == 0 {
+			delete(mp.accounts, addr)
+		}
+	}
+
+	for _, q := range mp.queues(st) {
+		mark := st.Snapshot()
+		st.beginBlock(st.Height + 1)
+		for _, p := range q {
+			tx := p.tx
+			if err := applyTx(mp.params, st, &tx); err != nil {
+				mp.evict(p)
+				break
+			}
+		}
+		st.RevertToSnapshot(mark)
+	}
+}
+
+// queues 返回可以按顺序打包的交易队列：每个账户从链上 nonce 开始的连续交易，
+// UTXO 模式下每笔交易单独成一个队列
+func (mp *Mempool) queues(st *State) [][]*poolTx {
+	var queues [][]*poolTx
+	if mp.params.Ledger == LedgerUTXO {
+		for _, p := range mp.byHash {
+			queues = append(queues, []*poolTx{p})
+		}
+	}
+	for addr, txs := range mp.accounts {
+		var q []*poolTx
+		for n := st.Nonce(addr); ; n++ {
+			p, ok := txs[n]
+			if !ok {
+				break
+			}
+			q = append(q, p)
+		}
+		if len(q) > 0 {
+			queues = append(queues, q)
+		}
+	}
+	return queues
+}
+
+// evict 移除执行失败的交易 p 以及同一账户 nonce 更大的交易（它们要等 p 上链才能执行）；
+// UTXO 模式下只移除 p 并释放它占用的输入
+func (mp *Mempool) evict(p *poolTx) {
+	if mp.params.Ledger == LedgerUTXO {
+		mp.removeUTXO(utils.ToHex(p.tx.Hash), p)
+		return
+	}
+	txs := mp.accounts[p.tx.From]
+	for n, q := range txs {
+		if n >= p.tx.Nonce {
+			delete(txs, n)
+			delete(mp.byHash, utils.ToHex(q.tx.Hash))
+		}
+	}
+	if len(txs) == 0 {
+		delete(mp.accounts, p.tx.From)
+	}
+}
+
+// removeUTXO 删除一笔 UTXO 交易，并释放它占用的输入
+func (mp *Mempool) removeUTXO(key string, p *poolTx) {
+	delete(mp.byHash, key)
+	for _, input := range p.tx.Inputs {
+		delete(mp.spent, OutPoint(input.TxHash, input.Index))
+	}
+}
+
+// Txs 返回交易池中的全部交易（按到达顺序）
+func (mp *Mempool) Txs() []Transaction {
+	all := make([]*poolTx, 0, len(mp.byHash))
+	for _, p := range mp.byHash {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].seq < all[j].seq })
+
+	txs := make([]Transaction, len(all))
+	for i, p := range all {
+		txs[i] = p.tx
+	}
+	return txs
+}
+
+// QueuedCount 返回因 nonce 不连续而暂时不能打包的交易数
+func (mp *Mempool) QueuedCount(st *State) int {
+	queued := 0
+	for addr, txs := range mp.accounts {
+		queued += len(txs) - int(mp.PendingNonce(addr, st)-st.Nonce(addr))
+	}
+	return queued
+}
+
+// LockedCount 返回在下一个区块中仍未解锁（锁定时间未到）的交易数
+func (mp *Mempool) LockedCount(st *State, mtp time.Time) int {
+	locked := 0
+	for _, p := range mp.byHash {
+		if !p.tx.IsFinal(st.Height+1, mtp) {
+			locked++
+		}
+	}
+	return locked
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// transferTx 返回 from 用 nonce 转给 bob 的一笔交易（交易池不验签，签名由调用方在入池前检查）
+func transferTx(from string, nonce uint64, fee uint32) Transaction {
+	return Transaction{From: from, To: "bob", Value: 1, Fee: fee, Nonce: nonce, Timestamp: time.Unix(1700000000, 0)}
+}
+
+func TestMempoolQueuesFutureNonces(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 100)
+	mp := NewMempool(&DefaultParams)
+
+	// nonce 1、2 先到：前面缺了 nonce 0，只能排队
+	for _, n := range []uint64{2, 1} {
+		ready, err := mp.Add(transferTx("alice", n, 1), st)
+		if err != nil {
+			t.Fatalf("add nonce %d: %v", n, err)
+		}
+		if ready {
+			t.Fatalf("nonce %d reported ready before nonce 0 arrived", n)
+		}
+	}
+	if got := mp.QueuedCount(st); got != 2 {
+		t.Fatalf("QueuedCount = %d, want 2", got)
+	}
+	if got := mp.PendingNonce("alice", st); got != 0 {
+		t.Fatalf("PendingNonce = %d, want 0", got)
+	}
+	if txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32); len(txs) != 0 {
+		t.Fatalf("Pending returned %d queued transactions", len(txs))
+	}
+
+	// nonce 0 到达后整串都可以打包，并且严格按 nonce 顺序
+	ready, err := mp.Add(transferTx("alice", 0, 1), st)
+	if err != nil || !ready {
+		t.Fatalf("add nonce 0: ready=%v err=%v", ready, err)
+	}
+	if got := mp.QueuedCount(st); got != 0 {
+		t.Fatalf("QueuedCount = %d after the gap was filled, want 0", got)
+	}
+	if got := mp.PendingNonce("alice", st); got != 3 {
+		t.Fatalf("PendingNonce = %d, want 3", got)
+	}
+	txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32)
+	if len(txs) != 3 {
+		t.Fatalf("Pending returned %d transactions, want 3", len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Nonce != uint64(i) {
+			t.Fatalf("Pending[%d] has nonce %d", i, tx.Nonce)
+		}
+	}
+	if st.Nonce("alice") != 0 || st.Balance("alice") != 100 {
+		t.Fatal("Pending left its trial execution in the state")
+	}
+}
+
+func TestMempoolRejectsBadNonces(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 100)
+	st.IncNonce("alice")
+	mp := NewMempool(&DefaultParams)
+
+	if _, err := mp.Add(transferTx("alice", 0, 1), st); !errors.Is(err, ErrNonceTooLow) {
+		t.Fatalf("used nonce: err = %v, want ErrNonceTooLow", err)
+	}
+	if _, err := mp.Add(transferTx("alice", 1+MaxQueuedPerAccount, 1), st); !errors.Is(err, ErrNonceTooHigh) {
+		t.Fatalf("far future nonce: err = %v, want ErrNonceTooHigh", err)
+	}
+	if _, err := mp.Add(transferTx("alice", 1, 1), st); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(transferTx("alice", 1, 2), st); !errors.Is(err, ErrNonceTaken) {
+		t.Fatalf("same nonce twice: err = %v, want ErrNonceTaken", err)
+	}
+}
+
+func TestMempoolQueuedSpendsCountAgainstBalance(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 5)
+	mp := NewMempool(&DefaultParams)
+
+	// 每笔花 1 + 1，排队中的交易同样占用余额：nonce 2 之前已有两笔，共需 6 > 5
+	for _, n := range []uint64{0, 1} {
+		if _, err := mp.Add(transferTx("alice", n, 1), st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mp.Add(transferTx("alice", 2, 1), st); !errors.Is(err, ErrOverspend) {
+		t.Fatalf("err = %v, want ErrOverspend", err)
+	}
+
+	// 链上 nonce 前进之后，已上链的交易被移出交易池
+	st.IncNonce("alice")
+	mp.Reset(st)
+	if mp.Len() != 1 {
+		t.Fatalf("Len after Reset = %d, want 1", mp.Len())
+	}
+}
+
+func TestMempoolPendingOrdersByFeeRate(t *testing.T) {
+	st := NewState()
+	for _, addr := range []string{"alice", "carol", "dave"} {
+		st.AddBalance(addr, 100)
+	}
+	mp := NewMempool(&DefaultParams)
+
+	// carol 的第一笔手续费低，第二笔高：账户内仍按 nonce 打包，高手续费不能插队到自己的前一笔之前
+	for _, tx := range []Transaction{
+		transferTx("alice", 0, 2),
+		transferTx("carol", 0, 1),
+		transferTx("carol", 1, 9),
+		transferTx("dave", 0, 5),
+	} {
+		if _, err := mp.Add(tx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []struct {
+		from  string
+		nonce uint64
+	}{{"dave", 0}, {"alice", 0}, {"carol", 0}, {"carol", 1}}
+	txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32)
+	if len(txs) != len(want) {
+		t.Fatalf("Pending returned %d transactions, want %d", len(txs), len(want))
+	}
+	for i, w := range want {
+		if txs[i].From != w.from || txs[i].Nonce != w.nonce {
+			t.Fatalf("Pending[%d] = %s/%d, want %s/%d", i, txs[i].From, txs[i].Nonce, w.from, w.nonce)
+		}
+	}
+	if got := TotalFees(txs); got != 17 {
+		t.Fatalf("TotalFees = %d, want 17", got)
+	}
+}
+
+func TestMempoolEvictsFailingHead(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 6)
+	st.AddBalance("carol", 100)
+	mp := NewMempool(&DefaultParams)
+	add := func(txs ...Transaction) {
+		t.Helper()
+		for _, tx := range txs {
+			if _, err := mp.Add(tx, st); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// carol 的 nonce 2 前面缺了 nonce 1，只能排队，不参与试执行
+	add(transferTx("alice", 0, 1), transferTx("alice", 1, 1), transferTx("alice", 2, 1),
+		transferTx("carol", 0, 1), transferTx("carol", 2, 1))
+
+	// alice 的余额在入池之后减少：队首执行失败，连同后面的 nonce 1、2 一起移出交易池
+	st.AddBalance("alice", -5)
+	txs := mp.Pending(st, 10, time.Time{}, math.MaxUint32)
+	if len(txs) != 1 || txs[0].From != "carol" {
+		t.Fatalf("Pending returned %+v, want only carol's transaction", txs)
+	}
+	if mp.Len() != 2 || mp.PendingNonce("alice", st) != 0 {
+		t.Fatalf("after Pending: Len %d, alice's pending nonce %d", mp.Len(), mp.PendingNonce("alice", st))
+	}
+
+	// 主链变化：alice 的 nonce 0 已上链，余额只剩 1。Reset 移除已上链的交易，
+	// 再移除在新状态上执行失败的 nonce 1 及其后续交易，carol 排队中的交易不受影响
+	st.AddBalance("alice", 5)
+	add(transferTx("alice", 0, 1), transferTx("alice", 1, 1), transferTx("alice", 2, 1))
+	st.IncNonce("alice")
+	st.AddBalance("alice", -5)
+	mp.Reset(st)
+	if mp.Len() != 2 || mp.PendingNonce("alice", st) != 1 || mp.QueuedCount(st) != 1 {
+		t.Fatalf("after Reset: Len %d, alice's pending nonce %d, queued %d",
+			mp.Len(), mp.PendingNonce("alice", st), mp.QueuedCount(st))
+	}
+	for _, tx := range mp.Txs() {
+		if tx.From != "carol" {
+			t.Fatalf("%s/%d kept after its head failed", tx.From, tx.Nonce)
+		}
+	}
+	if st.Nonce("alice") != 1 || st.Balance("alice") != 1 || st.Height != 0 {
+		t.Fatal("Reset left its trial execution in the state")
+	}
+}
+
+func TestMempoolPendingCapsFees(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 3_000_000_001)
+	st.AddBalance("carol", 3_000_000_001)
+	mp := NewMempool(&DefaultParams)
+	for _, tx := range []Transaction{transferTx("alice", 0, 3_000_000_000), transferTx("carol", 0, 3_000_000_000)} {
+		if _, err := mp.Add(tx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 两笔手续费之和超过 32 位：只打包一笔，另一笔留在池中
+	maxFees := uint64(math.MaxUint32 - 50)
+	txs := mp.Pending(st, 10, time.Time{}, maxFees)
+	if len(txs) != 1 || uint64(TotalFees(txs)) > maxFees {
+		t.Fatalf("Pending returned %d transactions with fees %d, limit %d", len(txs), TotalFees(txs), maxFees)
+	}
+	if mp.Len() != 2 {
+		t.Fatalf("Len %d, want both transactions kept in the pool", mp.Len())
+	}
+	if txs := mp.Pending(st, 10, time.Time{}, 100); len(txs) != 0 {
+		t.Fatalf("Pending returned %d transactions above the fee limit", len(txs))
+	}
+}
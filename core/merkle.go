@@ -1,6 +1,11 @@
 package core
 
-import "mychain/utils"
+import (
+	"bytes"
+	"fmt"
+
+	"mychain/utils"
+)
 
 // 计算简单 Merkle Root（无补齐，直接两两拼接哈希）
 func CalculateMerkleRoot(txs []Transaction) []byte {
@@ -37,3 +42,63 @@ func CalculateMerkleRoot(txs []Transaction) []byte {
 
 	return hashes[0]
 }
+
+// MerkleStep 是 Merkle 证明中的一步：与当前哈希拼接的兄弟节点，
+// Left 为 true 表示兄弟节点在左边（拼接顺序为 兄弟 + 当前）
+type MerkleStep struct {
+	Hash []byte `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// BuildMerkleProof 生成第 index 笔交易到 Merkle 根的路径（自底向上），
+// 与 CalculateMerkleRoot 的规则一致：奇数个节点时最后一个和自己拼接
+func BuildMerkleProof(txs []Transaction, index int) ([]MerkleStep, error) {
+	if index < 0 || index >= len(txs) {
+		return nil, fmt.Errorf("tx index %d out of range [0, %d)", index, len(txs))
+	}
+
+	var hashes [][]byte
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash)
+	}
+
+	var path []MerkleStep
+	for len(hashes) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(hashes) {
+			sibling = index // 奇数个时，最后一个与自己配对
+		}
+		path = append(path, MerkleStep{Hash: hashes[sibling], Left: sibling < index})
+
+		var newLevel [][]byte
+		for i := 0; i < len(hashes); i += 2 {
+			j := i + 1
+			if j == len(hashes) {
+				j = i
+			}
+			combined := append(append([]byte{}, hashes[i]...), hashes[j]...)
+			newLevel = append(newLevel, utils.Sha256(combined))
+		}
+		hashes = newLevel
+		index /= 2
+	}
+	return path, nil
+}
+
+// MerkleRootFromProof 沿着证明路径从叶子哈希重新计算 Merkle 根
+func MerkleRootFromProof(leaf []byte, path []MerkleStep) []byte {
+	hash := leaf
+	for _, step := range path {
+		if step.Left {
+			hash = utils.Sha256(append(append([]byte{}, step.Hash...), hash...))
+		} else {
+			hash = utils.Sha256(append(append([]byte{}, hash...), step.Hash...))
+		}
+	}
+	return hash
+}
+
+// VerifyMerkleProof 判断 leaf 是否通过 path 归属于 Merkle 根 root
+func VerifyMerkleProof(leaf, root []byte, path []MerkleStep) bool {
+	return bytes.Equal(MerkleRootFromProof(leaf, path), root)
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	// 1 ~ 7 笔交易覆盖了单个叶子、偶数个和奇数个节点（最后一个与自己配对）的情况
+	for n := 1; n <= 7; n++ {
+		txs := make([]Transaction, n)
+		for i := range txs {
+			txs[i] = transferTx("alice", uint64(i), 1)
+			txs[i].CalculateHash()
+		}
+		root := CalculateMerkleRoot(txs)
+
+		for i := range txs {
+			path, err := BuildMerkleProof(txs, i)
+			if err != nil {
+				t.Fatalf("n=%d: proof for tx %d: %v", n, i, err)
+			}
+			if !VerifyMerkleProof(txs[i].Hash, root, path) {
+				t.Fatalf("n=%d: proof for tx %d does not verify", n, i)
+			}
+			// 证明只对自己的交易有效
+			other := txs[(i+1)%n].Hash
+			if n > 1 && !bytes.Equal(other, txs[i].Hash) && VerifyMerkleProof(other, root, path) {
+				t.Fatalf("n=%d: proof for tx %d also verifies tx %d", n, i, (i+1)%n)
+			}
+			// 篡改路径中的任何一个哈希都会让证明失效
+			for j := range path {
+				tampered := append([]MerkleStep(nil), path...)
+				tampered[j].Hash = append([]byte{^path[j].Hash[0]}, path[j].Hash[1:]...)
+				if VerifyMerkleProof(txs[i].Hash, root, tampered) {
+					t.Fatalf("n=%d: tampered step %d of tx %d still verifies", n, j, i)
+				}
+			}
+		}
+	}
+
+	if _, err := BuildMerkleProof(nil, 0); err == nil {
+		t.Fatal("proof for an empty block should fail")
+	}
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkInterval 每个工作协程每算多少次哈希检查一次是否需要停止，并汇总一次计数
+const checkInterval = 4096
+
+// Miner 是并行、可取消的 POW 挖矿器：
+//   - 把 32 位 nonce 空间平均分给多个协程同时搜索（默认与 GOMAXPROCS 相同）
+//   - nonce 全部试完仍未找到时，把时间戳往后挪 1 秒再搜索一轮
+//   - 通过 context 随时取消（例如收到了竞争区块）
+//
+// 创世块必须在所有节点上得到同样的结果，仍使用顺序搜索的 Block.Mine。
+type Miner struct {
+	Workers int
+
+	mining      atomic.Bool
+	totalHashes atomic.Uint64
+	hashRate    atomic.Uint64 // 最近一次挖矿的算力（H/s），以 float64 的位模式保存
+}
+
+// MinerStats 是挖矿器的运行统计
+type MinerStats struct {
+	Workers     int     `json:"workers"`
+	Mining      bool    `json:"mining"`      // 当前是否正在挖矿
+	HashRate    float64 `json:"hashRate"`    // 最近一次挖矿的平均算力（H/s）
+	TotalHashes uint64  `json:"totalHashes"` // 启动以来累计计算的哈希次数
+}
+
+// NewMiner 创建挖矿器，workers <= 0 时使用 GOMAXPROCS 个协程
+func NewMiner(workers int) *Miner {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &Miner{Workers: workers}
+}
+
+// Stats 返回挖矿统计
+func (m *Miner) Stats() MinerStats {
+	return MinerStats{
+		Workers:     m.Workers,
+		Mining:      m.mining.Load(),
+		HashRate:    math.Float64frombits(m.hashRate.Load()),
+		TotalHashes: m.totalHashes.Load(),
+	}
+}
+
+// Mine 为区块 b 搜索满足难度的 nonce，找到后填入 b.Header 的 Nonce 和 Hash（时间戳可能被后移）。
+// ctx 被取消时立即停止并返回 ctx.Err()。
+func (m *Miner) Mine(ctx context.Context, b *Block) error {
+	m.mining.Store(true)
+	defer m.mining.Store(false)
+
+	var hashes atomic.Uint64
+	start := time.Now()
+	defer func() {
+		n := hashes.Load()
+		m.totalHashes.Add(n)
+		if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+			m.hashRate.Store(math.Float64bits(float64(n) / elapsed))
+		}
+	}()
+
+	target := make([]byte, 32)
+	NewPow(b).Target.FillBytes(target)
+
+	for {
+		nonce, hash, found := m.searchRound(ctx, b.Header, target, &hashes)
+		if err := ctx.Err(); err != nil && !found {
+			return err
+		}
+		if found {
+			b.Header.Nonce = nonce
+			b.Header.Hash = hash
+			return nil
+		}
+
+		// 32 位 nonce 空间已经用完：时间戳后移（至少 1 秒）后重新搜索
+		next := b.Header.Timestamp.Add(time.Second)
+		if now := time.Now().Truncate(time.Second); now.After(next) {
+			next = now
+		}
+		b.Header.Timestamp = next
+	}
+}
+
+// searchRound 在当前时间戳下把整个 nonce 空间分给各协程搜索，任一协程找到即让其他协程停止
+func (m *Miner) searchRound(ctx context.Context, h *BlockHeader, target []byte, hashes *atomic.Uint64) (uint32, []byte, bool) {
+	roundCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 区块头编码的最后 4 个字节就是 nonce（见 encoding.go），只需替换这 4 个字节
+	prefix := encodeHeader(h, 0)
+
+	type result struct {
+		nonce uint32
+		hash  []byte
+	}
+	found := make(chan result, 1)
+
+	var wg sync.WaitGroup
+	chunk := (uint64(math.MaxUint32) + 1) / uint64(m.Workers)
+	for i := 0; i < m.Workers; i++ {
+		from := uint64(i) * chunk
+		to := from + chunk
+		if i == m.Workers-1 {
+			to = uint64(math.MaxUint32) + 1
+		}
+
+		wg.Add(1)
+		go func(from, to uint64) {
+			defer wg.Done()
+
+			data := append([]byte(nil), prefix...)
+			tail := data[len(data)-4:]
+			var count uint64
+			for n := from; n < to; n++ {
+				binary.BigEndian.PutUint32(tail, uint32(n))
+				sum := sha256.Sum256(data)
+				count++
+
+				if bytes.Compare(sum[:], target) <= 0 {
+					select {
+					case found <- result{nonce: uint32(n), hash: sum[:]}:
+					default:
+					}
+					cancel()
+					break
+				}
+				if count%checkInterval == 0 {
+					hashes.Add(checkInterval)
+					if roundCtx.Err() != nil {
+						break
+					}
+				}
+			}
+			hashes.Add(count % checkInterval)
+		}(from, to)
+	}
+	wg.Wait()
+
+	select {
+	case r := <-found:
+		return r.nonce, r.hash, true
+	default:
+		return 0, nil, false
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMinerFindsValidNonce(t *testing.T) {
+	bc := NewBlockchain(&DefaultParams)
+	b, err := bc.NewBlockTemplate([]Transaction{DefaultParams.NewCoinbase(1, "alice", uint32(DefaultParams.Monetary.Subsidy(1)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMiner(4)
+	if err := m.Mine(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !NewPow(&b).Validate() {
+		t.Fatal("mined block does not satisfy its target")
+	}
+	if _, err := bc.ProcessBlock(b); err != nil {
+		t.Fatalf("mined block rejected: %v", err)
+	}
+	if st := m.Stats(); st.Workers != 4 || st.Mining || st.TotalHashes == 0 {
+		t.Fatalf("stats after mining: %+v", st)
+	}
+}
+
+func TestMinerStopsWhenCancelled(t *testing.T) {
+	bc := NewBlockchain(&DefaultParams)
+	b, err := bc.NewBlockTemplate([]Transaction{DefaultParams.NewCoinbase(1, "alice", uint32(DefaultParams.Monetary.Subsidy(1)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Header.Bits = 0x03000001 // 目标为 1，几乎不可能挖到
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewMiner(2).Mine(ctx, &b); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if b.Header.Hash != nil {
+		t.Fatal("cancelled mining filled in a hash")
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// BurnAddress 是公开的销毁地址：地址为公钥哈希，不可能有公钥哈希为全 0，
+// 转到这里的币永远无法再花费，/supply 把它们计为已销毁
+var BurnAddress = strings.Repeat("0", 64)
+
+var ErrImmatureSpend = errors.New("coinbase funds not yet mature")
+
+// MonetaryPolicy 决定每个高度的区块奖励以及挖出的币何时可以花费，各网络的取值见链配置文件；
+// mainnet 为 50 起步，每 100 个区块减半，总量上限 10000，挖出的币 3 个区块后成熟
+type MonetaryPolicy struct {
+	InitialReward    uint64 `json:"initialReward"`    // 高度 1 开始的区块奖励
+	HalvingInterval  uint64 `json:"halvingInterval"`  // 每隔多少个区块奖励减半，0 表示不减半
+	TailEmission     uint64 `json:"tailEmission"`     // 减半后奖励的下限（尾部增发），0 表示可以减到 0
+	MaxSupply        uint64 `json:"maxSupply"`        // 区块奖励的发行总量上限（不含创世分配），0 表示不设上限
+	CoinbaseMaturity uint64 `json:"coinbaseMaturity"` // 高度 h 的 coinbase 要到高度 h+CoinbaseMaturity 的区块才能花费
+}
+
+// baseSubsidy 返回不考虑总量上限时高度 height 的区块奖励
+func (m *MonetaryPolicy) baseSubsidy(height uint64) uint64 {
+	if height == 0 {
+		return 0 // 创世块没有奖励
+	}
+	reward := m.InitialReward
+	if m.HalvingInterval > 0 {
+		halvings := (height - 1) / m.HalvingInterval
+		if halvings >= 64 {
+			reward = 0
+		} else {
+			reward >>= halvings
+		}
+	}
+	if reward < m.TailEmission {
+		reward = m.TailEmission
+	}
+	return reward
+}
+
+// Issued 返回高度 0 到 height（含）的区块奖励总和，即截至该高度的发行量
+func (m *MonetaryPolicy) Issued(height uint64) uint64 {
+	var total uint64
+	// 按奖励不变的区间（减半周期）整段累加，而不是逐块累加
+	for h := uint64(1); h <= height; {
+		end := height
+		if m.HalvingInterval > 0 {
+			if eraEnd := ((h-1)/m.HalvingInterval + 1) * m.HalvingInterval; eraEnd < end {
+				end = eraEnd
+			}
+		}
+		reward := m.baseSubsidy(h)
+		if reward == 0 && m.TailEmission == 0 {
+			break
+		}
+		total += reward * (end - h + 1)
+		if m.MaxSupply > 0 && total >= m.MaxSupply {
+			return m.MaxSupply
+		}
+		h = end + 1
+	}
+	return total
+}
+
+// Subsidy 返回高度 height 的区块奖励（不含手续费）：按减半规则计算，不低于尾部增发，
+// 并且不能让发行量超过上限
+func (m *MonetaryPolicy) Subsidy(height uint64) uint64 {
+	if height == 0 {
+		return 0
+	}
+	return m.Issued(height) - m.Issued(height-1)
+}
+
+// Supply 描述某个高度时的货币供应
+type Supply struct {
+	Height      uint64 `json:"height"`
+	Subsidy     uint64 `json:"subsidy"`     // 该高度的区块奖励
+	Issued      uint64 `json:"issued"`      // 累计发行量（创世分配 + 区块奖励之和，手续费只是转移不计入）
+	Burned      uint64 `json:"burned"`      // 累计转入销毁地址以及被罚没的金额
+	Circulating uint64 `json:"circulating"` // 流通量 = 发行量 - 销毁量
+	MaxSupply   uint64 `json:"maxSupply"`   // 区块奖励的发行上限（不含创世分配），0 表示不设上限
+}
+
+// blockIssuance 返回高度 height 的区块新发行的币：创世块为创世分配，之后为区块奖励
+func blockIssuance(p *ChainParams, height uint64) uint64 {
+	if height == 0 {
+		return p.GenesisSupply()
+	}
+	return p.Monetary.Subsidy(height)
+}
+
+// addSupply 累加状态中的发行量与销毁量。销毁在余额转入 BurnAddress（普通转账、UTXO 输出、
+// 合约的 OP_TRANSFER 都经过 AddBalance）与罚没时计入
+func (st *State) addSupply(issued, burned uint64) {
+	prevIssued, prevBurned := st.Issued, st.Burned
+	st.journal = append(st.journal, func() {
+		st.Issued, st.Burned = prevIssued, prevBurned
+	})
+	st.Issued += issued
+	st.Burned += burned
+}
+
+// recordSupply 在区块 n 执行完之后记下状态中的累计发行量与销毁量，供查询历史高度的供应
+func (bc *Blockchain) recordSupply(n *blockNode) {
+	n.issued, n.burned = bc.State.Issued, bc.State.Burned
+}
+
+// Supply 返回主链在高度 height 时的货币供应，发行量与销毁量来自该区块执行完之后的状态
+func (bc *Blockchain) Supply(height uint64) (*Supply, error) {
+	if height > uint64(bc.tip.height) {
+		return nil, ErrBadHeight
+	}
+	n := bc.tip.ancestor(int(height))
+
+	m := &bc.Params.Monetary
+	return &Supply{
+		Height:      height,
+		Subsidy:     m.Subsidy(height),
+		Issued:      n.issued,
+		Burned:      n.burned,
+		Circulating: n.issued - n.burned,
+		MaxSupply:   m.MaxSupply,
+	}, nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+func TestSubsidySchedule(t *testing.T) {
+	mainnet := DefaultParams.Monetary // 50 起步，每 100 个区块减半，上限 10000
+	tests := []struct {
+		name   string
+		policy MonetaryPolicy
+		height uint64
+		want   uint64
+	}{
+		{"genesis", mainnet, 0, 0},
+		{"first block", mainnet, 1, 50},
+		{"last block of era 0", mainnet, 100, 50},
+		{"first block of era 1", mainnet, 101, 25},
+		{"era 2", mainnet, 201, 12},
+		{"era 5", mainnet, 501, 1},
+		{"halved to zero", mainnet, 601, 0},
+		{"64 halvings", MonetaryPolicy{InitialReward: 50, HalvingInterval: 1}, 1000, 0},
+		{"tail emission", MonetaryPolicy{InitialReward: 8, HalvingInterval: 1, TailEmission: 2}, 5, 2},
+		{"no halving", MonetaryPolicy{InitialReward: 7}, 1 << 40, 7},
+		// 上限 120、每块 50：第 3 块只能发 20，之后不再发行
+		{"below the cap", MonetaryPolicy{InitialReward: 50, MaxSupply: 120}, 2, 50},
+		{"reaches the cap", MonetaryPolicy{InitialReward: 50, MaxSupply: 120}, 3, 20},
+		{"above the cap", MonetaryPolicy{InitialReward: 50, MaxSupply: 120}, 4, 0},
+		{"tail emission stops at the cap", MonetaryPolicy{InitialReward: 8, HalvingInterval: 1, TailEmission: 2, MaxSupply: 17}, 5, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Subsidy(tt.height); got != tt.want {
+			t.Errorf("%s: Subsidy(%d) = %d, want %d", tt.name, tt.height, got, tt.want)
+		}
+	}
+
+	// 按周期累加的 Issued 与逐块累加一致，且不超过上限
+	for _, m := range []MonetaryPolicy{mainnet, {InitialReward: 50, MaxSupply: 120}, {InitialReward: 8, HalvingInterval: 3, TailEmission: 1}} {
+		var sum uint64
+		for h := uint64(0); h <= 1000; h++ {
+			sum += m.baseSubsidy(h)
+			if m.MaxSupply > 0 && sum > m.MaxSupply {
+				sum = m.MaxSupply
+			}
+			if got := m.Issued(h); got != sum {
+				t.Fatalf("%+v: Issued(%d) = %d, want %d", m, h, got, sum)
+			}
+		}
+	}
+	if got := mainnet.Issued(1 << 40); got != 9700 {
+		t.Fatalf("total issuance = %d, want 9700", got)
+	}
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	p := DefaultParams // mainnet：CoinbaseMaturity = 3
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := utils.PubKeyToAddress(pub)
+	reward := uint32(p.Monetary.Subsidy(1))
+
+	bc := NewBlockchain(&p)
+	mineBlocks(t, bc, alice, 1)
+
+	spend := Transaction{From: alice, To: "bob", Value: 10, Fee: 1, Timestamp: time.Now()}
+	if err := spend.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	spendIn := func() error {
+		coinbase := p.NewCoinbase(bc.NextHeight(), "miner", reward+spend.Fee)
+		_, err := bc.AddBlock([]Transaction{coinbase, spend})
+		return err
+	}
+
+	// 高度 1 的 coinbase 在高度 2、3 都不能花：交易池拒收，区块也不能打包
+	for bc.NextHeight() < 1+p.Monetary.CoinbaseMaturity {
+		if got := bc.SpendableBalance(alice); got != 0 {
+			t.Fatalf("height %d: spendable %d before maturity", bc.NextHeight(), got)
+		}
+		if _, err := NewMempool(&p).Add(spend, bc.State); !errors.Is(err, ErrImmatureSpend) {
+			t.Fatalf("height %d: mempool err = %v, want ErrImmatureSpend", bc.NextHeight(), err)
+		}
+		if err := spendIn(); !errors.Is(err, ErrImmatureSpend) {
+			t.Fatalf("height %d: block err = %v, want ErrImmatureSpend", bc.NextHeight(), err)
+		}
+		mineBlocks(t, bc, "miner", 1)
+	}
+
+	// 高度 4 成熟
+	if got := bc.SpendableBalance(alice); got != int64(reward) {
+		t.Fatalf("spendable at maturity = %d, want %d", got, reward)
+	}
+	if _, err := NewMempool(&p).Add(spend, bc.State); err != nil {
+		t.Fatalf("mempool at maturity: %v", err)
+	}
+	if err := spendIn(); err != nil {
+		t.Fatalf("spend at maturity: %v", err)
+	}
+	if got := bc.GetBalance(alice); got != int64(reward)-11 {
+		t.Fatalf("alice balance = %d, want %d", got, int64(reward)-11)
+	}
+}
+
+func TestSupplyTracksIssuanceAndBurns(t *testing.T) {
+	p := loadRegtest(t)
+	reward := p.Monetary.Subsidy(1)
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := utils.PubKeyToAddress(pub)
+
+	bc := NewBlockchain(p)
+	mineBlocks(t, bc, alice, 2)
+
+	// 高度 3：alice 把 10 转入销毁地址
+	burn := Transaction{From: alice, To: BurnAddress, Value: 10, Fee: 1, Timestamp: time.Unix(1700000000, 0)}
+	if err := burn.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	coinbase := p.NewCoinbase(bc.NextHeight(), alice, uint32(reward)+burn.Fee)
+	if _, err := bc.AddBlock([]Transaction{coinbase, burn}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(height, issued, burned uint64) {
+		t.Helper()
+		s, err := bc.Supply(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Issued != issued || s.Burned != burned || s.Circulating != issued-burned {
+			t.Fatalf("height %d: issued %d burned %d circulating %d, want %d %d %d",
+				height, s.Issued, s.Burned, s.Circulating, issued, burned, issued-burned)
+		}
+	}
+	check(3, 3*reward, 10)
+	check(2, 2*reward, 0) // 历史高度使用当时的累计值
+	if _, err := bc.Supply(4); !errors.Is(err, ErrBadHeight) {
+		t.Fatalf("Supply above the tip: err = %v, want ErrBadHeight", err)
+	}
+
+	// 合约的 OP_TRANSFER 转入销毁地址同样计入，撤销后恢复
+	mark := bc.State.Snapshot()
+	bc.State.AddBalance("contract", 5)
+	host := &contractHost{st: bc.State, caller: alice, self: "contract"}
+	if err := host.Transfer(BurnAddress, 5); err != nil {
+		t.Fatal(err)
+	}
+	if bc.State.Burned != 15 {
+		t.Fatalf("burned after contract transfer = %d, want 15", bc.State.Burned)
+	}
+	bc.State.RevertToSnapshot(mark)
+	if bc.State.Burned != 10 {
+		t.Fatalf("burned after revert = %d, want 10", bc.State.Burned)
+	}
+
+	// 重组到一条更长、没有销毁的分叉：断开的区块的发行量与销毁量一并撤销
+	processAll(t, bc, mineBlocks(t, NewBlockchain(p), "bob", 4))
+	check(4, 4*reward, 0)
+	check(3, 3*reward, 0)
+	if bc.State.Issued != 4*reward || bc.State.Burned != 0 {
+		t.Fatalf("state totals after reorg: issued %d burned %d", bc.State.Issued, bc.State.Burned)
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sort"
+
+	"mychain/utils"
+)
+
+// MaxMultisigKeys 一个多签地址最多包含多少个公钥
+const MaxMultisigKeys = 15
+
+var (
+	ErrBadMultisig      = errors.New("malformed multisig")
+	ErrMultisigAddress  = errors.New("from address does not match multisig keys")
+	ErrNotEnoughSigs    = errors.New("not enough valid multisig signatures")
+	ErrNotMultisigOwner = errors.New("key is not part of the multisig")
+)
+
+// MultiSig 是 M-of-N 多签交易的签名部分：N 个公钥中至少 Threshold 个对交易签名。
+// Sigs 与 PubKeys 一一对应，未签名的位置为空。
+// 多签地址 = SHA256("multisig" | u32 Threshold | u32 N | 每个公钥（bytes）)，
+// 只由门限和公钥决定，因此 From 必须等于它。
+type MultiSig struct {
+	Threshold uint32   `json:"threshold"`
+	PubKeys   [][]byte `json:"pubKeys"`
+	Sigs      [][]byte `json:"sigs"`
+}
+
+// NewMultiSig 用门限和一组公钥构造一个尚未签名的多签，公钥按字节序排序，
+// 这样同一组公钥无论以什么顺序给出都得到同一个地址
+func NewMultiSig(threshold uint32, pubKeys [][]byte) (*MultiSig, error) {
+	keys := make([][]byte, len(pubKeys))
+	copy(keys, pubKeys)
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+	ms := &MultiSig{Threshold: threshold, PubKeys: keys, Sigs: make([][]byte, len(keys))}
+	if err := ms.check(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// check 检查门限和公钥是否合理：1 <= Threshold <= N <= MaxMultisigKeys，公钥不能重复
+func (ms *MultiSig) check() error {
+	n := len(ms.PubKeys)
+	if n == 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("%w: %d keys (max %d)", ErrBadMultisig, n, MaxMultisigKeys)
+	}
+	if ms.Threshold == 0 || int(ms.Threshold) > n {
+		return fmt.Errorf("%w: threshold %d of %d", ErrBadMultisig, ms.Threshold, n)
+	}
+	if len(ms.Sigs) != n {
+		return fmt.Errorf("%w: %d sigs for %d keys", ErrBadMultisig, len(ms.Sigs), n)
+	}
+	seen := make(map[string]bool)
+	for _, pk := range ms.PubKeys {
+		if len(pk) == 0 || seen[string(pk)] {
+			return fmt.Errorf("%w: empty or duplicate pubkey", ErrBadMultisig)
+		}
+		seen[string(pk)] = true
+	}
+	return nil
+}
+
+// Address 返回多签地址
+func (ms *MultiSig) Address() string {
+	var e encoder
+	e.buf.WriteString("multisig")
+	e.u32(ms.Threshold)
+	e.u32(uint32(len(ms.PubKeys)))
+	for _, pk := range ms.PubKeys {
+		e.bytes(pk)
+	}
+	return utils.ToHex(utils.Sha256(e.buf.Bytes()))
+}
+
+// SignCount 返回已经填上的签名个数（不校验签名本身）
+func (ms *MultiSig) SignCount() int {
+	count := 0
+	for _, sig := range ms.Sigs {
+		if len(sig) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// CoSign 用 priv 为多签交易签名，签名放在 priv 对应公钥的位置。
+// 多签交易的 Hash 与单签一样只取决于 txBody，各签名人签的是同一份内容。
+func (tx *Transaction) CoSign(priv *ecdsa.PrivateKey) error {
+	if tx.MultiSig == nil {
+		return fmt.Errorf("%w: transaction is not multisig", ErrBadMultisig)
+	}
+	pub, err := utils.ExportPubKey(&priv.PublicKey)
+	if err != nil {
+		return err
+	}
+	for i, pk := range tx.MultiSig.PubKeys {
+		if !bytes.Equal(pk, pub) {
+			continue
+		}
+		sig, err := utils.SignECDSA(priv, tx.payload())
+		if err != nil {
+			return err
+		}
+		tx.MultiSig.Sigs[i] = sig
+		tx.CalculateHash()
+		return nil
+	}
+	return ErrNotMultisigOwner
+}
+
+// verifyMultisig 校验多签交易：From 必须是这组公钥的多签地址，单签字段必须为空，
+// 且至少 Threshold 个公钥的签名正确
+func verifyMultisig(tx *Transaction) error {
+	ms := tx.MultiSig
+	if len(tx.PubKey) != 0 || len(tx.Sig) != 0 {
+		return fmt.Errorf("%w: single-key pubkey/sig must be empty", ErrBadMultisig)
+	}
+	if err := ms.check(); err != nil {
+		return err
+	}
+	if addr := ms.Address(); tx.From != addr {
+		return fmt.Errorf("%w: declared %s, derived %s", ErrMultisigAddress, tx.From, addr)
+	}
+
+	data := tx.payload()
+	valid := 0
+	for i, sig := range ms.Sigs {
+		if len(sig) == 0 {
+			continue
+		}
+		if !utils.VerifyECDSA(ms.PubKeys[i], data, sig) {
+			return fmt.Errorf("%w: signature %d", ErrBadSignature, i)
+		}
+		valid++
+	}
+	if valid < int(ms.Threshold) {
+		return fmt.Errorf("%w: %d of %d", ErrNotEnoughSigs, valid, ms.Threshold)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+// newSigners 生成 n 个密钥及其公钥
+func newSigners(t *testing.T, n int) ([]*ecdsa.PrivateKey, [][]byte) {
+	t.Helper()
+	keys := make([]*ecdsa.PrivateKey, n)
+	pubs := make([][]byte, n)
+	for i := range keys {
+		priv, pub, err := utils.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i], pubs[i] = priv, pub
+	}
+	return keys, pubs
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	keys, pubs := newSigners(t, 3)
+	ms, err := NewMultiSig(2, pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 公钥顺序不影响地址
+	reversed, err := NewMultiSig(2, [][]byte{pubs[2], pubs[1], pubs[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reversed.Address() != ms.Address() {
+		t.Fatal("multisig address depends on key order")
+	}
+	if other, _ := NewMultiSig(3, pubs); other.Address() == ms.Address() {
+		t.Fatal("2-of-3 and 3-of-3 share an address")
+	}
+
+	newTx := func() Transaction {
+		m := *ms
+		m.PubKeys = append([][]byte(nil), ms.PubKeys...)
+		m.Sigs = make([][]byte, len(ms.PubKeys))
+		return Transaction{From: ms.Address(), To: "bob", Value: 1, Timestamp: time.Unix(1700000000, 0), MultiSig: &m}
+	}
+	signed := func(signers ...int) Transaction {
+		tx := newTx()
+		for _, i := range signers {
+			if err := tx.CoSign(keys[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tx
+	}
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		want error
+	}{
+		{"no signatures", signed(), ErrNotEnoughSigs},
+		{"one of two", signed(0), ErrNotEnoughSigs},
+		{"two of two", signed(0, 2), nil},
+		{"all three", signed(0, 1, 2), nil},
+		{"same signer twice", signed(1, 1), ErrNotEnoughSigs},
+		{"signature copied to another key", func() Transaction {
+			tx := signed(0)
+			var sig []byte
+			for _, s := range tx.MultiSig.Sigs {
+				if len(s) > 0 {
+					sig = s
+				}
+			}
+			for i := range tx.MultiSig.Sigs {
+				tx.MultiSig.Sigs[i] = sig
+			}
+			return tx
+		}(), ErrBadSignature},
+		{"wrong from", func() Transaction { tx := signed(0, 1); tx.From = "mallory"; return tx }(), ErrMultisigAddress},
+		{"tampered value", func() Transaction { tx := signed(0, 1); tx.Value = 2; return tx }(), ErrBadSignature},
+		{"single-key signature too", func() Transaction { tx := signed(0, 1); tx.PubKey = pubs[0]; return tx }(), ErrBadMultisig},
+		{"duplicate key", func() Transaction {
+			tx := signed(0, 1)
+			tx.MultiSig.PubKeys[1] = tx.MultiSig.PubKeys[0]
+			return tx
+		}(), ErrBadMultisig},
+	}
+	for _, tt := range tests {
+		if err := VerifyTxSignature(&tt.tx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 不在多签中的密钥不能签名
+	stranger, _, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := newTx()
+	if err := tx.CoSign(stranger); !errors.Is(err, ErrNotMultisigOwner) {
+		t.Fatalf("CoSign by a stranger: err = %v, want ErrNotMultisigOwner", err)
+	}
+}
+
+func TestNewMultiSigRejectsBadShapes(t *testing.T) {
+	_, pubs := newSigners(t, 3)
+	tests := []struct {
+		name      string
+		threshold uint32
+		keys      [][]byte
+	}{
+		{"no keys", 1, nil},
+		{"zero threshold", 0, pubs},
+		{"threshold above n", 4, pubs},
+		{"duplicate keys", 2, [][]byte{pubs[0], pubs[1], pubs[0]}},
+		{"empty key", 1, [][]byte{pubs[0], nil}},
+		{"too many keys", 1, make([][]byte, MaxMultisigKeys+1)},
+	}
+	for _, tt := range tests {
+		if _, err := NewMultiSig(tt.threshold, tt.keys); !errors.Is(err, ErrBadMultisig) {
+			t.Errorf("%s: err = %v, want ErrBadMultisig", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"mychain/utils"
+)
+
+// 交易可以附带一段不超过 MaxTxData 字节的数据（参与签名和交易哈希，不影响状态）。
+// 公证：数据为 NotaryTag + 文件的 SHA256，交易上链后，所在区块的时间戳就证明了该文件在那时已经存在。
+
+// MaxTxData 交易附带数据最多多少字节
+const MaxTxData = 256
+
+// NotaryTag 是公证数据的前缀
+const NotaryTag = "NOTARY"
+
+var (
+	ErrDataTooLarge  = errors.New("transaction data too large")
+	ErrNotNotarized  = errors.New("document is not notarized in main chain")
+	ErrNotNotaryTx   = errors.New("transaction is not a notarization")
+	ErrBadNotaryHash = errors.New("document hash must be 32 bytes")
+)
+
+// NotaryProof 证明某个文件哈希在主链上最早被公证的位置和时间
+type NotaryProof struct {
+	Document  string    `json:"document"`  // 文件的 SHA256（hex）
+	BlockHash string    `json:"blockHash"` // 所在区块哈希（hex）
+	Timestamp time.Time `json:"timestamp"` // 所在区块的时间戳
+	TxProof             // 公证交易及其 Merkle 证明
+}
+
+// NotaryData 返回公证文件哈希 docHash 时交易应附带的数据
+func NotaryData(docHash []byte) []byte {
+	return append([]byte(NotaryTag), docHash...)
+}
+
+// NotarizedHash 返回交易公证的文件哈希，不是公证交易时返回 nil
+func (tx *Transaction) NotarizedHash() []byte {
+	if len(tx.Data) != len(NotaryTag)+32 || !bytes.HasPrefix(tx.Data, []byte(NotaryTag)) {
+		return nil
+	}
+	return tx.Data[len(NotaryTag):]
+}
+
+// checkTxData 检查交易附带数据的长度
+func checkTxData(tx *Transaction) error {
+	if len(tx.Data) > MaxTxData {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrDataTooLarge, len(tx.Data), MaxTxData)
+	}
+	return nil
+}
+
+// NotaryProof 在主链上从创世块开始查找最早公证 docHash 的交易，并生成它的 Merkle 证明
+func (bc *Blockchain) NotaryProof(docHash []byte) (*NotaryProof, error) {
+	if len(docHash) != 32 {
+		return nil, ErrBadNotaryHash
+	}
+	for h := range bc.Blocks {
+		b := &bc.Blocks[h]
+		for i := range b.Txs {
+			if !bytes.Equal(b.Txs[i].NotarizedHash(), docHash) {
+				continue
+			}
+			path, err := BuildMerkleProof(b.Txs, i)
+			if err != nil {
+				return nil, err
+			}
+			return &NotaryProof{
+				Document:  utils.ToHex(docHash),
+				BlockHash: utils.ToHex(b.Header.Hash),
+				Timestamp: b.Header.Timestamp,
+				TxProof:   TxProof{Tx: b.Txs[i], Height: h, Index: i, Path: path, Header: b.Header},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotNotarized, utils.ToHex(docHash))
+}
+
+// VerifyNotaryProof 用已校验的区块头链验证公证证明：交易确实公证了 docHash，并且被打包在主链上。
+// 返回确认数，文件存在的时间以 headers 中对应区块头的时间戳为准
+func VerifyNotaryProof(p *NotaryProof, docHash []byte, headers []*BlockHeader) (int, error) {
+	if !bytes.Equal(p.Tx.NotarizedHash(), docHash) {
+		return 0, ErrNotNotaryTx
+	}
+	return VerifyTxProof(&p.TxProof, headers)
+}
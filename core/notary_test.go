@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+func TestNotaryProof(t *testing.T) {
+	p := loadRegtest(t)
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := utils.PubKeyToAddress(pub)
+	bc := NewBlockchain(p)
+	mineBlocks(t, bc, alice, 1)
+
+	doc := utils.Sha256([]byte("contract.pdf"))
+	other := utils.Sha256([]byte("other.pdf"))
+
+	// 高度 2、3 都公证了同一份文件，证明应指向最早的高度 2
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := Transaction{From: alice, To: alice, Value: 1, Fee: 1, Nonce: nonce,
+			Data: NotaryData(doc), Timestamp: time.Unix(1700000000, 0)}
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		height := bc.NextHeight()
+		coinbase := p.NewCoinbase(height, alice, uint32(p.Monetary.Subsidy(height))+tx.Fee)
+		if _, err := bc.AddBlock([]Transaction{coinbase, tx}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	proof, err := bc.NotaryProof(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Height != 2 || proof.Index != 1 || !proof.Timestamp.Equal(bc.Blocks[2].Header.Timestamp) {
+		t.Fatalf("proof points at height %d index %d, want the earliest notarization at height 2", proof.Height, proof.Index)
+	}
+	confirmations, err := VerifyNotaryProof(proof, doc, bc.Headers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmations != 2 {
+		t.Fatalf("confirmations = %d, want 2", confirmations)
+	}
+
+	// 证明的交易公证的不是 other
+	if _, err := VerifyNotaryProof(proof, other, bc.Headers()); !errors.Is(err, ErrNotNotaryTx) {
+		t.Fatalf("proof for another document: err = %v, want ErrNotNotaryTx", err)
+	}
+	// 换掉交易数据冒充 other：交易哈希不再匹配
+	forged := *proof
+	forged.Tx.Data = NotaryData(other)
+	if _, err := VerifyNotaryProof(&forged, other, bc.Headers()); !errors.Is(err, ErrTxHashMismatch) {
+		t.Fatalf("forged proof: err = %v, want ErrTxHashMismatch", err)
+	}
+	// 对方的区块头链里没有证明所在的区块
+	if _, err := VerifyNotaryProof(proof, doc, bc.Headers()[:2]); !errors.Is(err, ErrHeaderNotFound) {
+		t.Fatalf("proof above the known headers: err = %v, want ErrHeaderNotFound", err)
+	}
+
+	if _, err := bc.NotaryProof(other); !errors.Is(err, ErrNotNotarized) {
+		t.Fatalf("unknown document: err = %v, want ErrNotNotarized", err)
+	}
+	if _, err := bc.NotaryProof(doc[:31]); !errors.Is(err, ErrBadNotaryHash) {
+		t.Fatalf("short hash: err = %v, want ErrBadNotaryHash", err)
+	}
+}
+
+func TestNotarizedHash(t *testing.T) {
+	doc := utils.Sha256([]byte("doc"))
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"notary data", NotaryData(doc), doc},
+		{"no data", nil, nil},
+		{"short hash", NotaryData(doc[:31]), nil},
+		{"long hash", NotaryData(append(doc, 0)), nil},
+		{"wrong tag", append([]byte("NOTARZ"), doc...), nil},
+	}
+	for _, tt := range tests {
+		tx := Transaction{Data: tt.data}
+		if got := tx.NotarizedHash(); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: NotarizedHash = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	p := loadRegtest(t)
+	tx := Transaction{From: "alice", To: "bob", Value: 1, Data: make([]byte, MaxTxData)}
+	if err := CheckTxFormat(p, &tx); err != nil {
+		t.Fatalf("data at the limit: %v", err)
+	}
+	tx.Data = append(tx.Data, 0)
+	if err := CheckTxFormat(p, &tx); !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("data above the limit: err = %v, want ErrDataTooLarge", err)
+	}
+}
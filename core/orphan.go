@@ -0,0 +1,131 @@
+package core
+
+import (
+	"time"
+
+	"mychain/utils"
+)
+
+// 孤块池参数
+const (
+	// MaxOrphanBlocks 孤块池最多保存多少个区块
+	MaxOrphanBlocks = 100
+
+	// OrphanExpiry 孤块在池中最多停留多久，超时仍未等到父区块就丢弃
+	OrphanExpiry = 10 * time.Minute
+)
+
+// orphanBlock 记录一个父区块未知的区块，以及它是从哪个节点收到的
+type orphanBlock struct {
+	block    Block
+	peer     string
+	received time.Time
+}
+
+// OrphanPool 暂存父区块还未收到的区块，等父区块到达后再一起接入区块树
+type OrphanPool struct {
+	orphans  map[string]*orphanBlock // 区块哈希 → 孤块
+	byParent map[string][]string     // 父区块哈希 → 等待它的孤块哈希
+	maxSize  int
+	maxAge   time.Duration
+}
+
+// NewOrphanPool 创建一个孤块池
+func NewOrphanPool(maxSize int, maxAge time.Duration) *OrphanPool {
+	return &OrphanPool{
+		orphans:  make(map[string]*orphanBlock),
+		byParent: make(map[string][]string),
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+	}
+}
+
+// Len 返回当前孤块数量
+func (p *OrphanPool) Len() int {
+	return len(p.orphans)
+}
+
+// Has 判断某个区块是否已在孤块池中
+func (p *OrphanPool) Has(hash []byte) bool {
+	_, ok := p.orphans[utils.ToHex(hash)]
+	return ok
+}
+
+// Add 把一个孤块放入池中：先清理过期孤块，池满时淘汰最早收到的那个
+func (p *OrphanPool) Add(b Block, peer string) {
+	key := utils.ToHex(b.Header.Hash)
+	if _, ok := p.orphans[key]; ok {
+		return
+	}
+
+	now := time.Now()
+	for k, o := range p.orphans {
+		if now.Sub(o.received) > p.maxAge {
+			p.remove(k)
+		}
+	}
+	if len(p.orphans) >= p.maxSize {
+		var oldest string
+		for k, o := range p.orphans {
+			if oldest == "" || o.received.Before(p.orphans[oldest].received) {
+				oldest = k
+			}
+		}
+		p.remove(oldest)
+	}
+
+	p.orphans[key] = &orphanBlock{block: b, peer: peer, received: now}
+	parentKey := utils.ToHex(b.Header.PreviousHash)
+	p.byParent[parentKey] = append(p.byParent[parentKey], key)
+}
+
+// MissingAncestor 沿着孤块的 PreviousHash 一直往回找，
+// 返回这一串孤块最前面缺失的那个区块哈希（也就是需要向邻居请求的区块）
+func (p *OrphanPool) MissingAncestor(hash []byte) []byte {
+	missing := hash
+	for {
+		o, ok := p.orphans[utils.ToHex(missing)]
+		if !ok {
+			return missing
+		}
+		missing = o.block.Header.PreviousHash
+	}
+}
+
+// TakeChildren 取出（并移出池子）所有以 parentHash 为父区块的孤块
+func (p *OrphanPool) TakeChildren(parentHash []byte) []Block {
+	keys := p.byParent[utils.ToHex(parentHash)]
+	children := make([]Block, 0, len(keys))
+	for _, k := range keys {
+		if o, ok := p.orphans[k]; ok {
+			children = append(children, o.block)
+		}
+	}
+	for _, k := range keys {
+		p.remove(k)
+	}
+	return children
+}
+
+// remove 从池中删除一个孤块，同时维护 byParent 索引
+func (p *OrphanPool) remove(key string) {
+	o, ok := p.orphans[key]
+	if !ok {
+		return
+	}
+	delete(p.orphans, key)
+
+	parentKey := utils.ToHex(o.block.Header.PreviousHash)
+	siblings := p.byParent[parentKey]
+	for i, k := range siblings {
+		if k == key {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(p.byParent, parentKey)
+	} else {
+		p.byParent[parentKey] = siblings
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrphanPoolLinksChains(t *testing.T) {
+	p := loadRegtest(t)
+	blocks := mineBlocks(t, NewBlockchain(p), "alice", 4)
+
+	// 区块 2、3、4 倒序到达：父区块都未知，进入孤块池
+	bc := NewBlockchain(p)
+	pool := NewOrphanPool(MaxOrphanBlocks, OrphanExpiry)
+	for i := 3; i >= 1; i-- {
+		if _, err := bc.ProcessBlock(blocks[i]); !errors.Is(err, ErrUnknownParent) {
+			t.Fatalf("block %d: err = %v, want ErrUnknownParent", i+1, err)
+		}
+		pool.Add(blocks[i], "peer")
+	}
+	if pool.Len() != 3 || !pool.Has(blocks[2].Header.Hash) {
+		t.Fatalf("pool has %d orphans", pool.Len())
+	}
+
+	// 从任何一个孤块往回找，缺的都是区块 1
+	for i := 1; i <= 3; i++ {
+		if missing := pool.MissingAncestor(blocks[i].Header.Hash); !bytes.Equal(missing, blocks[0].Header.Hash) {
+			t.Fatalf("MissingAncestor(block %d) = %x, want block 1", i+1, missing)
+		}
+	}
+
+	// 区块 1 到达后，逐层取出等待它的孤块，整串接入主链
+	if _, err := bc.ProcessBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	queue := [][]byte{blocks[0].Header.Hash}
+	for len(queue) > 0 {
+		children := pool.TakeChildren(queue[0])
+		queue = queue[1:]
+		for _, child := range children {
+			if _, err := bc.ProcessBlock(child); err != nil {
+				t.Fatalf("connect orphan %x: %v", child.Header.Hash, err)
+			}
+			queue = append(queue, child.Header.Hash)
+		}
+	}
+	if pool.Len() != 0 {
+		t.Fatalf("%d orphans left in the pool", pool.Len())
+	}
+	if !bytes.Equal(bc.LatestBlock().Header.Hash, blocks[3].Header.Hash) {
+		t.Fatalf("main chain has %d blocks, want 5", len(bc.Blocks))
+	}
+}
+
+func TestOrphanPoolEvicts(t *testing.T) {
+	orphan := func(parent, hash byte) Block {
+		return Block{Header: &BlockHeader{PreviousHash: []byte{parent}, Hash: []byte{hash}}}
+	}
+
+	// 池满时淘汰最早收到的孤块，并从父区块索引中一并删除
+	pool := NewOrphanPool(2, time.Hour)
+	pool.Add(orphan(0, 1), "")
+	time.Sleep(time.Millisecond)
+	pool.Add(orphan(0, 2), "")
+	time.Sleep(time.Millisecond)
+	pool.Add(orphan(9, 3), "")
+	if pool.Has([]byte{1}) || !pool.Has([]byte{2}) || !pool.Has([]byte{3}) {
+		t.Fatal("the oldest orphan was not evicted")
+	}
+	if children := pool.TakeChildren([]byte{0}); len(children) != 1 || children[0].Header.Hash[0] != 2 {
+		t.Fatalf("TakeChildren returned %d blocks after eviction", len(children))
+	}
+
+	// 过期的孤块在下一次加入时被清理
+	pool = NewOrphanPool(10, time.Millisecond)
+	pool.Add(orphan(0, 1), "")
+	time.Sleep(5 * time.Millisecond)
+	pool.Add(orphan(0, 2), "")
+	if pool.Has([]byte{1}) || pool.Len() != 1 {
+		t.Fatal("expired orphan was not dropped")
+	}
+}
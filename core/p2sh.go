@@ -0,0 +1,65 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"mychain/core/script"
+	"mychain/utils"
+)
+
+var (
+	ErrScriptAddress = errors.New("from address does not match redeem script")
+	ErrScriptFailed  = errors.New("spending script failed")
+)
+
+// ScriptSpend 是花费 pay-to-script-hash 地址时附带的脚本（见 core/script）：
+// Redeem 为赎回脚本，决定花费条件；Unlock 为只含压栈指令的解锁脚本，提供签名、哈希原像等。
+// 脚本地址 = SHA256("script" | bytes Redeem)，只由赎回脚本决定，因此 From 必须等于它。
+type ScriptSpend struct {
+	Redeem []byte `json:"redeem"`
+	Unlock []byte `json:"unlock"`
+}
+
+// ScriptAddress 返回赎回脚本对应的地址，向它转账即把资金锁定在脚本描述的条件下
+func ScriptAddress(redeem []byte) string {
+	var e encoder
+	e.buf.WriteString("script")
+	e.bytes(redeem)
+	return utils.ToHex(utils.Sha256(e.buf.Bytes()))
+}
+
+// txChecker 为脚本提供与交易相关的判断：签名内容与单签、多签相同，都是 txBody
+type txChecker struct {
+	tx      *Transaction
+	payload []byte
+}
+
+func (c *txChecker) CheckSig(pubKey, sig []byte) bool {
+	return utils.VerifyECDSA(pubKey, c.payload, sig)
+}
+
+// CheckLockTime 与 OP_CHECKLOCKTIMEVERIFY 对应：交易自身的 LockTime 必须与 lockTime 同类
+// （都是高度或都是时间）且不小于它。交易的 LockTime 由区块校验保证已经到达，
+// 所以脚本只要检查 LockTime 就能确定花费发生在 lockTime 之后
+func (c *txChecker) CheckLockTime(lockTime int64) bool {
+	if (c.tx.LockTime < LockTimeThreshold) != (lockTime < LockTimeThreshold) {
+		return false
+	}
+	return c.tx.LockTime >= uint64(lockTime)
+}
+
+// verifyScript 校验脚本花费：From 必须是赎回脚本的地址，单签字段必须为空，脚本执行通过
+func verifyScript(tx *Transaction) error {
+	if len(tx.PubKey) != 0 || len(tx.Sig) != 0 || tx.MultiSig != nil {
+		return fmt.Errorf("%w: pubkey/sig/multisig must be empty", ErrScriptFailed)
+	}
+	if addr := ScriptAddress(tx.Script.Redeem); tx.From != addr {
+		return fmt.Errorf("%w: declared %s, derived %s", ErrScriptAddress, tx.From, addr)
+	}
+	checker := &txChecker{tx: tx, payload: tx.payload()}
+	if _, err := script.Verify(tx.Script.Unlock, tx.Script.Redeem, checker); err != nil {
+		return fmt.Errorf("%w: %v", ErrScriptFailed, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"mychain/core/bytecode"
+	"mychain/core/script"
+	"mychain/utils"
+)
+
+func TestScriptSpend(t *testing.T) {
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assemble := func(src string) []byte {
+		b, err := script.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// 高度锁 100 + 签名
+	redeem := assemble(fmt.Sprintf("100 OP_CHECKLOCKTIMEVERIFY 0x%s OP_CHECKSIG", utils.ToHex(pub)))
+
+	// spend 构造从 redeem 的脚本地址付款、锁定到 lockTime 并由 priv 签名解锁的交易
+	spend := func(redeem []byte, lockTime uint64) *Transaction {
+		tx := &Transaction{From: ScriptAddress(redeem), To: "bob", Value: 1, LockTime: lockTime,
+			Timestamp: time.Unix(1700000000, 0), Script: &ScriptSpend{Redeem: redeem}}
+		sig, err := utils.SignECDSA(priv, tx.SigningPayload())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.Script.Unlock = bytecode.PushData(sig)
+		return tx
+	}
+
+	if err := VerifyTxSignature(spend(redeem, 100)); err != nil {
+		t.Fatalf("valid script spend: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tx   func() *Transaction
+		want error
+	}{
+		{"height lock not reached", func() *Transaction { return spend(redeem, 99) }, ErrScriptFailed},
+		{"no locktime", func() *Transaction { return spend(redeem, 0) }, ErrScriptFailed},
+		{"time locktime against height lock", func() *Transaction { return spend(redeem, LockTimeThreshold+100) }, ErrScriptFailed},
+		{"redeem hash mismatch", func() *Transaction {
+			tx := spend(redeem, 100)
+			tx.From = ScriptAddress(assemble(fmt.Sprintf("0x%s OP_CHECKSIG", utils.ToHex(pub))))
+			return tx
+		}, ErrScriptAddress},
+		{"redeem swapped after funding", func() *Transaction {
+			tx := spend(redeem, 100)
+			tx.Script.Redeem = assemble(fmt.Sprintf("100 OP_CHECKLOCKTIMEVERIFY 0x%s OP_CHECKSIG", utils.ToHex(otherPub)))
+			return tx
+		}, ErrScriptAddress},
+		{"wrong signer", func() *Transaction {
+			other := assemble(fmt.Sprintf("0x%s OP_CHECKSIG", utils.ToHex(otherPub)))
+			return spend(other, 0)
+		}, ErrScriptFailed},
+		{"tampered value", func() *Transaction {
+			tx := spend(redeem, 100)
+			tx.Value++
+			return tx
+		}, ErrScriptFailed},
+		{"single-key signature fields set", func() *Transaction {
+			tx := spend(redeem, 100)
+			tx.PubKey = pub
+			return tx
+		}, ErrScriptFailed},
+		{"unlock not push only", func() *Transaction {
+			tx := spend(redeem, 100)
+			tx.Script.Unlock = append(tx.Script.Unlock, script.OP_DUP)
+			return tx
+		}, ErrScriptFailed},
+	}
+	for _, tt := range tests {
+		if err := VerifyTxSignature(tt.tx()); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestScriptLockTimeKinds(t *testing.T) {
+	mtp := int64(1700000000)
+	tests := []struct {
+		name        string
+		txLock      uint64
+		scriptLock  int64
+		wantAllowed bool
+	}{
+		{"height reached", 150, 100, true},
+		{"height equal", 100, 100, true},
+		{"height not reached", 99, 100, false},
+		{"time reached", uint64(mtp), mtp - 1, true},
+		{"time not reached", uint64(mtp) - 1, mtp, false},
+		{"height tx against time lock", 150, mtp, false},
+		{"time tx against height lock", uint64(mtp), 100, false},
+	}
+	for _, tt := range tests {
+		c := &txChecker{tx: &Transaction{LockTime: tt.txLock}}
+		if got := c.CheckLockTime(tt.scriptLock); got != tt.wantAllowed {
+			t.Errorf("%s: CheckLockTime = %v, want %v", tt.name, got, tt.wantAllowed)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// LedgerMode 表示链使用的记账模型
+type LedgerMode string
+
+const (
+	// LedgerAccount 账户模型：交易为 From/To/Value，状态为账户余额 + nonce
+	LedgerAccount LedgerMode = "account"
+
+	// LedgerUTXO UTXO 模型：交易引用之前的输出作为输入，可有多个输出（含找零）
+	LedgerUTXO LedgerMode = "utxo"
+)
+
+var ErrBadChainSpec = errors.New("invalid chain spec")
+
+// ChainParams 是同一个网络中所有节点必须一致的链参数，对应一个 JSON 链配置文件（chain spec），
+// 内置的 mainnet / testnet / regtest 见 core/chainspecs/
+type ChainParams struct {
+	Name      string     `json:"name"`      // 网络名称，同时决定数据目录 data/<name>/
+	NetworkID uint32     `json:"networkId"` // 网络编号，握手时不一致的节点不会互相采信
+	Ledger    LedgerMode `json:"ledger"`
+
+	Genesis GenesisSpec `json:"genesis"`
+
+	// InitialBits 是创世块以及最低难度对应的 compact 目标值
+	InitialBits uint32 `json:"initialBits"`
+	// RetargetInterval 每隔多少个区块重新计算一次难度，0 表示从不调整
+	RetargetInterval uint64 `json:"retargetInterval"`
+	// TargetBlockSeconds 期望的平均出块间隔（秒）
+	TargetBlockSeconds uint64 `json:"targetBlockSeconds"`
+	// MaxTxPerBlock 每个区块最多打包多少笔交易（不含 coinbase）
+	MaxTxPerBlock int `json:"maxTxPerBlock"`
+	// MaxBlockGas 每个区块中合约交易 gas 上限之和的最大值，0 表示不支持合约（UTXO 模式下也不支持，见 contract.go）
+	MaxBlockGas uint64 `json:"maxBlockGas"`
+	// GasPrice 合约交易每 GasPriceUnit 个 gas 至少支付的手续费，开启合约时必须为正（见 contract.go）
+	GasPrice uint64 `json:"gasPrice"`
+
+	Monetary MonetaryPolicy `json:"monetary"`
+
+	// Consensus 选择共识引擎，省略时为 POW（见 consensus.go）
+	Consensus ConsensusSpec `json:"consensus"`
+}
+
+// GenesisSpec 描述创世块：固定时间戳，以及创世时直接分配给各地址的余额
+type GenesisSpec struct {
+	Timestamp int64             `json:"timestamp"` // Unix 秒
+	Alloc     map[string]uint64 `json:"alloc"`     // 地址 → 初始余额
+}
+
+// DefaultParams 默认网络（mainnet）：账户模型、默认货币政策
+var DefaultParams = mustLoadNetwork("mainnet")
+
+// ParseLedgerMode 解析命令行传入的记账模型名称
+func ParseLedgerMode(s string) (LedgerMode, error) {
+	switch LedgerMode(s) {
+	case LedgerAccount, LedgerUTXO:
+		return LedgerMode(s), nil
+	}
+	return "", fmt.Errorf("unknown ledger mode %q (want %q or %q)", s, LedgerAccount, LedgerUTXO)
+}
+
+// Validate 检查链参数是否完整、合理
+func (p *ChainParams) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrBadChainSpec)
+	}
+	if _, err := ParseLedgerMode(string(p.Ledger)); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadChainSpec, err)
+	}
+	if t := CompactToBig(p.InitialBits); t.Sign() <= 0 || t.Cmp(powLimit) > 0 {
+		return fmt.Errorf("%w: initialBits %08x out of range", ErrBadChainSpec, p.InitialBits)
+	}
+	if p.TargetBlockSeconds == 0 {
+		return fmt.Errorf("%w: targetBlockSeconds must be positive", ErrBadChainSpec)
+	}
+	if p.MaxTxPerBlock <= 0 {
+		return fmt.Errorf("%w: maxTxPerBlock must be positive", ErrBadChainSpec)
+	}
+	if p.MaxBlockGas > 0 && p.GasPrice == 0 {
+		return fmt.Errorf("%w: gasPrice must be positive when contracts are enabled", ErrBadChainSpec)
+	}
+	if GasFee(p.GasPrice, p.MaxBlockGas) > math.MaxUint32 {
+		return fmt.Errorf("%w: fee for maxBlockGas at gasPrice %d does not fit in 32 bits", ErrBadChainSpec, p.GasPrice)
+	}
+	if m := p.Monetary; m.InitialReward > math.MaxUint32 || m.TailEmission > math.MaxUint32 {
+		return fmt.Errorf("%w: block reward does not fit in 32 bits", ErrBadChainSpec)
+	}
+	for addr, value := range p.Genesis.Alloc {
+		if addr == "" || value == 0 || value > math.MaxUint32 {
+			return fmt.Errorf("%w: bad genesis alloc %q: %d", ErrBadChainSpec, addr, value)
+		}
+	}
+	if err := p.Consensus.validate(); err != nil {
+		return err
+	}
+	if p.Consensus.Engine == EnginePoS && p.Ledger != LedgerAccount {
+		return fmt.Errorf("%w: %q needs the %q ledger", ErrBadChainSpec, EnginePoS, LedgerAccount)
+	}
+	return nil
+}
+
+// TargetBlockTime 期望的平均出块间隔
+func (p *ChainParams) TargetBlockTime() time.Duration {
+	return time.Duration(p.TargetBlockSeconds) * time.Second
+}
+
+// GenesisSupply 返回创世分配（含 PoS 的创世质押）的总额（不计入货币政策的发行上限）
+func (p *ChainParams) GenesisSupply() uint64 {
+	var sum uint64
+	for _, v := range p.Genesis.Alloc {
+		sum += v
+	}
+	for _, v := range p.Consensus.Stakes {
+		sum += v
+	}
+	return sum
+}
+
+// genesisTxs 按地址排序，为每个创世分配构造一笔 From 为空的交易，之后是创世质押：
+// 它们不消耗任何余额或输入，只出现在创世块中
+func (p *ChainParams) genesisTxs() []Transaction {
+	addrs := make([]string, 0, len(p.Genesis.Alloc))
+	for addr := range p.Genesis.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	txs := make([]Transaction, 0, len(addrs))
+	for _, addr := range addrs {
+		tx := Transaction{Timestamp: time.Unix(p.Genesis.Timestamp, 0)}
+		value := uint32(p.Genesis.Alloc[addr])
+		if p.Ledger == LedgerUTXO {
+			tx.Outputs = []TxOutput{{To: addr, Value: value}}
+		} else {
+			tx.To = addr
+			tx.Value = value
+		}
+		tx.CalculateHash()
+		txs = append(txs, tx)
+	}
+	return append(txs, p.genesisStakeTxs()...)
+}
+
+// genesisStakeTxs 按验证者地址排序，为 PoS 的每个创世质押构造一笔 From 为空的质押交易
+func (p *ChainParams) genesisStakeTxs() []Transaction {
+	addrs := make([]string, 0, len(p.Consensus.Stakes))
+	for addr := range p.Consensus.Stakes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	txs := make([]Transaction, 0, len(addrs))
+	for _, addr := range addrs {
+		tx := Transaction{
+			Kind:      TxStake,
+			To:        addr,
+			Value:     uint32(p.Consensus.Stakes[addr]),
+			Timestamp: time.Unix(p.Genesis.Timestamp, 0),
+		}
+		tx.CalculateHash()
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// NewCoinbase 按链的记账模型构造一笔在高度 height 给 to 支付 value 的 coinbase 交易。
+// coinbase 的 nonce 记录区块高度（类似 BIP34），使不同高度的 coinbase 哈希各不相同：
+// UTXO 模式下输出以交易哈希为索引，不能重复。时间戳固定为 0，coinbase 完全由参数决定。
+func (p *ChainParams) NewCoinbase(height uint64, to string, value uint32) Transaction {
+	tx := Transaction{
+		From:      CoinbaseFrom,
+		Nonce:     height,
+		Timestamp: time.Unix(0, 0),
+	}
+	if p.Ledger == LedgerUTXO {
+		tx.Outputs = []TxOutput{{To: to, Value: value}}
+	} else {
+		tx.To = to
+		tx.Value = value
+	}
+	tx.CalculateHash()
+	return tx
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"mychain/utils"
+)
+
+// 权威证明（PoA，参考以太坊 Clique）：
+//   - 链配置 consensus.signers 列出有权出块的地址，高度 h 轮到 signers[h % N]
+//   - 区块头带签名者公钥（Signer），签名者对区块哈希签名（Seal）；Signer 参与区块哈希，Seal 不参与
+//   - 轮到的签名者出块权重为 2，其他签名者也可以出块（例如轮到的节点离线），权重为 1；
+//     分叉时累计权重大的链为主链，因此按顺序出块的链总是胜出
+//   - 一个签名者在最近 N/2 个区块中签过名就不能再签，少数签名者无法独占出块
+//
+// PoA 区块头的 Bits 表示出块权重，不是难度目标；nonce 固定为 0。
+
+const (
+	poaInTurn    uint32 = 2 // 轮到的签名者出块的权重
+	poaOutOfTurn uint32 = 1 // 其他签名者出块的权重
+)
+
+var (
+	ErrUnauthorizedSigner = errors.New("block signer is not authorized")
+	ErrRecentlySigned     = errors.New("signer signed a recent block")
+)
+
+// ProofOfAuthority 是权威证明引擎。校验区块只需要链配置；出块的节点还要用 Authorize 设置签名者私钥
+type ProofOfAuthority struct {
+	Params  *ChainParams
+	signers map[string]int // 签名者地址 → 在轮换顺序中的位置
+
+	signer *blockSigner // 本节点的签名者私钥，未设置时只能校验不能出块
+}
+
+// NewProofOfAuthority 按链配置中的签名者列表创建 PoA 引擎
+func NewProofOfAuthority(p *ChainParams) *ProofOfAuthority {
+	a := &ProofOfAuthority{Params: p, signers: make(map[string]int)}
+	for i, addr := range p.Consensus.Signers {
+		a.signers[addr] = i
+	}
+	return a
+}
+
+func (a *ProofOfAuthority) Name() EngineName { return EnginePoA }
+
+// Authorize 设置本节点出块使用的私钥，其地址必须在签名者列表中
+func (a *ProofOfAuthority) Authorize(priv *ecdsa.PrivateKey) error {
+	s, err := newBlockSigner(priv, a.Params.NetworkID)
+	if err != nil {
+		return err
+	}
+	if _, ok := a.signers[s.addr]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotSigner, s.addr)
+	}
+	a.signer = s
+	return nil
+}
+
+// Signer 返回本节点的签名者地址，未授权时为空
+func (a *ProofOfAuthority) Signer() string {
+	if a.signer == nil {
+		return ""
+	}
+	return a.signer.addr
+}
+
+// InTurn 返回高度 height 轮到的签名者地址
+func (a *ProofOfAuthority) InTurn(height uint64) string {
+	signers := a.Params.Consensus.Signers
+	return signers[height%uint64(len(signers))]
+}
+
+// weight 返回 addr 在高度 height 出块时区块头应带的权重
+func (a *ProofOfAuthority) weight(height uint64, addr string) uint32 {
+	if a.InTurn(height) == addr {
+		return poaInTurn
+	}
+	return poaOutOfTurn
+}
+
+// checkRecent 检查 addr 在 height 之前的最近 N/2 个区块中没有签过名（创世块没有签名者）
+func (a *ProofOfAuthority) checkRecent(chain HeaderReader, height uint64, addr string) error {
+	limit := uint64(len(a.signers) / 2)
+	for h := height - 1; h >= 1 && h+limit >= height; h-- {
+		if prev := chain.HeaderAt(h); prev != nil && utils.PubKeyToAddress(prev.Signer) == addr {
+			return fmt.Errorf("%w: %s signed block %d", ErrRecentlySigned, addr, h)
+		}
+	}
+	return nil
+}
+
+// Prepare 填写本节点的签名者公钥与出块权重；本节点不是签名者或最近刚签过名时返回错误
+func (a *ProofOfAuthority) Prepare(chain HeaderReader, h *BlockHeader) error {
+	if a.signer == nil {
+		return ErrNotSigner
+	}
+	if err := a.checkRecent(chain, h.Height, a.signer.addr); err != nil {
+		return err
+	}
+	h.Signer = a.signer.pub
+	h.Bits = a.weight(h.Height, a.signer.addr)
+	h.Nonce = 0
+	return nil
+}
+
+// Seal 用本节点的签名者私钥对区块哈希签名
+func (a *ProofOfAuthority) Seal(ctx context.Context, b *Block) error {
+	return a.signer.seal(b)
+}
+
+// VerifySeal 检查区块哈希、签名者与签名；有父区块时再检查出块权重和最近签名限制
+func (a *ProofOfAuthority) VerifySeal(chain HeaderReader, h *BlockHeader) error {
+	addr, err := verifySignedHeader(a.Params.NetworkID, h)
+	if err != nil {
+		return err
+	}
+	if _, ok := a.signers[addr]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, addr)
+	}
+
+	if chain == nil {
+		return nil
+	}
+	if want := a.weight(h.Height, addr); h.Bits != want {
+		return fmt.Errorf("%w: got weight %d, want %d", ErrBadDifficulty, h.Bits, want)
+	}
+	return a.checkRecent(chain, h.Height, addr)
+}
+
+// Work 返回出块权重：轮到的签名者为 2，其他为 1
+func (a *ProofOfAuthority) Work(h *BlockHeader) *big.Int {
+	return big.NewInt(int64(h.Bits))
+}
+
+// Reward 按货币政策计算区块奖励，不想增发的许可链可以把 initialReward 设为 0，签名者只收手续费
+func (a *ProofOfAuthority) Reward(height uint64) uint64 {
+	return a.Params.Monetary.Subsidy(height)
+}
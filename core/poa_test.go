@@ -0,0 +1,203 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+// poaNetwork 返回一个有 n 个签名者的 PoA 网络（其余参数与回归测试网络相同），以及每个签名者授权好的区块链
+func poaNetwork(t *testing.T, n int) (*ChainParams, []*Blockchain) {
+	t.Helper()
+	p := loadRegtest(t)
+	p.Consensus = ConsensusSpec{Engine: EnginePoA}
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		priv, pub, err := utils.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = priv
+		p.Consensus.Signers = append(p.Consensus.Signers, utils.PubKeyToAddress(pub))
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make([]*Blockchain, n)
+	for i, key := range keys {
+		nodes[i] = NewBlockchain(p)
+		if err := nodes[i].Engine.(*ProofOfAuthority).Authorize(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p, nodes
+}
+
+// sealPoA 由 bc 的签名者在 bc 的主链末尾构造并签名一个只含 coinbase 的区块（不接入任何链）
+func sealPoA(bc *Blockchain) (Block, error) {
+	engine := bc.Engine.(*ProofOfAuthority)
+	height := bc.NextHeight()
+	coinbase := bc.Params.NewCoinbase(height, engine.Signer(), uint32(engine.Reward(height)))
+	b, err := bc.NewBlockTemplate([]Transaction{coinbase})
+	if err != nil {
+		return Block{}, err
+	}
+	return b, engine.Seal(context.Background(), &b)
+}
+
+func TestPoASignersTakeTurns(t *testing.T) {
+	_, nodes := poaNetwork(t, 3)
+	genesisWork := nodes[0].TotalWork().Int64()
+	signer := func(bc *Blockchain) string { return bc.Engine.(*ProofOfAuthority).Signer() }
+
+	// 高度 h 轮到 signers[h % 3]：每个高度由轮到的签名者出块，所有节点接入同一个区块
+	for h := uint64(1); h <= 6; h++ {
+		bc := nodes[h%3]
+		if got := bc.Engine.(*ProofOfAuthority).InTurn(h); got != signer(bc) {
+			t.Fatalf("height %d: in turn %s, want %s", h, got, signer(bc))
+		}
+		b, err := sealPoA(bc)
+		if err != nil {
+			t.Fatalf("height %d: %v", h, err)
+		}
+		if b.Header.Bits != poaInTurn || b.Header.Nonce != 0 {
+			t.Fatalf("height %d: bits %d nonce %d", h, b.Header.Bits, b.Header.Nonce)
+		}
+		for i, n := range nodes {
+			if _, err := n.ProcessBlock(b); err != nil {
+				t.Fatalf("height %d: node %d: %v", h, i, err)
+			}
+		}
+	}
+	if got := nodes[0].TotalWork().Int64() - genesisWork; got != 6*int64(poaInTurn) {
+		t.Fatalf("work above genesis %d, want %d", got, 6*poaInTurn)
+	}
+}
+
+func TestPoARecentSignerAndForkChoice(t *testing.T) {
+	_, nodes := poaNetwork(t, 3)
+
+	// 高度 1 轮到 nodes[1]；nodes[0] 不按顺序出块，权重只有 1
+	out, err := sealPoA(nodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Header.Bits != poaOutOfTurn {
+		t.Fatalf("out-of-turn weight %d, want %d", out.Header.Bits, poaOutOfTurn)
+	}
+	if _, err := nodes[0].ProcessBlock(out); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 个签名者时最近 1 个区块的签名者不能连续出块
+	if _, err := sealPoA(nodes[0]); !errors.Is(err, ErrRecentlySigned) {
+		t.Fatalf("signing twice in a row: err = %v, want ErrRecentlySigned", err)
+	}
+
+	// 轮到的签名者在同一高度出块：累计权重更大，所有节点都切换到它
+	in, err := sealPoA(nodes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		if _, err := n.ProcessBlock(in); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		if !bytes.Equal(n.LatestBlock().Header.Hash, in.Header.Hash) {
+			t.Fatalf("node %d did not prefer the in-turn block", i)
+		}
+	}
+	// 不按顺序的区块晚到也不会取代主链
+	if _, err := nodes[2].ProcessBlock(out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(nodes[2].LatestBlock().Header.Hash, in.Header.Hash) {
+		t.Fatal("out-of-turn block replaced the in-turn block")
+	}
+}
+
+func TestPoAVerifySeal(t *testing.T) {
+	p, nodes := poaNetwork(t, 3)
+	bc := nodes[0]
+	valid, err := sealPoA(nodes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个网络的签名者：签名正确，但不在本链的签名者列表中
+	_, others := poaNetwork(t, 2)
+	outsider := others[1].Engine.(*ProofOfAuthority)
+	if err := bc.Engine.(*ProofOfAuthority).Authorize(outsider.signer.key); !errors.Is(err, ErrNotSigner) {
+		t.Fatalf("authorize a non-signer: err = %v, want ErrNotSigner", err)
+	}
+
+	// resign 修改区块头之后由 engine 重新签名
+	resign := func(engine *ProofOfAuthority, change func(h *BlockHeader)) Block {
+		b := valid
+		h := *valid.Header
+		b.Header = &h
+		change(b.Header)
+		if err := engine.Seal(context.Background(), &b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	signer1 := nodes[1].Engine.(*ProofOfAuthority)
+
+	tests := []struct {
+		name  string
+		block Block
+		want  error
+	}{
+		{"unauthorized signer", resign(outsider, func(h *BlockHeader) { h.Signer = outsider.signer.pub }), ErrUnauthorizedSigner},
+		{"wrong weight", resign(signer1, func(h *BlockHeader) { h.Bits = poaOutOfTurn }), ErrBadDifficulty},
+		{"nonce set", resign(signer1, func(h *BlockHeader) { h.Nonce = 1 }), ErrBadSeal},
+		{"forged seal", func() Block {
+			b := resign(signer1, func(*BlockHeader) {})
+			b.Header.Seal = resign(signer1, func(h *BlockHeader) { h.Bits = 1 }).Header.Seal
+			return b
+		}(), ErrBadSeal},
+		{"hash mismatch", func() Block {
+			b := resign(signer1, func(*BlockHeader) {})
+			b.Header.Timestamp = b.Header.Timestamp.Add(time.Second)
+			return b
+		}(), ErrBadSeal},
+		{"no signer", func() Block {
+			b := resign(signer1, func(*BlockHeader) {})
+			b.Header.Signer = nil
+			b.Header.Hash = headerHash(b.Header)
+			return b
+		}(), ErrBadSeal},
+	}
+	for _, tt := range tests {
+		if _, err := bc.ProcessBlock(tt.block); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// POW 区块（即使 nonce 恰好为 0）不能出现在 PoA 链上，PoA 区块也不能出现在 POW 链上
+	pow := loadRegtest(t)
+	powHeader := *mineBlocks(t, NewBlockchain(pow), "miner", 1)[0].Header
+	powHeader.Nonce = 0
+	powHeader.Hash = headerHash(&powHeader)
+	if err := bc.Engine.VerifySeal(nil, &powHeader); !errors.Is(err, ErrBadSeal) {
+		t.Fatalf("pow block on a poa chain: err = %v, want ErrBadSeal", err)
+	}
+	if err := NewEngine(pow).VerifySeal(nil, valid.Header); !errors.Is(err, ErrBadSeal) {
+		t.Fatalf("poa block on a pow chain: err = %v, want ErrBadSeal", err)
+	}
+
+	// 合法区块照常接入
+	if _, err := bc.ProcessBlock(valid); err != nil {
+		t.Fatalf("valid block: %v", err)
+	}
+	if p.Consensus.Signers[1] != utils.PubKeyToAddress(valid.Header.Signer) {
+		t.Fatal("block signed by the wrong signer")
+	}
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"mychain/utils"
+)
+
+// 权益证明（PoS）：
+//   - 质押不少于 consensus.minStake 的地址是验证者，创世质押在 consensus.stakes 中给出，之后用质押交易增减（见 stake.go）
+//   - 高度 h 的出块者由父区块执行完之后的质押决定：以 SHA256("proposer" | bytes 父区块哈希 | u64 h) 为随机数，
+//     在按地址排序的验证者中按质押加权选出一个。所有节点得到同样的结果，质押越多被选中的概率越大，
+//     而父区块哈希事先无法预知，验证者不能提前很多块安排自己出块
+//   - 出块者与 PoA 一样把公钥写入区块头（Signer），并对区块哈希签名（Seal）；nonce 固定为 0
+//   - 每个区块的权重（Bits）都是 1，分叉时最长的链为主链
+//   - 同一验证者在同一高度签了两个不同的区块，任何人都可以提交罚没交易（见 stake.go）
+//
+// 出块者要读取父区块的状态才能确定，因此 VerifySeal 只检查签名；区块进入区块树之前由 ProcessBlock
+// 按父区块执行完之后的质押检查出块者，孤块进入孤块池之前要求签名者是当前主链上的验证者（见 CheckOrphan）。
+// 轮到的验证者离线时链会停在这个高度，直到它重新上线。
+
+const posWeight uint32 = 1 // PoS 区块的权重
+
+var (
+	ErrWrongProposer = errors.New("block signer is not the selected proposer")
+	ErrNoValidators  = errors.New("no validator has the minimum stake")
+)
+
+// Validator 是验证者及其质押
+type Validator struct {
+	Address string `json:"address"`
+	Stake   uint64 `json:"stake"`
+}
+
+// ProofOfStake 是权益证明引擎。校验区块只需要链配置；出块的节点还要用 Authorize 设置验证者私钥
+type ProofOfStake struct {
+	Params *ChainParams
+	signer *blockSigner // 本节点的验证者私钥，未设置时只能校验不能出块
+}
+
+// NewProofOfStake 创建 PoS 引擎
+func NewProofOfStake(p *ChainParams) *ProofOfStake {
+	return &ProofOfStake{Params: p}
+}
+
+func (s *ProofOfStake) Name() EngineName { return EnginePoS }
+
+// Authorize 设置本节点出块使用的私钥。验证者集合随质押变化，这里不检查地址，轮不到它时 Prepare 之后的检查会失败
+func (s *ProofOfStake) Authorize(priv *ecdsa.PrivateKey) error {
+	signer, err := newBlockSigner(priv, s.Params.NetworkID)
+	if err != nil {
+		return err
+	}
+	s.signer = signer
+	return nil
+}
+
+// Signer 返回本节点的验证者地址，未授权时为空
+func (s *ProofOfStake) Signer() string {
+	if s.signer == nil {
+		return ""
+	}
+	return s.signer.addr
+}
+
+// Prepare 填写本节点的公钥与出块权重；是否轮到本节点出块由 Blockchain.NewBlockTemplate 按状态检查
+func (s *ProofOfStake) Prepare(chain HeaderReader, h *BlockHeader) error {
+	if s.signer == nil {
+		return ErrNotSigner
+	}
+	h.Signer = s.signer.pub
+	h.Bits = posWeight
+	h.Nonce = 0
+	return nil
+}
+
+// Seal 用本节点的验证者私钥对区块哈希签名
+func (s *ProofOfStake) Seal(ctx context.Context, b *Block) error {
+	return s.signer.seal(b)
+}
+
+// VerifySeal 检查区块哈希、签名与出块权重，出块者是否被选中由 ProcessBlock 按父区块的状态检查
+func (s *ProofOfStake) VerifySeal(chain HeaderReader, h *BlockHeader) error {
+	if _, err := verifySignedHeader(s.Params.NetworkID, h); err != nil {
+		return err
+	}
+	if h.Bits != posWeight {
+		return fmt.Errorf("%w: got weight %d, want %d", ErrBadDifficulty, h.Bits, posWeight)
+	}
+	return nil
+}
+
+// Work 返回出块权重：每个区块都是 1
+func (s *ProofOfStake) Work(h *BlockHeader) *big.Int {
+	return big.NewInt(int64(h.Bits))
+}
+
+// Reward 按货币政策计算区块奖励
+func (s *ProofOfStake) Reward(height uint64) uint64 {
+	return s.Params.Monetary.Subsidy(height)
+}
+
+// validateStaking 检查 PoS 参数：至少一个创世质押达到 minStake，罚没比例不超过 100%
+func (c *ConsensusSpec) validateStaking() error {
+	if c.MinStake == 0 {
+		return fmt.Errorf("%w: %q needs a positive minStake", ErrBadChainSpec, EnginePoS)
+	}
+	if c.SlashPercent > 100 {
+		return fmt.Errorf("%w: slashPercent %d exceeds 100", ErrBadChainSpec, c.SlashPercent)
+	}
+	validators := 0
+	for addr, stake := range c.Stakes {
+		if addr == "" || stake == 0 || stake > math.MaxUint32 {
+			return fmt.Errorf("%w: bad genesis stake %q: %d", ErrBadChainSpec, addr, stake)
+		}
+		if stake >= c.MinStake {
+			validators++
+		}
+	}
+	if validators == 0 {
+		return fmt.Errorf("%w: no genesis stake reaches minStake %d", ErrBadChainSpec, c.MinStake)
+	}
+	return nil
+}
+
+// Validators 返回质押不少于 minStake 的验证者（按地址排序）
+func (st *State) Validators(p *ChainParams) []Validator {
+	validators := []Validator{}
+	for addr, stake := range st.Stakes {
+		if stake >= p.Consensus.MinStake {
+			validators = append(validators, Validator{Address: addr, Stake: stake})
+		}
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Address < validators[j].Address })
+	return validators
+}
+
+// SelectProposer 返回在状态 st（父区块执行完之后）上、父区块为 prevHash 时高度 height 的出块者
+func SelectProposer(p *ChainParams, st *State, prevHash []byte, height uint64) (string, error) {
+	return selectProposer(st.Validators(p), prevHash, height)
+}
+
+// selectProposer 按质押加权从 validators（按地址排序）中选出父区块为 prevHash 时高度 height 的出块者
+func selectProposer(validators []Validator, prevHash []byte, height uint64) (string, error) {
+	var total uint64
+	for _, v := range validators {
+		total += v.Stake
+	}
+	if total == 0 {
+		return "", ErrNoValidators
+	}
+
+	var e encoder
+	e.buf.WriteString("proposer")
+	e.bytes(prevHash)
+	e.u64(height)
+	seed := new(big.Int).SetBytes(utils.Sha256(e.buf.Bytes()))
+	pick := seed.Mod(seed, new(big.Int).SetUint64(total)).Uint64()
+
+	for _, v := range validators[:len(validators)-1] {
+		if pick < v.Stake {
+			return v.Address, nil
+		}
+		pick -= v.Stake
+	}
+	return validators[len(validators)-1].Address, nil
+}
+
+// checkProposer 在 PoS 链上检查区块的签名者是被选中的出块者，validators 为父区块执行完之后的验证者集合；
+// 其他共识引擎不检查
+func checkProposer(p *ChainParams, validators []Validator, h *BlockHeader) error {
+	if p.Consensus.Engine != EnginePoS || h.Height == 0 {
+		return nil
+	}
+	want, err := selectProposer(validators, h.PreviousHash, h.Height)
+	if err != nil {
+		return err
+	}
+	if got := utils.PubKeyToAddress(h.Signer); got != want {
+		return fmt.Errorf("%w: got %s, want %s", ErrWrongProposer, got, want)
+	}
+	return nil
+}
+
+// recordValidators 在 PoS 链上记下区块 n 执行完之后的验证者集合（状态此时正是 n 执行完之后的状态），
+// 之后在 n 上出块的区块进入区块树之前按它检查出块者
+func (bc *Blockchain) recordValidators(n *blockNode) {
+	if bc.Params.Consensus.Engine == EnginePoS {
+		n.validators = bc.State.Validators(bc.Params)
+	}
+}
+
+// validatorsAt 返回区块 n 执行完之后的验证者集合。n 执行过时直接使用记下的集合；
+// 否则（n 在从未成为主链的分叉上）临时把状态切换到 n：断开主链到分叉点，执行分叉到 n 的区块，
+// 记下途中每个区块的验证者集合后恢复原来的主链。途中有区块执行失败时把它及其后代标记为非法
+func (bc *Blockchain) validatorsAt(n *blockNode) ([]Validator, error) {
+	if n.validators != nil {
+		return n.validators, nil
+	}
+	fork := findFork(bc.tip, n)
+
+	var detached []*blockNode
+	for m := bc.tip; m != fork; m = m.parent {
+		m.undo.Revert()
+		m.undo = nil
+		detached = append(detached, m)
+	}
+	attach := make([]*blockNode, n.height-fork.height)
+	for m := n; m != fork; m = m.parent {
+		attach[m.height-fork.height-1] = m
+	}
+
+	var err error
+	applied := 0
+	for _, m := range attach {
+		undo, e := ApplyBlock(bc.Params, bc.State, m.block)
+		if e != nil {
+			bc.markInvalid(m)
+			err = fmt.Errorf("block %s: %w", utils.ToHex(m.block.Header.Hash), e)
+			break
+		}
+		m.undo = undo
+		bc.recordValidators(m)
+		applied++
+	}
+
+	for i := applied - 1; i >= 0; i-- {
+		attach[i].undo.Revert()
+		attach[i].undo = nil
+	}
+	for j := len(detached) - 1; j >= 0; j-- {
+		detached[j].undo, _ = ApplyBlock(bc.Params, bc.State, detached[j].block)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n.validators, nil
+}
+
+// checkStakedProposer 在 PoS 链上按父区块执行完之后的质押检查区块的出块者，在区块进入区块树之前调用，
+// 这样不是验证者（或没有轮到）的密钥签的区块不能占用区块树；其他共识引擎不检查
+func (bc *Blockchain) checkStakedProposer(b *Block, parent *blockNode) error {
+	if bc.Params.Consensus.Engine != EnginePoS {
+		return nil
+	}
+	validators, err := bc.validatorsAt(parent)
+	if err != nil {
+		return err
+	}
+	return checkProposer(bc.Params, validators, b.Header)
+}
+
+// CheckOrphan 在孤块进入孤块池之前做不依赖父区块的检查：共识封装，以及 PoS 链上签名者是验证者。
+// 父区块的状态未知，PoS 链按当前主链的质押检查，只要求签名者是验证者而不检查是否轮到它
+func (bc *Blockchain) CheckOrphan(b *Block) error {
+	if err := bc.Engine.VerifySeal(nil, b.Header); err != nil {
+		return err
+	}
+	if bc.Params.Consensus.Engine != EnginePoS {
+		return nil
+	}
+	signer := utils.PubKeyToAddress(b.Header.Signer)
+	for _, v := range bc.State.Validators(bc.Params) {
+		if v.Address == signer {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not a validator", ErrWrongProposer, signer)
+}
@@ -2,37 +2,32 @@ package core
 
 import (
 	"bytes"
-	"encoding/json"
+	"math/big"
 	"mychain/utils"
 )
 
 // 封装 POW 所需内容
 type Pow struct {
-	Block      *Block
-	Difficulty []byte // 例如 {0x00, 0x00}
+	Block  *Block
+	Target *big.Int // 由区块头中的 Bits 展开得到，哈希值（视为大整数）必须 <= Target
 }
 
 // 创建 POW 实例
 func NewPow(b *Block) *Pow {
 	return &Pow{
-		Block:      b,
-		Difficulty: []byte{0x00, 0x00}, // 难度可调
+		Block:  b,
+		Target: CompactToBig(b.Header.Bits), // 难度由区块头携带
 	}
 }
 
-// 准备计算哈希的数据（序列化 BlockHeader + Txs）
+// 准备计算哈希的数据：区块头的规范二进制编码（见 encoding.go），交易通过 Merkle 根参与
 func (pow *Pow) prepareData(nonce uint32) []byte {
-	header := pow.Block.Header
-
-	tmp := map[string]interface{}{
-		"prev":   header.PreviousHash,
-		"merkle": header.MerkleRoot,
-		"time":   header.Timestamp.Unix(),
-		"nonce":  nonce,
-	}
+	return encodeHeader(pow.Block.Header, nonce)
+}
 
-	data, _ := json.Marshal(tmp)
-	return data
+// meetsTarget 判断哈希值是否不大于目标值
+func (pow *Pow) meetsTarget(hash []byte) bool {
+	return new(big.Int).SetBytes(hash).Cmp(pow.Target) <= 0
 }
 
 // 核心：挖矿
@@ -44,7 +39,7 @@ func (pow *Pow) Run() ([]byte, uint32) {
 		data := pow.prepareData(nonce)
 		hash = utils.Sha256(data)
 
-		if bytes.HasPrefix(hash, pow.Difficulty) {
+		if pow.meetsTarget(hash) {
 			return hash, nonce
 		}
 		nonce++
@@ -55,12 +50,17 @@ func (pow *Pow) Run() ([]byte, uint32) {
 func (pow *Pow) Validate() bool {
 	header := pow.Block.Header
 
+	// 目标值必须合法，且不能比最低难度还低
+	if pow.Target.Sign() <= 0 || pow.Target.Cmp(powLimit) > 0 {
+		return false
+	}
+
 	// 用当前区块头里的 Nonce 重新算一遍 hash
 	data := pow.prepareData(header.Nonce)
 	hash := utils.Sha256(data)
 
-	// 必须既满足难度前缀，又和区块里存的 Hash 一致
-	if !bytes.HasPrefix(hash, pow.Difficulty) {
+	// 必须既满足难度目标，又和区块里存的 Hash 一致
+	if !pow.meetsTarget(hash) {
 		return false
 	}
 	if !bytes.Equal(hash, header.Hash) {
@@ -0,0 +1,28 @@
+package script
+
+import "fmt"
+
+// 脚本的文本形式（汇编）见 core/bytecode：OP_XXX 操作码、0x<hex> 数据、'text' 文本、十进制整数，# 之后为注释。
+// 脚本没有跳转指令，不支持标签。
+//
+// 例如哈希锁 + 签名：OP_SHA256 0x<哈希> OP_EQUALVERIFY 0x<公钥> OP_CHECKSIG
+
+// Assemble 把汇编文本编译成脚本字节
+func Assemble(src string) ([]byte, error) {
+	out, err := opcodes.Assemble(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxScriptSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrScriptTooLarge, len(out), MaxScriptSize)
+	}
+	return out, nil
+}
+
+// Disassemble 把脚本字节还原成汇编文本
+func Disassemble(script []byte) (string, error) {
+	if _, err := parse(script); err != nil {
+		return "", err
+	}
+	return opcodes.Disassemble(script)
+}
@@ -0,0 +1,81 @@
+package script
+
+import (
+	"fmt"
+
+	"mychain/core/bytecode"
+)
+
+// 操作码，取值与比特币脚本一致，便于对照；只实现了其中很小的一个子集
+const (
+	OP_0         = bytecode.OP_0 // 压栈操作码与合约虚拟机共用（见 core/bytecode）
+	OP_PUSHDATA1 = bytecode.OP_PUSHDATA1
+	OP_PUSHDATA2 = bytecode.OP_PUSHDATA2
+	OP_1NEGATE   = bytecode.OP_1NEGATE
+	OP_1         = bytecode.OP_1
+	OP_16        = bytecode.OP_16
+
+	OP_NOP    byte = 0x61
+	OP_IF     byte = 0x63
+	OP_NOTIF  byte = 0x64
+	OP_ELSE   byte = 0x67
+	OP_ENDIF  byte = 0x68
+	OP_VERIFY byte = 0x69
+	OP_RETURN byte = 0x6a
+
+	OP_DROP byte = 0x75
+	OP_DUP  byte = 0x76
+	OP_OVER byte = 0x78
+	OP_SWAP byte = 0x7c
+	OP_SIZE byte = 0x82
+
+	OP_EQUAL       byte = 0x87
+	OP_EQUALVERIFY byte = 0x88
+
+	OP_NOT                byte = 0x91
+	OP_ADD                byte = 0x93
+	OP_SUB                byte = 0x94
+	OP_BOOLAND            byte = 0x9a
+	OP_BOOLOR             byte = 0x9b
+	OP_NUMEQUAL           byte = 0x9c
+	OP_NUMEQUALVERIFY     byte = 0x9d
+	OP_LESSTHAN           byte = 0x9f
+	OP_GREATERTHAN        byte = 0xa0
+	OP_LESSTHANOREQUAL    byte = 0xa1
+	OP_GREATERTHANOREQUAL byte = 0xa2
+
+	OP_SHA256              byte = 0xa8
+	OP_CHECKSIG            byte = 0xac
+	OP_CHECKSIGVERIFY      byte = 0xad
+	OP_CHECKMULTISIG       byte = 0xae
+	OP_CHECKMULTISIGVERIFY byte = 0xaf
+	OP_CHECKLOCKTIMEVERIFY byte = 0xb1
+)
+
+// opcodes 是脚本的指令集：压栈之外的操作码 → 名称，不在表中的操作码都是非法的
+var opcodes = bytecode.NewInstructionSet(map[byte]string{
+	OP_NOP: "OP_NOP", OP_IF: "OP_IF", OP_NOTIF: "OP_NOTIF", OP_ELSE: "OP_ELSE", OP_ENDIF: "OP_ENDIF",
+	OP_VERIFY: "OP_VERIFY", OP_RETURN: "OP_RETURN",
+	OP_DROP: "OP_DROP", OP_DUP: "OP_DUP", OP_OVER: "OP_OVER", OP_SWAP: "OP_SWAP", OP_SIZE: "OP_SIZE",
+	OP_EQUAL: "OP_EQUAL", OP_EQUALVERIFY: "OP_EQUALVERIFY",
+	OP_NOT: "OP_NOT", OP_ADD: "OP_ADD", OP_SUB: "OP_SUB", OP_BOOLAND: "OP_BOOLAND", OP_BOOLOR: "OP_BOOLOR",
+	OP_NUMEQUAL: "OP_NUMEQUAL", OP_NUMEQUALVERIFY: "OP_NUMEQUALVERIFY",
+	OP_LESSTHAN: "OP_LESSTHAN", OP_GREATERTHAN: "OP_GREATERTHAN",
+	OP_LESSTHANOREQUAL: "OP_LESSTHANOREQUAL", OP_GREATERTHANOREQUAL: "OP_GREATERTHANOREQUAL",
+	OP_SHA256: "OP_SHA256", OP_CHECKSIG: "OP_CHECKSIG", OP_CHECKSIGVERIFY: "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG: "OP_CHECKMULTISIG", OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+}, 0)
+
+// parse 检查脚本长度后把脚本字节解析成指令序列（见 bytecode.InstructionSet.Parse）
+func parse(script []byte) ([]bytecode.Instruction, error) {
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrScriptTooLarge, len(script), MaxScriptSize)
+	}
+	return opcodes.Parse(script)
+}
+
+// IsPushOnly 判断脚本是否只包含压栈指令（解锁脚本必须如此）
+func IsPushOnly(script []byte) bool {
+	return len(script) <= MaxScriptSize && opcodes.IsPushOnly(script)
+}
@@ -0,0 +1,448 @@
+// Package script 实现一个很小的栈式脚本语言，用于描述“怎样才能花这笔钱”：
+// 签名检查、哈希锁、时间锁以及门限组合。
+//
+// 花费 pay-to-script-hash 地址的资金时，交易附带赎回脚本（redeem）和解锁脚本（unlock）：
+// 先执行只含压栈指令的解锁脚本，再在同一个栈上执行赎回脚本，结束时栈顶为 true 才算通过。
+// 执行完全确定（不读取外部状态，签名和时间的判断由调用方通过 Checker 提供），
+// 每条指令消耗 gas，超出 MaxGas 立即失败，因此任何脚本的执行代价都有上限。
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"mychain/core/bytecode"
+)
+
+// 脚本限制
+const (
+	MaxScriptSize  = 1024                    // 单个脚本最多多少字节
+	MaxElementSize = bytecode.MaxElementSize // 单个栈元素最多多少字节
+	MaxStackSize   = 256                     // 栈上最多多少个元素
+	MaxNumSize     = bytecode.MaxNumSize     // 作为数字使用的栈元素最多多少字节
+	MaxMultisigKey = 15                      // OP_CHECKMULTISIG 最多多少个公钥
+	MaxGas         = 2000                    // 一次验证（解锁脚本 + 赎回脚本）最多消耗的 gas
+)
+
+// 各类指令的 gas 消耗，签名验证远比其他指令昂贵
+const (
+	gasBase     = 1
+	gasHash     = 10
+	gasCheckSig = 100
+)
+
+var (
+	ErrBadScript       = errors.New("malformed script")
+	ErrUnknownOpcode   = bytecode.ErrUnknownOpcode
+	ErrScriptTooLarge  = errors.New("script too large")
+	ErrElementTooLarge = bytecode.ErrElementTooLarge
+	ErrStackOverflow   = errors.New("stack overflow")
+	ErrStackUnderflow  = errors.New("stack underflow")
+	ErrNumOverflow     = bytecode.ErrNumOverflow
+	ErrOutOfGas        = errors.New("script ran out of gas")
+	ErrUnbalancedIf    = errors.New("unbalanced conditional")
+	ErrVerifyFailed    = errors.New("script verify failed")
+	ErrEarlyReturn     = errors.New("script executed OP_RETURN")
+	ErrNullFail        = errors.New("non-empty signature failed verification")
+	ErrLockTime        = errors.New("locktime requirement not satisfied")
+	ErrNotPushOnly     = errors.New("unlock script must only push data")
+	ErrScriptFalse     = errors.New("script evaluated to false")
+)
+
+// Checker 提供脚本需要的交易相关判断，由 core 按具体交易实现
+type Checker interface {
+	// CheckSig 判断 sig 是否为 pubKey 对当前交易的有效签名
+	CheckSig(pubKey, sig []byte) bool
+	// CheckLockTime 判断当前交易的锁定时间是否已经达到 lockTime（区块高度或 Unix 秒）
+	CheckLockTime(lockTime int64) bool
+}
+
+// Verify 执行解锁脚本与赎回脚本，返回消耗的 gas；脚本不通过时返回具体原因
+func Verify(unlock, redeem []byte, c Checker) (int, error) {
+	if !IsPushOnly(unlock) {
+		if _, err := parse(unlock); err != nil {
+			return 0, err
+		}
+		return 0, ErrNotPushOnly
+	}
+
+	vm := &engine{checker: c}
+	if err := vm.run(unlock); err != nil {
+		return vm.gas, err
+	}
+	if err := vm.run(redeem); err != nil {
+		return vm.gas, err
+	}
+	if len(vm.stack) == 0 || !bytecode.AsBool(vm.stack[len(vm.stack)-1]) {
+		return vm.gas, ErrScriptFalse
+	}
+	return vm.gas, nil
+}
+
+// engine 是脚本虚拟机：一个数据栈，加上记录 OP_IF 嵌套中各层是否执行的条件栈
+type engine struct {
+	checker Checker
+	stack   [][]byte
+	conds   []bool
+	gas     int
+}
+
+func (vm *engine) useGas(n int) error {
+	vm.gas += n
+	if vm.gas > MaxGas {
+		return fmt.Errorf("%w: used %d (max %d)", ErrOutOfGas, vm.gas, MaxGas)
+	}
+	return nil
+}
+
+// executing 当前是否处于需要执行的分支
+func (vm *engine) executing() bool {
+	for _, c := range vm.conds {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+func (vm *engine) push(b []byte) error {
+	if len(vm.stack) >= MaxStackSize {
+		return ErrStackOverflow
+	}
+	vm.stack = append(vm.stack, b)
+	return nil
+}
+
+func (vm *engine) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	b := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return b, nil
+}
+
+func (vm *engine) popNum() (int64, error) {
+	b, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	return bytecode.DecodeNum(b)
+}
+
+func (vm *engine) popBool() (bool, error) {
+	b, err := vm.pop()
+	if err != nil {
+		return false, err
+	}
+	return bytecode.AsBool(b), nil
+}
+
+// peek 返回从栈顶往下第 i 个元素（0 为栈顶）
+func (vm *engine) peek(i int) ([]byte, error) {
+	if i >= len(vm.stack) {
+		return nil, ErrStackUnderflow
+	}
+	return vm.stack[len(vm.stack)-1-i], nil
+}
+
+// run 执行一段脚本，脚本结束时 OP_IF / OP_ENDIF 必须配对
+func (vm *engine) run(script []byte) error {
+	ins, err := parse(script)
+	if err != nil {
+		return err
+	}
+	vm.conds = vm.conds[:0]
+	for _, in := range ins {
+		if err := vm.step(in); err != nil {
+			return fmt.Errorf("%s: %w", opcodes.Name(in.Op), err)
+		}
+	}
+	if len(vm.conds) != 0 {
+		return ErrUnbalancedIf
+	}
+	return nil
+}
+
+// step 执行一条指令。不在执行分支中的指令同样消耗 gas，只处理条件嵌套
+func (vm *engine) step(in bytecode.Instruction) error {
+	if err := vm.useGas(gasBase); err != nil {
+		return err
+	}
+
+	switch in.Op {
+	case OP_IF, OP_NOTIF:
+		cond := false
+		if vm.executing() {
+			v, err := vm.popBool()
+			if err != nil {
+				return err
+			}
+			cond = v == (in.Op == OP_IF)
+		}
+		vm.conds = append(vm.conds, cond)
+		return nil
+	case OP_ELSE:
+		if len(vm.conds) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.conds[len(vm.conds)-1] = !vm.conds[len(vm.conds)-1]
+		return nil
+	case OP_ENDIF:
+		if len(vm.conds) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.conds = vm.conds[:len(vm.conds)-1]
+		return nil
+	}
+	if !vm.executing() {
+		return nil
+	}
+
+	if in.IsPush() {
+		return vm.push(in.PushValue())
+	}
+
+	switch in.Op {
+	case OP_NOP:
+		return nil
+	case OP_VERIFY:
+		return vm.verify()
+	case OP_RETURN:
+		return ErrEarlyReturn
+
+	case OP_DROP:
+		_, err := vm.pop()
+		return err
+	case OP_DUP, OP_OVER:
+		depth := 0
+		if in.Op == OP_OVER {
+			depth = 1
+		}
+		b, err := vm.peek(depth)
+		if err != nil {
+			return err
+		}
+		return vm.push(b)
+	case OP_SWAP:
+		if len(vm.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		n := len(vm.stack)
+		vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+		return nil
+	case OP_SIZE:
+		b, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		return vm.push(bytecode.EncodeNum(int64(len(b))))
+
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if err := vm.push(bytecode.FromBool(bytes.Equal(a, b))); err != nil {
+			return err
+		}
+		if in.Op == OP_EQUALVERIFY {
+			return vm.verify()
+		}
+		return nil
+
+	case OP_NOT:
+		n, err := vm.popNum()
+		if err != nil {
+			return err
+		}
+		return vm.push(bytecode.FromBool(n == 0))
+	case OP_ADD, OP_SUB, OP_BOOLAND, OP_BOOLOR, OP_NUMEQUAL, OP_NUMEQUALVERIFY,
+		OP_LESSTHAN, OP_GREATERTHAN, OP_LESSTHANOREQUAL, OP_GREATERTHANOREQUAL:
+		return vm.binaryNum(in.Op)
+
+	case OP_SHA256:
+		if err := vm.useGas(gasHash); err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		return vm.push(sum[:])
+
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		if err := vm.useGas(gasCheckSig); err != nil {
+			return err
+		}
+		pk, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		ok, err := vm.checkSig(pk, sig)
+		if err != nil {
+			return err
+		}
+		if err := vm.push(bytecode.FromBool(ok)); err != nil {
+			return err
+		}
+		if in.Op == OP_CHECKSIGVERIFY {
+			return vm.verify()
+		}
+		return nil
+
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		ok, err := vm.checkMultisig()
+		if err != nil {
+			return err
+		}
+		if err := vm.push(bytecode.FromBool(ok)); err != nil {
+			return err
+		}
+		if in.Op == OP_CHECKMULTISIGVERIFY {
+			return vm.verify()
+		}
+		return nil
+
+	case OP_CHECKLOCKTIMEVERIFY:
+		n, err := vm.popNum()
+		if err != nil {
+			return err
+		}
+		if n < 0 || !vm.checker.CheckLockTime(n) {
+			return fmt.Errorf("%w: %d", ErrLockTime, n)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: 0x%02x", ErrUnknownOpcode, in.Op)
+}
+
+// verify 弹出栈顶，不为 true 时脚本失败
+func (vm *engine) verify() error {
+	ok, err := vm.popBool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// binaryNum 执行弹出两个数字、压入一个结果的算术 / 比较指令
+func (vm *engine) binaryNum(op byte) error {
+	b, err := vm.popNum()
+	if err != nil {
+		return err
+	}
+	a, err := vm.popNum()
+	if err != nil {
+		return err
+	}
+
+	var r []byte
+	switch op {
+	case OP_ADD, OP_SUB:
+		if op == OP_SUB {
+			b = -b
+		}
+		sum := a + b
+		if (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0) {
+			return ErrNumOverflow
+		}
+		r = bytecode.EncodeNum(sum)
+	case OP_BOOLAND:
+		r = bytecode.FromBool(a != 0 && b != 0)
+	case OP_BOOLOR:
+		r = bytecode.FromBool(a != 0 || b != 0)
+	case OP_NUMEQUAL, OP_NUMEQUALVERIFY:
+		r = bytecode.FromBool(a == b)
+	case OP_LESSTHAN:
+		r = bytecode.FromBool(a < b)
+	case OP_GREATERTHAN:
+		r = bytecode.FromBool(a > b)
+	case OP_LESSTHANOREQUAL:
+		r = bytecode.FromBool(a <= b)
+	case OP_GREATERTHANOREQUAL:
+		r = bytecode.FromBool(a >= b)
+	}
+	if err := vm.push(r); err != nil {
+		return err
+	}
+	if op == OP_NUMEQUALVERIFY {
+		return vm.verify()
+	}
+	return nil
+}
+
+// checkSig 空签名表示“这个人没有签”，结果为 false；非空但不正确的签名直接让脚本失败，
+// 这样无效签名不能被用来凑数或浪费验证者的算力
+func (vm *engine) checkSig(pk, sig []byte) (bool, error) {
+	if len(sig) == 0 {
+		return false, nil
+	}
+	if !vm.checker.CheckSig(pk, sig) {
+		return false, ErrNullFail
+	}
+	return true, nil
+}
+
+// checkMultisig 栈布局（从下往上）：sig_1 … sig_m | m | pk_1 … pk_n | n。
+// 签名必须按公钥的顺序给出，每个签名在剩余公钥中依次向后匹配，m 个签名都匹配上才为 true
+func (vm *engine) checkMultisig() (bool, error) {
+	n, err := vm.popNum()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > MaxMultisigKey {
+		return false, fmt.Errorf("%w: %d keys", ErrBadScript, n)
+	}
+	if err := vm.useGas(gasCheckSig * int(n)); err != nil {
+		return false, err
+	}
+	pks := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pks[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+	m, err := vm.popNum()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, fmt.Errorf("%w: threshold %d of %d", ErrBadScript, m, n)
+	}
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	k := 0
+	for _, sig := range sigs {
+		if len(sig) == 0 {
+			return false, nil
+		}
+		for k < len(pks) && !vm.checker.CheckSig(pks[k], sig) {
+			k++
+		}
+		if k == len(pks) {
+			return false, ErrNullFail
+		}
+		k++
+	}
+	return true, nil
+}
@@ -0,0 +1,157 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"mychain/core/bytecode"
+)
+
+// fakeChecker 把 0xaa | pubKey 当作 pubKey 的有效签名，锁定时间不超过 now 的都算已经到达
+type fakeChecker struct {
+	now int64
+}
+
+func (c fakeChecker) CheckSig(pubKey, sig []byte) bool {
+	return bytes.Equal(sig, append([]byte{0xaa}, pubKey...))
+}
+
+func (c fakeChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.now
+}
+
+func asm(t *testing.T, src string) []byte {
+	t.Helper()
+	b, err := Assemble(src)
+	if err != nil {
+		t.Fatalf("assemble %q: %v", src, err)
+	}
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	const multisig = "2 0x01 0x02 0x03 3 OP_CHECKMULTISIG"
+	tooManyKeys := "1" + strings.Repeat(" 0x01", MaxMultisigKey+1) + " 16 OP_CHECKMULTISIG"
+
+	tests := []struct {
+		name           string
+		unlock, redeem string
+		want           error // nil 表示脚本通过
+	}{
+		// 条件分支
+		{"if true", "1", "OP_IF 1 OP_ELSE 0 OP_ENDIF", nil},
+		{"if false", "0", "OP_IF 1 OP_ELSE 0 OP_ENDIF", ErrScriptFalse},
+		{"notif", "0", "OP_NOTIF 1 OP_ELSE 0 OP_ENDIF", nil},
+		{"nested if", "0 1", "OP_IF OP_IF 0 OP_ELSE 1 OP_ENDIF OP_ELSE 0 OP_ENDIF", nil},
+		{"nested in skipped branch", "0", "OP_IF OP_IF 0 OP_ENDIF 0 OP_ELSE 1 OP_ENDIF", nil},
+		{"skipped branch is not executed", "1", "OP_IF 1 OP_ELSE OP_RETURN OP_ENDIF", nil},
+		{"taken branch returns", "0", "OP_IF 1 OP_ELSE OP_RETURN OP_ENDIF", ErrEarlyReturn},
+		{"if without endif", "1", "OP_IF 1", ErrUnbalancedIf},
+		{"else without if", "1", "OP_ELSE 1", ErrUnbalancedIf},
+		{"endif without if", "1", "OP_ENDIF", ErrUnbalancedIf},
+		{"if on empty stack", "", "OP_IF 1 OP_ENDIF", ErrStackUnderflow},
+
+		// OP_CHECKSIG：空签名为 false，非空的错误签名直接失败
+		{"checksig", "0xaa01", "0x01 OP_CHECKSIG", nil},
+		{"checksig empty sig", "0x", "0x01 OP_CHECKSIG", ErrScriptFalse},
+		{"checksig wrong key", "0xaa02", "0x01 OP_CHECKSIG", ErrNullFail},
+		{"checksig not", "0x", "0x01 OP_CHECKSIG OP_NOT", nil},
+		{"checksigverify", "0xaa01", "0x01 OP_CHECKSIGVERIFY 1", nil},
+		{"checksigverify empty sig", "0x", "0x01 OP_CHECKSIGVERIFY 1", ErrVerifyFailed},
+
+		// OP_CHECKMULTISIG：签名按公钥顺序匹配
+		{"multisig 1 and 3", "0xaa01 0xaa03", multisig, nil},
+		{"multisig 2 and 3", "0xaa02 0xaa03", multisig, nil},
+		{"multisig out of order", "0xaa03 0xaa01", multisig, ErrNullFail},
+		{"multisig same signer twice", "0xaa01 0xaa01", multisig, ErrNullFail},
+		{"multisig bad sig", "0xaa01 0xaa04", multisig, ErrNullFail},
+		{"multisig empty sig", "0xaa01 0x", multisig, ErrScriptFalse},
+		{"multisig too few sigs", "0xaa01", multisig, ErrStackUnderflow},
+		{"multisig threshold above keys", "0xaa01 0xaa02 0xaa03", "3 0x01 0x02 2 OP_CHECKMULTISIG", ErrBadScript},
+		{"multisig too many keys", "0xaa01", tooManyKeys, ErrBadScript},
+		{"multisigverify", "0xaa01 0xaa02", "2 0x01 0x02 0x03 3 OP_CHECKMULTISIGVERIFY 1", nil},
+
+		// OP_CHECKLOCKTIMEVERIFY：锁定时间由 Checker 判断，负数总是失败
+		{"cltv reached", "", "100 OP_CHECKLOCKTIMEVERIFY 1", nil},
+		{"cltv not reached", "", "101 OP_CHECKLOCKTIMEVERIFY 1", ErrLockTime},
+		{"cltv negative", "", "-1 OP_CHECKLOCKTIMEVERIFY 1", ErrLockTime},
+		{"cltv empty stack", "", "OP_CHECKLOCKTIMEVERIFY 1", ErrStackUnderflow},
+
+		// 哈希锁与算术
+		{"hashlock", "0x736563726574", "OP_SHA256 0x2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b OP_EQUAL", nil},
+		{"hashlock wrong preimage", "0x736563726574ff", "OP_SHA256 0x2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b OP_EQUALVERIFY 1", ErrVerifyFailed},
+		{"add", "2 3", "OP_ADD 5 OP_NUMEQUAL", nil},
+		{"add overflow", "0x" + "ffffffffffffff7f" + " 1", "OP_ADD", ErrNumOverflow},
+		{"number too long", "0x" + "000000000000000001", "1 OP_ADD", ErrNumOverflow},
+		{"empty stack at end", "1", "OP_DROP", ErrScriptFalse},
+
+		// 解锁脚本只能压栈
+		{"unlock not push only", "1 OP_DUP", "OP_EQUAL", ErrNotPushOnly},
+		{"unlock with checksig", "0xaa01 0x01 OP_CHECKSIG", "", ErrNotPushOnly},
+	}
+	for _, tt := range tests {
+		_, err := Verify(asm(t, tt.unlock), asm(t, tt.redeem), fakeChecker{now: 100})
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyLimits(t *testing.T) {
+	one := asm(t, "1")
+	tests := []struct {
+		name           string
+		unlock, redeem []byte
+		want           error
+	}{
+		{"stack at limit", bytes.Repeat([]byte{OP_1}, MaxStackSize), one[:0], nil},
+		{"stack overflow", bytes.Repeat([]byte{OP_1}, MaxStackSize), one, ErrStackOverflow},
+		{"script too large", one, append(bytes.Repeat([]byte{OP_NOP}, MaxScriptSize), OP_1), ErrScriptTooLarge},
+		{"element too large", bytecode.PushData(make([]byte, MaxElementSize+1)), one, ErrElementTooLarge},
+		{"truncated push", []byte{0x05, 0x01}, one, bytecode.ErrBadBytecode},
+		{"truncated pushdata2", []byte{OP_PUSHDATA2, 0x01}, one, bytecode.ErrBadBytecode},
+		{"unknown opcode", one, []byte{0xba}, ErrUnknownOpcode},
+		{"unknown opcode in skipped branch", asm(t, "0"), []byte{OP_IF, 0xba, OP_ENDIF, OP_1}, ErrUnknownOpcode},
+		{"unknown opcode in unlock", []byte{0xba}, one, ErrUnknownOpcode},
+		// 每条 OP_SHA256 消耗 gasBase + gasHash，不到 200 条就超出 MaxGas
+		{"out of gas", one, bytes.Repeat([]byte{OP_SHA256}, 200), ErrOutOfGas},
+		{"checkmultisig gas", asm(t, "0"), append(bytes.Repeat([]byte{OP_DUP}, MaxMultisigKey),
+			asm(t, "15 OP_CHECKMULTISIGVERIFY 0 15 OP_CHECKMULTISIG")...), ErrOutOfGas},
+	}
+	for _, tt := range tests {
+		_, err := Verify(tt.unlock, tt.redeem, fakeChecker{})
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"OP_DUP OP_SHA256 0x0102 OP_EQUALVERIFY OP_CHECKSIG",
+		"OP_IF 0x" + strings.Repeat("ab", 80) + " OP_ELSE OP_1NEGATE OP_ENDIF",
+		"OP_0 OP_1 OP_16 0x11 0x8000 OP_CHECKLOCKTIMEVERIFY",
+	} {
+		got, err := Disassemble(asm(t, src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != src {
+			t.Errorf("round trip of %q = %q", src, got)
+		}
+	}
+	if _, err := Assemble("OP_PUSHDATA1 0x01"); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("raw OP_PUSHDATA1: err = %v, want ErrUnknownOpcode", err)
+	}
+	if _, err := Assemble("OP_FOO"); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("OP_FOO: err = %v, want ErrUnknownOpcode", err)
+	}
+}
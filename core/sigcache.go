@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"mychain/utils"
+)
+
+// 签名校验是区块校验中最慢的一步（ECDSA、多签、脚本）。这里提供两样东西：
+//   - SigCache：记住已经校验通过的交易，交易先在交易池验过一次，区块到达时就不必再验；
+//   - 并行校验：把一个区块（或一批区块）中所有交易的签名分给多个协程同时校验。
+
+// DefaultSigCacheSize 签名缓存默认最多记住多少笔交易
+const DefaultSigCacheSize = 20000
+
+// SigCache 记录签名校验通过的交易，可以被多个协程同时使用。
+// 键是交易哈希，值是整笔交易规范编码（含公钥、签名、多签与脚本部分）的哈希：
+// 交易哈希不包含签名，同一内容换一个签名时必须重新校验。
+// 缓存满了以后按加入顺序淘汰最早的记录。
+type SigCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+	max     int
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// SigCacheStats 是签名缓存的统计信息
+type SigCacheStats struct {
+	Size   int    `json:"size"`
+	Hits   uint64 `json:"hits"`   // 命中缓存、跳过校验的次数
+	Misses uint64 `json:"misses"` // 实际执行校验的次数
+}
+
+// NewSigCache 创建最多记住 max 笔交易的签名缓存
+func NewSigCache(max int) *SigCache {
+	return &SigCache{entries: make(map[string][]byte), max: max}
+}
+
+// witnessHash 返回整笔交易规范编码的哈希，编码失败（格式错误）时返回 nil
+func witnessHash(tx *Transaction) []byte {
+	b, err := tx.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return utils.Sha256(b)
+}
+
+// Verify 校验交易签名（见 VerifyTxSignature），校验过的相同交易直接通过。c 为 nil 时不使用缓存
+func (c *SigCache) Verify(tx *Transaction) error {
+	if c == nil {
+		return VerifyTxSignature(tx)
+	}
+
+	// 用内容重新计算交易哈希，不信任交易自带的 Hash 字段
+	key := utils.ToHex(utils.Sha256(tx.payload()))
+	wh := witnessHash(tx)
+
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && wh != nil && bytes.Equal(cached, wh) {
+		c.hits.Add(1)
+		return nil
+	}
+
+	c.misses.Add(1)
+	if err := VerifyTxSignature(tx); err != nil {
+		return err
+	}
+	if wh != nil {
+		c.add(key, wh)
+	}
+	return nil
+}
+
+func (c *SigCache) add(key string, wh []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = wh
+	for len(c.order) > c.max {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// Stats 返回缓存大小与命中统计
+func (c *SigCache) Stats() SigCacheStats {
+	if c == nil {
+		return SigCacheStats{}
+	}
+	c.mu.Lock()
+	size := len(c.entries)
+	c.mu.Unlock()
+	return SigCacheStats{Size: size, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// verifySignatures 用一组协程并行校验 txs 的签名（跳过 coinbase），
+// 返回序号最小的失败交易的下标与错误，全部通过时返回 -1, nil
+func verifySignatures(txs []*Transaction, c *SigCache) (int, error) {
+	errs := make([]error, len(txs))
+	jobs := make(chan int)
+
+	workers := runtime.NumCPU()
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if !txs[i].IsCoinbase() {
+					errs[i] = c.Verify(txs[i])
+				}
+			}
+		}()
+	}
+	for i := range txs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// PreverifyBlocks 并行校验一批区块中所有交易的签名，通过的交易记入缓存 c。
+// 同步整条链时先调用它，随后逐个校验区块时签名检查都会命中缓存；
+// 这里不报告错误，签名错误由之后的 CheckBlock 按区块给出
+func PreverifyBlocks(blocks []Block, c *SigCache) {
+	if c == nil {
+		return
+	}
+	var txs []*Transaction
+	for i := range blocks {
+		for j := range blocks[i].Txs {
+			txs = append(txs, &blocks[i].Txs[j])
+		}
+	}
+	verifySignatures(txs, c)
+}
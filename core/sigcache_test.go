@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+// signedTxs 返回 n 笔由同一个账户签名的转账
+func signedTxs(t *testing.T, n int) []Transaction {
+	t.Helper()
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := make([]Transaction, n)
+	for i := range txs {
+		txs[i] = Transaction{From: utils.PubKeyToAddress(pub), To: "bob", Value: 1, Nonce: uint64(i), Timestamp: time.Unix(1700000000, 0)}
+		if err := txs[i].Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return txs
+}
+
+func TestSigCacheVerify(t *testing.T) {
+	c := NewSigCache(10)
+	tx := signedTxs(t, 1)[0]
+
+	check := func(tx Transaction, want error, hits, misses uint64) {
+		t.Helper()
+		if err := c.Verify(&tx); !errors.Is(err, want) {
+			t.Fatalf("err = %v, want %v", err, want)
+		}
+		if s := c.Stats(); s.Hits != hits || s.Misses != misses {
+			t.Fatalf("stats %+v, want %d hits %d misses", s, hits, misses)
+		}
+	}
+	check(tx, nil, 0, 1)
+	check(tx, nil, 1, 1)
+
+	// 内容相同、签名被篡改：不能因为交易哈希在缓存中就通过
+	forged := tx
+	forged.Sig = append([]byte(nil), tx.Sig...)
+	forged.Sig[len(forged.Sig)-1] ^= 1
+	check(forged, ErrBadSignature, 1, 2)
+
+	// 改了金额但保留原来的 Hash 字段：缓存的键由内容重新计算，同样要重新校验
+	tampered := tx
+	tampered.Value++
+	check(tampered, ErrBadSignature, 1, 3)
+
+	// 失败的校验不进入缓存，原交易仍然命中
+	check(tx, nil, 2, 3)
+	if s := c.Stats(); s.Size != 1 {
+		t.Fatalf("cache size %d, want 1", s.Size)
+	}
+
+	// 不使用缓存时照常校验
+	var none *SigCache
+	if err := none.Verify(&forged); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("nil cache: err = %v, want ErrBadSignature", err)
+	}
+	if s := none.Stats(); s != (SigCacheStats{}) {
+		t.Fatalf("nil cache stats: %+v", s)
+	}
+}
+
+func TestSigCacheEvictsOldest(t *testing.T) {
+	c := NewSigCache(2)
+	txs := signedTxs(t, 3)
+	for i := range txs {
+		if err := c.Verify(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := c.Stats(); s.Size != 2 || s.Misses != 3 {
+		t.Fatalf("stats %+v, want size 2 and 3 misses", s)
+	}
+	// 最晚加入的仍在缓存中，最早的已被淘汰
+	c.Verify(&txs[2])
+	c.Verify(&txs[0])
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 4 {
+		t.Fatalf("stats %+v, want 1 hit and 4 misses", s)
+	}
+}
+
+func TestVerifySignaturesReportsFirstFailure(t *testing.T) {
+	txs := signedTxs(t, 20)
+	ptrs := make([]*Transaction, len(txs)+1)
+	coinbase := DefaultParams.NewCoinbase(1, "miner", 10)
+	ptrs[0] = &coinbase // coinbase 没有签名，不参与校验
+	for i := range txs {
+		ptrs[i+1] = &txs[i]
+	}
+	if i, err := verifySignatures(ptrs, nil); i != -1 || err != nil {
+		t.Fatalf("all valid: index %d, err %v", i, err)
+	}
+
+	txs[15].Sig = nil
+	txs[7].Value++
+	if i, err := verifySignatures(ptrs, nil); i != 8 || !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("index %d, err %v, want the earliest failure at 8", i, err)
+	}
+
+	// 预先校验区块：通过的交易进入缓存，失败的不进入
+	c := NewSigCache(100)
+	PreverifyBlocks([]Block{{Txs: txs[:10]}, {Txs: txs[10:]}}, c)
+	if s := c.Stats(); s.Size != 18 {
+		t.Fatalf("cache size after preverify = %d, want 18", s.Size)
+	}
+}
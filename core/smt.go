@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+
+	"mychain/utils"
+)
+
+// 稀疏 Merkle 树（压缩形式）：
+//   - 每个叶子的位置由 256 位的键（SHA256 后的状态键）决定，从最高位开始，0 向左、1 向右
+//   - 空子树的哈希为 32 个 0 字节
+//   - 只含一个叶子的子树直接用该叶子的哈希表示，不再向下展开
+//   - 叶子哈希 = SHA256(0x00 | key | valueHash)，内部节点 = SHA256(0x01 | left | right)
+//
+// 这样整棵树的根只取决于叶子集合本身，与插入顺序无关。
+
+var ErrBadStateProof = errors.New("state proof does not match state root")
+
+var smtEmpty = make([]byte, 32)
+
+// smtLeaf 是树中的一个叶子
+type smtLeaf struct {
+	key   []byte // 32 字节
+	value []byte // 值哈希
+	hash  []byte // 叶子哈希
+}
+
+func smtLeafHash(key, valueHash []byte) []byte {
+	data := make([]byte, 0, 1+len(key)+len(valueHash))
+	data = append(data, 0x00)
+	data = append(data, key...)
+	data = append(data, valueHash...)
+	return utils.Sha256(data)
+}
+
+func smtNodeHash(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, 0x01)
+	data = append(data, left...)
+	data = append(data, right...)
+	return utils.Sha256(data)
+}
+
+// smtBit 返回 key 第 depth 位（从最高位开始计数）
+func smtBit(key []byte, depth int) int {
+	return int(key[depth/8]>>(7-uint(depth%8))) & 1
+}
+
+// smtNode 是内存中的一棵子树：leaf 不为 nil 时子树只含这一个叶子，否则是至少含两个叶子的内部节点，
+// nil 表示空子树。内部节点缓存自己的哈希，修改叶子时只清掉路径上的缓存，
+// 下次取根时只重算这些节点，其余子树的哈希直接复用
+type smtNode struct {
+	leaf        *smtLeaf
+	left, right *smtNode
+	hash        []byte // 内部节点的哈希缓存，nil 表示需要重算
+}
+
+// sum 返回子树的哈希
+func (n *smtNode) sum() []byte {
+	switch {
+	case n == nil:
+		return smtEmpty
+	case n.leaf != nil:
+		return n.leaf.hash
+	case n.hash == nil:
+		n.hash = smtNodeHash(n.left.sum(), n.right.sum())
+	}
+	return n.hash
+}
+
+// smtInsert 把叶子写入 depth 层的子树 n（键已存在时替换），返回新的子树
+func smtInsert(n *smtNode, leaf *smtLeaf, depth int) *smtNode {
+	if n == nil || (n.leaf != nil && bytes.Equal(n.leaf.key, leaf.key)) {
+		return &smtNode{leaf: leaf}
+	}
+	if n.leaf != nil {
+		// 遇到另一个叶子：展开成内部节点，两个叶子继续往下放，直到键的某一位不同
+		other := n
+		n = &smtNode{}
+		if smtBit(other.leaf.key, depth) == 0 {
+			n.left = other
+		} else {
+			n.right = other
+		}
+	}
+	n.hash = nil
+	if smtBit(leaf.key, depth) == 0 {
+		n.left = smtInsert(n.left, leaf, depth+1)
+	} else {
+		n.right = smtInsert(n.right, leaf, depth+1)
+	}
+	return n
+}
+
+// smtDelete 从 depth 层的子树 n 中删除键 key（不存在时不变），返回新的子树
+func smtDelete(n *smtNode, key []byte, depth int) *smtNode {
+	if n == nil || n.leaf != nil {
+		if n != nil && bytes.Equal(n.leaf.key, key) {
+			return nil
+		}
+		return n
+	}
+	n.hash = nil
+	if smtBit(key, depth) == 0 {
+		n.left = smtDelete(n.left, key, depth+1)
+	} else {
+		n.right = smtDelete(n.right, key, depth+1)
+	}
+	// 只剩一个叶子的子树收缩成这个叶子
+	switch {
+	case n.left == nil && (n.right == nil || n.right.leaf != nil):
+		return n.right
+	case n.right == nil && n.left.leaf != nil:
+		return n.left
+	}
+	return n
+}
+
+// SMTProof 证明某个键在树中的值（或不存在）：
+// Siblings 为从根往下每一层的兄弟子树哈希；走到底时所在的子树要么为空，
+// 要么只有一个叶子 LeafKey / LeafValueHash（可能是别的键，此时同样证明目标键不存在）
+type SMTProof struct {
+	Siblings      [][]byte `json:"siblings"`
+	LeafKey       []byte   `json:"leafKey,omitempty"`
+	LeafValueHash []byte   `json:"leafValueHash,omitempty"`
+}
+
+// smtProve 在树 n 中沿着 key 的路径生成证明
+func smtProve(n *smtNode, key []byte) SMTProof {
+	var proof SMTProof
+	for depth := 0; n != nil && n.leaf == nil; depth++ {
+		if smtBit(key, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, n.right.sum())
+			n = n.left
+		} else {
+			proof.Siblings = append(proof.Siblings, n.left.sum())
+			n = n.right
+		}
+	}
+	if n != nil {
+		proof.LeafKey = n.leaf.key
+		proof.LeafValueHash = n.leaf.value
+	}
+	return proof
+}
+
+// VerifySMTProof 检查 proof 能否证明：在 root 下键 key 的值哈希为 valueHash；
+// valueHash 为 nil 表示证明该键不存在
+func VerifySMTProof(root, key, valueHash []byte, proof *SMTProof) bool {
+	if len(key) != 32 || len(proof.Siblings) > 256 {
+		return false
+	}
+
+	var node []byte
+	switch {
+	case proof.LeafKey == nil:
+		// 路径末端是空子树：只能证明不存在
+		if valueHash != nil {
+			return false
+		}
+		node = smtEmpty
+	case bytes.Equal(proof.LeafKey, key):
+		// 末端就是目标键：值必须一致
+		if valueHash == nil || !bytes.Equal(proof.LeafValueHash, valueHash) {
+			return false
+		}
+		node = smtLeafHash(key, valueHash)
+	default:
+		// 末端是另一个键：它必须和目标键共享这段路径，才能说明目标键不存在
+		if valueHash != nil || len(proof.LeafKey) != 32 {
+			return false
+		}
+		for depth := range proof.Siblings {
+			if smtBit(proof.LeafKey, depth) != smtBit(key, depth) {
+				return false
+			}
+		}
+		node = smtLeafHash(proof.LeafKey, proof.LeafValueHash)
+	}
+
+	for depth := len(proof.Siblings) - 1; depth >= 0; depth-- {
+		if smtBit(key, depth) == 0 {
+			node = smtNodeHash(node, proof.Siblings[depth])
+		} else {
+			node = smtNodeHash(proof.Siblings[depth], node)
+		}
+	}
+	return bytes.Equal(node, root)
+}
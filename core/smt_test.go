@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// rebuildRoot 把状态的全部叶子按给定顺序插入一棵新树，返回它的根，用来核对增量维护的根
+func rebuildRoot(st *State, order *rand.Rand) []byte {
+	st.flushTree()
+	var leaves []*smtLeaf
+	var walk func(n *smtNode)
+	walk = func(n *smtNode) {
+		switch {
+		case n == nil:
+		case n.leaf != nil:
+			leaves = append(leaves, n.leaf)
+		default:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(st.tree)
+	order.Shuffle(len(leaves), func(i, j int) { leaves[i], leaves[j] = leaves[j], leaves[i] })
+
+	var tree *smtNode
+	for _, l := range leaves {
+		tree = smtInsert(tree, l, 0)
+	}
+	return tree.sum()
+}
+
+func TestStateRootIsIncremental(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	st := NewState()
+	if !bytes.Equal(st.Root(), smtEmpty) {
+		t.Fatal("empty state should have the empty root")
+	}
+
+	for round := 0; round < 200; round++ {
+		before := st.Root()
+		mark := st.Snapshot()
+		for i := 0; i < 10; i++ {
+			addr := fmt.Sprint("addr", r.Intn(40))
+			switch r.Intn(5) {
+			case 0:
+				st.AddBalance(addr, int64(r.Intn(5)-2))
+			case 1:
+				st.IncNonce(addr)
+			case 2:
+				st.SetStorage(addr, []byte{byte(r.Intn(4))}, []byte{byte(r.Intn(3))})
+			case 3:
+				st.addStake(addr, int64(r.Intn(3)))
+			case 4:
+				st.AddTokenBalance("GOLD", addr, int64(r.Intn(3)-1))
+			}
+		}
+		if got, want := st.Root(), rebuildRoot(st, r); !bytes.Equal(got, want) {
+			t.Fatalf("round %d: incremental root %x, rebuilt root %x", round, got, want)
+		}
+		// 三分之一的轮次撤销本轮的修改，根必须回到修改之前
+		if r.Intn(3) == 0 {
+			st.RevertToSnapshot(mark)
+			if got := st.Root(); !bytes.Equal(got, before) {
+				t.Fatalf("round %d: root after revert %x, want %x", round, got, before)
+			}
+		} else {
+			st.commit(mark)
+		}
+	}
+}
+
+func TestAccountProofs(t *testing.T) {
+	st := NewState()
+	for i := 0; i < 50; i++ {
+		st.AddBalance(fmt.Sprint("addr", i), int64(i+1))
+	}
+	st.IncNonce("addr7")
+	root := st.Root()
+
+	for i := 0; i < 50; i++ {
+		p := st.ProveAccount(fmt.Sprint("addr", i))
+		if err := VerifyAccountProof(p, root); err != nil {
+			t.Fatalf("addr%d: %v", i, err)
+		}
+		// 改动余额、nonce 或证明路径都会失效
+		forged := *p
+		forged.Balance++
+		if VerifyAccountProof(&forged, root) == nil {
+			t.Fatalf("addr%d: proof verifies a wrong balance", i)
+		}
+		forged = *p
+		forged.Nonce++
+		if VerifyAccountProof(&forged, root) == nil {
+			t.Fatalf("addr%d: proof verifies a wrong nonce", i)
+		}
+		if len(p.Proof.Siblings) > 0 {
+			forged = *p
+			forged.Proof.Siblings = append([][]byte(nil), p.Proof.Siblings...)
+			forged.Proof.Siblings[0] = smtEmpty
+			if bytes.Equal(p.Proof.Siblings[0], smtEmpty) {
+				forged.Proof.Siblings[0] = root
+			}
+			if VerifyAccountProof(&forged, root) == nil {
+				t.Fatalf("addr%d: proof with a tampered sibling verifies", i)
+			}
+		}
+	}
+
+	// 不存在的账户：证明余额与 nonce 为 0，声称它有余额的证明不成立
+	p := st.ProveAccount("nobody")
+	if p.Balance != 0 || p.Nonce != 0 {
+		t.Fatalf("missing account reported balance %d nonce %d", p.Balance, p.Nonce)
+	}
+	if err := VerifyAccountProof(p, root); err != nil {
+		t.Fatalf("non-existence proof: %v", err)
+	}
+	p.Balance = 1
+	if VerifyAccountProof(p, root) == nil {
+		t.Fatal("non-existence proof verifies a non-zero balance")
+	}
+
+	// 旧的证明在状态变化后对新的根无效
+	old := st.ProveAccount("addr3")
+	st.AddBalance("addr3", 1)
+	if VerifyAccountProof(old, st.Root()) == nil {
+		t.Fatal("stale proof verifies against the new root")
+	}
+}
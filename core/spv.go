@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTxNotFound     = errors.New("transaction not found in main chain")
+	ErrBadHeaderChain = errors.New("invalid header chain")
+	ErrHeaderNotFound = errors.New("proof header is not in header chain")
+	ErrBadMerkleProof = errors.New("merkle proof does not match header")
+)
+
+// TxProof 证明一笔交易被打包在主链的某个区块中（SPV 证明）：
+// 只需要区块头链 + 这条 Merkle 路径，就能在不下载完整区块的情况下验证交易
+type TxProof struct {
+	Tx     Transaction  `json:"tx"`
+	Height int          `json:"height"` // 区块高度
+	Index  int          `json:"index"`  // 交易在区块中的序号
+	Path   []MerkleStep `json:"path"`   // 从交易哈希到 Merkle 根的兄弟节点路径
+	Header *BlockHeader `json:"header"`
+}
+
+// Headers 返回主链上全部区块头（轻节点只需要下载这些）
+func (bc *Blockchain) Headers() []*BlockHeader {
+	headers := make([]*BlockHeader, len(bc.Blocks))
+	for i := range bc.Blocks {
+		headers[i] = bc.Blocks[i].Header
+	}
+	return headers
+}
+
+// TxProof 在主链上查找交易并生成它的 Merkle 证明
+func (bc *Blockchain) TxProof(txHash []byte) (*TxProof, error) {
+	for h := len(bc.Blocks) - 1; h >= 0; h-- {
+		b := &bc.Blocks[h]
+		for i := range b.Txs {
+			if !bytes.Equal(b.Txs[i].Hash, txHash) {
+				continue
+			}
+			path, err := BuildMerkleProof(b.Txs, i)
+			if err != nil {
+				return nil, err
+			}
+			return &TxProof{Tx: b.Txs[i], Height: h, Index: i, Path: path, Header: b.Header}, nil
+		}
+	}
+	return nil, ErrTxNotFound
+}
+
+// VerifyHeaders 只根据区块头校验一条属于网络 p 的链：创世块一致、版本与高度正确、哈希首尾相连、
+// 时间戳晚于过去中位时间、共识封装正确（POW：难度符合调整规则且哈希满足难度；PoA：签名者按规则出块且签名正确）
+func VerifyHeaders(p *ChainParams, headers []*BlockHeader) error {
+	engine := NewEngine(p)
+	genesis := p.GenesisBlock()
+	if len(headers) == 0 || headers[0] == nil || !bytes.Equal(headers[0].Hash, genesis.Header.Hash) {
+		return fmt.Errorf("%w: %v", ErrBadHeaderChain, ErrGenesisMismatch)
+	}
+
+	for h := 1; h < len(headers); h++ {
+		header, parent := headers[h], headers[h-1]
+		if header == nil {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, ErrNoHeader)
+		}
+		if header.Version != BlockVersion {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, ErrBadVersion)
+		}
+		if !bytes.Equal(header.PreviousHash, parent.Hash) {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, ErrPrevHashMismatch)
+		}
+		if header.Height != uint64(h) {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, ErrBadHeight)
+		}
+		if !header.Timestamp.After(MedianTimePast(headers[:h])) {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, ErrTimeTooOld)
+		}
+
+		// 共识：POW 的难度与哈希，PoA 的签名者、出块权重与签名
+		if err := engine.VerifySeal(headerList(headers[:h]), header); err != nil {
+			return fmt.Errorf("%w: header %d: %v", ErrBadHeaderChain, h, err)
+		}
+	}
+	return nil
+}
+
+// VerifyTxProof 用区块头链校验一份交易证明，返回交易的确认数（所在区块算 1 个）。
+// 调用方需要先用 VerifyHeaders 确认 headers 本身合法。
+func VerifyTxProof(p *TxProof, headers []*BlockHeader) (int, error) {
+	if p.Header == nil || p.Height < 0 || p.Height >= len(headers) ||
+		!bytes.Equal(headers[p.Height].Hash, p.Header.Hash) {
+		return 0, ErrHeaderNotFound
+	}
+
+	// 交易内容必须与哈希一致，否则对方可能换了交易内容而保留原来的证明
+	tx := p.Tx
+	tx.CalculateHash()
+	if !bytes.Equal(tx.Hash, p.Tx.Hash) {
+		return 0, ErrTxHashMismatch
+	}
+
+	// 使用本地区块头中的 Merkle 根，而不是证明里附带的
+	if !VerifyMerkleProof(tx.Hash, headers[p.Height].MerkleRoot, p.Path) {
+		return 0, ErrBadMerkleProof
+	}
+	return len(headers) - p.Height, nil
+}
@@ -0,0 +1,383 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"mychain/utils"
+)
+
+// 质押（PoS 链，共识见 pos.go）：
+//   - 质押（TxStake）：From 支付 Value，记入 To 的质押，To 可以是自己也可以是别的验证者；
+//     创世时的质押（链配置 consensus.stakes）是创世块中 From 为空的质押交易
+//   - 取消质押（TxUnstake）：From 的质押减少 Value，转为解锁中，UnbondingBlocks 个区块之后回到余额；
+//     解锁中的金额仍然可以被罚没，因此作恶后立即取消质押也逃不掉
+//   - 罚没（TxSlash）：Payload 为双签证据，即同一验证者在本网络上对同一高度两个不同区块头的签名，任何人都可以提交。
+//     验证者质押与解锁中金额之和的 SlashPercent% 被销毁（先扣质押），剩余质押全部转为解锁中，
+//     验证者因此退出验证者集合。同一验证者在同一高度只能被罚没一次。
+//     区块签名绑定 networkId（见 consensus.go 的 sealHash），同一把私钥在别的网络签的区块头不能作为证据
+//
+// 质押、解锁中金额与罚没记录都进入状态树；解锁计划与 coinbase 锁定一样由执行历史决定，不进入状态树。
+
+var (
+	ErrBadStakeTx        = errors.New("malformed staking transaction")
+	ErrStakingDisabled   = errors.New("staking needs the proof-of-stake engine")
+	ErrInsufficientStake = errors.New("insufficient stake")
+	ErrBadEvidence       = errors.New("invalid double-sign evidence")
+	ErrAlreadySlashed    = errors.New("validator already slashed for this height")
+)
+
+// Slash 是一次罚没的记录
+type Slash struct {
+	Validator string `json:"validator"`
+	Height    uint64 `json:"height"`    // 双签的区块高度
+	Amount    uint64 `json:"amount"`    // 销毁的金额
+	SlashedAt uint64 `json:"slashedAt"` // 罚没交易所在的区块高度
+	Reporter  string `json:"reporter"`  // 提交证据的地址
+}
+
+// SlashKey 返回验证者在某个高度双签的罚没记录键
+func SlashKey(validator string, height uint64) string {
+	return validator + ":" + strconv.FormatUint(height, 10)
+}
+
+// DoubleSignEvidence 是双签证据：同一验证者签名的两个同高度、不同哈希的区块头。
+// 二进制编码为 bytes(A 的 sealedHeader) | bytes(B 的 sealedHeader)，作为罚没交易的 Payload
+type DoubleSignEvidence struct {
+	A *BlockHeader `json:"a"`
+	B *BlockHeader `json:"b"`
+}
+
+// MarshalBinary 返回证据的二进制编码
+func (ev *DoubleSignEvidence) MarshalBinary() ([]byte, error) {
+	a, err := ev.A.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ev.B.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var e encoder
+	e.bytes(a)
+	e.bytes(b)
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary 解码证据，两个区块头的 Hash 按内容重新计算
+func (ev *DoubleSignEvidence) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	a, b := d.bytes(), d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+	ev.A, ev.B = new(BlockHeader), new(BlockHeader)
+	if err := ev.A.UnmarshalBinary(a); err != nil {
+		return err
+	}
+	return ev.B.UnmarshalBinary(b)
+}
+
+// Verify 检查两个区块头都由同一验证者在网络 p 上签名、高度相同而哈希不同，返回验证者地址
+func (ev *DoubleSignEvidence) Verify(p *ChainParams) (string, error) {
+	if ev.A == nil || ev.B == nil {
+		return "", fmt.Errorf("%w: missing header", ErrBadEvidence)
+	}
+	a, err := verifySignedHeader(p.NetworkID, ev.A)
+	if err != nil {
+		return "", fmt.Errorf("%w: header a: %v", ErrBadEvidence, err)
+	}
+	b, err := verifySignedHeader(p.NetworkID, ev.B)
+	if err != nil {
+		return "", fmt.Errorf("%w: header b: %v", ErrBadEvidence, err)
+	}
+	if a != b {
+		return "", fmt.Errorf("%w: signed by %s and %s", ErrBadEvidence, a, b)
+	}
+	if ev.A.Height != ev.B.Height {
+		return "", fmt.Errorf("%w: heights %d and %d", ErrBadEvidence, ev.A.Height, ev.B.Height)
+	}
+	if bytes.Equal(ev.A.Hash, ev.B.Hash) {
+		return "", fmt.Errorf("%w: same block", ErrBadEvidence)
+	}
+	return a, nil
+}
+
+// slashEvidence 解码并检查罚没交易中的证据，返回验证者地址
+func slashEvidence(p *ChainParams, tx *Transaction) (*DoubleSignEvidence, string, error) {
+	ev := new(DoubleSignEvidence)
+	if err := ev.UnmarshalBinary(tx.Payload); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrBadEvidence, err)
+	}
+	validator, err := ev.Verify(p)
+	return ev, validator, err
+}
+
+// checkStakeTx 检查质押相关交易的格式（与状态无关）：链使用 PoS，有发送方，不带 gas；
+// 质押要有验证者和金额，取消质押要有金额，罚没不带收款方和金额、证据有效
+func checkStakeTx(p *ChainParams, tx *Transaction) error {
+	if p.Consensus.Engine != EnginePoS {
+		return ErrStakingDisabled
+	}
+	if tx.IsCoinbase() || tx.From == "" {
+		return fmt.Errorf("%w: needs a sender", ErrBadStakeTx)
+	}
+	if tx.GasLimit != 0 {
+		return fmt.Errorf("%w: carries a gas limit", ErrBadStakeTx)
+	}
+	switch tx.Kind {
+	case TxStake:
+		if tx.To == "" || tx.Value == 0 || len(tx.Payload) != 0 {
+			return fmt.Errorf("%w: stake needs a validator and an amount", ErrBadStakeTx)
+		}
+	case TxUnstake:
+		if tx.To != "" || tx.Value == 0 || len(tx.Payload) != 0 {
+			return fmt.Errorf("%w: unstake needs an amount and no recipient", ErrBadStakeTx)
+		}
+	case TxSlash:
+		if tx.To != "" || tx.Value != 0 {
+			return fmt.Errorf("%w: slash carries to/value", ErrBadStakeTx)
+		}
+		if _, _, err := slashEvidence(p, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStakeTx 判断是否为质押相关交易
+func (tx *Transaction) isStakeTx() bool {
+	return tx.Kind == TxStake || tx.Kind == TxUnstake || tx.Kind == TxSlash
+}
+
+// checkStakingState 检查取消质押不超过已有质押、罚没的证据没有用过，在扣除手续费之前调用，失败时状态不变
+func checkStakingState(p *ChainParams, st *State, tx *Transaction) error {
+	switch tx.Kind {
+	case TxUnstake:
+		if have := st.Stake(tx.From); have < uint64(tx.Value) {
+			return fmt.Errorf("%w: account %s has staked %d, unstakes %d",
+				ErrInsufficientStake, tx.From, have, tx.Value)
+		}
+	case TxSlash:
+		ev, validator, err := slashEvidence(p, tx)
+		if err != nil {
+			return err
+		}
+		if key := SlashKey(validator, ev.A.Height); st.Slashes[key] != nil {
+			return fmt.Errorf("%w: %s", ErrAlreadySlashed, key)
+		}
+	}
+	return nil
+}
+
+// applyStakeTx 执行质押相关交易（已经由 checkStakingState 检查过）。
+// 调用方已经扣除了手续费（质押还扣除了 Value）并把 nonce 加一
+func applyStakeTx(p *ChainParams, st *State, tx *Transaction) {
+	switch tx.Kind {
+	case TxStake:
+		st.addStake(tx.To, int64(tx.Value))
+	case TxUnstake:
+		st.unbond(p, tx.From, uint64(tx.Value))
+	case TxSlash:
+		ev, validator, _ := slashEvidence(p, tx)
+		applySlash(p, st, validator, ev.A.Height, tx.From)
+	}
+}
+
+// applySlash 销毁验证者质押与解锁中金额之和的 SlashPercent%（先扣质押，再扣最晚到期的解锁），
+// 剩余质押全部转为解锁中
+func applySlash(p *ChainParams, st *State, validator string, height uint64, reporter string) {
+	stake, unbonding := st.Stake(validator), st.Unbonding[validator]
+	amount := (stake + unbonding) * p.Consensus.SlashPercent / 100
+
+	fromStake := amount
+	if fromStake > stake {
+		fromStake = stake
+	}
+	st.addStake(validator, -int64(fromStake))
+	st.burnUnbonding(validator, amount-fromStake)
+	if rest := st.Stake(validator); rest > 0 {
+		st.unbond(p, validator, rest)
+	}
+
+	st.addSupply(0, amount)
+	st.setSlash(SlashKey(validator, height), &Slash{
+		Validator: validator,
+		Height:    height,
+		Amount:    amount,
+		SlashedAt: st.Height,
+		Reporter:  reporter,
+	})
+}
+
+// Stake 返回地址当前的质押
+func (st *State) Stake(addr string) uint64 {
+	return st.Stakes[addr]
+}
+
+// addStake 给地址的质押加上 delta（可以为负），为 0 的项被删除
+func (st *State) addStake(addr string, delta int64) {
+	addUint(&st.journal, st.Stakes, addr, delta)
+	st.touchStake(addr)
+}
+
+// addUnbonding 给地址解锁中的金额加上 delta（可以为负）
+func (st *State) addUnbonding(addr string, delta int64) {
+	addUint(&st.journal, st.Unbonding, addr, delta)
+	st.touchStake(addr)
+}
+
+// addRelease 修改地址在高度 height 到期的解锁金额
+func (st *State) addRelease(height uint64, addr string, delta int64) {
+	due, existed := st.releases[height]
+	st.journal = append(st.journal, func() {
+		if !existed {
+			delete(st.releases, height)
+		}
+	})
+	if !existed {
+		due = make(map[string]uint64)
+		st.releases[height] = due
+	}
+	addUint(&st.journal, due, addr, delta)
+	if len(due) == 0 {
+		delete(st.releases, height)
+		st.journal = append(st.journal, func() {
+			st.releases[height] = due
+		})
+	}
+}
+
+// addUint 修改 m[key]，把修改前的值记录到 journal，结果为 0 时删除该项
+func addUint(journal *[]func(), m map[string]uint64, key string, delta int64) {
+	prev, existed := m[key]
+	*journal = append(*journal, func() {
+		if existed {
+			m[key] = prev
+		} else {
+			delete(m, key)
+		}
+	})
+	if v := uint64(int64(prev) + delta); v == 0 {
+		delete(m, key)
+	} else {
+		m[key] = v
+	}
+}
+
+// unbond 把地址的 amount 质押转为解锁中，UnbondingBlocks 个区块之后回到余额；UnbondingBlocks 为 0 时立即回到余额
+func (st *State) unbond(p *ChainParams, addr string, amount uint64) {
+	st.addStake(addr, -int64(amount))
+	wait := p.Consensus.UnbondingBlocks
+	if wait == 0 {
+		st.AddBalance(addr, int64(amount))
+		return
+	}
+	st.addUnbonding(addr, int64(amount))
+	st.addRelease(st.Height+wait, addr, int64(amount))
+}
+
+// burnUnbonding 销毁地址 amount 的解锁中金额，从最晚到期的解锁计划开始扣
+func (st *State) burnUnbonding(addr string, amount uint64) {
+	if amount == 0 {
+		return
+	}
+	st.addUnbonding(addr, -int64(amount))
+
+	heights := make([]uint64, 0, len(st.releases))
+	for h, due := range st.releases {
+		if due[addr] > 0 {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] > heights[j] })
+	for _, h := range heights {
+		if amount == 0 {
+			break
+		}
+		n := st.releases[h][addr]
+		if n > amount {
+			n = amount
+		}
+		st.addRelease(h, addr, -int64(n))
+		amount -= n
+	}
+}
+
+// releaseUnbonding 把在高度 height 到期的解锁金额转回余额，由 beginBlock 调用
+func (st *State) releaseUnbonding(height uint64) {
+	due, ok := st.releases[height]
+	if !ok {
+		return
+	}
+	for addr, amount := range due {
+		st.addUnbonding(addr, -int64(amount))
+		st.AddBalance(addr, int64(amount))
+	}
+	st.journal = append(st.journal, func() {
+		st.releases[height] = due
+	})
+	delete(st.releases, height)
+}
+
+func (st *State) setSlash(key string, s *Slash) {
+	st.journal = append(st.journal, func() {
+		delete(st.Slashes, key)
+	})
+	st.Slashes[key] = s
+	st.touchSlash(key)
+}
+
+// SlashList 返回全部罚没记录（按罚没高度、键排序）
+func (st *State) SlashList() []*Slash {
+	list := make([]*Slash, 0, len(st.Slashes))
+	for _, s := range st.Slashes {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].SlashedAt != list[j].SlashedAt {
+			return list[i].SlashedAt < list[j].SlashedAt
+		}
+		return SlashKey(list[i].Validator, list[i].Height) < SlashKey(list[j].Validator, list[j].Height)
+	})
+	return list
+}
+
+// DoubleSigns 在区块树（包括分叉链）中查找同一签名者在同一高度签名的不同区块，
+// 返回尚未在主链状态中罚没的证据（按高度、验证者排序），可以直接作为罚没交易的 Payload 提交
+func (bc *Blockchain) DoubleSigns() []*DoubleSignEvidence {
+	first := make(map[string]*BlockHeader) // SlashKey → 最先找到的区块头
+	reported := make(map[string]bool)
+	var found []*DoubleSignEvidence
+	for _, n := range bc.index {
+		h := n.block.Header
+		if len(h.Signer) == 0 {
+			continue
+		}
+		key := SlashKey(utils.PubKeyToAddress(h.Signer), h.Height)
+		other, ok := first[key]
+		if !ok {
+			first[key] = h
+			continue
+		}
+		if reported[key] || bc.State.Slashes[key] != nil {
+			continue
+		}
+		a, b := other, h
+		if bytes.Compare(a.Hash, b.Hash) > 0 {
+			a, b = b, a
+		}
+		found = append(found, &DoubleSignEvidence{A: a, B: b})
+		reported[key] = true
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].A.Height != found[j].A.Height {
+			return found[i].A.Height < found[j].A.Height
+		}
+		return bytes.Compare(found[i].A.Signer, found[j].A.Signer) < 0
+	})
+	return found
+}
@@ -0,0 +1,329 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+// posNetwork 返回一个 PoS 网络：验证者 A 质押 300、B 质押 100，alice 不是验证者，三者创世余额各 1000。
+// 解锁期 3 个区块，罚没 50%
+func posNetwork(t *testing.T) (*ChainParams, map[string]*ecdsa.PrivateKey, []string) {
+	t.Helper()
+	p := loadRegtest(t)
+	keys := make(map[string]*ecdsa.PrivateKey)
+	addrs := make([]string, 3)
+	for i := range addrs {
+		priv, pub, err := utils.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = utils.PubKeyToAddress(pub)
+		keys[addrs[i]] = priv
+	}
+	p.Genesis.Alloc = map[string]uint64{addrs[0]: 1000, addrs[1]: 1000, addrs[2]: 1000}
+	p.Consensus = ConsensusSpec{
+		Engine:          EnginePoS,
+		Stakes:          map[string]uint64{addrs[0]: 300, addrs[1]: 100},
+		MinStake:        50,
+		UnbondingBlocks: 3,
+		SlashPercent:    50,
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return p, keys, addrs
+}
+
+// posBlock 由被选中的验证者在 bc 的主链末尾出一个包含 txs 的区块，coinbase 付给 miner
+func posBlock(bc *Blockchain, keys map[string]*ecdsa.PrivateKey, txs ...Transaction) (Block, error) {
+	proposer, err := SelectProposer(bc.Params, bc.State, bc.LatestBlock().Header.Hash, bc.NextHeight())
+	if err != nil {
+		return Block{}, err
+	}
+	if err := bc.Engine.(*ProofOfStake).Authorize(keys[proposer]); err != nil {
+		return Block{}, err
+	}
+	height := bc.NextHeight()
+	coinbase := bc.Params.NewCoinbase(height, "miner", uint32(bc.Params.Monetary.Subsidy(height)+TotalFees(txs)))
+	return bc.AddBlock(append([]Transaction{coinbase}, txs...))
+}
+
+// stakeTx 返回一笔由 key 签名、手续费为 1 的质押相关交易
+func stakeTx(t *testing.T, key *ecdsa.PrivateKey, kind TxKind, to string, value uint32, nonce uint64, payload []byte) Transaction {
+	t.Helper()
+	pub, err := utils.ExportPubKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := Transaction{From: utils.PubKeyToAddress(pub), To: to, Value: value, Fee: 1, Nonce: nonce,
+		Kind: kind, Payload: payload, Timestamp: time.Unix(1700000000, 0)}
+	if err := tx.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// signedHeader 返回 key 在网络 network 上对高度 height 签名的区块头，sec 用来区分同一高度的不同区块
+func signedHeader(t *testing.T, key *ecdsa.PrivateKey, network uint32, prev []byte, height uint64, sec int64) *BlockHeader {
+	t.Helper()
+	signer, err := newBlockSigner(key, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &BlockHeader{Height: height, PreviousHash: prev, Timestamp: time.Unix(1700000000+sec, 0),
+		Bits: posWeight, Signer: signer.pub}
+	if err := signer.seal(&Block{Header: h}); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// doubleSign 返回 key 在网络 network 上、高度 height 签了两个不同区块的证据（编码后可作为罚没交易的 Payload）
+func doubleSign(t *testing.T, key *ecdsa.PrivateKey, network uint32, prev []byte, height uint64) []byte {
+	t.Helper()
+	ev := &DoubleSignEvidence{A: signedHeader(t, key, network, prev, height, 1), B: signedHeader(t, key, network, prev, height, 2)}
+	payload, err := ev.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestStakeUnstakeRelease(t *testing.T) {
+	p, keys, addrs := posNetwork(t)
+	b, alice := addrs[1], addrs[2]
+	bc := NewBlockchain(p)
+
+	// 高度 1：alice 替 B 质押 40，B 自己再质押 10，质押金额与手续费都从余额扣除
+	if _, err := posBlock(bc, keys,
+		stakeTx(t, keys[alice], TxStake, b, 40, 0, nil),
+		stakeTx(t, keys[b], TxStake, b, 10, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if bc.State.Stake(b) != 150 || bc.State.Stake(alice) != 0 {
+		t.Fatalf("stakes after staking: B %d, alice %d", bc.State.Stake(b), bc.State.Stake(alice))
+	}
+	if bc.GetBalance(alice) != 959 || bc.GetBalance(b) != 989 {
+		t.Fatalf("balances after staking: alice %d, B %d", bc.GetBalance(alice), bc.GetBalance(b))
+	}
+
+	// 只能取消自己名下的质押，且不超过已有质押
+	for name, tx := range map[string]Transaction{
+		"more than staked": stakeTx(t, keys[b], TxUnstake, "", 151, 1, nil),
+		"stake of another": stakeTx(t, keys[alice], TxUnstake, "", 1, 1, nil),
+	} {
+		if _, err := posBlock(bc, keys, tx); !errors.Is(err, ErrInsufficientStake) {
+			t.Fatalf("%s: err = %v, want ErrInsufficientStake", name, err)
+		}
+	}
+
+	// 高度 2：B 取消 60，转为解锁中，到高度 2+3 才回到余额
+	if _, err := posBlock(bc, keys, stakeTx(t, keys[b], TxUnstake, "", 60, 1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	for h := uint64(2); h <= 4; h++ {
+		if h > 2 {
+			if _, err := posBlock(bc, keys); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if bc.State.Stake(b) != 90 || bc.State.Unbonding[b] != 60 || bc.GetBalance(b) != 988 {
+			t.Fatalf("height %d: B has stake %d, unbonding %d, balance %d",
+				h, bc.State.Stake(b), bc.State.Unbonding[b], bc.GetBalance(b))
+		}
+	}
+	if _, err := posBlock(bc, keys); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bc.State.Unbonding[b]; ok || bc.GetBalance(b) != 1048 {
+		t.Fatalf("height 5: B has unbonding %d, balance %d", bc.State.Unbonding[b], bc.GetBalance(b))
+	}
+	if len(bc.State.releases) != 0 {
+		t.Fatalf("release schedule left: %v", bc.State.releases)
+	}
+}
+
+func TestSlashBurnsStakeAndUnbonding(t *testing.T) {
+	p, keys, addrs := posNetwork(t)
+	a, b, alice := addrs[0], addrs[1], addrs[2]
+	bc := NewBlockchain(p)
+	evidence := doubleSign(t, keys[b], p.NetworkID, bc.GenesisHash(), 1)
+
+	// 高度 1：B 取消 70 想要逃避罚没，质押剩 30，解锁中 70 到高度 4 回到余额
+	if _, err := posBlock(bc, keys, stakeTx(t, keys[b], TxUnstake, "", 70, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	before, err := bc.Supply(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 高度 2：alice 提交证据。销毁 (30+70)*50% = 50：先扣光质押 30，再从解锁中扣 20
+	if _, err := posBlock(bc, keys, stakeTx(t, keys[alice], TxSlash, "", 0, 0, evidence)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bc.State.Stakes[b]; ok || bc.State.Unbonding[b] != 50 {
+		t.Fatalf("after slash: B has stake %d, unbonding %d", bc.State.Stake(b), bc.State.Unbonding[b])
+	}
+	want := Slash{Validator: b, Height: 1, Amount: 50, SlashedAt: 2, Reporter: alice}
+	if s := bc.State.Slashes[SlashKey(b, 1)]; s == nil || *s != want {
+		t.Fatalf("slash record %+v, want %+v", s, want)
+	}
+	if v := bc.State.Validators(p); len(v) != 1 || v[0].Address != a {
+		t.Fatalf("validators after slash: %+v", v)
+	}
+	after, err := bc.Supply(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Burned != before.Burned+50 {
+		t.Fatalf("burned %d, want %d", after.Burned, before.Burned+50)
+	}
+
+	// 同一证据不能再用一次；A 在另一个网络上的双签也不能在本网络罚没
+	if _, err := posBlock(bc, keys, stakeTx(t, keys[alice], TxSlash, "", 0, 1,
+		doubleSign(t, keys[a], p.NetworkID+1, bc.GenesisHash(), 1))); !errors.Is(err, ErrBadEvidence) {
+		t.Fatalf("evidence from another network: err = %v, want ErrBadEvidence", err)
+	}
+	if _, err := posBlock(bc, keys, stakeTx(t, keys[alice], TxSlash, "", 0, 1, evidence)); !errors.Is(err, ErrAlreadySlashed) {
+		t.Fatalf("slashing twice: err = %v, want ErrAlreadySlashed", err)
+	}
+
+	// 高度 4：解锁中剩下的 50 照常回到余额
+	for i := 0; i < 2; i++ {
+		if _, err := posBlock(bc, keys); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := bc.State.Unbonding[b]; ok || bc.GetBalance(b) != 1049 {
+		t.Fatalf("height 4: B has unbonding %d, balance %d", bc.State.Unbonding[b], bc.GetBalance(b))
+	}
+}
+
+func TestDoubleSignEvidence(t *testing.T) {
+	p, keys, addrs := posNetwork(t)
+	a, b := keys[addrs[0]], keys[addrs[1]]
+	prev := utils.Sha256([]byte("parent"))
+	h1, h2 := signedHeader(t, b, p.NetworkID, prev, 5, 1), signedHeader(t, b, p.NetworkID, prev, 5, 2)
+
+	payload, err := (&DoubleSignEvidence{A: h1, B: h2}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev DoubleSignEvidence
+	if err := ev.UnmarshalBinary(payload); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ev.Verify(p); err != nil || v != addrs[1] {
+		t.Fatalf("decoded evidence: validator %s, err %v", v, err)
+	}
+
+	tampered := *h2
+	tampered.Timestamp = tampered.Timestamp.Add(time.Second)
+	tests := []struct {
+		name string
+		ev   DoubleSignEvidence
+	}{
+		{"identical headers", DoubleSignEvidence{A: h1, B: h1}},
+		{"different heights", DoubleSignEvidence{A: h1, B: signedHeader(t, b, p.NetworkID, prev, 6, 2)}},
+		{"different signers", DoubleSignEvidence{A: h1, B: signedHeader(t, a, p.NetworkID, prev, 5, 2)}},
+		{"hash mismatch", DoubleSignEvidence{A: h1, B: &tampered}},
+		{"missing header", DoubleSignEvidence{A: h1}},
+		// 同一把私钥在另一个网络上签的两个区块头：签名绑定 networkId，在本网络不成立
+		{"other network", DoubleSignEvidence{A: signedHeader(t, b, p.NetworkID+1, prev, 5, 1), B: signedHeader(t, b, p.NetworkID+1, prev, 5, 2)}},
+		{"one header from another network", DoubleSignEvidence{A: h1, B: signedHeader(t, b, p.NetworkID+1, prev, 5, 2)}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.ev.Verify(p); !errors.Is(err, ErrBadEvidence) {
+			t.Errorf("%s: err = %v, want ErrBadEvidence", tt.name, err)
+		}
+	}
+
+	// 交易格式：罚没不带收款方与金额，质押要有验证者，取消质押不带收款方；PoW 链上不能质押
+	same, err := (&DoubleSignEvidence{A: h1, B: h1}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pow := loadRegtest(t)
+	formats := []struct {
+		name string
+		p    *ChainParams
+		tx   Transaction
+		want error
+	}{
+		{"slash with value", p, stakeTx(t, a, TxSlash, "", 1, 0, payload), ErrBadStakeTx},
+		{"slash of one block", p, stakeTx(t, a, TxSlash, "", 0, 0, same), ErrBadEvidence},
+		{"slash with garbage", p, stakeTx(t, a, TxSlash, "", 0, 0, []byte{1, 2, 3}), ErrBadEvidence},
+		{"stake without validator", p, stakeTx(t, a, TxStake, "", 1, 0, nil), ErrBadStakeTx},
+		{"stake with gas", p, func() Transaction {
+			tx := stakeTx(t, a, TxStake, addrs[1], 1, 0, nil)
+			tx.GasLimit = 1
+			return tx
+		}(), ErrBadStakeTx},
+		{"unstake with recipient", p, stakeTx(t, a, TxUnstake, addrs[1], 1, 0, nil), ErrBadStakeTx},
+		{"unstake nothing", p, stakeTx(t, a, TxUnstake, "", 0, 0, nil), ErrBadStakeTx},
+		{"stake on proof of work", pow, stakeTx(t, a, TxStake, addrs[1], 1, 0, nil), ErrStakingDisabled},
+	}
+	for _, tt := range formats {
+		if err := checkStakeTx(tt.p, &tt.tx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestStakingUndoOnReorg(t *testing.T) {
+	p, keys, addrs := posNetwork(t)
+	b, alice := addrs[1], addrs[2]
+	bc := NewBlockchain(p)
+
+	// 主链：质押、取消质押、罚没都在上面
+	if _, err := posBlock(bc, keys,
+		stakeTx(t, keys[alice], TxStake, b, 40, 0, nil),
+		stakeTx(t, keys[b], TxUnstake, "", 70, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := posBlock(bc, keys,
+		stakeTx(t, keys[alice], TxSlash, "", 0, 1, doubleSign(t, keys[b], p.NetworkID, bc.GenesisHash(), 1))); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.State.Slashes) != 1 || len(bc.State.Unbonding) != 1 {
+		t.Fatalf("main chain: slashes %v, unbonding %v", bc.State.Slashes, bc.State.Unbonding)
+	}
+
+	// 从创世块分出的更长的空链：切换之后质押、解锁计划与罚没全部撤销
+	fork := NewBlockchain(p)
+	var blocks []Block
+	for i := 0; i < 3; i++ {
+		blk, err := posBlock(fork, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, blk)
+	}
+	processAll(t, bc, blocks)
+	if !bytes.Equal(bc.LatestBlock().Header.Hash, fork.LatestBlock().Header.Hash) {
+		t.Fatal("did not switch to the longer fork")
+	}
+	if bc.State.Stake(b) != 100 || len(bc.State.Unbonding) != 0 || len(bc.State.Slashes) != 0 || len(bc.State.releases) != 0 {
+		t.Fatalf("after reorg: B stake %d, unbonding %v, slashes %v, releases %v",
+			bc.State.Stake(b), bc.State.Unbonding, bc.State.Slashes, bc.State.releases)
+	}
+	if bc.GetBalance(alice) != 1000 || bc.GetBalance(b) != 1000 {
+		t.Fatalf("after reorg: alice %d, B %d", bc.GetBalance(alice), bc.GetBalance(b))
+	}
+	if !bytes.Equal(bc.State.Root(), fork.State.Root()) {
+		t.Fatal("state root differs from the fork's")
+	}
+	s, err := bc.Supply(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Burned != 0 {
+		t.Fatalf("burned %d after the slash was undone", s.Burned)
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+// State 表示执行完某个区块之后的链上状态。
+// 所有修改都通过方法进行，并记录到 journal 中，以便在区块回滚（重组）
+// 或校验失败时把状态恢复原样。
+type State struct {
+	Balances map[string]int64
+	Nonces   map[string]uint64 // 账户下一笔交易应使用的 nonce（即已上链的交易数）
+	UTXOs    map[string]UTXO   // UTXO 模式下尚未花费的输出，键见 OutPoint
+	Height   uint64            // 当前正在执行 / 最后执行的区块高度
+	Locked   map[string]int64  // 账户模式下尚未成熟的 coinbase 金额（包含在余额中，但不能花费）
+
+	Code     map[string][]byte            // 合约地址 → 合约代码
+	Storage  map[string]map[string][]byte // 合约地址 → 键 → 值
+	Receipts map[string]*Receipt          // 合约交易哈希（hex）→ 执行结果，不计入状态根
+
+	Tokens        map[string]*Token           // 资产 ID → 代币信息
+	TokenBalances map[string]map[string]int64 // 资产 ID → 地址 → 代币余额（不含 0）
+
+	Stakes    map[string]uint64 // PoS：地址 → 质押（不含 0），见 stake.go
+	Unbonding map[string]uint64 // PoS：地址 → 取消质押后尚未回到余额的金额
+	Slashes   map[string]*Slash // PoS：SlashKey → 罚没记录
+
+	Issued uint64 // 累计发行量：创世分配加上已执行区块的区块奖励，不计入状态根（见 monetary.go）
+	Burned uint64 // 累计销毁量：转入 BurnAddress 的金额加上罚没的金额，不计入状态根
+
+	unlocks  map[uint64]map[string]int64  // 区块高度 → 到该高度时解锁的 coinbase 金额
+	releases map[uint64]map[string]uint64 // 区块高度 → 到该高度时回到余额的解锁中金额
+
+	journal []func() // 每次修改前记录一个“恢复原值”的闭包
+
+	tree  *smtNode                 // 缓存的状态树（见 statetree.go）
+	dirty map[string]func() []byte // 修改过、还没写回 tree 的状态键 → 计算当前值哈希
+}
+
+// Undo 是连接一个区块时产生的回滚日志，Revert 会按相反顺序撤销所有修改
+type Undo []func()
+
+// Revert 撤销日志中记录的全部修改
+func (u Undo) Revert() {
+	for i := len(u) - 1; i >= 0; i-- {
+		u[i]()
+	}
+}
+
+// NewState 创建一个空状态
+func NewState() *State {
+	return &State{
+		Balances: make(map[string]int64),
+		Nonces:   make(map[string]uint64),
+		UTXOs:    make(map[string]UTXO),
+		Locked:   make(map[string]int64),
+		Code:     make(map[string][]byte),
+		Storage:  make(map[string]map[string][]byte),
+		Receipts: make(map[string]*Receipt),
+		unlocks:  make(map[uint64]map[string]int64),
+		releases: make(map[uint64]map[string]uint64),
+
+		Tokens:        make(map[string]*Token),
+		TokenBalances: make(map[string]map[string]int64),
+
+		Stakes:    make(map[string]uint64),
+		Unbonding: make(map[string]uint64),
+		Slashes:   make(map[string]*Slash),
+
+		dirty: make(map[string]func() []byte),
+	}
+}
+
+// Balance 返回某个地址的余额
+func (st *State) Balance(addr string) int64 {
+	return st.Balances[addr]
+}
+
+// AddBalance 给地址加上 delta（可以为负）
+func (st *State) AddBalance(addr string, delta int64) {
+	prev, existed := st.Balances[addr]
+	st.journal = append(st.journal, func() {
+		if existed {
+			st.Balances[addr] = prev
+		} else {
+			delete(st.Balances, addr)
+		}
+	})
+	st.Balances[addr] = prev + delta
+	st.touchAccount(addr)
+	if addr == BurnAddress && delta > 0 {
+		st.addSupply(0, uint64(delta)) // 销毁地址没有私钥，转入的币不会再转出
+	}
+}
+
+// Nonce 返回某个地址下一笔交易应使用的 nonce
+func (st *State) Nonce(addr string) uint64 {
+	return st.Nonces[addr]
+}
+
+// IncNonce 把地址的 nonce 加一
+func (st *State) IncNonce(addr string) {
+	prev, existed := st.Nonces[addr]
+	st.journal = append(st.journal, func() {
+		if existed {
+			st.Nonces[addr] = prev
+		} else {
+			delete(st.Nonces, addr)
+		}
+	})
+	st.Nonces[addr] = prev + 1
+	st.touchAccount(addr)
+}
+
+// Spendable 返回地址可以花费的余额（余额减去尚未成熟的 coinbase）
+func (st *State) Spendable(addr string) int64 {
+	return st.Balances[addr] - st.Locked[addr]
+}
+
+// beginBlock 开始执行高度为 height 的区块：记录高度，把到期的解锁中质押转回余额，并解锁在该高度成熟的 coinbase
+func (st *State) beginBlock(height uint64) {
+	prevHeight := st.Height
+	st.journal = append(st.journal, func() {
+		st.Height = prevHeight
+	})
+	st.Height = height
+	st.releaseUnbonding(height)
+
+	due, ok := st.unlocks[height]
+	if !ok {
+		return
+	}
+	for addr, amount := range due {
+		st.addLocked(addr, -amount)
+	}
+	st.journal = append(st.journal, func() {
+		st.unlocks[height] = due
+	})
+	delete(st.unlocks, height)
+}
+
+// lockCoinbase 把 addr 收到的 coinbase 金额锁定到 unlockHeight
+func (st *State) lockCoinbase(addr string, amount int64, unlockHeight uint64) {
+	st.addLocked(addr, amount)
+
+	due, existed := st.unlocks[unlockHeight]
+	prev := due[addr]
+	st.journal = append(st.journal, func() {
+		if !existed {
+			delete(st.unlocks, unlockHeight)
+		} else if prev == 0 {
+			delete(due, addr)
+		} else {
+			due[addr] = prev
+		}
+	})
+	if !existed {
+		due = make(map[string]int64)
+		st.unlocks[unlockHeight] = due
+	}
+	due[addr] = prev + amount
+}
+
+func (st *State) addLocked(addr string, delta int64) {
+	prev, existed := st.Locked[addr]
+	st.journal = append(st.journal, func() {
+		if existed {
+			st.Locked[addr] = prev
+		} else {
+			delete(st.Locked, addr)
+		}
+	})
+	if prev+delta == 0 {
+		delete(st.Locked, addr)
+	} else {
+		st.Locked[addr] = prev + delta
+	}
+}
+
+// Snapshot 返回当前日志位置，之后可用 RevertToSnapshot 回到这里
+func (st *State) Snapshot() int {
+	return len(st.journal)
+}
+
+// RevertToSnapshot 撤销 Snapshot 之后的所有修改
+func (st *State) RevertToSnapshot(id int) {
+	Undo(st.journal[id:]).Revert()
+	st.journal = st.journal[:id]
+}
+
+// commit 把 Snapshot 之后的修改打包成 Undo 交给调用方保管，并从日志中移除
+func (st *State) commit(id int) Undo {
+	undo := make(Undo, len(st.journal)-id)
+	copy(undo, st.journal[id:])
+	st.journal = st.journal[:id]
+	return undo
+}
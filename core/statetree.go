@@ -0,0 +1,272 @@
+package core
+
+import (
+	"fmt"
+
+	"mychain/utils"
+)
+
+// 状态树：把账户（余额 + nonce）、UTXO、合约的代码和存储、代币以及质押放进稀疏 Merkle 树（见 smt.go），
+// 树根写入区块头的 StateRoot。键加了前缀区分类型，避免不同类型的键冲突：
+//   - 账户：SHA256("account:" + 地址)，值 = u8 版本 | i64 余额 | u64 nonce
+//   - UTXO：SHA256("utxo:" + OutPoint)，值 = u8 版本 | bytes 交易哈希 | u32 序号 | bytes 地址 | u32 金额
+//     | u64 高度 | u8 是否 coinbase
+//   - 合约代码：SHA256("code:" + 地址)，值 = u8 版本 | bytes 代码
+//   - 合约存储：SHA256("storage:" + 地址 + ":" + hex(键))，值 = u8 版本 | bytes 值
+//   - 代币：SHA256("token:" + 资产 ID)，值 = u8 版本 | bytes 发行者 | u64 高度 | bytes 符号 | u8 小数位数 | u64 发行量
+//   - 代币余额：SHA256("token-balance:" + 资产 ID + ":" + 地址)，值 = u8 版本 | i64 余额
+//   - 质押：SHA256("stake:" + 地址)，值 = u8 版本 | u64 质押 | u64 解锁中
+//   - 罚没记录：SHA256("slash:" + SlashKey)，值 = u8 版本 | bytes 验证者 | u64 高度 | u64 金额 | u64 罚没高度 | bytes 提交者
+//
+// 余额与 nonce 都为 0 的账户视为不存在，不进入树中。
+
+// AccountKey 返回账户在状态树中的键
+func AccountKey(addr string) []byte {
+	return utils.Sha256([]byte("account:" + addr))
+}
+
+// UTXOKey 返回 UTXO 在状态树中的键
+func UTXOKey(outPoint string) []byte {
+	return utils.Sha256([]byte("utxo:" + outPoint))
+}
+
+// CodeKey 返回合约代码在状态树中的键
+func CodeKey(addr string) []byte {
+	return utils.Sha256([]byte("code:" + addr))
+}
+
+// StorageKey 返回合约存储项在状态树中的键
+func StorageKey(addr string, key []byte) []byte {
+	return utils.Sha256([]byte("storage:" + addr + ":" + utils.ToHex(key)))
+}
+
+// TokenKey 返回代币信息在状态树中的键
+func TokenKey(asset string) []byte {
+	return utils.Sha256([]byte("token:" + asset))
+}
+
+// TokenBalanceKey 返回代币余额在状态树中的键
+func TokenBalanceKey(asset, addr string) []byte {
+	return utils.Sha256([]byte("token-balance:" + asset + ":" + addr))
+}
+
+// StakeKey 返回地址的质押在状态树中的键
+func StakeKey(addr string) []byte {
+	return utils.Sha256([]byte("stake:" + addr))
+}
+
+// SlashTreeKey 返回罚没记录在状态树中的键
+func SlashTreeKey(key string) []byte {
+	return utils.Sha256([]byte("slash:" + key))
+}
+
+// AccountValueHash 返回账户叶子的值哈希
+func AccountValueHash(balance int64, nonce uint64) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.i64(balance)
+	e.u64(nonce)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// bytesValueHash 返回合约代码 / 存储叶子的值哈希
+func bytesValueHash(v []byte) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.bytes(v)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+func tokenValueHash(t *Token) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.string(t.Issuer)
+	e.u64(t.Height)
+	e.string(t.Symbol)
+	e.u8(t.Decimals)
+	e.u64(t.Supply)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// tokenBalanceValueHash 返回代币余额叶子的值哈希
+func tokenBalanceValueHash(balance int64) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.i64(balance)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+func stakeValueHash(stake, unbonding uint64) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.u64(stake)
+	e.u64(unbonding)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+func slashValueHash(s *Slash) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.string(s.Validator)
+	e.u64(s.Height)
+	e.u64(s.Amount)
+	e.u64(s.SlashedAt)
+	e.string(s.Reporter)
+	return utils.Sha256(e.buf.Bytes())
+}
+
+func utxoValueHash(u UTXO) []byte {
+	var e encoder
+	e.u8(EncodingVersion)
+	e.bytes(u.TxHash)
+	e.u32(u.Index)
+	e.string(u.To)
+	e.u32(u.Value)
+	e.u64(u.Height)
+	if u.Coinbase {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+	return utils.Sha256(e.buf.Bytes())
+}
+
+// 状态树不在每次取根时从头构建：State 在 tree 中缓存整棵树，修改状态的方法用 touch 记下改动的键，
+// Root 只把这些键的叶子写回树中，重算它们到根的路径。撤销修改（回滚、重组）时同样会记下这些键。
+
+// touch 记下状态键 key 的叶子需要更新：取根时调用 value 得到它当前的值哈希（nil 表示叶子已不存在）。
+// 日志中也记一笔，撤销修改时同样会重新计算这个叶子
+func (st *State) touch(key []byte, value func() []byte) {
+	k := string(key)
+	st.dirty[k] = value
+	st.journal = append(st.journal, func() {
+		st.dirty[k] = value
+	})
+}
+
+// touchAccount 记下账户叶子需要更新（余额与 nonce 都为 0 的账户不在树中）
+func (st *State) touchAccount(addr string) {
+	st.touch(AccountKey(addr), func() []byte {
+		if st.Balance(addr) == 0 && st.Nonce(addr) == 0 {
+			return nil
+		}
+		return AccountValueHash(st.Balance(addr), st.Nonce(addr))
+	})
+}
+
+func (st *State) touchUTXO(key string) {
+	st.touch(UTXOKey(key), func() []byte {
+		if u, ok := st.UTXOs[key]; ok {
+			return utxoValueHash(u)
+		}
+		return nil
+	})
+}
+
+func (st *State) touchCode(addr string) {
+	st.touch(CodeKey(addr), func() []byte {
+		if code, ok := st.Code[addr]; ok {
+			return bytesValueHash(code)
+		}
+		return nil
+	})
+}
+
+func (st *State) touchStorage(addr string, key []byte) {
+	st.touch(StorageKey(addr, key), func() []byte {
+		if v, ok := st.Storage[addr][string(key)]; ok {
+			return bytesValueHash(v)
+		}
+		return nil
+	})
+}
+
+func (st *State) touchToken(asset string) {
+	st.touch(TokenKey(asset), func() []byte {
+		if t, ok := st.Tokens[asset]; ok {
+			return tokenValueHash(t)
+		}
+		return nil
+	})
+}
+
+func (st *State) touchTokenBalance(asset, addr string) {
+	st.touch(TokenBalanceKey(asset, addr), func() []byte {
+		if bal, ok := st.TokenBalances[asset][addr]; ok {
+			return tokenBalanceValueHash(bal)
+		}
+		return nil
+	})
+}
+
+// touchStake 记下质押叶子需要更新（质押与解锁中金额都为 0 的地址不在树中）
+func (st *State) touchStake(addr string) {
+	st.touch(StakeKey(addr), func() []byte {
+		_, staked := st.Stakes[addr]
+		_, unbonding := st.Unbonding[addr]
+		if !staked && !unbonding {
+			return nil
+		}
+		return stakeValueHash(st.Stakes[addr], st.Unbonding[addr])
+	})
+}
+
+func (st *State) touchSlash(key string) {
+	st.touch(SlashTreeKey(key), func() []byte {
+		if s, ok := st.Slashes[key]; ok {
+			return slashValueHash(s)
+		}
+		return nil
+	})
+}
+
+// flushTree 把 touch 记下的叶子写回缓存的树中
+func (st *State) flushTree() {
+	for k, value := range st.dirty {
+		key := []byte(k)
+		if v := value(); v != nil {
+			st.tree = smtInsert(st.tree, &smtLeaf{key: key, value: v, hash: smtLeafHash(key, v)}, 0)
+		} else {
+			st.tree = smtDelete(st.tree, key, 0)
+		}
+	}
+	clear(st.dirty)
+}
+
+// Root 返回当前状态树的根，写入区块头的 StateRoot。
+// 只与状态内容有关，执行历史不同但内容相同的状态得到同一个根。
+func (st *State) Root() []byte {
+	st.flushTree()
+	return st.tree.sum()
+}
+
+// AccountProof 证明某个账户在某个状态根下的余额与 nonce
+type AccountProof struct {
+	Address string   `json:"address"`
+	Balance int64    `json:"balance"`
+	Nonce   uint64   `json:"nonce"`
+	Proof   SMTProof `json:"proof"`
+}
+
+// ProveAccount 生成账户的状态证明（账户不存在时生成余额、nonce 为 0 的不存在证明）
+func (st *State) ProveAccount(addr string) *AccountProof {
+	st.flushTree()
+	return &AccountProof{
+		Address: addr,
+		Balance: st.Balance(addr),
+		Nonce:   st.Nonce(addr),
+		Proof:   smtProve(st.tree, AccountKey(addr)),
+	}
+}
+
+// VerifyAccountProof 用状态根（通常来自已校验的区块头）检查账户证明
+func VerifyAccountProof(p *AccountProof, stateRoot []byte) error {
+	var valueHash []byte
+	if p.Balance != 0 || p.Nonce != 0 {
+		valueHash = AccountValueHash(p.Balance, p.Nonce)
+	}
+	if !VerifySMTProof(stateRoot, AccountKey(p.Address), valueHash, &p.Proof) {
+		return fmt.Errorf("%w: account %s", ErrBadStateProof, p.Address)
+	}
+	return nil
+}
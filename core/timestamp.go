@@ -0,0 +1,128 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 区块时间戳相关的共识参数
+const (
+	// MedianTimeBlocks 计算“过去中位时间”（median time past）时取最近多少个区块
+	MedianTimeBlocks = 11
+
+	// MaxFutureBlockTime 区块时间戳最多可以比本节点的网络调整时间超前多少
+	MaxFutureBlockTime = 2 * time.Minute
+
+	// MaxClockAdjustment 网络调整时间最多在本地时钟基础上修正多少，
+	// 邻居时间偏差的中位数超过它时认为是本地时钟坏了或邻居在作恶，不做修正
+	MaxClockAdjustment = time.Minute
+
+	// MaxClockSamples 网络调整时间最多记录多少个邻居的时间偏差，记满后不再接受新邻居的样本
+	MaxClockSamples = 200
+)
+
+var (
+	ErrTimeTooOld = errors.New("block timestamp not after median time past")
+	ErrTimeTooNew = errors.New("block timestamp too far in the future")
+)
+
+// MedianTimePast 返回 headers（按高度升序，最后一个是父区块）中最近 MedianTimeBlocks 个区块时间戳的中位数。
+// 新区块的时间戳必须严格大于它：单个矿工无法把时间往回拨，同时允许各节点时钟有少量误差。
+func MedianTimePast(headers []*BlockHeader) time.Time {
+	if len(headers) > MedianTimeBlocks {
+		headers = headers[len(headers)-MedianTimeBlocks:]
+	}
+	if len(headers) == 0 {
+		return time.Time{}
+	}
+	times := make([]time.Time, len(headers))
+	for i, h := range headers {
+		times[i] = h.Timestamp
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times[len(times)/2]
+}
+
+// medianTimePast 沿区块树往回取 n 及其祖先计算过去中位时间
+func medianTimePast(n *blockNode) time.Time {
+	headers := make([]*BlockHeader, 0, MedianTimeBlocks)
+	for ; n != nil && len(headers) < MedianTimeBlocks; n = n.parent {
+		headers = append(headers, n.block.Header)
+	}
+	return MedianTimePast(headers)
+}
+
+// MedianTimePast 返回主链末端的过去中位时间，即下一个区块判断时间锁定交易是否解锁所用的时间
+func (bc *Blockchain) MedianTimePast() time.Time {
+	return medianTimePast(bc.tip)
+}
+
+// NetworkClock 是网络调整时间：本地时钟加上各邻居握手时报告的时间偏差的中位数，
+// 这样本地时钟略有偏差的节点仍能与网络对“现在”达成一致
+type NetworkClock struct {
+	mu      sync.Mutex
+	offsets map[string]time.Duration // 邻居主机（IP 或域名）→ 邻居时间 - 本地时间
+}
+
+// NewNetworkClock 创建一个还没有任何邻居样本的时钟（此时等于本地时钟）
+func NewNetworkClock() *NetworkClock {
+	return &NetworkClock{offsets: make(map[string]time.Duration)}
+}
+
+// AddSample 记录邻居主机 host 的时间偏差，同一台主机只保留最新一次（同一主机上的多个节点只算一个样本）。
+// 已记满 MaxClockSamples 台主机时忽略新主机的样本，返回是否记录
+func (c *NetworkClock) AddSample(host string, offset time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.offsets[host]; !ok && len(c.offsets) >= MaxClockSamples {
+		return false
+	}
+	c.offsets[host] = offset
+	return true
+}
+
+// Offset 返回当前对本地时钟的修正量：本节点（偏差 0）与所有邻居偏差的中位数，
+// 超过 MaxClockAdjustment 时不修正
+func (c *NetworkClock) Offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.offsets) == 0 {
+		return 0
+	}
+	all := []time.Duration{0}
+	for _, off := range c.offsets {
+		all = append(all, off)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	// 偶数个样本取中间两个的平均值，避免偏向某一侧
+	mid := len(all) / 2
+	median := all[mid]
+	if len(all)%2 == 0 {
+		median = (all[mid-1] + all[mid]) / 2
+	}
+	if median > MaxClockAdjustment || median < -MaxClockAdjustment {
+		return 0
+	}
+	return median
+}
+
+// Samples 返回已记录的邻居时间偏差
+func (c *NetworkClock) Samples() map[string]time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]time.Duration, len(c.offsets))
+	for peer, off := range c.offsets {
+		out[peer] = off
+	}
+	return out
+}
+
+// Now 返回网络调整时间
+func (c *NetworkClock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianTimePast(t *testing.T) {
+	at := func(secs ...int64) []*BlockHeader {
+		headers := make([]*BlockHeader, len(secs))
+		for i, s := range secs {
+			headers[i] = &BlockHeader{Timestamp: time.Unix(s, 0)}
+		}
+		return headers
+	}
+	tests := []struct {
+		name    string
+		headers []*BlockHeader
+		want    int64
+	}{
+		{"single block", at(10), 10},
+		{"odd count", at(10, 30, 20), 20},
+		{"even count takes the upper middle", at(10, 40, 20, 30), 30},
+		{"out of order timestamps", at(50, 10, 40, 20, 30), 30},
+		// 只看最近 11 个区块：前面的 1000 不参与
+		{"window of 11", at(1000, 1000, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11), 6},
+	}
+	for _, tt := range tests {
+		if got := MedianTimePast(tt.headers); !got.Equal(time.Unix(tt.want, 0)) {
+			t.Errorf("%s: MedianTimePast = %d, want %d", tt.name, got.Unix(), tt.want)
+		}
+	}
+	if got := MedianTimePast(nil); !got.IsZero() {
+		t.Fatalf("MedianTimePast(nil) = %v, want zero", got)
+	}
+}
+
+func TestNetworkClockOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    time.Duration
+	}{
+		{"no peers", nil, 0},
+		// 本节点自己算一个偏差为 0 的样本
+		{"one peer", []time.Duration{10 * time.Second}, 5 * time.Second},
+		{"median of peers", []time.Duration{-5 * time.Second, 20 * time.Second, 30 * time.Second}, 10 * time.Second},
+		{"outlier ignored", []time.Duration{2 * time.Second, 3 * time.Second, time.Hour}, 2500 * time.Millisecond},
+		{"beyond the limit", []time.Duration{2 * time.Minute, 3 * time.Minute}, 0},
+		{"at the limit", []time.Duration{MaxClockAdjustment, MaxClockAdjustment}, MaxClockAdjustment},
+	}
+	for _, tt := range tests {
+		c := NewNetworkClock()
+		for i, off := range tt.samples {
+			c.AddSample(string(rune('a'+i)), off)
+		}
+		if got := c.Offset(); got != tt.want {
+			t.Errorf("%s: Offset = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 同一个邻居只保留最新样本
+	c := NewNetworkClock()
+	c.AddSample("peer", time.Hour)
+	c.AddSample("peer", 20*time.Second)
+	if got := c.Offset(); got != 10*time.Second {
+		t.Fatalf("Offset after replacing a sample = %v, want 10s", got)
+	}
+}
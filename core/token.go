@@ -0,0 +1,219 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"mychain/utils"
+)
+
+// 代币：任何账户都可以发行自己的同质化代币，之后像原生币一样转账。
+// 手续费总是用原生币支付；UTXO 模式不支持代币。
+
+// 代币参数限制
+const (
+	MaxTokenSymbol   = 12 // 代币符号最多多少个字符
+	MaxTokenDecimals = 18 // 最多多少位小数
+)
+
+var (
+	ErrBadTokenTx    = errors.New("malformed token transaction")
+	ErrUnknownToken  = errors.New("unknown token")
+	ErrTokenOverdraw = errors.New("insufficient token balance")
+)
+
+// TokenSpec 是发行交易中给出的代币参数
+type TokenSpec struct {
+	Symbol   string `json:"symbol"`   // 代币符号，1~12 个大写字母或数字
+	Decimals uint8  `json:"decimals"` // 小数位数，只影响显示，链上金额都是最小单位的整数
+	Supply   uint64 `json:"supply"`   // 发行总量（最小单位），全部归发行者
+}
+
+// Token 是链上已发行的代币
+type Token struct {
+	ID     string `json:"id"`     // 资产 ID，见 AssetID
+	Issuer string `json:"issuer"` // 发行者地址
+	Height uint64 `json:"height"` // 发行交易所在的区块高度
+	TokenSpec
+}
+
+// TokenHolding 是某个地址持有的一种代币
+type TokenHolding struct {
+	Asset    string `json:"asset"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Balance  int64  `json:"balance"`
+}
+
+// AssetID 返回 issuer 用 nonce 发行的代币的资产 ID：SHA256("asset" | bytes issuer | u64 nonce)
+func AssetID(issuer string, nonce uint64) string {
+	var e encoder
+	e.buf.WriteString("asset")
+	e.string(issuer)
+	e.u64(nonce)
+	return utils.ToHex(utils.Sha256(e.buf.Bytes()))
+}
+
+// NativeValue 返回交易转移的原生币金额：代币转账的 Value 是代币金额，取消质押的 Value 是质押金额，
+// 都不动用原生币
+func (tx *Transaction) NativeValue() uint64 {
+	if tx.Asset != "" || tx.Kind == TxUnstake {
+		return 0
+	}
+	return uint64(tx.Value)
+}
+
+// checkTokenFields 检查交易中与代币相关的字段：
+// 只有发行交易带 Token，只有普通转账可以带 Asset（且不能是 coinbase）
+func checkTokenFields(tx *Transaction) error {
+	if tx.Kind != TxIssue && tx.Token != nil {
+		return fmt.Errorf("%w: token spec outside an issue transaction", ErrBadTokenTx)
+	}
+	if tx.Asset == "" {
+		return nil
+	}
+	if tx.Kind != TxTransfer {
+		return fmt.Errorf("%w: only transfers can carry an asset", ErrBadTokenTx)
+	}
+	if tx.IsCoinbase() || tx.From == "" {
+		return fmt.Errorf("%w: coinbase cannot carry an asset", ErrBadTokenTx)
+	}
+	if tx.To == "" || tx.Value == 0 {
+		return fmt.Errorf("%w: token transfer needs a recipient and an amount", ErrBadTokenTx)
+	}
+	return nil
+}
+
+// checkIssueTx 检查发行交易：有发送方，不带收款方、金额、代码和 gas，代币参数合法
+func checkIssueTx(tx *Transaction) error {
+	if tx.IsCoinbase() || tx.From == "" {
+		return fmt.Errorf("%w: issue needs a sender", ErrBadTokenTx)
+	}
+	if tx.To != "" || tx.Value != 0 || len(tx.Payload) != 0 || tx.GasLimit != 0 {
+		return fmt.Errorf("%w: issue carries to/value/payload/gas", ErrBadTokenTx)
+	}
+	t := tx.Token
+	if t == nil {
+		return fmt.Errorf("%w: issue without token spec", ErrBadTokenTx)
+	}
+	if len(t.Symbol) == 0 || len(t.Symbol) > MaxTokenSymbol {
+		return fmt.Errorf("%w: symbol must be 1-%d characters", ErrBadTokenTx, MaxTokenSymbol)
+	}
+	for _, c := range t.Symbol {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return fmt.Errorf("%w: symbol %q must be upper-case letters or digits", ErrBadTokenTx, t.Symbol)
+		}
+	}
+	if t.Decimals > MaxTokenDecimals {
+		return fmt.Errorf("%w: %d decimals (max %d)", ErrBadTokenTx, t.Decimals, MaxTokenDecimals)
+	}
+	if t.Supply == 0 || t.Supply > math.MaxInt64 {
+		return fmt.Errorf("%w: supply must be between 1 and %d", ErrBadTokenTx, int64(math.MaxInt64))
+	}
+	return nil
+}
+
+// applyIssueTx 登记新代币，全部发行量归发行者。调用方已经扣除了手续费并把 nonce 加一，
+// 资产 ID 由发行者和发行交易的 nonce 决定，不会重复
+func applyIssueTx(st *State, tx *Transaction) {
+	t := &Token{
+		ID:        AssetID(tx.From, tx.Nonce),
+		Issuer:    tx.From,
+		Height:    st.Height,
+		TokenSpec: *tx.Token,
+	}
+	st.setToken(t)
+	st.AddTokenBalance(t.ID, tx.From, int64(t.Supply))
+}
+
+// checkTokenTransfer 检查代币存在且发送方余额足够，在扣除手续费之前调用，失败时状态不变
+func checkTokenTransfer(st *State, tx *Transaction) error {
+	if st.Tokens[tx.Asset] == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownToken, tx.Asset)
+	}
+	if have := st.TokenBalance(tx.Asset, tx.From); have < int64(tx.Value) {
+		return fmt.Errorf("%w: account %s has %d of %s, spends %d",
+			ErrTokenOverdraw, tx.From, have, tx.Asset, tx.Value)
+	}
+	return nil
+}
+
+// applyTokenTransfer 转移代币（已经由 checkTokenTransfer 检查过）
+func applyTokenTransfer(st *State, tx *Transaction) {
+	amount := int64(tx.Value)
+	st.AddTokenBalance(tx.Asset, tx.From, -amount)
+	st.AddTokenBalance(tx.Asset, tx.To, amount)
+}
+
+// TokenBalance 返回地址持有的某种代币的数量
+func (st *State) TokenBalance(asset, addr string) int64 {
+	return st.TokenBalances[asset][addr]
+}
+
+// AddTokenBalance 给地址的代币余额加上 delta（可以为负），余额为 0 的项被删除
+func (st *State) AddTokenBalance(asset, addr string, delta int64) {
+	holders, existed := st.TokenBalances[asset]
+	prev, had := holders[addr]
+	st.journal = append(st.journal, func() {
+		if !existed {
+			delete(st.TokenBalances, asset)
+		} else if had {
+			holders[addr] = prev
+		} else {
+			delete(holders, addr)
+		}
+	})
+	if !existed {
+		holders = make(map[string]int64)
+		st.TokenBalances[asset] = holders
+	}
+	if v := prev + delta; v == 0 {
+		delete(holders, addr)
+	} else {
+		holders[addr] = v
+	}
+	st.touchTokenBalance(asset, addr)
+}
+
+func (st *State) setToken(t *Token) {
+	st.journal = append(st.journal, func() {
+		delete(st.Tokens, t.ID)
+	})
+	st.Tokens[t.ID] = t
+	st.touchToken(t.ID)
+}
+
+// TokenHoldings 返回地址持有的全部代币（按符号、资产 ID 排序）
+func (st *State) TokenHoldings(addr string) []TokenHolding {
+	holdings := []TokenHolding{}
+	for asset, holders := range st.TokenBalances {
+		if bal, ok := holders[addr]; ok {
+			t := st.Tokens[asset]
+			holdings = append(holdings, TokenHolding{Asset: asset, Symbol: t.Symbol, Decimals: t.Decimals, Balance: bal})
+		}
+	}
+	sort.Slice(holdings, func(i, j int) bool {
+		if holdings[i].Symbol != holdings[j].Symbol {
+			return holdings[i].Symbol < holdings[j].Symbol
+		}
+		return holdings[i].Asset < holdings[j].Asset
+	})
+	return holdings
+}
+
+// TokenList 返回链上全部代币（按发行高度、资产 ID 排序）
+func (st *State) TokenList() []*Token {
+	tokens := make([]*Token, 0, len(st.Tokens))
+	for _, t := range st.Tokens {
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Height != tokens[j].Height {
+			return tokens[i].Height < tokens[j].Height
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func issueTx(from string, nonce uint64, spec TokenSpec) *Transaction {
+	tx := &Transaction{From: from, Kind: TxIssue, Token: &spec, Fee: 1, Nonce: nonce, Timestamp: time.Unix(1700000000, 0)}
+	tx.CalculateHash()
+	return tx
+}
+
+func tokenTx(asset, from, to string, value uint32, nonce uint64) *Transaction {
+	tx := &Transaction{From: from, To: to, Asset: asset, Value: value, Fee: 1, Nonce: nonce, Timestamp: time.Unix(1700000000, 0)}
+	tx.CalculateHash()
+	return tx
+}
+
+func TestTokenIssueAndTransfer(t *testing.T) {
+	p := loadRegtest(t)
+	st := NewState()
+	st.AddBalance("alice", 100)
+
+	// apply 检查并执行一笔交易，返回撤销它的日志
+	apply := func(tx *Transaction) (Undo, error) {
+		t.Helper()
+		if err := checkTxKind(p, tx); err != nil {
+			t.Fatal(err)
+		}
+		mark := st.Snapshot()
+		if err := applyTx(p, st, tx); err != nil {
+			st.RevertToSnapshot(mark)
+			return nil, err
+		}
+		return st.commit(mark), nil
+	}
+
+	empty := st.Root()
+	undoIssue, err := apply(issueTx("alice", 0, TokenSpec{Symbol: "GOLD", Decimals: 2, Supply: 1000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gold := AssetID("alice", 0)
+	if tok := st.Tokens[gold]; tok == nil || tok.Issuer != "alice" || tok.Supply != 1000 {
+		t.Fatalf("issued token: %+v", tok)
+	}
+	// 发行只扣手续费
+	if st.TokenBalance(gold, "alice") != 1000 || st.Balance("alice") != 99 {
+		t.Fatalf("after issue: alice has %d GOLD and %d native", st.TokenBalance(gold, "alice"), st.Balance("alice"))
+	}
+	issued := st.Root()
+
+	// 余额不足与未知代币：报错且状态不变
+	if _, err := apply(tokenTx(gold, "alice", "bob", 1001, 1)); !errors.Is(err, ErrTokenOverdraw) {
+		t.Fatalf("overdraw: err = %v, want ErrTokenOverdraw", err)
+	}
+	if _, err := apply(tokenTx(AssetID("bob", 0), "alice", "bob", 1, 1)); !errors.Is(err, ErrUnknownToken) {
+		t.Fatalf("unknown token: err = %v, want ErrUnknownToken", err)
+	}
+	if !bytes.Equal(st.Root(), issued) || st.Nonce("alice") != 1 {
+		t.Fatal("failed token transfers changed the state")
+	}
+
+	// 全部转给 bob：alice 的 0 余额项被删除，原生币只扣手续费、bob 的原生币不变
+	undoTransfer, err := apply(tokenTx(gold, "alice", "bob", 1000, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.TokenBalances[gold]["alice"]; ok {
+		t.Fatal("zero token balance kept in state")
+	}
+	if st.TokenBalance(gold, "bob") != 1000 || st.Balance("bob") != 0 || st.Balance("alice") != 98 {
+		t.Fatalf("after transfer: bob has %d GOLD and %d native, alice %d native",
+			st.TokenBalance(gold, "bob"), st.Balance("bob"), st.Balance("alice"))
+	}
+	if h := st.TokenHoldings("alice"); len(h) != 0 {
+		t.Fatalf("alice still holds %+v", h)
+	}
+	if h := st.TokenHoldings("bob"); len(h) != 1 || h[0].Symbol != "GOLD" || h[0].Balance != 1000 {
+		t.Fatalf("bob holds %+v", h)
+	}
+
+	// 撤销转账：alice 的余额项恢复，bob 的被删除，状态根回到转账之前
+	undoTransfer.Revert()
+	if v, ok := st.TokenBalances[gold]["alice"]; !ok || v != 1000 {
+		t.Fatalf("alice's entry after undo: %d, %v", v, ok)
+	}
+	if _, ok := st.TokenBalances[gold]["bob"]; ok {
+		t.Fatal("bob's entry kept after undo")
+	}
+	if !bytes.Equal(st.Root(), issued) {
+		t.Fatal("state root differs after undoing the transfer")
+	}
+
+	// 撤销发行：代币与余额全部消失
+	undoIssue.Revert()
+	if len(st.Tokens) != 0 || len(st.TokenBalances) != 0 || !bytes.Equal(st.Root(), empty) {
+		t.Fatalf("after undoing the issue: tokens %v, balances %v", st.Tokens, st.TokenBalances)
+	}
+}
+
+func TestMempoolTokenSpends(t *testing.T) {
+	st := NewState()
+	st.AddBalance("alice", 100)
+	applyIssueTx(st, issueTx("alice", 0, TokenSpec{Symbol: "GOLD", Supply: 10}))
+	st.IncNonce("alice")
+	gold := AssetID("alice", 0)
+	mp := NewMempool(&DefaultParams)
+
+	// 排队中的同一代币的转账计入已花费的代币
+	if _, err := mp.Add(*tokenTx(gold, "alice", "bob", 6, 1), st); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(*tokenTx(gold, "alice", "bob", 5, 2), st); !errors.Is(err, ErrTokenOverdraw) {
+		t.Fatalf("pending token spends above balance: err = %v, want ErrTokenOverdraw", err)
+	}
+	if _, err := mp.Add(*tokenTx(gold, "alice", "bob", 4, 2), st); err != nil {
+		t.Fatalf("pending token spends at balance: %v", err)
+	}
+	if _, err := mp.Add(*tokenTx(AssetID("bob", 0), "alice", "bob", 1, 3), st); !errors.Is(err, ErrUnknownToken) {
+		t.Fatalf("unknown token: err = %v, want ErrUnknownToken", err)
+	}
+}
+
+func TestCheckTokenTx(t *testing.T) {
+	p := loadRegtest(t)
+	spec := TokenSpec{Symbol: "GOLD", Supply: 1}
+	withSpec := func(f func(*TokenSpec)) *Transaction {
+		s := spec
+		f(&s)
+		return issueTx("alice", 0, s)
+	}
+	withTx := func(tx *Transaction, f func(*Transaction)) *Transaction {
+		f(tx)
+		return tx
+	}
+	gold := AssetID("alice", 0)
+
+	tests := []struct {
+		name string
+		tx   *Transaction
+	}{
+		{"empty symbol", withSpec(func(s *TokenSpec) { s.Symbol = "" })},
+		{"long symbol", withSpec(func(s *TokenSpec) { s.Symbol = "ABCDEFGHIJKLM" })},
+		{"lower-case symbol", withSpec(func(s *TokenSpec) { s.Symbol = "gold" })},
+		{"too many decimals", withSpec(func(s *TokenSpec) { s.Decimals = MaxTokenDecimals + 1 })},
+		{"zero supply", withSpec(func(s *TokenSpec) { s.Supply = 0 })},
+		{"supply above int64", withSpec(func(s *TokenSpec) { s.Supply = math.MaxInt64 + 1 })},
+		{"issue without spec", withTx(issueTx("alice", 0, spec), func(tx *Transaction) { tx.Token = nil })},
+		{"issue with recipient", withTx(issueTx("alice", 0, spec), func(tx *Transaction) { tx.To = "bob" })},
+		{"issue with value", withTx(issueTx("alice", 0, spec), func(tx *Transaction) { tx.Value = 1 })},
+		{"issue by coinbase", withTx(issueTx(CoinbaseFrom, 0, spec), func(*Transaction) {})},
+		{"spec on a transfer", withTx(tokenTx("", "alice", "bob", 1, 0), func(tx *Transaction) { tx.Token = &spec })},
+		{"asset on a deploy", withTx(tokenTx(gold, "alice", "", 1, 0), func(tx *Transaction) { tx.Kind = TxDeploy })},
+		{"asset on a coinbase", tokenTx(gold, CoinbaseFrom, "bob", 1, 0)},
+		{"asset without recipient", tokenTx(gold, "alice", "", 1, 0)},
+		{"asset with zero value", tokenTx(gold, "alice", "bob", 0, 0)},
+	}
+	for _, tt := range tests {
+		if err := checkTxKind(p, tt.tx); !errors.Is(err, ErrBadTokenTx) {
+			t.Errorf("%s: err = %v, want ErrBadTokenTx", tt.name, err)
+		}
+	}
+	if err := checkTxKind(p, withSpec(func(s *TokenSpec) { s.Symbol, s.Decimals = "USD1", MaxTokenDecimals })); err != nil {
+		t.Fatalf("valid issue: %v", err)
+	}
+}
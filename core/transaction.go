@@ -2,39 +2,59 @@ package core
 
 import (
 	"crypto/ecdsa"
-	"encoding/json"
 	"mychain/utils"
 	"time"
 )
 
-// 非 UTXO 简化版，先用 From/To/Value 模式，后面再加“异常行为探测”
+// TxKind 表示交易的类型
+type TxKind uint8
+
+const (
+	TxTransfer TxKind = iota // 普通转账
+	TxDeploy                 // 部署合约：Payload 为合约代码，Value 转入新合约，见 contract.go
+	TxCall                   // 调用合约：To 为合约地址，Payload 为调用参数，Value 转入合约
+	TxIssue                  // 发行代币：Token 为代币参数，发行量全部归 From，见 token.go
+	TxStake                  // 质押：From 支付 Value，记入 To 的质押，见 stake.go
+	TxUnstake                // 取消质押：From 的 Value 质押解锁后回到余额
+	TxSlash                  // 罚没：Payload 为双签证据，销毁该验证者的部分质押
+)
+
+// 默认使用 From/To/Value 的账户模型；链参数选择 UTXO 模式时，
+// 改用 Inputs/Outputs 描述资金来源与去向（From 仍为签名者地址，所有输入都必须属于它）
 type Transaction struct {
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	Value     uint32    `json:"value"`
-	Timestamp time.Time `json:"timestamp"`
-	Hash      []byte    `json:"hash"`   // 交易内容的哈希
-	PubKey    []byte    `json:"pubKey"` // 发送方公钥（X.509 编码）
-	Sig       []byte    `json:"sig"`    // ECDSA 签名
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Value     uint32       `json:"value"`
+	Fee       uint32       `json:"fee"`   // 交易手续费，从发送方扣除，归打包该交易的矿工
+	Nonce     uint64       `json:"nonce"` // 发送方账户的第几笔交易（从 0 开始），防止重放
+	Timestamp time.Time    `json:"timestamp"`
+	LockTime  uint64       `json:"lockTime,omitempty"` // 锁定时间：小于 LockTimeThreshold 为区块高度，否则为 Unix 秒，见 locktime.go
+	Inputs    []TxInput    `json:"inputs,omitempty"`   // UTXO 模式：花费的输出
+	Outputs   []TxOutput   `json:"outputs,omitempty"`  // UTXO 模式：新产生的输出（含找零）
+	Kind      TxKind       `json:"kind,omitempty"`     // 交易类型，默认为普通转账
+	Payload   []byte       `json:"payload,omitempty"`  // 合约代码（部署）、调用参数（调用）或双签证据（罚没）
+	GasLimit  uint64       `json:"gasLimit,omitempty"` // 合约交易最多消耗的 gas
+	Asset     string       `json:"asset,omitempty"`    // 转账的代币资产 ID，为空表示原生币；不为空时 Value 是代币金额
+	Token     *TokenSpec   `json:"token,omitempty"`    // 发行交易：代币符号、小数位数与发行量
+	Data      []byte       `json:"data,omitempty"`     // 附带数据（最多 MaxTxData 字节），例如公证的文件哈希，见 notary.go
+	Hash      []byte       `json:"hash"`               // 交易内容的哈希
+	PubKey    []byte       `json:"pubKey"`             // 发送方公钥（X.509 编码）
+	Sig       []byte       `json:"sig"`                // ECDSA 签名
+	MultiSig  *MultiSig    `json:"multisig,omitempty"` // 多签交易：代替 PubKey / Sig，From 为多签地址
+	Script    *ScriptSpend `json:"script,omitempty"`   // 脚本交易：代替 PubKey / Sig，From 为赎回脚本的地址
 }
 
-// payload 返回参与哈希 / 签名的“核心字段”字节序列
-// 注意：不包含 Hash / Sig 字段本身，避免递归依赖
+// payload 返回参与哈希 / 签名的“核心字段”字节序列，即规范二进制编码中的 txBody（见 encoding.go）
+// 注意：不包含 Hash / PubKey / Sig / MultiSig / Script 字段本身，避免递归依赖
 func (tx *Transaction) payload() []byte {
-	// 这里只对 From/To/Value/Timestamp 做摘要，PubKey 也可以加入
-	tmp := struct {
-		From      string    `json:"from"`
-		To        string    `json:"to"`
-		Value     uint32    `json:"value"`
-		Timestamp time.Time `json:"timestamp"`
-	}{
-		From:      tx.From,
-		To:        tx.To,
-		Value:     tx.Value,
-		Timestamp: tx.Timestamp,
-	}
-	b, _ := json.Marshal(tmp)
-	return b
+	var e encoder
+	tx.encodeBody(&e)
+	return e.buf.Bytes()
+}
+
+// SigningPayload 返回交易哈希与签名所用的字节（规范二进制编码中的 txBody）
+func (tx *Transaction) SigningPayload() []byte {
+	return tx.payload()
 }
 
 // CalculateHash 计算交易的 Hash（对 payload 做 SHA256）
@@ -75,3 +95,20 @@ func (tx *Transaction) Verify() bool {
 	data := tx.payload()
 	return utils.VerifyECDSA(tx.PubKey, data, tx.Sig)
 }
+
+// Size 返回交易规范二进制编码的字节数，用于计算手续费率
+func (tx *Transaction) Size() int {
+	b, _ := tx.MarshalBinary()
+	return len(b)
+}
+
+// TotalFees 返回一组交易（不含 coinbase）的手续费之和
+func TotalFees(txs []Transaction) uint64 {
+	var fees uint64
+	for i := range txs {
+		if !txs[i].IsCoinbase() {
+			fees += uint64(txs[i].Fee)
+		}
+	}
+	return fees
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"mychain/utils"
+)
+
+var (
+	ErrWrongLedger   = errors.New("transaction format does not match ledger mode")
+	ErrBadUTXOTx     = errors.New("malformed utxo transaction")
+	ErrMissingInput  = errors.New("input not found or already spent")
+	ErrInputOwner    = errors.New("input is not owned by sender")
+	ErrFeeMismatch   = errors.New("inputs do not equal outputs plus fee")
+	ErrDuplicateTx   = errors.New("transaction outputs already exist")
+	ErrMempoolDouble = errors.New("input already spent by a pending transaction")
+)
+
+// TxInput 引用之前某笔交易的某个输出
+type TxInput struct {
+	TxHash []byte `json:"txHash"`
+	Index  uint32 `json:"index"`
+}
+
+// TxOutput 是一笔交易产生的一个输出
+type TxOutput struct {
+	To    string `json:"to"`
+	Value uint32 `json:"value"`
+}
+
+// UTXO 是一个尚未被花费的输出
+type UTXO struct {
+	TxHash   []byte `json:"txHash"`
+	Index    uint32 `json:"index"`
+	To       string `json:"to"`
+	Value    uint32 `json:"value"`
+	Height   uint64 `json:"height"`             // 产生该输出的区块高度
+	Coinbase bool   `json:"coinbase,omitempty"` // 是否为 coinbase 输出（需要等待成熟）
+}
+
+// Mature 判断输出能否在高度 height 的区块中被花费
+func (m *MonetaryPolicy) Mature(u UTXO, height uint64) bool {
+	return !u.Coinbase || height >= u.Height+m.CoinbaseMaturity
+}
+
+// OutPoint 返回输出在 UTXO 集合中的键：<交易哈希hex>:<序号>
+func OutPoint(txHash []byte, index uint32) string {
+	return fmt.Sprintf("%s:%d", utils.ToHex(txHash), index)
+}
+
+// IsUTXO 判断交易是否为 UTXO 格式
+func (tx *Transaction) IsUTXO() bool {
+	return len(tx.Inputs) > 0 || len(tx.Outputs) > 0
+}
+
+// OutputValue 返回交易支付出去的总金额（UTXO 为所有输出之和，账户模型为 Value）
+func (tx *Transaction) OutputValue() uint64 {
+	if !tx.IsUTXO() {
+		return uint64(tx.Value)
+	}
+	var sum uint64
+	for _, out := range tx.Outputs {
+		sum += uint64(out.Value)
+	}
+	return sum
+}
+
+// CheckTxFormat 检查交易格式是否与链的记账模型一致（与状态无关）：
+//   - 两种模型：附带数据不超过 MaxTxData 字节
+//   - 账户模型：不能带输入 / 输出，合约交易的字段要合法（见 checkTxKind）
+//   - UTXO 模型：普通交易至少一个输入、一个输出，输入不能重复，不使用 To/Value/Nonce；
+//     coinbase 没有输入，只有输出；不支持合约交易和代币
+func CheckTxFormat(p *ChainParams, tx *Transaction) error {
+	if err := checkTxData(tx); err != nil {
+		return err
+	}
+	if p.Ledger != LedgerUTXO {
+		if tx.IsUTXO() {
+			return ErrWrongLedger
+		}
+		return checkTxKind(p, tx)
+	}
+
+	if tx.To != "" || tx.Value != 0 || tx.Nonce != 0 {
+		return fmt.Errorf("%w: to/value/nonce must be empty", ErrWrongLedger)
+	}
+	if tx.Kind != TxTransfer || len(tx.Payload) != 0 || tx.GasLimit != 0 {
+		return fmt.Errorf("%w: contracts need the account ledger", ErrWrongLedger)
+	}
+	if tx.Asset != "" || tx.Token != nil {
+		return fmt.Errorf("%w: tokens need the account ledger", ErrWrongLedger)
+	}
+	if len(tx.Outputs) == 0 {
+		return fmt.Errorf("%w: no outputs", ErrBadUTXOTx)
+	}
+	if tx.IsCoinbase() {
+		if len(tx.Inputs) != 0 {
+			return fmt.Errorf("%w: coinbase has inputs", ErrBadUTXOTx)
+		}
+		return nil
+	}
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("%w: no inputs", ErrBadUTXOTx)
+	}
+	seen := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		key := OutPoint(in.TxHash, in.Index)
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate input %s", ErrBadUTXOTx, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// applyUTXOTx 在 UTXO 集合上执行一笔交易：花掉输入、创建输出，并同步维护地址余额；
+// coinbase 输出要等到成熟后才能作为输入
+func applyUTXOTx(p *ChainParams, st *State, tx *Transaction) error {
+	// 同一哈希的输出已经存在，说明交易重复（例如两笔完全相同的 coinbase）
+	for i := range tx.Outputs {
+		if _, ok := st.UTXOs[OutPoint(tx.Hash, uint32(i))]; ok {
+			return ErrDuplicateTx
+		}
+	}
+
+	var in uint64
+	for _, input := range tx.Inputs {
+		key := OutPoint(input.TxHash, input.Index)
+		u, ok := st.UTXOs[key]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMissingInput, key)
+		}
+		if u.To != tx.From {
+			return fmt.Errorf("%w: %s belongs to %s", ErrInputOwner, key, u.To)
+		}
+		if !p.Monetary.Mature(u, st.Height) {
+			return fmt.Errorf("%w: %s mined at height %d", ErrImmatureSpend, key, u.Height)
+		}
+		in += uint64(u.Value)
+		st.SpendUTXO(key)
+	}
+
+	// coinbase 和创世分配（From 为空，只出现在创世块中）没有输入
+	if !tx.IsCoinbase() && tx.From != "" && in != tx.OutputValue()+uint64(tx.Fee) {
+		return fmt.Errorf("%w: in %d, out %d, fee %d", ErrFeeMismatch, in, tx.OutputValue(), tx.Fee)
+	}
+
+	for i, out := range tx.Outputs {
+		st.AddUTXO(UTXO{
+			TxHash:   tx.Hash,
+			Index:    uint32(i),
+			To:       out.To,
+			Value:    out.Value,
+			Height:   st.Height,
+			Coinbase: tx.IsCoinbase(),
+		})
+	}
+	return nil
+}
+
+// UTXOsOf 返回某个地址拥有的全部 UTXO（按交易哈希、序号排序）
+func (st *State) UTXOsOf(addr string) []UTXO {
+	var result []UTXO
+	for _, u := range st.UTXOs {
+		if u.To == addr {
+			result = append(result, u)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := OutPoint(result[i].TxHash, result[i].Index), OutPoint(result[j].TxHash, result[j].Index)
+		return a < b
+	})
+	return result
+}
+
+// AddUTXO 把一个新输出加入 UTXO 集合，并给所有者加上余额
+func (st *State) AddUTXO(u UTXO) {
+	key := OutPoint(u.TxHash, u.Index)
+	st.journal = append(st.journal, func() {
+		delete(st.UTXOs, key)
+	})
+	st.UTXOs[key] = u
+	st.touchUTXO(key)
+	st.AddBalance(u.To, int64(u.Value))
+}
+
+// SpendUTXO 从 UTXO 集合中删除一个输出，并扣除所有者的余额
+func (st *State) SpendUTXO(key string) {
+	u, ok := st.UTXOs[key]
+	if !ok {
+		return
+	}
+	st.journal = append(st.journal, func() {
+		st.UTXOs[key] = u
+	})
+	delete(st.UTXOs, key)
+	st.touchUTXO(key)
+	st.AddBalance(u.To, -int64(u.Value))
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUTXOSpendWithChange(t *testing.T) {
+	p := &ChainParams{Ledger: LedgerUTXO}
+	st := NewState()
+	coinbase := p.NewCoinbase(1, "alice", 50)
+	if err := applyTx(p, st, &coinbase); err != nil {
+		t.Fatal(err)
+	}
+	mark := st.Snapshot()
+
+	// 花掉 50：给 bob 30、找零 19、手续费 1
+	spend := func(outs ...TxOutput) Transaction {
+		tx := Transaction{
+			From:      "alice",
+			Fee:       1,
+			Timestamp: time.Unix(1700000000, 0),
+			Inputs:    []TxInput{{TxHash: coinbase.Hash, Index: 0}},
+			Outputs:   outs,
+		}
+		tx.CalculateHash()
+		return tx
+	}
+	tx := spend(TxOutput{To: "bob", Value: 30}, TxOutput{To: "alice", Value: 19})
+	if err := CheckTxFormat(p, &tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyTx(p, st, &tx); err != nil {
+		t.Fatal(err)
+	}
+	if st.Balance("alice") != 19 || st.Balance("bob") != 30 || len(st.UTXOs) != 2 {
+		t.Fatalf("balances alice %d bob %d, %d utxos", st.Balance("alice"), st.Balance("bob"), len(st.UTXOs))
+	}
+	if err := applyTx(p, st, &tx); !errors.Is(err, ErrDuplicateTx) {
+		t.Fatalf("replay: err = %v, want ErrDuplicateTx", err)
+	}
+	again := spend(TxOutput{To: "carol", Value: 49})
+	if err := applyTx(p, st, &again); !errors.Is(err, ErrMissingInput) {
+		t.Fatalf("spend a spent output: err = %v, want ErrMissingInput", err)
+	}
+
+	// 撤销后输入恢复，新输出消失
+	st.RevertToSnapshot(mark)
+	if st.Balance("alice") != 50 || st.Balance("bob") != 0 || len(st.UTXOs) != 1 {
+		t.Fatalf("after revert: alice %d bob %d, %d utxos", st.Balance("alice"), st.Balance("bob"), len(st.UTXOs))
+	}
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		want error
+	}{
+		{"outputs exceed inputs", spend(TxOutput{To: "bob", Value: 50}), ErrFeeMismatch},
+		{"outputs below inputs", spend(TxOutput{To: "bob", Value: 40}), ErrFeeMismatch},
+		{"input of someone else", func() Transaction { tx := spend(TxOutput{To: "bob", Value: 49}); tx.From = "mallory"; return tx }(), ErrInputOwner},
+	}
+	for _, tt := range tests {
+		if err := applyTx(p, st, &tt.tx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		st.RevertToSnapshot(mark)
+	}
+
+	// 账户模型的交易不能出现在 UTXO 链上，反之亦然
+	account := Transaction{From: "alice", To: "bob", Value: 1}
+	if err := CheckTxFormat(p, &account); !errors.Is(err, ErrWrongLedger) {
+		t.Fatalf("account tx on utxo chain: err = %v, want ErrWrongLedger", err)
+	}
+	if err := CheckTxFormat(&DefaultParams, &tx); !errors.Is(err, ErrWrongLedger) {
+		t.Fatalf("utxo tx on account chain: err = %v, want ErrWrongLedger", err)
+	}
+}
+
+func TestMempoolRejectsUTXODoubleSpend(t *testing.T) {
+	p := &ChainParams{Ledger: LedgerUTXO}
+	st := NewState()
+	coinbase := p.NewCoinbase(1, "alice", 50)
+	if err := applyTx(p, st, &coinbase); err != nil {
+		t.Fatal(err)
+	}
+	mp := NewMempool(p)
+
+	pay := func(to string) Transaction {
+		tx := Transaction{
+			From:      "alice",
+			Timestamp: time.Unix(1700000000, 0),
+			Inputs:    []TxInput{{TxHash: coinbase.Hash, Index: 0}},
+			Outputs:   []TxOutput{{To: to, Value: 50}},
+		}
+		tx.CalculateHash()
+		return tx
+	}
+	if _, err := mp.Add(pay("bob"), st); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(pay("carol"), st); !errors.Is(err, ErrMempoolDouble) {
+		t.Fatalf("double spend: err = %v, want ErrMempoolDouble", err)
+	}
+	if !mp.IsSpent(OutPoint(coinbase.Hash, 0)) {
+		t.Fatal("input not marked as spent by the pool")
+	}
+
+	// 输入在链上被花掉后，池内交易被移除，输入也被释放
+	st.SpendUTXO(OutPoint(coinbase.Hash, 0))
+	mp.Reset(st)
+	if mp.Len() != 0 || mp.IsSpent(OutPoint(coinbase.Hash, 0)) {
+		t.Fatalf("after Reset: %d transactions left", mp.Len())
+	}
+}
+
+func TestCoinbaseCommitsHeight(t *testing.T) {
+	p := &ChainParams{Ledger: LedgerUTXO}
+	a, b := p.NewCoinbase(1, "alice", 50), p.NewCoinbase(1, "alice", 50)
+	if string(a.Hash) != string(b.Hash) {
+		t.Fatal("coinbase depends on more than its arguments")
+	}
+	// 同一地址在不同高度得到相同金额：nonce 记录高度，输出的交易哈希不会重复
+	if c := p.NewCoinbase(2, "alice", 50); string(c.Hash) == string(a.Hash) || c.Nonce != 2 {
+		t.Fatalf("coinbase at height 2 has nonce %d and hash %x", c.Nonce, c.Hash)
+	}
+}
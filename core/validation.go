@@ -0,0 +1,306 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"mychain/utils"
+)
+
+// CoinbaseFrom coinbase 交易固定使用的 From
+const CoinbaseFrom = "COINBASE"
+
+// 区块 / 交易校验失败时返回的错误，调用方可以用 errors.Is 判断具体违反了哪条规则
+var (
+	ErrNoHeader           = errors.New("block has no header")
+	ErrGenesisMismatch    = errors.New("genesis block mismatch")
+	ErrPrevHashMismatch   = errors.New("previous hash mismatch")
+	ErrBadDifficulty      = errors.New("difficulty bits do not match schedule")
+	ErrBadPow             = errors.New("proof of work invalid")
+	ErrTooManyTxs         = errors.New("too many transactions in block")
+	ErrTxHashMismatch     = errors.New("transaction hash mismatch")
+	ErrMerkleMismatch     = errors.New("merkle root mismatch")
+	ErrMissingCoinbase    = errors.New("block has no coinbase")
+	ErrMisplacedCoinbase  = errors.New("coinbase is not the first transaction")
+	ErrMultipleCoinbase   = errors.New("block has multiple coinbase transactions")
+	ErrBadReward          = errors.New("coinbase pays wrong reward")
+	ErrBadCoinbaseHeight  = errors.New("coinbase does not commit to block height")
+	ErrMissingSignature   = errors.New("missing pubkey or signature")
+	ErrFromPubKeyMismatch = errors.New("from address does not match pubkey")
+	ErrBadSignature       = errors.New("invalid signature")
+	ErrOverspend          = errors.New("balance not enough")
+	ErrBadNonce           = errors.New("nonce mismatch")
+	ErrBadVersion         = errors.New("unsupported block version")
+	ErrBadHeight          = errors.New("block height mismatch")
+	ErrStateRootMismatch  = errors.New("state root mismatch")
+)
+
+// BlockContext 描述校验一个区块时所依赖的链上下文
+type BlockContext struct {
+	Params *ChainParams
+	Prev   *Block       // 父区块
+	Chain  HeaderReader // 父区块及其祖先，共识引擎据此计算难度 / 轮到的签名者
+	Engine Consensus    // 共识引擎
+	State  *State       // 父区块执行完之后的状态（ValidateBlock 结束后会恢复原样）
+
+	MedianTime time.Time // 父区块及其祖先的过去中位时间，区块时间戳必须晚于它
+	Now        time.Time // 本节点的网络调整时间，区块时间戳不能超前它 MaxFutureBlockTime 以上
+
+	SigCache *SigCache // 可选：已校验过签名的交易缓存，为 nil 时每笔都重新校验
+}
+
+// IsCoinbase 判断是否为挖矿奖励交易
+func (tx *Transaction) IsCoinbase() bool {
+	return tx.From == CoinbaseFrom
+}
+
+// VerifyTxSignature 校验普通交易的签名：
+// 必须带 PubKey + Sig，From 必须由 PubKey 推导，签名必须正确；
+// 多签交易改为检查 From 是多签地址、且有足够的正确签名（见 multisig.go）；
+// 脚本交易检查 From 是赎回脚本的地址，并执行解锁脚本 + 赎回脚本（见 p2sh.go）
+func VerifyTxSignature(tx *Transaction) error {
+	if tx.Script != nil {
+		return verifyScript(tx)
+	}
+	if tx.MultiSig != nil {
+		return verifyMultisig(tx)
+	}
+	if len(tx.PubKey) == 0 || len(tx.Sig) == 0 {
+		return ErrMissingSignature
+	}
+	if expected := utils.PubKeyToAddress(tx.PubKey); tx.From != expected {
+		return fmt.Errorf("%w: declared %s, derived %s", ErrFromPubKeyMismatch, tx.From, expected)
+	}
+	if !tx.Verify() {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// ValidateBlock 对一个非创世区块做完整的共识校验，所有接收区块的路径
+// （/newblock、整链同步、从文件加载）都应通过这里。
+// 先做 CheckBlock 中与状态无关的检查，再在 ctx.State 上试执行交易检查余额与状态根，
+// 试执行的修改在返回前全部撤销。
+func ValidateBlock(b *Block, ctx *BlockContext) error {
+	if err := CheckBlock(b, ctx); err != nil {
+		return err
+	}
+
+	mark := ctx.State.Snapshot()
+	defer ctx.State.RevertToSnapshot(mark)
+	return applyBlock(ctx.Params, ctx.State, b)
+}
+
+// CheckBlock 做不依赖账户状态的检查，分叉链上的区块在收到时只做这一部分，
+// 真正接入主链时再由 ApplyBlock 检查余额。
+// 校验顺序：版本 → 前驱哈希与高度 → 时间戳 → 共识（难度与 POW / PoA、PoS 签名） → 交易数量 → 交易哈希与 Merkle 根 →
+// coinbase 位置与奖励 → 签名 → 锁定时间 → 合约 gas
+func CheckBlock(b *Block, ctx *BlockContext) error {
+	if b.Header == nil {
+		return ErrNoHeader
+	}
+
+	// 0. 区块版本
+	if b.Header.Version != BlockVersion {
+		return fmt.Errorf("%w: %d", ErrBadVersion, b.Header.Version)
+	}
+
+	// 1. 链式结构：前驱哈希一致，高度恰好比父区块大 1
+	if ctx.Prev == nil || !bytes.Equal(b.Header.PreviousHash, ctx.Prev.Header.Hash) {
+		return ErrPrevHashMismatch
+	}
+	if want := ctx.Prev.Header.Height + 1; b.Header.Height != want {
+		return fmt.Errorf("%w: got %d, want %d", ErrBadHeight, b.Header.Height, want)
+	}
+
+	// 2. 时间戳：晚于过去中位时间，且不能太超前。
+	//    太超前的区块只是“暂时”不合法，调用方不应把它永久标记为非法
+	if !b.Header.Timestamp.After(ctx.MedianTime) {
+		return fmt.Errorf("%w: %s, median time past %s", ErrTimeTooOld,
+			b.Header.Timestamp.Format(time.RFC3339), ctx.MedianTime.Format(time.RFC3339))
+	}
+	if limit := ctx.Now.Add(MaxFutureBlockTime); b.Header.Timestamp.After(limit) {
+		return fmt.Errorf("%w: %s, limit %s", ErrTimeTooNew,
+			b.Header.Timestamp.Format(time.RFC3339), limit.Format(time.RFC3339))
+	}
+
+	// 3-4. 共识：POW 检查难度符合调整规则且哈希满足难度，PoA 检查签名者、出块权重与签名，PoS 检查签名与权重（出块者在 applyBlock 中检查），
+	//      BFT 检查签名者是验证者（提交证书在 ProcessBlock 中检查）
+	if err := ctx.Engine.VerifySeal(ctx.Chain, b.Header); err != nil {
+		return err
+	}
+
+	// 5. 交易数量（coinbase 不计入）
+	if len(b.Txs) > ctx.Params.MaxTxPerBlock+1 {
+		return fmt.Errorf("%w: %d", ErrTooManyTxs, len(b.Txs))
+	}
+
+	// 6. 每笔交易的格式要符合记账模型，Hash 必须与内容一致，再用它们重算 Merkle 根
+	for i := range b.Txs {
+		if err := CheckTxFormat(ctx.Params, &b.Txs[i]); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+		tx := b.Txs[i]
+		tx.CalculateHash()
+		if !bytes.Equal(tx.Hash, b.Txs[i].Hash) {
+			return fmt.Errorf("%w: tx %d", ErrTxHashMismatch, i)
+		}
+	}
+	if !bytes.Equal(CalculateMerkleRoot(b.Txs), b.Header.MerkleRoot) {
+		return ErrMerkleMismatch
+	}
+
+	// 7. coinbase：有且只有一笔，必须放在第一位，nonce 等于区块高度，金额恰好等于 该高度的区块奖励 + 本块手续费
+	if len(b.Txs) == 0 || !b.Txs[0].IsCoinbase() {
+		for i := range b.Txs {
+			if b.Txs[i].IsCoinbase() {
+				return fmt.Errorf("%w: found at index %d", ErrMisplacedCoinbase, i)
+			}
+		}
+		return ErrMissingCoinbase
+	}
+	if got := b.Txs[0].Nonce; got != b.Header.Height {
+		return fmt.Errorf("%w: got %d, want %d", ErrBadCoinbaseHeight, got, b.Header.Height)
+	}
+	subsidy := ctx.Engine.Reward(b.Header.Height)
+	if got, want := b.Txs[0].OutputValue(), subsidy+TotalFees(b.Txs); got != want {
+		return fmt.Errorf("%w: got %d, want %d", ErrBadReward, got, want)
+	}
+
+	// 8. 普通交易的签名：并行校验，命中 SigCache 的交易跳过
+	txs := make([]*Transaction, 0, len(b.Txs)-1)
+	for i := 1; i < len(b.Txs); i++ {
+		if b.Txs[i].IsCoinbase() {
+			return fmt.Errorf("%w: index %d", ErrMultipleCoinbase, i)
+		}
+		txs = append(txs, &b.Txs[i])
+	}
+	if i, err := verifySignatures(txs, ctx.SigCache); err != nil {
+		return fmt.Errorf("tx %d: %w", i+1, err)
+	}
+
+	// 9. 锁定时间：区块中的交易都必须已经解锁
+	for i := range b.Txs {
+		if err := b.Txs[i].checkFinal(b.Header.Height, ctx.MedianTime); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+	}
+
+	// 10. 合约交易的 gas 上限之和不能超过 MaxBlockGas（每笔都已检查不超过 MaxBlockGas，求和不会溢出）
+	if gas := BlockGas(b.Txs); gas > ctx.Params.MaxBlockGas {
+		return fmt.Errorf("%w: %d, limit %d", ErrBlockGasLimit, gas, ctx.Params.MaxBlockGas)
+	}
+
+	return nil
+}
+
+// ApplyBlock 把区块中的交易作用到 st 上，返回用于回滚的 Undo。
+// 执行中发现余额不足（ErrOverspend）或执行后状态根与区块头不一致（ErrStateRootMismatch）
+// 会撤销已做的修改并返回错误。
+func ApplyBlock(p *ChainParams, st *State, b *Block) (Undo, error) {
+	mark := st.Snapshot()
+	if err := applyBlock(p, st, b); err != nil {
+		st.RevertToSnapshot(mark)
+		return nil, err
+	}
+	return st.commit(mark), nil
+}
+
+// applyBlock 检查 PoS 的出块者（见 pos.go）后执行区块中的交易，并检查执行后的状态根与区块头承诺的一致
+func applyBlock(p *ChainParams, st *State, b *Block) error {
+	if err := checkProposer(p, st.Validators(p), b.Header); err != nil {
+		return err
+	}
+	if err := executeBlock(p, st, b); err != nil {
+		return err
+	}
+	if root := st.Root(); !bytes.Equal(root, b.Header.StateRoot) {
+		return fmt.Errorf("%w: got %s, header has %s",
+			ErrStateRootMismatch, utils.ToHex(root), utils.ToHex(b.Header.StateRoot))
+	}
+	return nil
+}
+
+// executeBlock 进入区块高度（解锁到期的 coinbase）、计入本区块的发行量后依次执行区块中的交易
+func executeBlock(p *ChainParams, st *State, b *Block) error {
+	st.beginBlock(b.Header.Height)
+	st.addSupply(blockIssuance(p, b.Header.Height), 0)
+	return applyTxs(p, st, b)
+}
+
+// applyTxs 依次执行区块中的交易
+func applyTxs(p *ChainParams, st *State, b *Block) error {
+	for i := range b.Txs {
+		if err := applyTx(p, st, &b.Txs[i]); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// applyTx 执行一笔交易
+// 约定：
+//   - 普通交易：nonce 必须等于账户当前 nonce，From 账户减去 Value + Fee，To 账户加上 Value，
+//     余额不足或要花费尚未成熟的 coinbase 则报错；执行后账户 nonce 加一。
+//     手续费已计入 coinbase，这里不再单独转给矿工
+//   - 合约交易：同样扣除 Value + Fee、nonce 加一，再部署或调用合约，见 applyContractTx
+//   - 代币：发行交易与代币转账只扣 Fee（Value 是代币金额），代币余额不足则报错，见 token.go
+//   - 质押：质押扣除 Value + Fee，取消质押与罚没只扣 Fee，见 stake.go；创世质押 From 为空，不扣任何人
+//   - 挖矿奖励：From == "COINBASE"，只给 To 加钱，不扣任何人；
+//     这笔钱锁定 CoinbaseMaturity 个区块后才能花费
+//   - UTXO 模式下改为花费输入、创建输出，见 applyUTXOTx
+func applyTx(p *ChainParams, st *State, tx *Transaction) error {
+	if p.Ledger == LedgerUTXO {
+		return applyUTXOTx(p, st, tx)
+	}
+
+	amount := int64(tx.NativeValue())
+
+	if tx.From != "" && !tx.IsCoinbase() {
+		if want := st.Nonce(tx.From); tx.Nonce != want {
+			return fmt.Errorf("%w: account %s, got %d, want %d", ErrBadNonce, tx.From, tx.Nonce, want)
+		}
+		cost := amount + int64(tx.Fee)
+		if st.Balance(tx.From) < cost {
+			return fmt.Errorf("%w: account %s has %d, spends %d",
+				ErrOverspend, tx.From, st.Balance(tx.From), cost)
+		}
+		if st.Spendable(tx.From) < cost {
+			return fmt.Errorf("%w: account %s can spend %d, spends %d",
+				ErrImmatureSpend, tx.From, st.Spendable(tx.From), cost)
+		}
+		if tx.Asset != "" {
+			if err := checkTokenTransfer(st, tx); err != nil {
+				return err
+			}
+		}
+		if err := checkStakingState(p, st, tx); err != nil {
+			return err
+		}
+		st.AddBalance(tx.From, -cost)
+		st.IncNonce(tx.From)
+	}
+	switch {
+	case tx.Kind == TxIssue:
+		applyIssueTx(st, tx)
+		return nil
+	case tx.isStakeTx():
+		applyStakeTx(p, st, tx)
+		return nil
+	case tx.Kind != TxTransfer:
+		applyContractTx(st, tx)
+		return nil
+	case tx.Asset != "":
+		applyTokenTransfer(st, tx)
+		return nil
+	}
+	if tx.To != "" {
+		st.AddBalance(tx.To, amount)
+		if maturity := p.Monetary.CoinbaseMaturity; tx.IsCoinbase() && maturity > 0 {
+			st.lockCoinbase(tx.To, amount, st.Height+maturity)
+		}
+	}
+	return nil
+}
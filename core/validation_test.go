@@ -0,0 +1,120 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mychain/utils"
+)
+
+func TestValidateBlockRules(t *testing.T) {
+	priv, pub, err := utils.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := utils.PubKeyToAddress(pub)
+
+	// alice 先挖一个区块；回归测试网络的 coinbase 立即成熟，下一个区块就可以花
+	p := loadRegtest(t)
+	bc := NewBlockchain(p)
+	bc.AddBlock([]Transaction{p.NewCoinbase(1, alice, uint32(p.Monetary.Subsidy(1)))})
+	ctx := bc.NextBlockContext()
+	reward := uint32(p.Monetary.Subsidy(2))
+
+	coinbase := func(value uint32) Transaction {
+		return p.NewCoinbase(2, "miner", value)
+	}
+	pay := func(value uint32, nonce uint64) Transaction {
+		tx := Transaction{From: alice, To: "bob", Value: value, Fee: 1, Nonce: nonce, Timestamp: time.Now()}
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	// stateRoot 在 ctx.State 上试执行 txs 得到状态根（执行失败的区块在检查状态根之前就会被拒绝）
+	stateRoot := func(txs []Transaction) []byte {
+		mark := ctx.State.Snapshot()
+		defer ctx.State.RevertToSnapshot(mark)
+		applyTxs(ctx.Params, ctx.State, &Block{Txs: txs})
+		return ctx.State.Root()
+	}
+	// newBlock 构造接在 ctx.Prev 之后、已填好难度但尚未挖矿的区块
+	newBlock := func(parent *BlockHeader, ts time.Time, root []byte, txs []Transaction) *Block {
+		b := NewBlock(parent, ts, root, txs)
+		if err := ctx.Engine.Prepare(ctx.Chain, b.Header); err != nil {
+			t.Fatal(err)
+		}
+		return &b
+	}
+	build := func(txs ...Transaction) *Block {
+		b := newBlock(ctx.Prev.Header, ctx.MedianTime.Add(time.Second), stateRoot(txs), txs)
+		b.Mine()
+		return b
+	}
+	// remine 修改区块之后重新挖矿，让错误落在要测的那条规则上
+	remine := func(b *Block, change func(b *Block)) *Block {
+		change(b)
+		b.Mine()
+		return b
+	}
+
+	tests := []struct {
+		name  string
+		block *Block
+		want  error
+	}{
+		{"valid", build(coinbase(reward+1), pay(10, 0)), nil},
+		{"no header", &Block{}, ErrNoHeader},
+		{"wrong version", remine(build(coinbase(reward)), func(b *Block) { b.Header.Version++ }), ErrBadVersion},
+		{"wrong height", remine(build(coinbase(reward)), func(b *Block) { b.Header.Height++ }), ErrBadHeight},
+		{"wrong parent", func() *Block {
+			parent := &BlockHeader{Height: ctx.Prev.Header.Height, Hash: []byte("other")}
+			return newBlock(parent, time.Now(), nil, []Transaction{coinbase(reward)})
+		}(), ErrPrevHashMismatch},
+		{"time at median", remine(build(coinbase(reward)), func(b *Block) { b.Header.Timestamp = ctx.MedianTime }), ErrTimeTooOld},
+		{"time too far ahead", remine(build(coinbase(reward)), func(b *Block) {
+			b.Header.Timestamp = ctx.Now.Add(MaxFutureBlockTime + time.Second)
+		}), ErrTimeTooNew},
+		{"wrong bits", func() *Block {
+			b := newBlock(ctx.Prev.Header, ctx.MedianTime.Add(time.Second), nil, []Transaction{coinbase(reward)})
+			b.Header.Bits = 0x1f00fffe
+			return b
+		}(), ErrBadDifficulty},
+		{"bad pow", func() *Block {
+			b := build(coinbase(reward))
+			for NewPow(b).Validate() {
+				b.Header.Nonce++
+			}
+			return b
+		}(), ErrBadPow},
+		{"too many txs", func() *Block {
+			txs := []Transaction{coinbase(reward)}
+			for n := 0; n <= p.MaxTxPerBlock; n++ {
+				txs = append(txs, pay(1, uint64(n)))
+			}
+			return build(txs...)
+		}(), ErrTooManyTxs},
+		{"tx hash", remine(build(coinbase(reward), pay(1, 0)), func(b *Block) { b.Txs[1].Hash = []byte("x") }), ErrTxHashMismatch},
+		{"merkle root", remine(build(coinbase(reward)), func(b *Block) { b.Header.MerkleRoot = []byte("x") }), ErrMerkleMismatch},
+		{"no coinbase", build(pay(1, 0)), ErrMissingCoinbase},
+		{"coinbase second", build(pay(1, 0), coinbase(reward+1)), ErrMisplacedCoinbase},
+		{"two coinbases", build(coinbase(reward), coinbase(reward)), ErrMultipleCoinbase},
+		{"coinbase for another height", build(p.NewCoinbase(1, "miner", reward)), ErrBadCoinbaseHeight},
+		{"wrong reward", build(coinbase(reward + 1)), ErrBadReward},
+		{"fees not collected", build(coinbase(reward), pay(1, 0)), ErrBadReward},
+		{"unsigned", build(coinbase(reward), Transaction{From: alice, To: "bob", Value: 1, Timestamp: time.Now()}), ErrMissingSignature},
+		{"from mismatch", build(coinbase(reward+1), func() Transaction { tx := pay(1, 0); tx.From = "mallory"; return tx }()), ErrFromPubKeyMismatch},
+		{"bad signature", build(coinbase(reward+1), func() Transaction { tx := pay(1, 0); tx.Value = 2; return tx }()), ErrBadSignature},
+		{"nonce gap", build(coinbase(reward+1), pay(1, 1)), ErrBadNonce},
+		{"nonce replay", build(coinbase(reward+2), pay(1, 0), pay(1, 0)), ErrBadNonce},
+		{"state root", remine(build(coinbase(reward+1), pay(10, 0)), func(b *Block) { b.Header.StateRoot = []byte("x") }), ErrStateRootMismatch},
+		{"overspend", build(coinbase(reward+2), pay(30, 0), pay(30, 1)), ErrOverspend},
+	}
+	for _, tt := range tests {
+		err := ValidateBlock(tt.block, ctx)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package vm
+
+// 合约代码的文本形式（汇编）见 core/bytecode：OP_XXX 操作码、0x<hex> 数据、'text' 文本、十进制整数，
+// # 之后为注释；name: 定义标签（生成 OP_JUMPDEST），@name 压入标签的偏移，配合 OP_JUMP / OP_JUMPI 使用。
+//
+// 例如：OP_DUP 'inc' OP_EQUAL @inc OP_JUMPI … inc: OP_DROP …
+
+// Assemble 把汇编文本编译成字节码
+func Assemble(src string) ([]byte, error) {
+	code, err := opcodes.Assemble(src)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Parse(code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// Disassemble 把字节码还原成汇编文本（标签显示为 OP_JUMPDEST，跳转目标显示为数字数据）
+func Disassemble(code []byte) (string, error) {
+	if _, err := Parse(code); err != nil {
+		return "", err
+	}
+	return opcodes.Disassemble(code)
+}
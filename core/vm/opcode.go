@@ -0,0 +1,111 @@
+package vm
+
+import (
+	"fmt"
+
+	"mychain/core/bytecode"
+)
+
+// 操作码。数据压栈与 core/script 共用（见 core/bytecode），其余指令按用途分组
+const (
+	OP_0         = bytecode.OP_0
+	OP_PUSHDATA1 = bytecode.OP_PUSHDATA1
+	OP_PUSHDATA2 = bytecode.OP_PUSHDATA2
+	OP_1NEGATE   = bytecode.OP_1NEGATE
+	OP_1         = bytecode.OP_1
+	OP_16        = bytecode.OP_16
+
+	// 流程控制
+	OP_NOP      byte = 0x61
+	OP_JUMPDEST byte = 0x62 // 跳转目标，OP_JUMP / OP_JUMPI 只能跳到这里
+	OP_JUMP     byte = 0x63 // 弹出目标偏移并跳转
+	OP_JUMPI    byte = 0x64 // 弹出目标偏移和条件，条件为真时跳转
+	OP_STOP     byte = 0x65 // 成功结束，没有返回值
+	OP_RETURN   byte = 0x66 // 弹出栈顶作为返回值，成功结束
+	OP_REVERT   byte = 0x67 // 弹出栈顶作为错误信息，撤销本次调用的全部修改
+	OP_VERIFY   byte = 0x69 // 弹出栈顶，为假则撤销
+
+	// 栈操作
+	OP_DROP byte = 0x75
+	OP_DUP  byte = 0x76
+	OP_OVER byte = 0x78
+	OP_PICK byte = 0x79 // 弹出 n，复制从栈顶往下第 n 个元素（0 为栈顶）
+	OP_ROT  byte = 0x7b // 把第三个元素移到栈顶
+	OP_SWAP byte = 0x7c
+	OP_CAT  byte = 0x7e // 拼接两个字节串
+	OP_SIZE byte = 0x82 // 压入栈顶元素的长度（不弹出）
+
+	// 比较与算术
+	OP_EQUAL              byte = 0x87
+	OP_NOT                byte = 0x91
+	OP_ADD                byte = 0x93
+	OP_SUB                byte = 0x94
+	OP_MUL                byte = 0x95
+	OP_DIV                byte = 0x96
+	OP_MOD                byte = 0x97
+	OP_BOOLAND            byte = 0x9a
+	OP_BOOLOR             byte = 0x9b
+	OP_NUMEQUAL           byte = 0x9c
+	OP_LESSTHAN           byte = 0x9f
+	OP_GREATERTHAN        byte = 0xa0
+	OP_LESSTHANOREQUAL    byte = 0xa1
+	OP_GREATERTHANOREQUAL byte = 0xa2
+	OP_SHA256             byte = 0xa8
+
+	// 链上环境
+	OP_CALLER    byte = 0xc0 // 压入调用者地址
+	OP_CALLVALUE byte = 0xc1 // 压入本次调用转入合约的金额
+	OP_SELF      byte = 0xc2 // 压入合约自己的地址
+	OP_HEIGHT    byte = 0xc3 // 压入当前区块高度
+	OP_BALANCE   byte = 0xc4 // 弹出地址，压入它的余额
+
+	// 存储与转账
+	OP_SLOAD    byte = 0xd0 // 弹出键，压入合约存储中的值（不存在为空串）
+	OP_SSTORE   byte = 0xd1 // 弹出值和键，写入合约存储（值为空串表示删除）
+	OP_TRANSFER byte = 0xd2 // 弹出金额和收款地址，从合约余额中转出
+)
+
+// opcodes 是合约的指令集：压栈之外的操作码 → 名称，不在表中的操作码都是非法的；汇编标签生成 OP_JUMPDEST
+var opcodes = bytecode.NewInstructionSet(map[byte]string{
+	OP_NOP: "OP_NOP", OP_JUMPDEST: "OP_JUMPDEST", OP_JUMP: "OP_JUMP", OP_JUMPI: "OP_JUMPI",
+	OP_STOP: "OP_STOP", OP_RETURN: "OP_RETURN", OP_REVERT: "OP_REVERT", OP_VERIFY: "OP_VERIFY",
+	OP_DROP: "OP_DROP", OP_DUP: "OP_DUP", OP_OVER: "OP_OVER", OP_PICK: "OP_PICK", OP_ROT: "OP_ROT",
+	OP_SWAP: "OP_SWAP", OP_CAT: "OP_CAT", OP_SIZE: "OP_SIZE",
+	OP_EQUAL: "OP_EQUAL", OP_NOT: "OP_NOT", OP_ADD: "OP_ADD", OP_SUB: "OP_SUB", OP_MUL: "OP_MUL",
+	OP_DIV: "OP_DIV", OP_MOD: "OP_MOD", OP_BOOLAND: "OP_BOOLAND", OP_BOOLOR: "OP_BOOLOR",
+	OP_NUMEQUAL: "OP_NUMEQUAL", OP_LESSTHAN: "OP_LESSTHAN", OP_GREATERTHAN: "OP_GREATERTHAN",
+	OP_LESSTHANOREQUAL: "OP_LESSTHANOREQUAL", OP_GREATERTHANOREQUAL: "OP_GREATERTHANOREQUAL",
+	OP_SHA256: "OP_SHA256",
+	OP_CALLER: "OP_CALLER", OP_CALLVALUE: "OP_CALLVALUE", OP_SELF: "OP_SELF", OP_HEIGHT: "OP_HEIGHT",
+	OP_BALANCE: "OP_BALANCE", OP_SLOAD: "OP_SLOAD", OP_SSTORE: "OP_SSTORE", OP_TRANSFER: "OP_TRANSFER",
+}, OP_JUMPDEST)
+
+// Program 是解析好的合约代码：指令序列，以及合法跳转目标（OP_JUMPDEST 的偏移）→ 指令下标
+type Program struct {
+	ins   []bytecode.Instruction
+	dests map[int]int
+}
+
+// Parse 解析合约代码，超长、长度越界、数据过长或未知操作码都视为非法代码。
+// 部署时解析一次即可拒绝所有格式错误，执行时不会遇到非法指令。
+func Parse(code []byte) (*Program, error) {
+	if len(code) > MaxCodeSize {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrCodeTooLarge, len(code), MaxCodeSize)
+	}
+	ins, err := opcodes.Parse(code)
+	if err != nil {
+		return nil, err
+	}
+	p := &Program{ins: ins, dests: make(map[int]int)}
+	for i, in := range ins {
+		if in.Op == OP_JUMPDEST {
+			p.dests[in.Offset] = i
+		}
+	}
+	return p, nil
+}
+
+// IsPushOnly 判断代码是否只包含压栈指令（调用参数必须如此）
+func IsPushOnly(code []byte) bool {
+	return len(code) <= MaxCodeSize && opcodes.IsPushOnly(code)
+}
@@ -0,0 +1,444 @@
+// Package vm 实现合约的确定性解释器：一个栈式字节码虚拟机，
+// 可以读写合约自己的键值存储、查询余额、从合约余额中转账。
+//
+// 调用合约时，先执行只含压栈指令的调用参数（input），再在同一个栈上从头执行合约代码。
+// 约定参数的最后一项是方法名，合约代码据此分派。
+// 执行完全确定：不读取时间、随机数等外部状态，链上环境由调用方通过 Host 提供；
+// 每条指令消耗 gas，超出交易给定的上限立即失败。
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"mychain/core/bytecode"
+)
+
+// 合约限制
+const (
+	MaxCodeSize    = 8192                    // 合约代码最多多少字节
+	MaxInputSize   = 1024                    // 调用参数最多多少字节
+	MaxElementSize = bytecode.MaxElementSize // 单个栈元素（以及存储的键、值）最多多少字节
+	MaxStackSize   = 256                     // 栈上最多多少个元素
+	MaxNumSize     = bytecode.MaxNumSize     // 作为数字使用的栈元素最多多少字节
+)
+
+// 各类指令的 gas 消耗：读写存储、转账比普通指令昂贵得多
+const (
+	gasBase     = 1
+	gasHash     = 10
+	gasBalance  = 20
+	gasSLoad    = 20
+	gasSStore   = 100
+	gasTransfer = 50
+)
+
+var (
+	ErrBadCode         = errors.New("malformed contract code")
+	ErrUnknownOpcode   = bytecode.ErrUnknownOpcode
+	ErrCodeTooLarge    = errors.New("contract code too large")
+	ErrElementTooLarge = bytecode.ErrElementTooLarge
+	ErrStackOverflow   = errors.New("stack overflow")
+	ErrStackUnderflow  = errors.New("stack underflow")
+	ErrNumOverflow     = bytecode.ErrNumOverflow
+	ErrDivByZero       = errors.New("division by zero")
+	ErrOutOfGas        = errors.New("out of gas")
+	ErrBadJump         = errors.New("invalid jump destination")
+	ErrVerifyFailed    = errors.New("verify failed")
+	ErrReverted        = errors.New("execution reverted")
+	ErrNotPushOnly     = errors.New("call input must only push data")
+)
+
+// Host 是合约看到的链上环境，由 core 在执行交易时实现。
+// Store / Transfer 的修改由 Host 负责记录，执行失败时由调用方整体撤销。
+type Host interface {
+	Caller() string   // 调用者地址（交易的 From）
+	CallValue() int64 // 本次调用转入合约的金额
+	Self() string     // 合约地址
+	Height() uint64   // 当前区块高度
+	Balance(addr string) int64
+	Load(key []byte) []byte
+	Store(key, value []byte)
+	Transfer(to string, amount int64) error
+}
+
+// Result 是一次执行的结果
+type Result struct {
+	Return  []byte // OP_RETURN 返回的数据
+	GasUsed uint64
+}
+
+// Execute 用 gasLimit 的 gas 执行一次调用：先压入 input 中的参数，再执行 code。
+// 出错时同样返回已消耗的 gas。
+func Execute(code, input []byte, host Host, gasLimit uint64) (*Result, error) {
+	res := &Result{}
+	if len(input) > MaxInputSize {
+		return res, fmt.Errorf("%w: input of %d bytes (max %d)", ErrBadCode, len(input), MaxInputSize)
+	}
+	args, err := Parse(input)
+	if err != nil {
+		return res, err
+	}
+	prog, err := Parse(code)
+	if err != nil {
+		return res, err
+	}
+
+	m := &machine{host: host, gasLimit: gasLimit}
+	for _, in := range args.ins {
+		if !in.IsPush() {
+			return res, ErrNotPushOnly
+		}
+		if _, err := m.step(nil, in); err != nil {
+			res.GasUsed = m.gas
+			return res, err
+		}
+	}
+	err = m.run(prog)
+	res.Return = m.ret
+	res.GasUsed = m.gas
+	return res, err
+}
+
+// machine 是一次执行的虚拟机状态
+type machine struct {
+	host     Host
+	stack    [][]byte
+	gas      uint64
+	gasLimit uint64
+	ret      []byte
+}
+
+// stepResult 说明一条指令之后怎么继续：顺序执行、跳转到 target，还是结束
+type stepResult struct {
+	jump   bool
+	target int
+	halt   bool
+}
+
+func (m *machine) run(p *Program) error {
+	for pc := 0; pc < len(p.ins); {
+		in := p.ins[pc]
+		r, err := m.step(p, in)
+		if err != nil {
+			return fmt.Errorf("%s at offset %d: %w", opcodes.Name(in.Op), in.Offset, err)
+		}
+		switch {
+		case r.halt:
+			return nil
+		case r.jump:
+			pc = r.target
+		default:
+			pc++
+		}
+	}
+	return nil
+}
+
+func (m *machine) useGas(n uint64) error {
+	m.gas += n
+	if m.gas > m.gasLimit {
+		m.gas = m.gasLimit
+		return fmt.Errorf("%w: limit %d", ErrOutOfGas, m.gasLimit)
+	}
+	return nil
+}
+
+func (m *machine) push(b []byte) error {
+	if len(m.stack) >= MaxStackSize {
+		return ErrStackOverflow
+	}
+	if len(b) > MaxElementSize {
+		return ErrElementTooLarge
+	}
+	m.stack = append(m.stack, b)
+	return nil
+}
+
+func (m *machine) pop() ([]byte, error) {
+	if len(m.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	b := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return b, nil
+}
+
+func (m *machine) popNum() (int64, error) {
+	b, err := m.pop()
+	if err != nil {
+		return 0, err
+	}
+	return bytecode.DecodeNum(b)
+}
+
+func (m *machine) peek(i int) ([]byte, error) {
+	if i < 0 || i >= len(m.stack) {
+		return nil, ErrStackUnderflow
+	}
+	return m.stack[len(m.stack)-1-i], nil
+}
+
+// step 执行一条指令
+func (m *machine) step(p *Program, in bytecode.Instruction) (stepResult, error) {
+	var r stepResult
+	if err := m.useGas(gasBase); err != nil {
+		return r, err
+	}
+
+	if in.IsPush() {
+		return r, m.push(in.PushValue())
+	}
+
+	switch in.Op {
+	case OP_NOP, OP_JUMPDEST:
+		return r, nil
+	case OP_JUMP, OP_JUMPI:
+		target, err := m.popNum()
+		if err != nil {
+			return r, err
+		}
+		if in.Op == OP_JUMPI {
+			cond, err := m.pop()
+			if err != nil {
+				return r, err
+			}
+			if !bytecode.AsBool(cond) {
+				return r, nil
+			}
+		}
+		idx, ok := p.dests[int(target)]
+		if !ok || target < 0 {
+			return r, fmt.Errorf("%w: %d", ErrBadJump, target)
+		}
+		r.jump, r.target = true, idx
+		return r, nil
+	case OP_STOP:
+		r.halt = true
+		return r, nil
+	case OP_RETURN:
+		v, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		m.ret = v
+		r.halt = true
+		return r, nil
+	case OP_REVERT:
+		msg, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		return r, fmt.Errorf("%w: %q", ErrReverted, msg)
+	case OP_VERIFY:
+		v, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		if !bytecode.AsBool(v) {
+			return r, ErrVerifyFailed
+		}
+		return r, nil
+
+	case OP_DROP:
+		_, err := m.pop()
+		return r, err
+	case OP_DUP, OP_OVER:
+		depth := 0
+		if in.Op == OP_OVER {
+			depth = 1
+		}
+		b, err := m.peek(depth)
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(b)
+	case OP_PICK:
+		n, err := m.popNum()
+		if err != nil {
+			return r, err
+		}
+		if n < 0 || n >= int64(len(m.stack)) {
+			return r, ErrStackUnderflow
+		}
+		b, _ := m.peek(int(n))
+		return r, m.push(b)
+	case OP_ROT:
+		if len(m.stack) < 3 {
+			return r, ErrStackUnderflow
+		}
+		n := len(m.stack)
+		m.stack[n-3], m.stack[n-2], m.stack[n-1] = m.stack[n-2], m.stack[n-1], m.stack[n-3]
+		return r, nil
+	case OP_SWAP:
+		if len(m.stack) < 2 {
+			return r, ErrStackUnderflow
+		}
+		n := len(m.stack)
+		m.stack[n-1], m.stack[n-2] = m.stack[n-2], m.stack[n-1]
+		return r, nil
+	case OP_CAT:
+		b, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		a, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(append(append([]byte(nil), a...), b...))
+	case OP_SIZE:
+		b, err := m.peek(0)
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(bytecode.EncodeNum(int64(len(b))))
+
+	case OP_EQUAL:
+		b, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		a, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(bytecode.FromBool(bytes.Equal(a, b)))
+	case OP_NOT:
+		n, err := m.popNum()
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(bytecode.FromBool(n == 0))
+	case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_MOD, OP_BOOLAND, OP_BOOLOR, OP_NUMEQUAL,
+		OP_LESSTHAN, OP_GREATERTHAN, OP_LESSTHANOREQUAL, OP_GREATERTHANOREQUAL:
+		return r, m.binaryNum(in.Op)
+	case OP_SHA256:
+		if err := m.useGas(gasHash); err != nil {
+			return r, err
+		}
+		b, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		sum := sha256.Sum256(b)
+		return r, m.push(sum[:])
+
+	case OP_CALLER:
+		return r, m.push([]byte(m.host.Caller()))
+	case OP_CALLVALUE:
+		return r, m.push(bytecode.EncodeNum(m.host.CallValue()))
+	case OP_SELF:
+		return r, m.push([]byte(m.host.Self()))
+	case OP_HEIGHT:
+		return r, m.push(bytecode.EncodeNum(int64(m.host.Height())))
+	case OP_BALANCE:
+		if err := m.useGas(gasBalance); err != nil {
+			return r, err
+		}
+		addr, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(bytecode.EncodeNum(m.host.Balance(string(addr))))
+
+	case OP_SLOAD:
+		if err := m.useGas(gasSLoad); err != nil {
+			return r, err
+		}
+		key, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		return r, m.push(m.host.Load(key))
+	case OP_SSTORE:
+		if err := m.useGas(gasSStore); err != nil {
+			return r, err
+		}
+		value, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		key, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		if len(key) == 0 {
+			return r, fmt.Errorf("%w: empty storage key", ErrBadCode)
+		}
+		m.host.Store(key, value)
+		return r, nil
+	case OP_TRANSFER:
+		if err := m.useGas(gasTransfer); err != nil {
+			return r, err
+		}
+		amount, err := m.popNum()
+		if err != nil {
+			return r, err
+		}
+		to, err := m.pop()
+		if err != nil {
+			return r, err
+		}
+		if amount <= 0 || len(to) == 0 {
+			return r, fmt.Errorf("%w: transfer %d to %q", ErrBadCode, amount, to)
+		}
+		return r, m.host.Transfer(string(to), amount)
+	}
+	return r, fmt.Errorf("%w: 0x%02x", ErrUnknownOpcode, in.Op)
+}
+
+// binaryNum 执行弹出两个数字、压入一个结果的算术 / 比较指令
+func (m *machine) binaryNum(op byte) error {
+	b, err := m.popNum()
+	if err != nil {
+		return err
+	}
+	a, err := m.popNum()
+	if err != nil {
+		return err
+	}
+
+	var v int64
+	switch op {
+	case OP_ADD, OP_SUB:
+		if op == OP_SUB {
+			b = -b
+		}
+		v = a + b
+		if (a > 0 && b > 0 && v < 0) || (a < 0 && b < 0 && v >= 0) {
+			return ErrNumOverflow
+		}
+	case OP_MUL:
+		v = a * b
+		if a != 0 && v/a != b {
+			return ErrNumOverflow
+		}
+	case OP_DIV, OP_MOD:
+		if b == 0 {
+			return ErrDivByZero
+		}
+		if op == OP_DIV {
+			v = a / b
+		} else {
+			v = a % b
+		}
+	case OP_BOOLAND:
+		return m.push(bytecode.FromBool(a != 0 && b != 0))
+	case OP_BOOLOR:
+		return m.push(bytecode.FromBool(a != 0 || b != 0))
+	case OP_NUMEQUAL:
+		return m.push(bytecode.FromBool(a == b))
+	case OP_LESSTHAN:
+		return m.push(bytecode.FromBool(a < b))
+	case OP_GREATERTHAN:
+		return m.push(bytecode.FromBool(a > b))
+	case OP_LESSTHANOREQUAL:
+		return m.push(bytecode.FromBool(a <= b))
+	case OP_GREATERTHANOREQUAL:
+		return m.push(bytecode.FromBool(a >= b))
+	}
+	return m.push(bytecode.EncodeNum(v))
+}
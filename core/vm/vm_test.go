@@ -0,0 +1,206 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"mychain/core/bytecode"
+)
+
+// fakeHost 是内存中的链上环境，转账从合约自己的余额中扣除
+type fakeHost struct {
+	balances map[string]int64
+	storage  map[string][]byte
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{balances: map[string]int64{"self": 10}, storage: make(map[string][]byte)}
+}
+
+func (h *fakeHost) Caller() string            { return "alice" }
+func (h *fakeHost) CallValue() int64          { return 3 }
+func (h *fakeHost) Self() string              { return "self" }
+func (h *fakeHost) Height() uint64            { return 7 }
+func (h *fakeHost) Balance(addr string) int64 { return h.balances[addr] }
+func (h *fakeHost) Load(key []byte) []byte    { return h.storage[string(key)] }
+func (h *fakeHost) Store(key, value []byte)   { h.storage[string(key)] = value }
+func (h *fakeHost) Transfer(to string, amount int64) error {
+	if h.balances["self"] < amount {
+		return fmt.Errorf("overspend: has %d, transfers %d", h.balances["self"], amount)
+	}
+	h.balances["self"] -= amount
+	h.balances[to] += amount
+	return nil
+}
+
+func asm(t *testing.T, src string) []byte {
+	t.Helper()
+	b, err := Assemble(src)
+	if err != nil {
+		t.Fatalf("assemble %q: %v", src, err)
+	}
+	return b
+}
+
+func TestExecute(t *testing.T) {
+	const maxInt = "0xffffffffffffff7f" // 8 字节所能表示的最大正数
+	tests := []struct {
+		name        string
+		input, code string
+		want        []byte // 成功时 OP_RETURN 的返回值
+		err         error
+	}{
+		// 跳转：只能跳到 OP_JUMPDEST
+		{"jump to label", "", "@end OP_JUMP 0 OP_RETURN end: 1 OP_RETURN", bytecode.EncodeNum(1), nil},
+		{"jumpi taken", "1", "@end OP_JUMPI 0 OP_RETURN end: 1 OP_RETURN", bytecode.EncodeNum(1), nil},
+		{"jumpi not taken", "0", "@end OP_JUMPI 2 OP_RETURN end: 1 OP_RETURN", bytecode.EncodeNum(2), nil},
+		{"jump to non-jumpdest", "", "1 OP_JUMP OP_NOP OP_STOP", nil, ErrBadJump},
+		{"jump past end", "", "100 OP_JUMP", nil, ErrBadJump},
+		{"jump negative", "", "-1 OP_JUMP", nil, ErrBadJump},
+		{"jump into push data", "", "0x62 1 OP_JUMP", nil, ErrBadJump},
+		{"jumpi empty stack", "", "@end OP_JUMPI end:", nil, ErrStackUnderflow},
+
+		// 算术溢出与除零
+		{"add", "2 3", "OP_ADD OP_RETURN", bytecode.EncodeNum(5), nil},
+		{"add overflow", maxInt + " 1", "OP_ADD", nil, ErrNumOverflow},
+		{"sub overflow", "-2 " + maxInt, "OP_SUB", nil, ErrNumOverflow},
+		{"mul", "-4 5", "OP_MUL OP_RETURN", bytecode.EncodeNum(-20), nil},
+		{"mul overflow", maxInt + " 2", "OP_MUL", nil, ErrNumOverflow},
+		{"div", "7 2", "OP_DIV OP_RETURN", bytecode.EncodeNum(3), nil},
+		{"div by zero", "7 0", "OP_DIV", nil, ErrDivByZero},
+		{"mod by zero", "7 0", "OP_MOD", nil, ErrDivByZero},
+		{"number too long", "0x000000000000000001 1", "OP_ADD", nil, ErrNumOverflow},
+
+		// 存储：空值表示删除，键不能为空
+		{"sstore then sload", "", "'k' 'v' OP_SSTORE 'k' OP_SLOAD OP_RETURN", []byte("v"), nil},
+		{"sload missing", "", "'missing' OP_SLOAD OP_SIZE OP_RETURN", nil, nil},
+		{"sstore empty key", "", "0x 'v' OP_SSTORE", nil, ErrBadCode},
+		{"sstore underflow", "", "'v' OP_SSTORE", nil, ErrStackUnderflow},
+
+		// 转账：金额必须为正，不能超过合约余额
+		{"transfer", "", "'bob' 10 OP_TRANSFER 'bob' OP_BALANCE OP_RETURN", bytecode.EncodeNum(10), nil},
+		{"transfer zero", "", "'bob' 0 OP_TRANSFER", nil, ErrBadCode},
+		{"transfer negative", "", "'bob' -1 OP_TRANSFER", nil, ErrBadCode},
+		{"transfer to empty address", "", "0x 1 OP_TRANSFER", nil, ErrBadCode},
+
+		// 链上环境
+		{"caller", "", "OP_CALLER OP_RETURN", []byte("alice"), nil},
+		{"callvalue and height", "", "OP_CALLVALUE OP_HEIGHT OP_ADD OP_RETURN", bytecode.EncodeNum(10), nil},
+
+		// 结束方式
+		{"stop", "", "OP_STOP 1 OP_RETURN", nil, nil},
+		{"revert", "", "'nope' OP_REVERT", nil, ErrReverted},
+		{"verify false", "0", "OP_VERIFY", nil, ErrVerifyFailed},
+		{"stack underflow", "", "OP_DROP", nil, ErrStackUnderflow},
+		{"cat too large", "0x" + string(bytes.Repeat([]byte("ab"), MaxElementSize)), "OP_DUP OP_CAT", nil, ErrElementTooLarge},
+	}
+	for _, tt := range tests {
+		res, err := Execute(asm(t, tt.code), asm(t, tt.input), newFakeHost(), 10000)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(res.Return, tt.want) {
+			t.Errorf("%s: return = %x, want %x", tt.name, res.Return, tt.want)
+		}
+	}
+}
+
+func TestExecuteTransferOverspend(t *testing.T) {
+	host := newFakeHost()
+	if _, err := Execute(asm(t, "'bob' 11 OP_TRANSFER"), nil, host, 1000); err == nil {
+		t.Fatal("transfer above the contract balance succeeded")
+	}
+	if host.balances["self"] != 10 || host.balances["bob"] != 0 {
+		t.Fatalf("balances after failed transfer: %v", host.balances)
+	}
+}
+
+func TestExecuteGas(t *testing.T) {
+	code := asm(t, "'k' 1 OP_SSTORE OP_STOP")
+	// 两次压栈 + SSTORE + STOP：4 * gasBase + gasSStore
+	want := uint64(4*gasBase + gasSStore)
+	res, err := Execute(code, nil, newFakeHost(), want)
+	if err != nil {
+		t.Fatalf("exact gas limit: %v", err)
+	}
+	if res.GasUsed != want {
+		t.Fatalf("gas used = %d, want %d", res.GasUsed, want)
+	}
+
+	// SSTORE 的 gas 不够时报错，消耗的 gas 等于上限，存储没有写入
+	host := newFakeHost()
+	limit := want - 2
+	res, err = Execute(code, nil, host, limit)
+	if !errors.Is(err, ErrOutOfGas) {
+		t.Fatalf("err = %v, want ErrOutOfGas", err)
+	}
+	if res.GasUsed != limit {
+		t.Fatalf("gas used on failure = %d, want %d", res.GasUsed, limit)
+	}
+	if len(host.storage) != 0 {
+		t.Fatal("storage written although the call ran out of gas")
+	}
+
+	// 死循环同样在 gas 耗尽时停止
+	if _, err := Execute(asm(t, "loop: @loop OP_JUMP"), nil, newFakeHost(), 1000); !errors.Is(err, ErrOutOfGas) {
+		t.Fatalf("infinite loop: err = %v, want ErrOutOfGas", err)
+	}
+	// 参数的压栈也消耗 gas
+	if _, err := Execute(nil, asm(t, "1 2 3"), newFakeHost(), 2); !errors.Is(err, ErrOutOfGas) {
+		t.Fatalf("input pushes: err = %v, want ErrOutOfGas", err)
+	}
+}
+
+func TestExecuteInput(t *testing.T) {
+	code := asm(t, "OP_RETURN")
+	tests := []struct {
+		name  string
+		input []byte
+		err   error
+	}{
+		{"push only", asm(t, "'x' 5"), nil},
+		{"opcode in input", asm(t, "1 OP_DUP"), ErrNotPushOnly},
+		{"sstore in input", asm(t, "'k' 'v' OP_SSTORE 1"), ErrNotPushOnly},
+		{"truncated push", []byte{0x05, 0x01}, bytecode.ErrBadBytecode},
+		{"input too large", bytecode.PushData(make([]byte, MaxInputSize)), ErrBadCode},
+	}
+	for _, tt := range tests {
+		host := newFakeHost()
+		_, err := Execute(code, tt.input, host, 1000)
+		if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if len(host.storage) != 0 {
+			t.Errorf("%s: input wrote to storage", tt.name)
+		}
+	}
+	if IsPushOnly(asm(t, "1 OP_DUP")) || !IsPushOnly(asm(t, "1 'a' 0x0102 -1")) {
+		t.Fatal("IsPushOnly misclassified input")
+	}
+}
+
+func TestAssembleLabels(t *testing.T) {
+	if _, err := Assemble("a: a: OP_STOP"); !errors.Is(err, bytecode.ErrBadAssembly) {
+		t.Errorf("duplicate label: err = %v, want ErrBadAssembly", err)
+	}
+	if _, err := Assemble("@missing OP_JUMP"); !errors.Is(err, bytecode.ErrBadAssembly) {
+		t.Errorf("undefined label: err = %v, want ErrBadAssembly", err)
+	}
+	if _, err := Assemble("OP_PUSHDATA1"); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("raw OP_PUSHDATA1: err = %v, want ErrUnknownOpcode", err)
+	}
+	// 标签的偏移就是 OP_JUMPDEST 所在的字节
+	code := asm(t, "OP_NOP 'abc' end: OP_STOP # 注释")
+	if code[5] != OP_JUMPDEST {
+		t.Fatalf("label not at offset 5: %x", code)
+	}
+}
@@ -0,0 +1,24 @@
+# 计数器合约
+#   inc        计数加一
+#   add <n>    计数加 n（n >= 1）
+#   get        返回当前计数
+# 调用参数的最后一项是方法名，按方法名跳转到对应的代码
+
+OP_DUP 'inc' OP_EQUAL @inc OP_JUMPI
+OP_DUP 'add' OP_EQUAL @add OP_JUMPI
+OP_DUP 'get' OP_EQUAL @get OP_JUMPI
+'unknown-method' OP_REVERT
+
+inc:
+    OP_DROP 1 'add'             # 换成 add 1
+add:
+    OP_DROP                     # 去掉方法名，栈上剩 [n]
+    OP_DUP 1 OP_GREATERTHANOREQUAL OP_VERIFY
+    'count' OP_DUP OP_SLOAD     # [n, 'count', 旧值]
+    OP_ROT OP_ADD               # ['count', 旧值 + n]
+    OP_SSTORE
+    OP_STOP
+
+get:
+    OP_DROP
+    'count' OP_SLOAD OP_RETURN
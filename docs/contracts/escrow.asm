@@ -0,0 +1,35 @@
+# 托管合约：买家把货款存进合约，由买家或仲裁人放款给卖家，或由卖家或仲裁人退款给买家
+#   <seller> <arbiter> init   买家调用（同时转入货款），登记买家、卖家与仲裁人，只能调用一次
+#   release                   买家或仲裁人调用：合约余额全部转给卖家
+#   refund                    卖家或仲裁人调用：合约余额全部退给买家
+
+OP_DUP 'init' OP_EQUAL @init OP_JUMPI
+OP_DUP 'release' OP_EQUAL @release OP_JUMPI
+OP_DUP 'refund' OP_EQUAL @refund OP_JUMPI
+'unknown-method' OP_REVERT
+
+init:
+    OP_DROP                                 # [seller, arbiter]
+    'buyer' OP_SLOAD OP_SIZE OP_NOT OP_VERIFY OP_DROP
+    'arbiter' OP_SWAP OP_SSTORE             # [seller]
+    'seller' OP_SWAP OP_SSTORE
+    'buyer' OP_CALLER OP_SSTORE
+    OP_STOP
+
+release:
+    OP_DROP
+    'buyer' OP_SLOAD OP_CALLER OP_EQUAL
+    'arbiter' OP_SLOAD OP_CALLER OP_EQUAL
+    OP_BOOLOR OP_VERIFY
+    'seller' @payout OP_JUMP
+
+refund:
+    OP_DROP
+    'seller' OP_SLOAD OP_CALLER OP_EQUAL
+    'arbiter' OP_SLOAD OP_CALLER OP_EQUAL
+    OP_BOOLOR OP_VERIFY
+    'buyer'
+
+payout:                                     # [收款人在存储中的键]
+    OP_SLOAD OP_SELF OP_BALANCE OP_TRANSFER
+    OP_STOP
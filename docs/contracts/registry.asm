@@ -0,0 +1,31 @@
+# 名字注册表合约：先到先得，只有名字的主人能转让
+#   <name> register             把名字登记到调用者名下
+#   <name> lookup               返回名字的主人
+#   <name> <new owner> transfer 把名字转让给新主人
+
+OP_DUP 'register' OP_EQUAL @register OP_JUMPI
+OP_DUP 'lookup' OP_EQUAL @lookup OP_JUMPI
+OP_DUP 'transfer' OP_EQUAL @transfer OP_JUMPI
+'unknown-method' OP_REVERT
+
+register:
+    OP_DROP                             # [name]
+    OP_DUP OP_SLOAD OP_SIZE             # [name, 主人, 长度]
+    OP_SWAP OP_DROP                     # [name, 长度]
+    @taken OP_JUMPI                     # 已有主人则失败，[name]
+    OP_CALLER OP_SSTORE
+    OP_STOP
+
+lookup:
+    OP_DROP
+    OP_SLOAD OP_RETURN
+
+transfer:
+    OP_DROP                             # [name, new]
+    OP_OVER OP_SLOAD OP_CALLER OP_EQUAL # 只有主人可以转让
+    OP_VERIFY
+    OP_SSTORE
+    OP_STOP
+
+taken:
+    'name-taken' OP_REVERT
@@ -1,6 +1,8 @@
 package node
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,8 +14,12 @@ import (
 
 // Config 保存一个节点的启动配置
 type Config struct {
-	Port  string
-	Peers []string
+	Port      string
+	Peers     []string
+	Network   string          // 内置网络名称：mainnet / testnet / regtest，空表示 mainnet
+	ChainSpec string          // 自定义网络的链配置文件路径，优先于 Network
+	Ledger    core.LedgerMode // 记账模型，空表示使用链配置中的模型
+	SignerKey string          // PoA / PoS / BFT：本节点签名者的私钥文件（PEM），为空时只同步和校验区块、不出块
 }
 
 // Node 表示一个完整节点（包含区块链、存储、P2P 服务器）
@@ -26,8 +32,27 @@ type Node struct {
 
 // NewNode 根据配置创建并初始化节点：加载/创建区块链，构造 P2PServer
 func NewNode(cfg Config) (*Node, error) {
-	// 1. 统一把所有链文件放到 data/ 子目录下，按端口区分
-	dataDir := "data"
+	// 1. 选择网络，读取链参数
+	params, err := core.LoadParams(cfg.Network, cfg.ChainSpec)
+	if err != nil {
+		return nil, fmt.Errorf("加载链参数失败: %w", err)
+	}
+	specLedger := params.Ledger
+	if cfg.Ledger != "" {
+		params.Ledger = cfg.Ledger
+		if err := params.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("网络: %s（networkId=%d，记账模型 %s，共识 %s）\n", params.Name, params.NetworkID, params.Ledger, params.Consensus.Engine)
+
+	// 2. 统一把所有链文件放到 data/<网络名>/ 子目录下，按端口区分，不同网络互不干扰；
+	//    --ledger 改用了与链配置不同的记账模型时是另一条链，放到 data/<网络名>-<记账模型>/，不与原来的链文件混用
+	dataName := params.Name
+	if params.Ledger != specLedger {
+		dataName += "-" + string(params.Ledger)
+	}
+	dataDir := filepath.Join("data", dataName)
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("创建数据目录失败: %w", err)
 	}
@@ -35,11 +60,11 @@ func NewNode(cfg Config) (*Node, error) {
 
 	fs := stor.NewFileStorage(chainFile)
 
-	// 2. 先尝试从本地文件加载已有区块链
-	bc, err := fs.Load()
+	// 3. 先尝试从本地文件加载已有区块链
+	bc, err := fs.Load(params)
 	if os.IsNotExist(err) {
 		fmt.Println("本地没有区块链文件，创建新链...")
-		bc = core.NewBlockchain()
+		bc = core.NewBlockchain(params)
 		if err := fs.Save(bc); err != nil {
 			return nil, fmt.Errorf("保存新建区块链失败: %w", err)
 		}
@@ -47,24 +72,29 @@ func NewNode(cfg Config) (*Node, error) {
 		return nil, fmt.Errorf("加载区块链失败: %w", err)
 	}
 
-	// 2.5 基于当前区块链重建一次余额表（旧文件中没有 Balances 字段也没有关系）
-	bc.RebuildBalances()
+	// 3.5 PoA / PoS / BFT：用签名者私钥授权本节点出块（BFT 中还用它投票）
+	if cfg.SignerKey != "" {
+		if err := authorizeSigner(bc, cfg.SignerKey); err != nil {
+			return nil, err
+		}
+	}
 
-	// 3. 基于当前链和存储创建 P2P 服务器
+	// 4. 基于当前链和存储创建 P2P 服务器
 	server := p2p.NewServer(cfg.Port, bc, fs)
 
-	// 4. 添加配置中的邻居节点
+	// 5. 添加配置中的邻居节点
 	for _, p := range cfg.Peers {
 		if p != "" {
 			server.AddPeer(p)
 		}
 	}
 
-	// 5. 启动前先尝试和邻居同步一次“最长链”
-	fmt.Println("在节点启动前，从已配置的邻居节点尝试同步区块链...")
+	// 6. 与邻居握手交换时间，得到网络调整时间；再尝试和邻居同步一次“最长链”
+	fmt.Println("在节点启动前，与已配置的邻居节点握手并尝试同步区块链...")
+	server.Handshake()
 	server.SyncWithPeers()
 
-	// 6. 构造 Node 返回
+	// 7. 构造 Node 返回
 	n := &Node{
 		Config:  cfg,
 		BC:      bc,
@@ -80,3 +110,28 @@ func (n *Node) Start() {
 	fmt.Println("当前链区块数：", len(n.BC.Blocks))
 	n.Server.Start()
 }
+
+// authorizeSigner 读取 PEM 私钥，设置为 PoA / PoS / BFT 引擎的签名者
+func authorizeSigner(bc *core.Blockchain, path string) error {
+	engine, ok := bc.Engine.(core.SignerEngine)
+	if !ok {
+		return fmt.Errorf("--signer-key 只能用于 %s、%s 或 %s 共识，当前网络为 %s", core.EnginePoA, core.EnginePoS, core.EngineBFT, bc.Engine.Name())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取签名者私钥失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return fmt.Errorf("%s 不是 EC 私钥 PEM", path)
+	}
+	priv, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("解析签名者私钥失败: %w", err)
+	}
+	if err := engine.Authorize(priv); err != nil {
+		return err
+	}
+	fmt.Println("签名者:", engine.Signer())
+	return nil
+}
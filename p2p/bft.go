@@ -0,0 +1,717 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// BFT 共识的轮次状态机（规则见 core/bft.go，按 Tendermint 论文中的算法实现）：
+//   - 提议与投票通过 POST /bft/proposal、/bft/vote 在节点之间传播；节点第一次收到某条消息时转发给自己的邻居，
+//     因此验证者之间不必两两相连；每个验证者还会定期重发自己在当前轮的消息，重新上线的邻居能补上错过的投票
+//   - HTTP 处理函数只做与状态无关的签名检查，之后把消息交给一个协程（runBFT）按顺序处理；
+//     状态机需要读写区块链时才获取 s.mu，而处理函数从不等待状态机，两者不会互相等待
+//   - 区块提交后广播带提交证书的区块（/newblock），等待 targetBlockSeconds 秒再开始下一个高度
+//   - 收到更高高度的消息说明本节点落后了，向发送方同步整条链
+//
+// 每个 BFT 节点都运行状态机：没有验证者私钥的节点不提议、不投票，只转发消息并跟随提交。
+
+const (
+	bftInboxSize   = 1024                   // 等待状态机处理的消息上限，超过时丢弃新消息（超时会让轮次继续）
+	bftRetryDelay  = 100 * time.Millisecond // 队列满时超时的重新投递间隔
+	bftRoundWindow = 8                      // 只记录比当前轮次最多高这么多轮的消息，防止验证者用任意轮次撑大 rounds
+)
+
+type bftStep uint8
+
+const (
+	stepNewHeight bftStep = iota // 已提交上一个区块，等待开始第 0 轮
+	stepPropose
+	stepPrevote
+	stepPrecommit
+)
+
+func (s bftStep) String() string {
+	switch s {
+	case stepNewHeight:
+		return "new-height"
+	case stepPropose:
+		return "propose"
+	case stepPrevote:
+		return "prevote"
+	case stepPrecommit:
+		return "precommit"
+	}
+	return "unknown"
+}
+
+// bftMessage 是交给状态机的消息：提议、投票、超时或定时重发，都为空时只检查主链是否已经前进
+type bftMessage struct {
+	proposal *core.Proposal
+	vote     *core.Vote
+	timeout  *bftTimeout
+	resend   bool   // 重新广播本节点在当前轮的提议与投票
+	from     string // 发送方地址，本节点落后时从它同步
+}
+
+// bftTimeout 是某个高度、某一轮中某一步的超时；step 为 stepNewHeight 时表示开始第 0 轮
+type bftTimeout struct {
+	height uint64
+	round  uint32
+	step   bftStep
+}
+
+// bftRound 记录当前高度某一轮收到的提议与投票
+type bftRound struct {
+	proposal   *core.Proposal
+	valid      bool                  // 提议的区块在父区块的状态上执行通过
+	prevotes   map[string]*core.Vote // 验证者地址 → prevote，每个验证者只记第一票
+	precommits map[string]*core.Vote
+
+	// 下面的规则每一轮只触发一次
+	prevoteWait   bool // 已收到超过 2/3 的 prevote，启动了 prevote 超时
+	precommitWait bool // 已收到超过 2/3 的 precommit，启动了 precommit 超时
+	polSeen       bool // 本轮提议已得到超过 2/3 的 prevote
+	commitTried   bool // 已尝试提交本轮的区块
+}
+
+// count 返回投给 hash 的票数（hash 为 nil 时统计投空的票）
+func count(votes map[string]*core.Vote, hash []byte) int {
+	n := 0
+	for _, v := range votes {
+		if bytes.Equal(v.BlockHash, hash) {
+			n++
+		}
+	}
+	return n
+}
+
+// voters 返回在本轮提议或投过票的不同验证者数量
+func (r *bftRound) voters() int {
+	seen := make(map[string]bool)
+	if r.proposal != nil {
+		seen[utils.PubKeyToAddress(r.proposal.Proposer)] = true
+	}
+	for addr := range r.prevotes {
+		seen[addr] = true
+	}
+	for addr := range r.precommits {
+		seen[addr] = true
+	}
+	return len(seen)
+}
+
+// BFTStatus 是 /stats 中 BFT 状态机的状态
+type BFTStatus struct {
+	Height      uint64     `json:"height"`               // 正在共识的高度
+	Round       uint32     `json:"round"`                // 当前轮次
+	Step        string     `json:"step"`                 // new-height / propose / prevote / precommit
+	Proposer    string     `json:"proposer"`             // 当前轮次的提议者
+	LockedRound int32      `json:"lockedRound"`          // 锁定区块的轮次，-1 表示没有锁定
+	Validators  int        `json:"validators"`           // 验证者数量
+	Quorum      int        `json:"quorum"`               // 提交所需的票数
+	LastCommit  *BFTCommit `json:"lastCommit,omitempty"` // 本节点最近提交的区块
+}
+
+// BFTCommit 描述本节点提交的一个区块
+type BFTCommit struct {
+	Height uint64 `json:"height"`
+	Round  uint32 `json:"round"` // 在第几轮提交
+	Votes  int    `json:"votes"` // 提交证书中的 precommit 数
+}
+
+// bftState 是 BFT 状态机。除 inbox、syncing 与 status 外，所有字段只由 runBFT 协程访问
+type bftState struct {
+	engine  *core.BFT
+	inbox   chan bftMessage
+	syncing atomic.Bool // 是否正在向邻居同步整条链
+
+	height uint64
+	round  uint32
+	step   bftStep
+	rounds map[uint32]*bftRound
+	future []bftMessage // 下一个高度的消息，进入该高度时重新处理
+
+	lockedRound int32
+	lockedBlock *core.Block
+	validRound  int32
+	validBlock  *core.Block
+
+	statusMu sync.Mutex
+	status   BFTStatus
+}
+
+// startBFT 在 BFT 网络中启动状态机，其他共识引擎什么也不做
+func (s *P2PServer) startBFT() {
+	engine, ok := s.BC.Engine.(*core.BFT)
+	if !ok {
+		return
+	}
+	s.bft = &bftState{engine: engine, inbox: make(chan bftMessage, bftInboxSize)}
+	s.mu.Lock()
+	next := s.BC.NextHeight()
+	s.mu.Unlock()
+	s.enterHeight(next)
+	s.bft.publish()
+	go s.runBFT()
+
+	// 邻居离线期间错过的投票不会再收到，定期重发本节点当前轮的消息，邻居重新上线后各节点才能凑够票数
+	go func() {
+		for range time.Tick(engine.Timeout(0)) {
+			s.bft.deliver(bftMessage{resend: true})
+		}
+	}()
+}
+
+// runBFT 依次处理状态机的消息，每条消息之后反复应用规则，直到没有规则可以触发
+func (s *P2PServer) runBFT() {
+	b := s.bft
+	for msg := range b.inbox {
+		s.handleBFTMessage(msg)
+		for s.applyBFTRules() {
+		}
+		b.publish()
+	}
+}
+
+// deliver 把消息交给状态机，队列满时丢弃
+func (b *bftState) deliver(msg bftMessage) {
+	select {
+	case b.inbox <- msg:
+	default:
+		fmt.Println("[bft] 消息队列已满，丢弃消息")
+	}
+}
+
+// notifyBFT 在主链变化后让状态机检查是否要进入新的高度，非 BFT 网络什么也不做
+func (s *P2PServer) notifyBFT() {
+	if s.bft != nil {
+		s.bft.deliver(bftMessage{})
+	}
+}
+
+// Status 返回状态机最近一次处理完消息后的状态
+func (b *bftState) Status() BFTStatus {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	return b.status
+}
+
+func (b *bftState) publish() {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	b.status.Height = b.height
+	b.status.Round = b.round
+	b.status.Step = b.step.String()
+	b.status.Proposer = b.engine.Proposer(b.height, b.round)
+	b.status.LockedRound = b.lockedRound
+	b.status.Validators = b.engine.Validators()
+	b.status.Quorum = b.engine.Quorum()
+}
+
+// roundState 返回当前高度第 round 轮的记录，没有时创建
+func (b *bftState) roundState(round uint32) *bftRound {
+	r, ok := b.rounds[round]
+	if !ok {
+		r = &bftRound{prevotes: make(map[string]*core.Vote), precommits: make(map[string]*core.Vote)}
+		b.rounds[round] = r
+	}
+	return r
+}
+
+// enterHeight 清空已提交高度的状态（各轮的提议与投票只保留当前高度的），等待 targetBlockSeconds 秒后开始第 0 轮，
+// 期间收到的消息照常记录
+func (s *P2PServer) enterHeight(height uint64) {
+	b := s.bft
+	b.height = height
+	b.round = 0
+	b.step = stepNewHeight
+	b.rounds = make(map[uint32]*bftRound)
+	b.lockedRound, b.lockedBlock = -1, nil
+	b.validRound, b.validBlock = -1, nil
+
+	delay := time.Duration(s.BC.Params.TargetBlockSeconds) * time.Second
+	s.scheduleBFT(bftTimeout{height: height, step: stepNewHeight}, delay)
+
+	future := b.future
+	b.future = nil
+	for _, msg := range future {
+		s.handleBFTMessage(msg)
+	}
+}
+
+// startRound 进入当前高度的第 round 轮：轮到本节点时提议区块（有已见过超过 2/3 prevote 的区块时重新提议它），
+// 并启动 propose 超时
+func (s *P2PServer) startRound(round uint32) {
+	b := s.bft
+	b.round = round
+	b.step = stepPropose
+	fmt.Printf("[bft] 高度 %d 第 %d 轮开始，提议者 %s\n", b.height, round, DisplayName(b.engine.Proposer(b.height, round)))
+
+	if me := b.engine.Signer(); me != "" && b.engine.Proposer(b.height, round) == me {
+		p := &core.Proposal{Height: b.height, Round: round, POLRound: -1}
+		if b.validBlock != nil {
+			p.Block, p.POLRound = b.validBlock, b.validRound
+		} else {
+			p.Block = s.buildBFTBlock()
+		}
+		if p.Block != nil && b.engine.SignProposal(p) == nil {
+			s.recordProposal(p)
+		}
+	}
+	s.scheduleBFT(bftTimeout{height: b.height, round: round, step: stepPropose}, b.engine.Timeout(round))
+}
+
+// buildBFTBlock 用交易池构造并签名下一个区块，coinbase 奖励打给本节点的验证者地址
+func (s *P2PServer) buildBFTBlock() *core.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.BC.NextHeight() != s.bft.height {
+		return nil
+	}
+	block, err := s.BC.NewBlockTemplate(s.blockTxs(s.bft.engine.Signer()))
+	if err == nil {
+		err = s.BC.Engine.Seal(context.Background(), &block)
+	}
+	if err != nil {
+		fmt.Println("[bft] 构造区块失败:", err)
+		return nil
+	}
+	return &block
+}
+
+// scheduleBFT 在 d 之后把超时交给状态机。计时器协程从不阻塞：队列满时过 bftRetryDelay 再投递，
+// 超时不能像普通消息那样丢弃，否则停在某一步的状态机可能再也不会前进
+func (s *P2PServer) scheduleBFT(t bftTimeout, d time.Duration) {
+	time.AfterFunc(d, func() {
+		select {
+		case s.bft.inbox <- bftMessage{timeout: &t}:
+		default:
+			s.scheduleBFT(t, bftRetryDelay)
+		}
+	})
+}
+
+// handleBFTMessage 记录一条消息或处理超时；下一个高度的消息先暂存，更高的高度说明本节点落后，向发送方同步。
+// 轮次超出 bftRoundWindow 的消息直接丢弃（下一个高度从第 0 轮算起）
+func (s *P2PServer) handleBFTMessage(msg bftMessage) {
+	b := s.bft
+	if msg.timeout != nil {
+		s.onTimeout(*msg.timeout)
+		return
+	}
+	if msg.resend {
+		s.resendBFT()
+		return
+	}
+
+	var height uint64
+	var round uint32
+	switch {
+	case msg.proposal != nil:
+		height, round = msg.proposal.Height, msg.proposal.Round
+	case msg.vote != nil:
+		height, round = msg.vote.Height, msg.vote.Round
+	default:
+		return
+	}
+	switch {
+	case height < b.height:
+		return
+	case height == b.height+1:
+		if round <= bftRoundWindow && len(b.future) < bftInboxSize {
+			b.future = append(b.future, msg)
+		}
+		// 超过 f 个验证者已经进入下一个高度，本节点多半错过了区块的提交
+		if b.futureVoters() > (b.engine.Validators()-1)/3 {
+			s.catchUp(msg.from)
+		}
+		return
+	case height > b.height+1:
+		s.catchUp(msg.from)
+		return
+	}
+	if round > b.round && round-b.round > bftRoundWindow {
+		return
+	}
+
+	if msg.proposal != nil {
+		s.recordProposal(msg.proposal)
+	} else {
+		s.recordVote(msg.vote)
+	}
+}
+
+// resendBFT 重新广播本节点在当前轮的提议与投票
+func (s *P2PServer) resendBFT() {
+	b := s.bft
+	me := b.engine.Signer()
+	r, ok := b.rounds[b.round]
+	if me == "" || !ok {
+		return
+	}
+	if r.proposal != nil && utils.PubKeyToAddress(r.proposal.Proposer) == me {
+		s.broadcastBFT("/bft/proposal", r.proposal)
+	}
+	for _, votes := range []map[string]*core.Vote{r.prevotes, r.precommits} {
+		if v, ok := votes[me]; ok {
+			s.broadcastBFT("/bft/vote", v)
+		}
+	}
+}
+
+// futureVoters 返回下一个高度已发过消息的不同验证者数量
+func (b *bftState) futureVoters() int {
+	seen := make(map[string]bool)
+	for _, msg := range b.future {
+		if msg.proposal != nil {
+			seen[utils.PubKeyToAddress(msg.proposal.Proposer)] = true
+		} else {
+			seen[msg.vote.Address()] = true
+		}
+	}
+	return len(seen)
+}
+
+// recordProposal 记录本轮的第一个提议并在父区块的状态上执行它的区块，第一次见到时转发给邻居
+func (s *P2PServer) recordProposal(p *core.Proposal) {
+	r := s.bft.roundState(p.Round)
+	if r.proposal != nil {
+		return
+	}
+	r.proposal = p
+	r.valid = s.validBFTBlock(p.Block)
+	s.broadcastBFT("/bft/proposal", p)
+}
+
+// recordVote 记录验证者在某一轮的第一张同类选票，第一次见到时转发给邻居
+func (s *P2PServer) recordVote(v *core.Vote) {
+	r := s.bft.roundState(v.Round)
+	votes := r.prevotes
+	if v.Type == core.VotePrecommit {
+		votes = r.precommits
+	}
+	addr := v.Address()
+	if _, ok := votes[addr]; ok {
+		return
+	}
+	votes[addr] = v
+	s.broadcastBFT("/bft/vote", v)
+}
+
+// validBFTBlock 检查区块能接在当前主链末尾：完整的区块校验，包括在父区块的状态上执行交易、核对状态根
+func (s *P2PServer) validBFTBlock(block *core.Block) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if block.Header.Height != s.BC.NextHeight() {
+		return false
+	}
+	if err := core.ValidateBlock(block, s.BC.NextBlockContext()); err != nil {
+		fmt.Println("[bft] 提议的区块不合法:", err)
+		return false
+	}
+	return true
+}
+
+// vote 用本节点的验证者私钥对当前轮投票（hash 为 nil 时投空）；不是验证者时什么也不做
+func (s *P2PServer) vote(t core.VoteType, hash []byte) {
+	b := s.bft
+	v := &core.Vote{Type: t, Height: b.height, Round: b.round, BlockHash: hash}
+	if err := b.engine.SignVote(v); err != nil {
+		return
+	}
+	s.recordVote(v)
+}
+
+// onTimeout 处理超时：仍停在超时对应的那一步时，投空或进入下一轮
+func (s *P2PServer) onTimeout(t bftTimeout) {
+	b := s.bft
+	if t.height != b.height {
+		return
+	}
+	switch t.step {
+	case stepNewHeight:
+		if b.step == stepNewHeight {
+			s.startRound(0)
+		}
+	case stepPropose:
+		if t.round == b.round && b.step == stepPropose {
+			s.vote(core.VotePrevote, nil)
+			b.step = stepPrevote
+		}
+	case stepPrevote:
+		if t.round == b.round && b.step == stepPrevote {
+			s.vote(core.VotePrecommit, nil)
+			b.step = stepPrecommit
+		}
+	case stepPrecommit:
+		if t.round == b.round {
+			s.startRound(b.round + 1)
+		}
+	}
+}
+
+// applyBFTRules 检查 Tendermint 的各条规则，触发了一条时返回 true（调用方会再检查一遍）
+func (s *P2PServer) applyBFTRules() bool {
+	b := s.bft
+	quorum := b.engine.Quorum()
+
+	// 主链已经到达或越过当前高度（收到了别的节点提交的区块，或者同步了邻居的链）
+	s.mu.Lock()
+	next := s.BC.NextHeight()
+	s.mu.Unlock()
+	if next > b.height {
+		s.enterHeight(next)
+		return true
+	}
+
+	// 任意一轮中，某个区块得到超过 2/3 的 precommit：提交
+	for round, r := range b.rounds {
+		if r.commitTried {
+			continue
+		}
+		for _, v := range r.precommits {
+			if v.BlockHash == nil || count(r.precommits, v.BlockHash) < quorum {
+				continue
+			}
+			if block := b.findBlock(v.BlockHash); block != nil {
+				r.commitTried = true
+				s.commit(round, block, r.precommits)
+				return true
+			}
+		}
+	}
+	if b.step == stepNewHeight {
+		return false
+	}
+
+	// 更高的轮次中已有超过 f 个验证者发言：至少有一个诚实的验证者已经在那一轮，直接跳过去
+	f := (b.engine.Validators() - 1) / 3
+	var skip uint32
+	for round, r := range b.rounds {
+		if round > b.round && round > skip && r.voters() > f {
+			skip = round
+		}
+	}
+	if skip > 0 {
+		s.startRound(skip)
+		return true
+	}
+
+	r := b.roundState(b.round)
+
+	// propose：收到本轮的提议后投 prevote。已锁定其他区块时投空，除非提议带着更晚一轮的超过 2/3 prevote
+	if b.step == stepPropose && r.proposal != nil {
+		p := r.proposal
+		hash := p.Block.Header.Hash
+		switch {
+		case p.POLRound == -1:
+			ok := r.valid && (b.lockedRound == -1 || bytes.Equal(b.lockedBlock.Header.Hash, hash))
+			s.vote(core.VotePrevote, hashIf(ok, hash))
+		case b.polRound(p.POLRound, hash):
+			ok := r.valid && (b.lockedRound <= p.POLRound || bytes.Equal(b.lockedBlock.Header.Hash, hash))
+			s.vote(core.VotePrevote, hashIf(ok, hash))
+		default:
+			return false // 等待 polRound 的 prevote
+		}
+		b.step = stepPrevote
+		return true
+	}
+
+	// prevote：收到超过 2/3 的 prevote（投给任何值）后启动 prevote 超时
+	if b.step == stepPrevote && len(r.prevotes) >= quorum && !r.prevoteWait {
+		r.prevoteWait = true
+		s.scheduleBFT(bftTimeout{height: b.height, round: b.round, step: stepPrevote}, b.engine.Timeout(b.round))
+		return true
+	}
+
+	// 本轮提议有效且得到超过 2/3 的 prevote：锁定并投 precommit，同时记为之后可以重新提议的区块
+	if b.step >= stepPrevote && r.proposal != nil && r.valid && !r.polSeen &&
+		count(r.prevotes, r.proposal.Block.Header.Hash) >= quorum {
+		r.polSeen = true
+		block := r.proposal.Block
+		if b.step == stepPrevote {
+			b.lockedRound, b.lockedBlock = int32(b.round), block
+			s.vote(core.VotePrecommit, block.Header.Hash)
+			b.step = stepPrecommit
+		}
+		b.validRound, b.validBlock = int32(b.round), block
+		return true
+	}
+
+	// prevote：超过 2/3 的 prevote 投给空，投空的 precommit
+	if b.step == stepPrevote && count(r.prevotes, nil) >= quorum {
+		s.vote(core.VotePrecommit, nil)
+		b.step = stepPrecommit
+		return true
+	}
+
+	// 收到超过 2/3 的 precommit（投给任何值）后启动 precommit 超时，超时后进入下一轮
+	if len(r.precommits) >= quorum && !r.precommitWait {
+		r.precommitWait = true
+		s.scheduleBFT(bftTimeout{height: b.height, round: b.round, step: stepPrecommit}, b.engine.Timeout(b.round))
+		return true
+	}
+	return false
+}
+
+// polRound 判断第 round 轮已有超过 2/3 的 prevote 投给 hash
+func (b *bftState) polRound(round int32, hash []byte) bool {
+	r, ok := b.rounds[uint32(round)]
+	return ok && count(r.prevotes, hash) >= b.engine.Quorum()
+}
+
+// findBlock 在当前高度收到的提议中查找哈希为 hash 的区块
+func (b *bftState) findBlock(hash []byte) *core.Block {
+	for _, r := range b.rounds {
+		if r.proposal != nil && bytes.Equal(r.proposal.Block.Header.Hash, hash) {
+			return r.proposal.Block
+		}
+	}
+	return nil
+}
+
+func hashIf(ok bool, hash []byte) []byte {
+	if ok {
+		return hash
+	}
+	return nil
+}
+
+// commit 把第 round 轮的 precommit 作为提交证书附在区块上，接入主链并广播
+func (s *P2PServer) commit(round uint32, block *core.Block, precommits map[string]*core.Vote) {
+	b := s.bft
+	var votes []*core.Vote
+	for _, v := range precommits {
+		if bytes.Equal(v.BlockHash, block.Header.Hash) {
+			votes = append(votes, v)
+		}
+	}
+	committed := *block
+	committed.Commit = core.NewCommitCert(b.height, round, block.Header.Hash, votes)
+
+	s.mu.Lock()
+	_, err := s.processBlock(committed, "")
+	s.mu.Unlock()
+	if err != nil {
+		fmt.Println("[bft] 提交区块失败:", err)
+		return
+	}
+	fmt.Printf("[bft] 高度 %d 在第 %d 轮提交，%d 票，Hash: %s\n",
+		b.height, round, len(votes), utils.ToHex(block.Header.Hash))
+
+	b.statusMu.Lock()
+	b.status.LastCommit = &BFTCommit{Height: b.height, Round: round, Votes: len(votes)}
+	b.statusMu.Unlock()
+
+	go s.BroadcastBlock(&committed)
+	s.enterHeight(b.height + 1)
+}
+
+// catchUp 在后台向 peer 同步整条链（同一时间只同步一次），完成后通知状态机
+func (s *P2PServer) catchUp(peer string) {
+	if peer == "" || !s.bft.syncing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.bft.syncing.Store(false)
+
+		fmt.Println("[bft] 本节点落后，从", peer, "同步区块链")
+		blocks, err := s.fetchChainFromPeer(peer)
+		if err != nil {
+			fmt.Println("[bft] 同步失败:", err)
+			return
+		}
+		s.mu.Lock()
+		update, err := s.BC.ImportChain(blocks)
+		if len(update.Connected) > 0 {
+			s.applyChainUpdate(update)
+			if err := s.Storage.Save(s.BC); err != nil {
+				fmt.Println("[bft] 保存区块链失败:", err)
+			}
+		}
+		s.mu.Unlock()
+		if err != nil {
+			fmt.Println("[bft] 邻居的链中存在不合法区块:", err)
+		}
+		s.notifyBFT()
+	}()
+}
+
+// broadcastBFT 在后台把提议或投票发给所有邻居（附带本节点地址，对方落后时从这里同步）。
+// 邻居列表在持有 s.mu 时复制，调用方不能持有 s.mu
+func (s *P2PServer) broadcastBFT(path string, msg interface{}) {
+	data, _ := json.Marshal(msg)
+	s.mu.Lock()
+	peers := append([]string(nil), s.Peers...)
+	s.mu.Unlock()
+
+	go func() {
+		for _, peer := range peers {
+			resp, err := httpClient.Post(peer+path+"?from="+s.SelfURL, "application/json", bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+		}
+	}()
+}
+
+// /bft/proposal：接收提议，检查签名与提议者后交给状态机
+func (s *P2PServer) handleBFTProposal(w http.ResponseWriter, r *http.Request) {
+	if s.bft == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "not a BFT network")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	var p core.Proposal
+	if err := json.Unmarshal(body, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.bft.engine.VerifyProposal(&p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err)
+		return
+	}
+	s.bft.deliver(bftMessage{proposal: &p, from: r.URL.Query().Get("from")})
+	w.WriteHeader(http.StatusOK)
+}
+
+// /bft/vote：接收投票，检查签名与投票者后交给状态机
+func (s *P2PServer) handleBFTVote(w http.ResponseWriter, r *http.Request) {
+	if s.bft == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "not a BFT network")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	var v core.Vote
+	if err := json.Unmarshal(body, &v); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := s.bft.engine.VerifyVote(&v); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err)
+		return
+	}
+	s.bft.deliver(bftMessage{vote: &v, from: r.URL.Query().Get("from")})
+	w.WriteHeader(http.StatusOK)
+}
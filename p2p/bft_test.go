@@ -0,0 +1,188 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mychain/core"
+	"mychain/storage"
+	"mychain/utils"
+)
+
+// bftNetwork 返回一个有 n 个验证者的 BFT 网络（其余参数与回归测试网络相同）以及验证者的私钥
+func bftNetwork(t *testing.T, n int) (*core.ChainParams, []*ecdsa.PrivateKey) {
+	t.Helper()
+	p, err := core.LoadNetwork("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Consensus = core.ConsensusSpec{Engine: core.EngineBFT, RoundTimeoutMs: 200}
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		priv, pub, err := utils.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = priv
+		p.Consensus.Signers = append(p.Consensus.Signers, utils.PubKeyToAddress(pub))
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return p, keys
+}
+
+// startBFTNodes 创建有 n 个验证者的 BFT 网络，在本进程中启动前 online 个验证者的节点并互为邻居，
+// 其余验证者相当于离线
+func startBFTNodes(t *testing.T, n, online int) []*P2PServer {
+	t.Helper()
+	p, keys := bftNetwork(t, n)
+
+	// 状态机的协程在测试结束后不会退出，可能还在写链文件，删除目录时忽略错误
+	dir, err := os.MkdirTemp("", "bft-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	var nodes []*P2PServer
+	for i := 0; i < online; i++ {
+		bc := core.NewBlockchain(p)
+		if err := bc.Engine.(*core.BFT).Authorize(keys[i]); err != nil {
+			t.Fatal(err)
+		}
+		store := storage.NewFileStorage(filepath.Join(dir, fmt.Sprintf("chain_%d.json", i)))
+		s := NewServer("", bc, store)
+		hs := httptest.NewServer(s.Handler())
+		t.Cleanup(hs.Close)
+		s.SelfURL = hs.URL
+		nodes = append(nodes, s)
+	}
+	for _, s := range nodes {
+		for _, peer := range nodes {
+			if peer != s {
+				s.Peers = append(s.Peers, peer.SelfURL)
+			}
+		}
+	}
+	for _, s := range nodes {
+		s.startBFT()
+	}
+	return nodes
+}
+
+// waitForHeight 等待所有节点的主链都到达 height，返回每个节点的主链
+func waitForHeight(t *testing.T, nodes []*P2PServer, height uint64) [][]core.Block {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var chains [][]core.Block
+		for _, s := range nodes {
+			s.mu.Lock()
+			if uint64(len(s.BC.Blocks)) > height {
+				chains = append(chains, append([]core.Block(nil), s.BC.Blocks[:height+1]...))
+			}
+			s.mu.Unlock()
+		}
+		if len(chains) == len(nodes) {
+			return chains
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d nodes reached height %d", len(chains), len(nodes), height)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// checkCommitted 检查各节点的主链完全相同，并且每个区块都带有有效的提交证书
+func checkCommitted(t *testing.T, nodes []*P2PServer, chains [][]core.Block) {
+	t.Helper()
+	engine := nodes[0].BC.Engine.(*core.BFT)
+	for h, b := range chains[0][1:] {
+		if err := engine.VerifyCommit(&b); err != nil {
+			t.Fatalf("height %d: %v", h+1, err)
+		}
+		for i, chain := range chains[1:] {
+			if !bytes.Equal(chain[h+1].Header.Hash, b.Header.Hash) {
+				t.Fatalf("height %d: node %d committed a different block", h+1, i+1)
+			}
+		}
+	}
+}
+
+func TestBFTNodesCommitBlocks(t *testing.T) {
+	nodes := startBFTNodes(t, 4, 4)
+	chains := waitForHeight(t, nodes, 3)
+	checkCommitted(t, nodes, chains)
+
+	for i, s := range nodes {
+		if st := s.bft.Status(); st.LastCommit == nil || st.Validators != 4 || st.Quorum != 3 {
+			t.Fatalf("node %d status: %+v", i, st)
+		}
+	}
+}
+
+func TestBFTNodesCommitWithOneValidatorOffline(t *testing.T) {
+	// 第 4 个验证者离线：轮到它提议的高度（高度 3）第 0 轮超时，由下一轮的提议者提交
+	nodes := startBFTNodes(t, 4, 3)
+	chains := waitForHeight(t, nodes, 4)
+	checkCommitted(t, nodes, chains)
+
+	if c := chains[0][3].Commit; c.Round == 0 {
+		t.Fatal("height 3 committed in round 0 although its proposer is offline")
+	}
+	for h := 1; h <= 4; h++ {
+		if n := len(chains[0][h].Commit.Precommits); n != 3 {
+			t.Fatalf("height %d committed with %d precommits, want 3", h, n)
+		}
+	}
+}
+
+func TestBFTBoundsRounds(t *testing.T) {
+	p, keys := bftNetwork(t, 4)
+	bc := core.NewBlockchain(p)
+	s := NewServer("", bc, storage.NewFileStorage(filepath.Join(t.TempDir(), "chain.json")))
+	// 不启动状态机协程，直接调用 handleBFTMessage
+	s.bft = &bftState{engine: bc.Engine.(*core.BFT), inbox: make(chan bftMessage, bftInboxSize)}
+	s.enterHeight(1)
+	b := s.bft
+
+	engine := core.NewBFT(p)
+	if err := engine.Authorize(keys[1]); err != nil {
+		t.Fatal(err)
+	}
+	vote := func(height uint64, round uint32) bftMessage {
+		v := &core.Vote{Type: core.VotePrevote, Height: height, Round: round}
+		if err := engine.SignVote(v); err != nil {
+			t.Fatal(err)
+		}
+		return bftMessage{vote: v}
+	}
+
+	// 当前高度：窗口内的轮次照常记录，更高的轮次丢弃
+	for _, round := range []uint32{0, bftRoundWindow, bftRoundWindow + 1, math.MaxUint32} {
+		s.handleBFTMessage(vote(1, round))
+	}
+	if _, ok := b.rounds[bftRoundWindow]; len(b.rounds) != 2 || !ok {
+		t.Fatalf("recorded %d rounds, want rounds 0 and %d", len(b.rounds), bftRoundWindow)
+	}
+	// 下一个高度从第 0 轮算起
+	s.handleBFTMessage(vote(2, 1))
+	s.handleBFTMessage(vote(2, bftRoundWindow+1))
+	if len(b.future) != 1 {
+		t.Fatalf("buffered %d messages for the next height, want 1", len(b.future))
+	}
+
+	// 进入下一个高度后只剩暂存的那张投票
+	s.enterHeight(2)
+	if r, ok := b.rounds[1]; len(b.rounds) != 1 || !ok || len(r.prevotes) != 1 || len(b.future) != 0 {
+		t.Fatalf("after entering height 2: %d rounds, %d buffered", len(b.rounds), len(b.future))
+	}
+}
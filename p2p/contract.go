@@ -0,0 +1,139 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mychain/core"
+	"mychain/core/bytecode"
+	"mychain/core/vm"
+)
+
+// /contract?addr=<合约地址>：返回合约代码（汇编与 hex）、余额以及全部存储
+func (s *P2PServer) handleContract(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	addr := r.URL.Query().Get("addr")
+	if addr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "missing addr parameter"}`))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := s.BC.State.Code[addr]
+	if code == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": %q}`, core.ErrNotContract.Error())
+		return
+	}
+	asm, _ := vm.Disassemble(code)
+
+	type entry struct {
+		Key   string `json:"key"`           // hex
+		Text  string `json:"text"`          // 键按文本显示
+		Value string `json:"value"`         // hex
+		Num   *int64 `json:"num,omitempty"` // 值能按数字解析时给出
+	}
+	var storage []entry
+	for _, e := range s.BC.State.StorageOf(addr) {
+		item := entry{Key: hex.EncodeToString(e.Key), Text: string(e.Key), Value: hex.EncodeToString(e.Value)}
+		if n, err := bytecode.DecodeNum(e.Value); err == nil {
+			item.Num = &n
+		}
+		storage = append(storage, item)
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Address string  `json:"address"`
+		Balance int64   `json:"balance"`
+		Code    string  `json:"code"`
+		Asm     string  `json:"asm"`
+		Storage []entry `json:"storage"`
+	}{addr, s.BC.GetBalance(addr), hex.EncodeToString(code), asm, storage})
+}
+
+// /contract/call?addr=<合约地址>&method=<方法名>&args=<汇编参数>&from=<调用者>：
+// 在最新状态上只读地调用合约，不上链、不消耗余额，返回值与消耗的 gas。
+// 也可以用 input=<hex> 直接给出编码好的调用参数（此时忽略 method / args）
+func (s *P2PServer) handleContractCall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	addr := q.Get("addr")
+	if addr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "missing addr parameter"}`))
+		return
+	}
+	input, err := callInput(q.Get("input"), q.Get("args"), q.Get("method"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.BC.CallContract(ResolveAddress(q.Get("from")), addr, input)
+	if errors.Is(err, core.ErrNotContract) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	resp := struct {
+		Return  string `json:"return"` // hex
+		Num     *int64 `json:"num,omitempty"`
+		GasUsed uint64 `json:"gasUsed"`
+		Error   string `json:"error,omitempty"`
+	}{Return: hex.EncodeToString(res.Return), GasUsed: res.GasUsed}
+	if n, err := bytecode.DecodeNum(res.Return); err == nil && len(res.Return) > 0 {
+		resp.Num = &n
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// callInput 组装调用参数：给出 hex 时直接使用，否则把汇编参数与方法名依次压栈
+func callInput(rawHex, args, method string) ([]byte, error) {
+	if rawHex != "" {
+		return hex.DecodeString(rawHex)
+	}
+	input, err := vm.Assemble(args)
+	if err != nil {
+		return nil, err
+	}
+	if method != "" {
+		input = append(input, bytecode.PushData([]byte(method))...)
+	}
+	if !vm.IsPushOnly(input) {
+		return nil, vm.ErrNotPushOnly
+	}
+	return input, nil
+}
+
+// /contract/receipt?tx=<hex>：返回合约交易的执行结果
+func (s *P2PServer) handleContractReceipt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hash := r.URL.Query().Get("tx")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.BC.State.Receipts[hash]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "receipt not found"}`))
+		return
+	}
+	json.NewEncoder(w).Encode(receipt)
+}
@@ -0,0 +1,173 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"mychain/utils"
+)
+
+// handshakeMsg 是节点之间握手时交换的信息：双方报告各自的网络、创世块、高度和本地时间，
+// 接收方据此记录对方的时钟偏差，用于计算网络调整时间
+type handshakeMsg struct {
+	From      string `json:"from"`      // 发送方地址
+	NetworkID uint32 `json:"networkId"` // 网络编号
+	Genesis   string `json:"genesis"`   // 创世块哈希（hex），与网络编号任一不一致说明不是同一条链
+	Height    uint64 `json:"height"`    // 主链高度
+	Time      int64  `json:"time"`      // 发送方的本地时间（Unix 毫秒，不含网络调整）
+}
+
+// localHandshake 生成本节点的握手信息（调用方需持有 s.mu）
+func (s *P2PServer) localHandshake() handshakeMsg {
+	return handshakeMsg{
+		From:      s.SelfURL,
+		NetworkID: s.BC.Params.NetworkID,
+		Genesis:   utils.ToHex(s.BC.GenesisHash()),
+		Height:    s.BC.LatestBlock().Header.Height,
+		Time:      time.Now().UnixMilli(),
+	}
+}
+
+// recordPeerTime 校验对方的网络与创世块，并记录主机 host 上的对方时间与本地时间 local 之间的偏差。
+// 样本按主机而不是对方自报的地址记录，一台主机伪造再多的地址也只算一个样本（调用方需持有 s.mu）
+func (s *P2PServer) recordPeerTime(msg *handshakeMsg, host string, local time.Time) {
+	if msg.NetworkID != s.BC.Params.NetworkID {
+		fmt.Printf("[handshake] %s 属于网络 %d，与本地网络 %d 不同，忽略其时间\n", msg.From, msg.NetworkID, s.BC.Params.NetworkID)
+		return
+	}
+	if msg.Genesis != utils.ToHex(s.BC.GenesisHash()) {
+		fmt.Println("[handshake]", msg.From, "的创世块与本地不一致，忽略其时间")
+		return
+	}
+	offset := time.UnixMilli(msg.Time).Sub(local)
+	if !s.BC.Clock.AddSample(host, offset) {
+		fmt.Println("[handshake] 时钟样本已满，忽略", host, "的时间")
+		return
+	}
+	fmt.Printf("[handshake] %s（%s）高度 %d，时间偏差 %v，网络调整时间修正量 %v\n",
+		msg.From, host, msg.Height, offset.Round(time.Millisecond), s.BC.Clock.Offset().Round(time.Millisecond))
+}
+
+// remoteHost 返回连接对端地址（host:port）中的 IP
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// peerHost 返回邻居地址（如 http://localhost:8001）中的主机名
+func peerHost(peer string) string {
+	u, err := url.Parse(peer)
+	if err != nil {
+		return peer
+	}
+	return u.Hostname()
+}
+
+// isKnownHost 判断 IP 地址 host 是否属于某个已配置的邻居（邻居地址中的域名会先解析）
+func (s *P2PServer) isKnownHost(host string) bool {
+	ip := net.ParseIP(host)
+	s.mu.Lock()
+	peers := append([]string(nil), s.Peers...)
+	s.mu.Unlock()
+
+	for _, peer := range peers {
+		name := peerHost(peer)
+		if name == host {
+			return true
+		}
+		addrs, err := net.LookupIP(name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ip != nil && addr.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Handshake 与所有邻居握手：发送本节点信息，并根据对方回复的时间记录时钟偏差。
+// 对方的时间取请求往返的中点作为对照，以抵消网络延迟。
+func (s *P2PServer) Handshake() {
+	s.mu.Lock()
+	peers := append([]string(nil), s.Peers...)
+	s.mu.Unlock()
+
+	for _, peer := range peers {
+		s.mu.Lock()
+		msg := s.localHandshake()
+		s.mu.Unlock()
+
+		data, _ := json.Marshal(msg)
+		req, err := http.NewRequest(http.MethodPost, peer+"/handshake", bytes.NewBuffer(data))
+		if err != nil {
+			fmt.Println("[handshake] 邻居地址", peer, "无效:", err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// 与入站握手一样按连接的对端 IP 记录样本
+		var host string
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			host = remoteHost(info.Conn.RemoteAddr().String())
+		}}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			fmt.Println("[handshake] 与", peer, "握手失败:", err)
+			continue
+		}
+		end := time.Now()
+
+		var reply handshakeMsg
+		err = json.NewDecoder(resp.Body).Decode(&reply)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Println("[handshake] 解析", peer, "的回复失败:", err)
+			continue
+		}
+		reply.From = peer
+		s.mu.Lock()
+		s.recordPeerTime(&reply, host, start.Add(end.Sub(start)/2))
+		s.mu.Unlock()
+	}
+}
+
+// /handshake：回复本节点的握手信息。发起方的时间偏差按连接的来源 IP 记录，
+// 并且只记录已配置邻居的样本，陌生节点无法靠反复握手左右本节点的网络调整时间
+func (s *P2PServer) handleHandshake(w http.ResponseWriter, r *http.Request) {
+	local := time.Now()
+
+	var msg handshakeMsg
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.From == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid handshake"))
+		return
+	}
+	host := remoteHost(r.RemoteAddr)
+	known := s.isKnownHost(host)
+
+	s.mu.Lock()
+	if known {
+		s.recordPeerTime(&msg, host, local)
+	} else {
+		fmt.Println("[handshake]", msg.From, "（"+host+"）不是已配置的邻居，不记录其时间")
+	}
+	reply := s.localHandshake()
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// POST /notarize：提交一笔已签名的公证交易（JSON），附带数据必须是 NotaryTag + 文件的 SHA256。
+// 与 /newtx 一样检查后放入交易池并广播，返回交易哈希与文件哈希；上链后可以用 /notary/verify 查询
+func (s *P2PServer) handleNotarize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	var tx core.Transaction
+	if err := json.Unmarshal(body, &tx); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	doc := tx.NotarizedHash()
+	if doc == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, core.ErrNotNotaryTx.Error())
+		return
+	}
+
+	s.mu.Lock()
+	_, err := s.acceptTx(&tx)
+	s.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	s.relayTx(&tx)
+
+	json.NewEncoder(w).Encode(struct {
+		TxHash   string `json:"txHash"`
+		Document string `json:"document"`
+	}{utils.ToHex(tx.Hash), utils.ToHex(doc)})
+}
+
+// /notary/verify?hash=<文件 SHA256 的 hex>：返回主链上最早公证该文件的区块、时间戳以及交易的 Merkle 证明
+func (s *P2PServer) handleNotaryVerify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	doc, err := hex.DecodeString(r.URL.Query().Get("hash"))
+	if err != nil || len(doc) != 32 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, core.ErrBadNotaryHash.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proof, err := s.BC.NotaryProof(doc)
+	if errors.Is(err, core.ErrNotNotarized) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(proof)
+}
@@ -0,0 +1,183 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// httpClient 用于节点之间主动发起的请求，避免邻居无响应时一直卡住
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// processBlock 把区块交给区块树，并处理孤块（调用方需持有 s.mu）：
+//   - 父区块未知：先做不依赖父区块的共识检查（POW 或 PoA / PoS / BFT 签名，PoS 还要求签名者是验证者，
+//     防止垃圾区块占满孤块池），放入孤块池，返回 ErrUnknownParent；
+//     由收到新区块的 handleNewBlock 调用 requestMissing 请求缺失的祖先区块
+//   - 接入成功：把一直在等它的孤块依次接上，整串一起处理
+//
+// 主链发生变化时会同步调整交易池、保存到本地文件，并通知 BFT 状态机进入新的高度。
+func (s *P2PServer) processBlock(block core.Block, peer string) (*core.ChainUpdate, error) {
+	update, err := s.BC.ProcessBlock(block)
+	if errors.Is(err, core.ErrUnknownParent) {
+		if s.Orphans.Has(block.Header.Hash) {
+			return nil, err
+		}
+		if err := s.BC.CheckOrphan(&block); err != nil {
+			return nil, err
+		}
+		s.Orphans.Add(block, peer)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 以刚接入的区块为起点，逐层接上等待它的孤块
+	queue := [][]byte{block.Header.Hash}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range s.Orphans.TakeChildren(parent) {
+			u, err := s.BC.ProcessBlock(child)
+			if err != nil {
+				fmt.Println("孤块接入失败，丢弃:", utils.ToHex(child.Header.Hash), err)
+				continue
+			}
+			fmt.Println("孤块已接入区块树:", utils.ToHex(child.Header.Hash))
+			update.Disconnected = append(update.Disconnected, u.Disconnected...)
+			update.Connected = append(update.Connected, u.Connected...)
+			queue = append(queue, child.Header.Hash)
+		}
+	}
+
+	if len(update.Connected) > 0 {
+		if update.Reorged() {
+			fmt.Printf("发生链重组：断开 %d 个区块，接入 %d 个区块\n",
+				len(update.Disconnected), len(update.Connected))
+		}
+		s.applyChainUpdate(update)
+		if err := s.Storage.Save(s.BC); err != nil {
+			fmt.Println("保存区块链失败:", err)
+		}
+		s.notifyBFT()
+	}
+	return update, nil
+}
+
+// requestMissing 在后台向 peer 请求孤块 orphan 缺失的最早祖先（调用方需持有 s.mu）。
+// 同一个祖先已经在请求中时什么也不做，多个孤块等待同一段祖先时只有一个后台请求
+func (s *P2PServer) requestMissing(peer string, orphan []byte) {
+	missing := s.Orphans.MissingAncestor(orphan)
+	key := utils.ToHex(missing)
+	if s.fetching[key] {
+		return
+	}
+	s.fetching[key] = true
+
+	peers := []string{peer}
+	if peer == "" {
+		peers = append([]string(nil), s.Peers...)
+	}
+	go s.fetchMissingBlocks(peers, missing)
+}
+
+// fetchMissingBlocks 向 peers 逐个请求缺失的区块，直到接上本地区块树为止，一个邻居失败时换下一个接着补。
+// 每拿到一个区块都走 processBlock：如果它的父区块仍然未知，
+// 就在同一个请求中继续往前；一旦接上，整串孤块会被一起接入。
+// 正在请求的祖先记录在 s.fetching 中，往前走一步就换成新的祖先，结束时删除
+func (s *P2PServer) fetchMissingBlocks(peers []string, hash []byte) {
+	key := utils.ToHex(hash)
+	defer func() {
+		s.mu.Lock()
+		delete(s.fetching, key)
+		s.mu.Unlock()
+	}()
+
+	missing := hash
+	for _, p := range peers {
+		for i := 0; i < core.MaxOrphanBlocks; i++ {
+			block, err := fetchBlockFromPeer(p, missing)
+			if err != nil {
+				fmt.Println("[orphan] 从", p, "获取区块", utils.ToHex(missing), "失败:", err)
+				break
+			}
+
+			s.mu.Lock()
+			_, err = s.processBlock(*block, p)
+			next := s.Orphans.MissingAncestor(block.Header.Hash)
+			if errors.Is(err, core.ErrUnknownParent) {
+				if s.fetching[utils.ToHex(next)] {
+					// 更早的祖先已经有别的请求在补了
+					s.mu.Unlock()
+					return
+				}
+				delete(s.fetching, key)
+				key = utils.ToHex(next)
+				s.fetching[key] = true
+			}
+			s.mu.Unlock()
+
+			if !errors.Is(err, core.ErrUnknownParent) {
+				if err != nil && !errors.Is(err, core.ErrKnownBlock) {
+					fmt.Println("[orphan] 补齐的区块不合法:", err)
+				}
+				return
+			}
+			missing = next
+		}
+	}
+}
+
+// fetchBlockFromPeer 调用 peer 的 /block 接口按哈希获取区块
+func fetchBlockFromPeer(peer string, hash []byte) (*core.Block, error) {
+	resp, err := httpClient.Get(peer + "/block?hash=" + utils.ToHex(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status not OK: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var block core.Block
+	if err := json.Unmarshal(body, &block); err != nil {
+		return nil, err
+	}
+	if block.Header == nil {
+		return nil, core.ErrNoHeader
+	}
+	return &block, nil
+}
+
+// /block?hash=<hex>：按哈希返回区块（包括分叉链上的区块），供邻居补齐孤块的祖先
+func (s *P2PServer) handleGetBlock(w http.ResponseWriter, r *http.Request) {
+	hash, err := hex.DecodeString(r.URL.Query().Get("hash"))
+	if err != nil || len(hash) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing or invalid hash parameter"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block := s.BC.GetBlock(hash)
+	if block == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(block)
+}
@@ -2,10 +2,13 @@ package p2p
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 
 	"html"
@@ -13,63 +16,121 @@ import (
 	"mychain/storage"
 	"mychain/utils"
 	"sort"
-)
-
-// 一些和挖矿相关的参数
-const (
-	// 每个新区块给矿工的奖励（简单整数就好）
-	BlockReward = 50
-
-	// 每个区块最多打包多少笔交易（不含 coinbase）
-	MaxTxPerBlock = 5
+	"strconv"
+	"sync"
+	"time"
 )
 
 // P2PServer 表示一个节点
 type P2PServer struct {
 	Port    string
+	SelfURL string // 本节点对外地址，广播区块时告诉邻居“从哪里补齐缺失的区块”
 	BC      *core.Blockchain
 	Storage *storage.FileStorage
 	Peers   []string
-	Mempool []core.Transaction
+	Mempool *core.Mempool    // 待打包交易，按账户 nonce 组织
+	Orphans *core.OrphanPool // 父区块未知的区块
+
+	Miner *core.Miner // 并行挖矿器（POW 共识引擎使用的同一个）
+
+	mu         sync.Mutex         // 保护 BC、Peers、Mempool、Orphans、mineCancel、fetching，HTTP 处理函数是并发执行的
+	mineCancel context.CancelFunc // 正在进行的挖矿，主链变化时调用以取消
+	fetching   map[string]bool    // 正在向邻居请求的孤块祖先（hex 哈希），同一个祖先只有一个后台请求（见 orphan.go）
+
+	bft *bftState // BFT 共识的轮次状态机，其他共识引擎为 nil（见 bft.go）
 }
 
+// BinaryContentType 表示请求体是规范二进制编码（见 core/encoding.go）
+const BinaryContentType = "application/octet-stream"
+
 // 创建一个节点
 func NewServer(port string, bc *core.Blockchain, store *storage.FileStorage) *P2PServer {
 	return &P2PServer{
 		Port:    port,
+		SelfURL: "http://localhost:" + port,
 		BC:      bc,
 		Storage: store,
 		Peers:   []string{},
-		Mempool: []core.Transaction{},
+		Mempool: core.NewMempool(bc.Params),
+		Orphans: core.NewOrphanPool(core.MaxOrphanBlocks, core.OrphanExpiry),
+		Miner:   engineMiner(bc.Engine),
+
+		fetching: make(map[string]bool),
+	}
+}
+
+// engineSigner 返回 PoA / PoS / BFT 下本节点的签名者地址，没有设置私钥或是 POW 时为空
+func engineSigner(engine core.Consensus) string {
+	if se, ok := engine.(core.SignerEngine); ok {
+		return se.Signer()
+	}
+	return ""
+}
+
+// engineMiner 返回 POW 引擎的挖矿器，其他共识引擎不挖矿，返回一个空闲的挖矿器只用于统计
+func engineMiner(engine core.Consensus) *core.Miner {
+	if pow, ok := engine.(*core.ProofOfWork); ok {
+		return pow.Miner
 	}
+	return core.NewMiner(0)
+}
+
+// Handler 返回注册了节点全部接口的路由，每个节点使用自己的路由，同一进程中可以运行多个节点
+func (s *P2PServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", s.handleGetLatest)
+	mux.HandleFunc("/chain", s.handleGetChain)
+	mux.HandleFunc("/block", s.handleGetBlock)
+	mux.HandleFunc("/newblock", s.handleNewBlock)
+	mux.HandleFunc("/newtx", s.handleNewTx)
+	mux.HandleFunc("/mine", s.handleMine)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/balance", s.handleBalance)
+	mux.HandleFunc("/nonce", s.handleNonce)
+	mux.HandleFunc("/utxos", s.handleUTXOs)
+	mux.HandleFunc("/headers", s.handleGetHeaders)
+	mux.HandleFunc("/proof", s.handleProof)
+	mux.HandleFunc("/supply", s.handleSupply)
+	mux.HandleFunc("/contract", s.handleContract)
+	mux.HandleFunc("/contract/call", s.handleContractCall)
+	mux.HandleFunc("/contract/receipt", s.handleContractReceipt)
+	mux.HandleFunc("/tokens", s.handleTokens)
+	mux.HandleFunc("/validators", s.handleValidators)
+	mux.HandleFunc("/evidence", s.handleEvidence)
+	mux.HandleFunc("/bft/proposal", s.handleBFTProposal)
+	mux.HandleFunc("/bft/vote", s.handleBFTVote)
+	mux.HandleFunc("/notarize", s.handleNotarize)
+	mux.HandleFunc("/notary/verify", s.handleNotaryVerify)
+	mux.HandleFunc("/handshake", s.handleHandshake)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
+	return mux
 }
 
 // 启动 HTTP 服务器
 func (s *P2PServer) Start() {
-	http.HandleFunc("/latest", s.handleGetLatest)
-	http.HandleFunc("/chain", s.handleGetChain)
-	http.HandleFunc("/newblock", s.handleNewBlock)
-	http.HandleFunc("/newtx", s.handleNewTx)
-	http.HandleFunc("/mine", s.handleMine)
-	http.HandleFunc("/stats", s.handleStats)
-	http.HandleFunc("/balance", s.handleBalance)
-	http.HandleFunc("/dashboard", s.handleDashboard)
+	s.startBFT()
 
 	addr := ":" + s.Port
 	fmt.Println("节点启动 HTTP 服务，监听端口", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	log.Fatal(http.ListenAndServe(addr, s.Handler()))
 }
 
 // ================ 下面是 4 个接口 ==================
 
 // 返回最新区块
 func (s *P2PServer) handleGetLatest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	latest := s.BC.LatestBlock()
 	json.NewEncoder(w).Encode(latest)
 }
 
 // 返回整个区块链
 func (s *P2PServer) handleGetChain(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	json.NewEncoder(w).Encode(s.BC)
 }
 
@@ -79,7 +140,7 @@ func (s *P2PServer) handleNewBlock(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	var block core.Block
-	if err := json.Unmarshal(body, &block); err != nil {
+	if err := json.Unmarshal(body, &block); err != nil || block.Header == nil {
 		fmt.Println("解析新区块失败:", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -87,141 +148,183 @@ func (s *P2PServer) handleNewBlock(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("收到新区块，Hash:", utils.ToHex(block.Header.Hash))
 
-	prev := s.BC.LatestBlock()
-	if prev == nil {
-		fmt.Println("本地区块链为空，暂不接受该区块")
-		w.WriteHeader(http.StatusBadRequest)
+	// 发送方会在 from 参数里带上自己的地址，缺失父区块时向它补齐
+	peer := r.URL.Query().Get("from")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 交给区块树处理：与状态无关的检查在收到时完成，
+	// 余额等检查在区块真正接入主链时完成；分叉链累计工作量更大时会触发重组
+	update, err := s.processBlock(block, peer)
+	if errors.Is(err, core.ErrKnownBlock) {
+		fmt.Println("该区块已存在，忽略")
+		w.WriteHeader(http.StatusOK)
 		return
 	}
-
-	// 1. 判断前一个区块哈希是否匹配本地最新区块
-	if !bytes.Equal(block.Header.PreviousHash, prev.Header.Hash) {
-		fmt.Println("前一个区块 Hash 不匹配，拒绝该区块")
-		w.WriteHeader(http.StatusBadRequest)
+	if errors.Is(err, core.ErrUnknownParent) {
+		fmt.Println("父区块未知，放入孤块池并向邻居请求缺失的区块，当前孤块数 =", s.Orphans.Len())
+		s.requestMissing(peer, block.Header.Hash)
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
-
-	// 2. 验证 POW 是否合法
-	pow := core.NewPow(&block)
-	if !pow.Validate() {
-		fmt.Println("POW 不合法，拒绝该区块")
+	if err != nil {
+		fmt.Println("区块校验失败，拒绝该区块:", err)
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
 		return
 	}
 
-	// 3. 一切正常，加入本地区块链
-	s.BC.Blocks = append(s.BC.Blocks, block)
-	if err := s.Storage.Save(s.BC); err != nil {
-		fmt.Println("保存区块链失败:", err)
+	if len(update.Connected) == 0 {
+		fmt.Println("区块进入分叉链，累计工作量未超过主链，暂不切换")
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	// 收到新区块后，重算一次余额表
-	s.BC.RebuildBalances()
-
 	fmt.Println("成功接受并加入新区块！当前高度 =", len(s.BC.Blocks)-1)
 	w.WriteHeader(http.StatusOK)
 }
 
+// applyChainUpdate 根据主链的变化调整交易池：
+// 被断开区块中的普通交易按新状态重新尝试入池，已经上链（nonce 已用掉）的交易从交易池中移除。
+// 正在进行的挖矿基于旧的主链末尾，已经没有意义，立即取消。
+func (s *P2PServer) applyChainUpdate(update *core.ChainUpdate) {
+	if s.mineCancel != nil && len(update.Connected) > 0 {
+		fmt.Println("主链已变化，取消正在进行的挖矿")
+		s.mineCancel()
+	}
+
+	for _, b := range update.Disconnected {
+		for _, tx := range b.Txs {
+			if !tx.IsCoinbase() {
+				s.Mempool.Add(tx, s.BC.State)
+			}
+		}
+	}
+	s.Mempool.Reset(s.BC.State)
+}
+
 func (s *P2PServer) handleNewTx(w http.ResponseWriter, r *http.Request) {
 	body, _ := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 
+	// 默认按 JSON 解析；Content-Type 为 application/octet-stream 时按规范二进制编码解析
 	var tx core.Transaction
-	if err := json.Unmarshal(body, &tx); err != nil {
+	var err error
+	if r.Header.Get("Content-Type") == BinaryContentType {
+		err = tx.UnmarshalBinary(body)
+	} else {
+		err = json.Unmarshal(body, &tx)
+	}
+	if err != nil {
 		fmt.Println("解析交易失败:", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	// ----- 1. 交易必须包含签名（除 COINBASE）-----
-	if tx.From != "COINBASE" {
-		if len(tx.PubKey) == 0 || len(tx.Sig) == 0 {
-			fmt.Println("拒绝未签名交易：必须包含 PubKey + Sig")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("missing pubkey or signature"))
-			return
-		}
+	s.mu.Lock()
+	_, err = s.acceptTx(&tx)
+	s.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
-	// ----- 2. 校验 From 地址是否由 PubKey 推导 -----
-	if tx.From != "COINBASE" {
-		expectedAddr := utils.PubKeyToAddress(tx.PubKey)
-		if tx.From != expectedAddr {
-			fmt.Printf("拒绝交易：From 地址伪造！声明为 %s，但公钥推导为 %s\n",
-				tx.From, expectedAddr)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("forged from address"))
-			return
-		}
+	// 广播时不持有锁，避免两个节点互相转发时彼此等待
+	if r.URL.Query().Get("relay") != "1" {
+		s.relayTx(&tx)
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	// ----- 3. 校验签名是否正确 -----
-	if tx.From != "COINBASE" {
-		if !tx.Verify() {
-			fmt.Println("签名验证失败：拒绝该交易")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("invalid signature"))
-			return
-		}
+// acceptTx 检查一笔外部提交的交易并放入交易池，返回它是否可以立即打包。调用方持有 s.mu
+func (s *P2PServer) acceptTx(tx *core.Transaction) (bool, error) {
+	// ----- 1. coinbase 只能由矿工在出块时生成，不接受外部提交 -----
+	if tx.IsCoinbase() {
+		fmt.Println("拒绝外部提交的 COINBASE 交易")
+		return false, errors.New("coinbase transaction not allowed")
 	}
 
-	fmt.Println("交易签名验证通过 ✔")
-
-	// ----- 4. 余额系统检查（你已经实现）-----
-	if tx.From != "" && tx.From != "COINBASE" {
-		confirmed := s.BC.GetBalance(tx.From)
+	// ----- 2. 签名校验：必须带 PubKey + Sig，From 由 PubKey 推导，签名正确；多签交易需达到门限；
+	//          脚本交易的 From 须为赎回脚本地址，并执行解锁脚本 + 赎回脚本。
+	//          通过的交易记入签名缓存，打包进区块后不再重复校验 -----
+	if err := s.BC.SigCache.Verify(tx); err != nil {
+		fmt.Println("交易签名校验失败，拒绝该交易:", err)
+		return false, err
+	}
 
-		var pendingDelta int64 = 0
-		for _, pending := range s.Mempool {
-			amount := int64(pending.Value)
-			if pending.From == tx.From {
-				pendingDelta += amount
-			}
-		}
+	fmt.Println("交易签名验证通过 ✔")
 
-		available := confirmed - pendingDelta
-		if int64(tx.Value) > available {
-			fmt.Printf("交易余额不足：账户 %s 可用 %d，请求转出 %d\n",
-				tx.From, available, tx.Value)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("balance not enough"))
-			return
-		}
+	// ----- 2.5 交易格式必须与本链的记账模型（账户 / UTXO）一致 -----
+	if err := core.CheckTxFormat(s.BC.Params, tx); err != nil {
+		fmt.Println("交易格式不符合记账模型，拒绝该交易:", err)
+		return false, err
 	}
 
-	// ----- 5. 交易入池 + 广播（你之前的逻辑保持不变）-----
+	// ----- 3. 入池检查：nonce（过旧拒绝、超前排队）+ 余额（已确认余额 - 池内更早交易）；
+	//          UTXO 模式下检查输入存在、属于发送方、未被池内交易花费，且输入 = 输出 + 手续费 -----
+	pending, err := s.Mempool.Add(*tx, s.BC.State)
+	if err != nil {
+		fmt.Println("交易入池失败:", err)
+		return false, err
+	}
 
+	// ----- 4. 交易入池（广播由调用方在释放锁之后进行）-----
 	tx.CalculateHash()
-	fmt.Println("收到新交易：", tx.From, "→", tx.To, "金额", tx.Value)
-
-	s.Mempool = append(s.Mempool, tx)
-	fmt.Println("当前交易池大小：", len(s.Mempool))
-
-	relayFlag := r.URL.Query().Get("relay")
-	if relayFlag == "1" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
+	switch {
+	case tx.IsUTXO():
+		fmt.Println("收到新交易：", tx.From, "输入", len(tx.Inputs), "个，输出", len(tx.Outputs), "个，手续费", tx.Fee)
+	case tx.Kind == core.TxDeploy:
+		fmt.Println("收到合约部署交易：", tx.From, "代码", len(tx.Payload), "字节，合约地址", core.ContractAddress(tx.From, tx.Nonce), "gas 上限", tx.GasLimit)
+	case tx.Kind == core.TxIssue:
+		fmt.Println("收到代币发行交易：", tx.From, "符号", tx.Token.Symbol, "发行量", tx.Token.Supply, "资产 ID", core.AssetID(tx.From, tx.Nonce))
+	case tx.Asset != "":
+		fmt.Println("收到代币转账：", tx.From, "→", tx.To, "资产", tx.Asset, "金额", tx.Value, "手续费", tx.Fee, "nonce", tx.Nonce)
+	case tx.Kind == core.TxCall:
+		fmt.Println("收到合约调用交易：", tx.From, "→", tx.To, "金额", tx.Value, "gas 上限", tx.GasLimit, "nonce", tx.Nonce)
+	case tx.Kind == core.TxStake:
+		fmt.Println("收到质押交易：", tx.From, "→ 验证者", tx.To, "金额", tx.Value, "手续费", tx.Fee, "nonce", tx.Nonce)
+	case tx.Kind == core.TxUnstake:
+		fmt.Println("收到取消质押交易：", tx.From, "金额", tx.Value, "手续费", tx.Fee, "nonce", tx.Nonce)
+	case tx.Kind == core.TxSlash:
+		fmt.Println("收到罚没交易：", tx.From, "证据", len(tx.Payload), "字节，手续费", tx.Fee, "nonce", tx.Nonce)
+	case tx.NotarizedHash() != nil:
+		fmt.Println("收到公证交易：", tx.From, "文件哈希", utils.ToHex(tx.NotarizedHash()), "手续费", tx.Fee, "nonce", tx.Nonce)
+	default:
+		fmt.Println("收到新交易：", tx.From, "→", tx.To, "金额", tx.Value, "手续费", tx.Fee, "nonce", tx.Nonce)
+	}
+	if !pending {
+		fmt.Println("交易 nonce 不连续，暂存等待前面的交易")
+	}
+	if !tx.IsFinal(s.BC.NextHeight(), s.BC.MedianTimePast()) {
+		fmt.Println("交易锁定时间未到，暂存等待解锁，lockTime =", tx.LockTime)
+	}
+	fmt.Println("当前交易池大小：", s.Mempool.Len())
+	return pending, nil
+}
 
+// relayTx 把交易转发给所有邻居（邻居收到后不再继续转发）
+func (s *P2PServer) relayTx(tx *core.Transaction) {
 	data, _ := json.Marshal(tx)
 	for _, peer := range s.Peers {
 		url := peer + "/newtx?relay=1"
 		http.Post(url, "application/json", bytes.NewBuffer(data))
 	}
-
-	w.WriteHeader(http.StatusOK)
 }
 
 // 添加邻居节点
 func (s *P2PServer) AddPeer(addr string) {
 	fmt.Println("添加邻居节点:", addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.Peers = append(s.Peers, addr)
 }
 
-// 广播区块给所有已知节点
+// 广播区块给所有已知节点（附带本节点地址，便于对方补齐缺失的父区块）
 func (s *P2PServer) BroadcastBlock(block *core.Block) {
 	for _, peer := range s.Peers {
-		url := peer + "/newblock"
+		url := peer + "/newblock?from=" + s.SelfURL
 		fmt.Println("广播区块到", url)
 
 		data, _ := json.Marshal(block)
@@ -232,10 +335,32 @@ func (s *P2PServer) BroadcastBlock(block *core.Block) {
 	}
 }
 
-// /mine 接口：本节点挖一个新区块，并广播给所有邻居
+// /mine 接口：本节点出一个新区块（POW 挖矿，PoA / PoS 由本节点的签名者签名），并广播给所有邻居
 func (s *P2PServer) handleMine(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("收到挖矿请求，开始挖矿...")
 
+	s.mu.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			s.mu.Unlock()
+		}
+	}()
+
+	// BFT 网络的区块由验证者投票产生（见 bft.go），不能手动出块
+	if s.BC.Engine.Name() == core.EngineBFT {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, "BFT 网络由验证者按轮次自动出块，不支持 /mine")
+		return
+	}
+
+	// 同一时间只进行一次挖矿
+	if s.mineCancel != nil {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, "已经在挖矿中，请稍后再试")
+		return
+	}
+
 	// 0. 从 URL 上拿矿工地址：/mine?addr=<钱包Address>
 	minerAddr := r.URL.Query().Get("addr")
 	if minerAddr == "" {
@@ -245,77 +370,106 @@ func (s *P2PServer) handleMine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. 现在「交易池为空」不再阻止挖矿，而是只打 coinbase
-	if len(s.Mempool) == 0 {
-		fmt.Println("当前交易池为空，本次只打包 coinbase 挖矿奖励交易")
-	} else {
-		fmt.Println("当前交易池大小：", len(s.Mempool))
-	}
+	txs := s.blockTxs(minerAddr)
 
-	// 2. 计算本次最多打包多少笔「普通交易」
-	txCount := len(s.Mempool)
-	if txCount > MaxTxPerBlock {
-		txCount = MaxTxPerBlock
+	// 5. 构造区块模板，释放锁后封装（POW 并行挖矿，PoA / PoS 签名）：挖矿期间节点照常处理交易和区块，
+	//    主链一旦变化（收到竞争区块）就通过 context 取消本次挖矿；请求方断开连接同样会取消。
+	//    PoA 下本节点不是签名者、或最近刚签过名时不能出块；PoS 下只有被选中的出块者能出块
+	newBlock, err := s.BC.NewBlockTemplate(txs)
+	if errors.Is(err, core.ErrNotSigner) || errors.Is(err, core.ErrRecentlySigned) || errors.Is(err, core.ErrWrongProposer) {
+		fmt.Println("本节点现在不能出块:", err)
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, "本节点现在不能出块:", err)
+		return
 	}
-	fmt.Println("本次将从交易池中打包", txCount, "笔交易进行挖矿")
-
-	// 3. 构造 coinbase 奖励交易（放在第一笔）
-	//    ✅ 奖励直接打给 minerAddr（钱包 Address），而不是 "miner-端口"
-	reward := core.Transaction{
-		From:  "COINBASE",
-		To:    minerAddr,
-		Value: BlockReward,
+	if err != nil {
+		fmt.Println("构造区块失败:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, "构造区块失败:", err)
+		return
 	}
-	reward.CalculateHash()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	s.mineCancel = cancel
+	s.mu.Unlock()
+	locked = false
 
-	// 4. 组装本次要打包进区块的交易列表：
-	//    [coinbase] + [前 txCount 笔普通交易]（txCount 可能为 0）
-	txs := make([]core.Transaction, 0, txCount+1)
-	txs = append(txs, reward)
+	start := time.Now()
+	err = s.BC.Engine.Seal(ctx, &newBlock)
+	stats := s.Miner.Stats()
+	if s.BC.Engine.Name() == core.EnginePoW {
+		fmt.Printf("挖矿用时 %v，算力 %.0f H/s（%d 个协程）\n", time.Since(start).Round(time.Millisecond), stats.HashRate, stats.Workers)
+	}
 
-	if txCount > 0 {
-		txs = append(txs, s.Mempool[:txCount]...)
-		// 5. 将已打包的普通交易从 mempool 中移除，保留未打包部分
-		if txCount == len(s.Mempool) {
-			// 全部打完，清空交易池
-			s.Mempool = []core.Transaction{}
-		} else {
-			// 只打包了前 txCount 笔，后面的留在池子里
-			s.Mempool = s.Mempool[txCount:]
-		}
-	} else {
-		// 完全空池：只有 coinbase，此时直接把 mempool 清空即可（本来也为空）
-		s.Mempool = []core.Transaction{}
+	s.mu.Lock()
+	locked = true
+	s.mineCancel = nil
+	if err != nil {
+		fmt.Println("挖矿已取消:", err)
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, "挖矿已取消（收到竞争区块或请求中断）:", err)
+		return
 	}
 
-	fmt.Println("挖矿后交易池剩余：", len(s.Mempool))
+	// 6. 接入区块树；将已打包的普通交易从 mempool 中移除，保留未打包部分
+	update, err := s.BC.ProcessBlock(newBlock)
+	if err != nil {
+		fmt.Println("挖矿失败:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, "挖矿失败:", err)
+		return
+	}
+	s.applyChainUpdate(update)
+	fmt.Println("挖矿后交易池剩余：", s.Mempool.Len())
 
-	// 6. 使用 AddBlock 挖矿并加入链
-	newBlock := s.BC.AddBlock(txs)
 	if err := s.Storage.Save(s.BC); err != nil {
 		fmt.Println("保存区块链失败:", err)
 	}
 
-	// 基于新区块刷新一次余额表
-	s.BC.RebuildBalances()
-
-	fmt.Println("本地挖矿完成，新区块高度:", len(s.BC.Blocks)-1,
+	fmt.Println("本地挖矿完成，新区块高度:", newBlock.Header.Height,
 		"Hash:", utils.ToHex(newBlock.Header.Hash))
 
-	// 7. 广播给所有邻居
-	data, _ := json.Marshal(newBlock)
-	for _, peer := range s.Peers {
-		url := peer + "/newblock"
-		fmt.Println("广播新区块到:", url)
-		_, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-		if err != nil {
-			fmt.Println("广播到", url, "失败:", err)
-		}
+	height := newBlock.Header.Height
+	mempoolSize := s.Mempool.Len()
+	s.mu.Unlock()
+	locked = false
+
+	// 7. 广播给所有邻居（不持有锁，避免邻居回来补块时互相等待）
+	s.BroadcastBlock(&newBlock)
+
+	fmt.Fprintf(w, "挖矿完成，高度=%d，Hash=%s，本次打包交易数=%d（含1笔coinbase），剩余交易池=%d，算力=%.0f H/s\n",
+		height, utils.ToHex(newBlock.Header.Hash),
+		len(txs), mempoolSize, stats.HashRate)
+}
+
+// blockTxs 选出下一个区块要打包的交易：coinbase（奖励打给 minerAddr）+ 交易池中的可执行交易。调用方持有 s.mu
+func (s *P2PServer) blockTxs(minerAddr string) []core.Transaction {
+	// 1. 现在「交易池为空」不再阻止挖矿，而是只打 coinbase
+	if s.Mempool.Len() == 0 {
+		fmt.Println("当前交易池为空，本次只打包 coinbase 挖矿奖励交易")
+	} else {
+		fmt.Println("当前交易池大小：", s.Mempool.Len())
 	}
 
-	fmt.Fprintf(w, "挖矿完成，高度=%d，Hash=%s，本次打包交易数=%d（含1笔coinbase），剩余交易池=%d\n",
-		len(s.BC.Blocks)-1, utils.ToHex(newBlock.Header.Hash),
-		len(txs), len(s.Mempool))
+	// 2. 按手续费率从高到低选出最多 MaxTxPerBlock（链参数）笔可执行交易（同一账户按 nonce 递增），
+	//    锁定时间未到的交易留在池中；手续费之和加上区块奖励不能超出 coinbase 金额的 32 位
+	height := s.BC.NextHeight()
+	subsidy := s.BC.Engine.Reward(height)
+	pending := s.Mempool.Pending(s.BC.State, s.BC.Params.MaxTxPerBlock, s.BC.MedianTimePast(), math.MaxUint32-subsidy)
+	txCount := len(pending)
+	fmt.Println("本次将从交易池中打包", txCount, "笔交易进行挖矿")
+
+	// 3. 构造 coinbase 奖励交易（放在第一笔），金额 = 该高度的区块奖励（按货币政策）+ 本块全部手续费
+	//    ✅ 奖励直接打给 minerAddr（钱包 Address），而不是 "miner-端口"
+	//    UTXO 模式下奖励是 coinbase 的一个输出；coinbase 的 nonce 记录区块高度
+	reward := s.BC.Params.NewCoinbase(height, minerAddr, uint32(subsidy+core.TotalFees(pending)))
+
+	// 4. 组装本次要打包进区块的交易列表：
+	//    [coinbase] + [前 txCount 笔普通交易]（txCount 可能为 0）
+	txs := make([]core.Transaction, 0, txCount+1)
+	txs = append(txs, reward)
+	txs = append(txs, pending...)
+	return txs
 }
 
 // fetchChainFromPeer 向某个 peer 的 /chain 接口拉取整条区块链
@@ -347,8 +501,8 @@ func (s *P2PServer) fetchChainFromPeer(peer string) ([]core.Block, error) {
 	return wrapper.Blocks, nil
 }
 
-// SyncWithPeers 启动时向所有邻居请求它们的链，
-// 如果某个邻居的链更长且合法，就替换本地链。
+// SyncWithPeers 启动时向所有邻居请求它们的链，逐块导入本地区块树，
+// 如果某个邻居的链累计工作量更大且合法，主链就会切换过去。
 func (s *P2PServer) SyncWithPeers() {
 	if len(s.Peers) == 0 {
 		fmt.Println("[sync] 当前没有配置任何 peer，跳过同步")
@@ -371,61 +525,124 @@ func (s *P2PServer) SyncWithPeers() {
 			continue
 		}
 
-		if s.BC.ReplaceIfLonger(blocks) {
-			fmt.Println("[sync] 使用", peer, "的链替换本地区块链，当前高度：", len(s.BC.Blocks))
+		s.mu.Lock()
+		update, err := s.BC.ImportChain(blocks)
+		if len(update.Connected) > 0 {
+			fmt.Println("[sync] 根据", peer, "的链切换到累计工作量更大的主链，当前高度：", len(s.BC.Blocks)-1)
+			s.applyChainUpdate(update)
 			// 保存到本地文件
 			if err := s.Storage.Save(s.BC); err != nil {
 				fmt.Println("[sync] 保存链到本地失败：", err)
 			}
 			replaced = true
-		} else {
-			fmt.Println("[sync] ", peer, "的链不比本地更长或不合法，保持当前链")
+		}
+		s.mu.Unlock()
+		if err != nil {
+			fmt.Println("[sync] ", peer, "的链中存在不合法区块：", err)
+		} else if len(update.Connected) == 0 {
+			fmt.Println("[sync] ", peer, "的链累计工作量不比本地更大，保持当前链")
 		}
 	}
 
 	if !replaced {
-		fmt.Println("[sync] 没有发现更长的合法链，本地链保持不变，高度：", len(s.BC.Blocks))
+		fmt.Println("[sync] 没有发现工作量更大的合法链，本地链保持不变，高度：", len(s.BC.Blocks)-1)
 	}
 }
 
 // /stats：返回当前节点的一些状态信息（高度、mempool 大小、最新区块等）
 func (s *P2PServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// 方便前端/测试工具使用 JSON
 	w.Header().Set("Content-Type", "application/json")
 
 	height := len(s.BC.Blocks) - 1
-	mempoolSize := len(s.Mempool)
+	mempoolSize := s.Mempool.Len()
 
 	var latestHash string
 	var latestMerkle string
+	var latestStateRoot string
+	var latestVersion, latestBits uint32
 
 	if height >= 0 {
 		last := s.BC.LatestBlock()
 		if last != nil {
 			latestHash = utils.ToHex(last.Header.Hash)
 			latestMerkle = utils.ToHex(last.Header.MerkleRoot)
+			latestStateRoot = utils.ToHex(last.Header.StateRoot)
+			latestVersion = last.Header.Version
+			latestBits = last.Header.Bits
 		}
 	}
 
+	finalized := s.BC.FinalizedHeight()
+	var bft *BFTStatus
+	if s.bft != nil {
+		status := s.bft.Status()
+		bft = &status
+	}
+
+	peerOffsets := make(map[string]string)
+	for host, off := range s.BC.Clock.Samples() {
+		peerOffsets[host] = off.Round(time.Millisecond).String()
+	}
+
 	// 简单结构体作为返回体
 	resp := struct {
-		Port         string   `json:"port"`
-		Height       int      `json:"height"`       // 当前链高度（创世块为 0）
-		BlockCount   int      `json:"blockCount"`   // 区块总数
-		MempoolSize  int      `json:"mempoolSize"`  // 交易池中待打包交易数量
-		PeerCount    int      `json:"peerCount"`    // 已连接邻居数
-		Peers        []string `json:"peers"`        // 邻居列表
-		LatestHash   string   `json:"latestHash"`   // 最新区块哈希
-		LatestMerkle string   `json:"latestMerkle"` // 最新区块 Merkle 根
+		Port         string             `json:"port"`
+		Network      string             `json:"network"`          // 网络名称
+		NetworkID    uint32             `json:"networkId"`        // 网络编号
+		Ledger       string             `json:"ledger"`           // 记账模型：account / utxo
+		Consensus    core.EngineName    `json:"consensus"`        // 共识引擎：pow / poa / pos / bft
+		GasPrice     uint64             `json:"gasPrice"`         // 合约交易每 1000 gas 至少支付的手续费（见 core.GasFee）
+		Signer       string             `json:"signer,omitempty"` // PoA / PoS / BFT：本节点的签名者地址
+		Height       int                `json:"height"`           // 当前链高度（创世块为 0）
+		Finalized    uint64             `json:"finalizedHeight"`  // 已最终确定、不会被重组的高度（BFT 中为整条主链，其他共识只有创世块）
+		FinalHash    string             `json:"finalizedHash"`    // 该高度的区块哈希
+		BFT          *BFTStatus         `json:"bft,omitempty"`    // BFT：正在共识的高度、轮次、步骤与锁定情况
+		BlockCount   int                `json:"blockCount"`       // 区块总数
+		MempoolSize  int                `json:"mempoolSize"`      // 交易池中待打包交易数量
+		QueuedSize   int                `json:"queuedSize"`       // 其中因 nonce 不连续暂不能打包的数量
+		LockedSize   int                `json:"lockedSize"`       // 其中锁定时间未到、下一个区块还不能打包的数量
+		PeerCount    int                `json:"peerCount"`        // 已连接邻居数
+		Peers        []string           `json:"peers"`            // 邻居列表
+		LatestHash   string             `json:"latestHash"`       // 最新区块哈希
+		LatestMerkle string             `json:"latestMerkle"`     // 最新区块 Merkle 根
+		LatestState  string             `json:"latestStateRoot"`  // 最新区块状态根
+		LatestVer    uint32             `json:"latestVersion"`    // 最新区块版本
+		LatestBits   string             `json:"latestBits"`       // 最新区块难度（compact，hex；PoA / PoS 中为出块权重）
+		Miner        core.MinerStats    `json:"miner"`            // 挖矿器统计：协程数、是否在挖矿、算力
+		TimeOffset   string             `json:"timeOffset"`       // 网络调整时间相对本地时钟的修正量
+		PeerOffsets  map[string]string  `json:"peerOffsets"`      // 握手时记录的各邻居主机的时钟偏差
+		SigCache     core.SigCacheStats `json:"sigCache"`         // 签名缓存：大小、命中与实际校验次数
 	}{
 		Port:         s.Port,
+		Network:      s.BC.Params.Name,
+		NetworkID:    s.BC.Params.NetworkID,
+		Ledger:       string(s.BC.Params.Ledger),
+		Consensus:    s.BC.Engine.Name(),
+		GasPrice:     s.BC.Params.GasPrice,
+		Signer:       engineSigner(s.BC.Engine),
 		Height:       height,
+		Finalized:    finalized,
+		FinalHash:    utils.ToHex(s.BC.Blocks[finalized].Header.Hash),
+		BFT:          bft,
 		BlockCount:   len(s.BC.Blocks),
 		MempoolSize:  mempoolSize,
+		QueuedSize:   s.Mempool.QueuedCount(s.BC.State),
+		LockedSize:   s.Mempool.LockedCount(s.BC.State, s.BC.MedianTimePast()),
 		PeerCount:    len(s.Peers),
 		Peers:        s.Peers,
 		LatestHash:   latestHash,
 		LatestMerkle: latestMerkle,
+		LatestState:  latestStateRoot,
+		LatestVer:    latestVersion,
+		LatestBits:   fmt.Sprintf("%08x", latestBits),
+		Miner:        s.Miner.Stats(),
+		TimeOffset:   s.BC.Clock.Offset().String(),
+		PeerOffsets:  peerOffsets,
+		SigCache:     s.BC.SigCache.Stats(),
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -433,8 +650,12 @@ func (s *P2PServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// /balance?addr=Alice  查询某个账户当前余额
+// /balance?addr=Alice  查询某个账户当前余额（原生币）以及持有的全部代币
+// /balance?addr=Alice&proof=1  同时返回最新区块头和账户的状态证明，钱包可对照区块头中的 StateRoot 验证
 func (s *P2PServer) handleBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	w.Header().Set("Content-Type", "application/json")
 
 	raw := r.URL.Query().Get("addr")
@@ -446,42 +667,149 @@ func (s *P2PServer) handleBalance(w http.ResponseWriter, r *http.Request) {
 
 	addr := ResolveAddress(raw) // ✅ 支持传昵称或地址
 
-	// 调用 core 层的 GetBalance
+	// 调用 core 层的 GetBalance；可花费余额不含尚未成熟的 coinbase
 	balance := s.BC.GetBalance(addr)
+	spendable := s.BC.SpendableBalance(addr)
 
 	// 找展示名
 	display := DisplayName(addr)
 
 	// 返回 JSON
 	resp := struct {
-		Input   string `json:"input"`   // 用户传进来的原始字符串
-		Address string `json:"address"` // 实际地址
-		Name    string `json:"name"`    // 昵称+缩写
-		Balance int64  `json:"balance"`
+		Input     string              `json:"input"`   // 用户传进来的原始字符串
+		Address   string              `json:"address"` // 实际地址
+		Name      string              `json:"name"`    // 昵称+缩写
+		Balance   int64               `json:"balance"`
+		Spendable int64               `json:"spendable"`        // 下一个区块中可以花费的余额
+		Assets    []core.TokenHolding `json:"assets"`           // 持有的全部代币
+		Header    *core.BlockHeader   `json:"header,omitempty"` // proof=1 时：证明对应的区块头（当前最新区块）
+		Proof     *core.AccountProof  `json:"proof,omitempty"`  // proof=1 时：账户状态证明
+	}{
+		Input:     raw,
+		Address:   addr,
+		Name:      display,
+		Balance:   balance,
+		Spendable: spendable,
+		Assets:    s.BC.State.TokenHoldings(addr),
+	}
+	if r.URL.Query().Get("proof") == "1" {
+		resp.Header = s.BC.LatestBlock().Header
+		resp.Proof = s.BC.State.ProveAccount(addr)
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// /nonce?addr=Alice  查询账户的 nonce：
+// nonce 为链上已确认的下一个 nonce，pendingNonce 还考虑了交易池中连续的待打包交易，
+// 钱包发送新交易时应使用 pendingNonce
+func (s *P2PServer) handleNonce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	raw := r.URL.Query().Get("addr")
+	if raw == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "missing addr parameter"}`))
+		return
+	}
+	addr := ResolveAddress(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := struct {
+		Address      string `json:"address"`
+		Nonce        uint64 `json:"nonce"`
+		PendingNonce uint64 `json:"pendingNonce"`
+	}{
+		Address:      addr,
+		Nonce:        s.BC.State.Nonce(addr),
+		PendingNonce: s.Mempool.PendingNonce(addr, s.BC.State),
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// /utxos?addr=Alice  查询地址可用的 UTXO（仅 UTXO 模式）：
+// 已被交易池中待打包交易花费的输出、尚未成熟的 coinbase 输出不会返回，钱包可以直接用结果选币
+func (s *P2PServer) handleUTXOs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	raw := r.URL.Query().Get("addr")
+	if raw == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "missing addr parameter"}`))
+		return
+	}
+	addr := ResolveAddress(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.BC.Params.Ledger != core.LedgerUTXO {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "node is not running in utxo mode"}`))
+		return
+	}
+
+	utxos := []core.UTXO{}
+	next := s.BC.NextHeight()
+	for _, u := range s.BC.State.UTXOsOf(addr) {
+		if !s.Mempool.IsSpent(core.OutPoint(u.TxHash, u.Index)) && s.BC.Params.Monetary.Mature(u, next) {
+			utxos = append(utxos, u)
+		}
+	}
+
+	resp := struct {
+		Address string      `json:"address"`
+		UTXOs   []core.UTXO `json:"utxos"`
 	}{
-		Input:   raw,
 		Address: addr,
-		Name:    display,
-		Balance: balance,
+		UTXOs:   utxos,
 	}
 
 	json.NewEncoder(w).Encode(resp)
 }
 
+// /supply[?height=N]  查询某个高度（默认最新）的发行量、销毁量和流通量
+func (s *P2PServer) handleSupply(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	height := s.BC.LatestBlock().Header.Height
+	if raw := r.URL.Query().Get("height"); raw != "" {
+		h, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid height parameter"}`))
+			return
+		}
+		height = h
+	}
+
+	supply, err := s.BC.Supply(height)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(supply)
+}
+
 // topBalances 返回余额前 n 名的账户（基于当前区块链状态）
 // 这里只做 demo，用 map 排序实现。
 func (s *P2PServer) topBalances(n int) []struct {
 	Addr    string
 	Balance int64
 } {
-	s.BC.RebuildBalances()
-
 	type item struct {
 		addr string
 		bal  int64
 	}
 	var items []item
-	for addr, bal := range s.BC.Balances {
+	for addr, bal := range s.BC.State.Balances {
 		if addr == "COINBASE" {
 			continue
 		}
@@ -515,17 +843,24 @@ func (s *P2PServer) topBalances(n int) []struct {
 
 // /dashboard：简单的 HTML 可视化页面
 func (s *P2PServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	height := len(s.BC.Blocks) - 1
-	mempoolSize := len(s.Mempool)
+	mempoolSize := s.Mempool.Len()
 	peerCount := len(s.Peers)
 
-	var latestHash, latestMerkle string
+	var latestHash, latestMerkle, latestStateRoot string
+	var latestVersion, latestBits uint32
 	if height >= 0 {
 		if last := s.BC.LatestBlock(); last != nil {
 			latestHash = utils.ToHex(last.Header.Hash)
 			latestMerkle = utils.ToHex(last.Header.MerkleRoot)
+			latestStateRoot = utils.ToHex(last.Header.StateRoot)
+			latestVersion = last.Header.Version
+			latestBits = last.Header.Bits
 		}
 	}
 
@@ -559,12 +894,14 @@ func (s *P2PServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		<p><span class="badge">已连接邻居</span> %d</p>
 		<p><span class="badge">最新区块 Hash</span> <code>%s</code></p>
 		<p><span class="badge">最新 Merkle Root</span> <code>%s</code></p>
+		<p><span class="badge">最新状态根</span> <code>%s</code></p>
+		<p><span class="badge">区块版本</span> %d <span class="badge">难度 Bits</span> <code>%08x</code></p>
 	</div>
 
 	<div class="card">
 		<h2>邻居节点</h2>
 		<table>
-			<tr><th>#</th><th>Peer URL</th></tr>`, html.EscapeString(s.Port), html.EscapeString(s.Port), height, len(s.BC.Blocks), mempoolSize, peerCount, html.EscapeString(latestHash), html.EscapeString(latestMerkle))
+			<tr><th>#</th><th>Peer URL</th></tr>`, html.EscapeString(s.Port), html.EscapeString(s.Port), height, len(s.BC.Blocks), mempoolSize, peerCount, html.EscapeString(latestHash), html.EscapeString(latestMerkle), html.EscapeString(latestStateRoot), latestVersion, latestBits)
 
 	// peers 表格
 	for i, p := range s.Peers {
@@ -0,0 +1,47 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"mychain/core"
+)
+
+// /headers：返回主链全部区块头，轻钱包据此校验交易证明，无需下载完整区块
+func (s *P2PServer) handleGetHeaders(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.BC.Headers())
+}
+
+// /proof?tx=<hex>：返回交易、所在区块头以及交易到 Merkle 根的路径
+func (s *P2PServer) handleProof(w http.ResponseWriter, r *http.Request) {
+	hash, err := hex.DecodeString(r.URL.Query().Get("tx"))
+	if err != nil || len(hash) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing or invalid tx parameter"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proof, err := s.BC.TxProof(hash)
+	if errors.Is(err, core.ErrTxNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proof)
+}
@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"mychain/core"
+	"mychain/utils"
+)
+
+// /validators：PoS 链的验证者、解锁中的质押、下一个区块的出块者以及罚没记录
+func (s *P2PServer) handleValidators(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.BC.Params.Consensus.Engine != core.EnginePoS {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, core.ErrStakingDisabled.Error())
+		return
+	}
+
+	type validator struct {
+		core.Validator
+		Name string `json:"name"`
+	}
+	type unbonding struct {
+		Address string `json:"address"`
+		Amount  uint64 `json:"amount"`
+	}
+
+	st := s.BC.State
+	validators := []validator{}
+	var total uint64
+	for _, v := range st.Validators(s.BC.Params) {
+		validators = append(validators, validator{v, DisplayName(v.Address)})
+		total += v.Stake
+	}
+	pending := []unbonding{}
+	for addr, amount := range st.Unbonding {
+		pending = append(pending, unbonding{addr, amount})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Address < pending[j].Address })
+
+	latest := s.BC.Blocks[len(s.BC.Blocks)-1].Header
+	next, err := core.SelectProposer(s.BC.Params, st, latest.Hash, latest.Height+1)
+	if err != nil {
+		next = ""
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Validators   []validator   `json:"validators"`
+		TotalStake   uint64        `json:"totalStake"`
+		MinStake     uint64        `json:"minStake"`
+		Unbonding    []unbonding   `json:"unbonding"`
+		NextProposer string        `json:"nextProposer"` // 下一个区块的出块者，没有验证者时为空
+		Slashes      []*core.Slash `json:"slashes"`
+	}{validators, total, s.BC.Params.Consensus.MinStake, pending, next, st.SlashList()})
+}
+
+// /evidence：区块树中发现的、尚未罚没的双签证据，evidence 字段（hex）可以直接作为罚没交易的 Payload
+func (s *P2PServer) handleEvidence(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type item struct {
+		Validator string `json:"validator"`
+		Height    uint64 `json:"height"`
+		HashA     string `json:"hashA"`
+		HashB     string `json:"hashB"`
+		Evidence  string `json:"evidence"`
+	}
+	items := []item{}
+	for _, ev := range s.BC.DoubleSigns() {
+		data, err := ev.MarshalBinary()
+		if err != nil {
+			continue
+		}
+		items = append(items, item{
+			Validator: utils.PubKeyToAddress(ev.A.Signer),
+			Height:    ev.A.Height,
+			HashA:     utils.ToHex(ev.A.Hash),
+			HashB:     utils.ToHex(ev.B.Hash),
+			Evidence:  utils.ToHex(data),
+		})
+	}
+	json.NewEncoder(w).Encode(items)
+}
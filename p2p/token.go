@@ -0,0 +1,53 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"mychain/core"
+)
+
+// /tokens：链上全部代币
+// /tokens?id=<资产 ID>：单个代币的信息与全部持有者
+func (s *P2PServer) handleTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		json.NewEncoder(w).Encode(s.BC.State.TokenList())
+		return
+	}
+
+	token := s.BC.State.Tokens[id]
+	if token == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": %q}`, core.ErrUnknownToken.Error())
+		return
+	}
+
+	type holder struct {
+		Address string `json:"address"`
+		Name    string `json:"name"`
+		Balance int64  `json:"balance"`
+	}
+	holders := []holder{}
+	for addr, bal := range s.BC.State.TokenBalances[id] {
+		holders = append(holders, holder{addr, DisplayName(addr), bal})
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		if holders[i].Balance != holders[j].Balance {
+			return holders[i].Balance > holders[j].Balance
+		}
+		return holders[i].Address < holders[j].Address
+	})
+
+	json.NewEncoder(w).Encode(struct {
+		*core.Token
+		Holders []holder `json:"holders"`
+	}{token, holders})
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 
@@ -29,8 +30,8 @@ func (fs *FileStorage) Save(bc *core.Blockchain) error {
 	return ioutil.WriteFile(fs.Path, data, 0644)
 }
 
-// Load 从文件中读取区块链
-func (fs *FileStorage) Load() (*core.Blockchain, error) {
+// Load 从文件中读取区块链，并按链参数 params 重新校验
+func (fs *FileStorage) Load(params *core.ChainParams) (*core.Blockchain, error) {
 	// 检查文件是否存在
 	_, err := os.Stat(fs.Path)
 	if err != nil {
@@ -45,15 +46,24 @@ func (fs *FileStorage) Load() (*core.Blockchain, error) {
 		return nil, err
 	}
 
-	var bc core.Blockchain
-	if err := json.Unmarshal(data, &bc); err != nil {
+	var saved struct {
+		Blocks []core.Block `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
 		return nil, err
 	}
 
 	// 如果文件里 blocks 是空的，视为错误
-	if len(bc.Blocks) == 0 {
+	if len(saved.Blocks) == 0 {
 		return nil, errors.New("loaded blockchain has no blocks")
 	}
 
-	return &bc, nil
+	// 本地文件同样要逐块经过完整的共识校验，防止被篡改的链文件被直接使用；
+	// 校验过程中同时重建区块树和账户状态
+	bc, err := core.NewBlockchainFromBlocks(saved.Blocks, params)
+	if err != nil {
+		return nil, fmt.Errorf("loaded blockchain is invalid: %w", err)
+	}
+
+	return bc, nil
 }